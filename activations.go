@@ -41,6 +41,22 @@ func SoftmaxMatrix(m *Matrix) *Matrix {
 	return result
 }
 
+// softmaxRows applies softmax to each row of a matrix of any element type,
+// computing the exponentials in float64
+func softmaxRows[T Float](m *MatrixOf[T]) *MatrixOf[T] {
+	result := NewMatrixOf[T](m.Rows, m.Cols)
+	row := make([]float64, m.Cols)
+	for i := 0; i < m.Rows; i++ {
+		for j, v := range m.Data[i] {
+			row[j] = float64(v)
+		}
+		for j, v := range Softmax(row) {
+			result.Data[i][j] = T(v)
+		}
+	}
+	return result
+}
+
 // ReLU applies the ReLU activation function
 func ReLU(x float64) float64 {
 	if x > 0 {
@@ -59,3 +75,8 @@ func ReLUMatrix(m *Matrix) *Matrix {
 	}
 	return result
 }
+
+// Sigmoid applies the logistic function 1 / (1 + e^-x)
+func Sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
@@ -0,0 +1,684 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// maskedScore replaces attention scores of masked positions. A large finite
+// value keeps fully masked rows well defined (uniform) instead of NaN.
+const maskedScore = -1e9
+
+// rowToSequence reshapes one (time*dim) row into a (time, dim) matrix
+func rowToSequence[T Float](row []T, steps, dim int) *MatrixOf[T] {
+	seq := NewMatrixOf[T](steps, dim)
+	for t := 0; t < steps; t++ {
+		copy(seq.Data[t], row[t*dim:(t+1)*dim])
+	}
+	return seq
+}
+
+// sequenceToRow flattens a (time, dim) matrix into dst
+func sequenceToRow[T Float](seq *MatrixOf[T], dst []T) {
+	for t := 0; t < seq.Rows; t++ {
+		copy(dst[t*seq.Cols:(t+1)*seq.Cols], seq.Data[t])
+	}
+}
+
+// addBias adds a (1, cols) bias to every row of m in place
+func addBias[T Float](m, bias *MatrixOf[T]) {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			m.Data[i][j] += bias.Data[0][j]
+		}
+	}
+}
+
+// addColumnSums adds the column sums of m to the (1, cols) matrix dst
+func addColumnSums[T Float](dst, m *MatrixOf[T]) {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			dst.Data[0][j] += m.Data[i][j]
+		}
+	}
+}
+
+// TimeDistributedOf applies a layer independently to every step of a
+// (batch, time*InputSize) input by reshaping it to (batch*time, InputSize)
+type TimeDistributedOf[T Float] struct {
+	Layer     LayerOf[T]
+	InputSize int
+
+	steps int
+}
+
+// TimeDistributed is a TimeDistributedOf wrapping a float64 layer
+type TimeDistributed = TimeDistributedOf[float64]
+
+// NewTimeDistributed wraps layer so it is applied to every time step
+func NewTimeDistributed(layer Layer, inputSize int) *TimeDistributed {
+	return NewTimeDistributedOf[float64](layer, inputSize)
+}
+
+// NewTimeDistributedOf wraps a layer with element type T so it is applied
+// to every time step
+func NewTimeDistributedOf[T Float](layer LayerOf[T], inputSize int) *TimeDistributedOf[T] {
+	return &TimeDistributedOf[T]{Layer: layer, InputSize: inputSize}
+}
+
+// SetTraining forwards the mode to the wrapped layer
+func (td *TimeDistributedOf[T]) SetTraining(training bool) {
+	if t, ok := td.Layer.(TrainingSetter); ok {
+		t.SetTraining(training)
+	}
+}
+
+// SetSeed forwards the seed to the wrapped layer
+func (td *TimeDistributedOf[T]) SetSeed(seed int64) {
+	if s, ok := td.Layer.(Seeder); ok {
+		s.SetSeed(seed)
+	}
+}
+
+// Forward applies the wrapped layer to every step
+func (td *TimeDistributedOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if td.InputSize <= 0 || input.Cols%td.InputSize != 0 {
+		return nil, fmt.Errorf("input size %d is not a multiple of %d", input.Cols, td.InputSize)
+	}
+	td.steps = input.Cols / td.InputSize
+
+	out, err := td.Layer.Forward(td.split(input, td.InputSize))
+	if err != nil {
+		return nil, err
+	}
+	return td.merge(out, input.Rows), nil
+}
+
+// Backward applies the wrapped layer's backward pass to every step
+func (td *TimeDistributedOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if td.steps == 0 || gradOutput.Cols%td.steps != 0 {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	grad, err := td.Layer.Backward(td.split(gradOutput, gradOutput.Cols/td.steps))
+	if err != nil {
+		return nil, err
+	}
+	return td.merge(grad, gradOutput.Rows), nil
+}
+
+// split reshapes (batch, time*size) into (batch*time, size)
+func (td *TimeDistributedOf[T]) split(m *MatrixOf[T], size int) *MatrixOf[T] {
+	result := NewMatrixOf[T](m.Rows*td.steps, size)
+	for i := 0; i < m.Rows; i++ {
+		for t := 0; t < td.steps; t++ {
+			copy(result.Data[i*td.steps+t], m.Data[i][t*size:(t+1)*size])
+		}
+	}
+	return result
+}
+
+// merge reshapes (batch*time, size) back into (batch, time*size)
+func (td *TimeDistributedOf[T]) merge(m *MatrixOf[T], batch int) *MatrixOf[T] {
+	result := NewMatrixOf[T](batch, td.steps*m.Cols)
+	for i := 0; i < batch; i++ {
+		for t := 0; t < td.steps; t++ {
+			copy(result.Data[i][t*m.Cols:(t+1)*m.Cols], m.Data[i*td.steps+t])
+		}
+	}
+	return result
+}
+
+// GetParams returns the parameters of the wrapped layer
+func (td *TimeDistributedOf[T]) GetParams() []*MatrixOf[T] {
+	return td.Layer.GetParams()
+}
+
+// GetGrads returns the gradients of the wrapped layer
+func (td *TimeDistributedOf[T]) GetGrads() []*MatrixOf[T] {
+	return td.Layer.GetGrads()
+}
+
+// GetParamNames returns the parameter names of the wrapped layer
+func (td *TimeDistributedOf[T]) GetParamNames() []string {
+	return td.Layer.GetParamNames()
+}
+
+// MultiHeadAttentionOf is scaled dot-product self-attention over inputs of
+// shape (batch, time*ModelDim). Each head attends with ModelDim/NumHeads
+// dimensions; the heads are concatenated and projected back to ModelDim.
+type MultiHeadAttentionOf[T Float] struct {
+	ModelDim int
+	NumHeads int
+	Causal   bool // Forbid attending to later steps
+
+	QueryKernel, QueryBias   *MatrixOf[T] // Shapes: (ModelDim, ModelDim), (1, ModelDim)
+	KeyKernel, KeyBias       *MatrixOf[T]
+	ValueKernel, ValueBias   *MatrixOf[T]
+	OutputKernel, OutputBias *MatrixOf[T]
+
+	mask  *MatrixOf[T]
+	grads []*MatrixOf[T]
+
+	// Cache for backward pass
+	steps   int
+	samples []*attentionCache[T]
+}
+
+// MultiHeadAttention is a MultiHeadAttentionOf with float64 values
+type MultiHeadAttention = MultiHeadAttentionOf[float64]
+
+// attentionCache holds the values of one sample
+type attentionCache[T Float] struct {
+	x, q, k, v, concat *MatrixOf[T]
+	weights            []*MatrixOf[T] // Softmax attention weights per head, (time, time)
+}
+
+// NewMultiHeadAttention creates a new multi-head self-attention layer with
+// GlorotUniform projection kernels and zero biases, unless opts select
+// other initializers. ModelDim must be divisible by numHeads.
+func NewMultiHeadAttention(modelDim, numHeads int, causal bool, opts ...InitOption) *MultiHeadAttention {
+	return NewMultiHeadAttentionOf[float64](modelDim, numHeads, causal, opts...)
+}
+
+// NewMultiHeadAttentionOf creates a new multi-head self-attention layer
+// with element type T
+func NewMultiHeadAttentionOf[T Float](modelDim, numHeads int, causal bool, opts ...InitOption) *MultiHeadAttentionOf[T] {
+	kernelInit, biasInit := initializers(opts, GlorotUniform{}, Zeros{})
+	kernel := func() *MatrixOf[T] {
+		return initializedMatrixOf[T](kernelInit, modelDim, modelDim, modelDim, modelDim)
+	}
+	bias := func() *MatrixOf[T] {
+		return initializedMatrixOf[T](biasInit, 1, modelDim, modelDim, modelDim)
+	}
+	mha := &MultiHeadAttentionOf[T]{
+		ModelDim:     modelDim,
+		NumHeads:     numHeads,
+		Causal:       causal,
+		QueryKernel:  kernel(),
+		QueryBias:    bias(),
+		KeyKernel:    kernel(),
+		KeyBias:      bias(),
+		ValueKernel:  kernel(),
+		ValueBias:    bias(),
+		OutputKernel: kernel(),
+		OutputBias:   bias(),
+	}
+	for _, p := range mha.GetParams() {
+		mha.grads = append(mha.grads, NewMatrixOf[T](p.Rows, p.Cols))
+	}
+	return mha
+}
+
+// SetMask sets a (batch, time) padding mask for the following passes: keys
+// at steps with a 0 are not attended to. A nil mask disables it.
+func (mha *MultiHeadAttentionOf[T]) SetMask(mask *MatrixOf[T]) {
+	mha.mask = mask
+}
+
+// Forward computes softmax(QKᵀ/sqrt(d))V for every head of every sample
+func (mha *MultiHeadAttentionOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if mha.NumHeads <= 0 || mha.ModelDim%mha.NumHeads != 0 {
+		return nil, fmt.Errorf("model dimension %d is not divisible by %d heads", mha.ModelDim, mha.NumHeads)
+	}
+	if input.Cols%mha.ModelDim != 0 || input.Cols == 0 {
+		return nil, fmt.Errorf("input size %d is not a positive multiple of %d", input.Cols, mha.ModelDim)
+	}
+	steps := input.Cols / mha.ModelDim
+	if mha.mask != nil && (mha.mask.Rows != input.Rows || mha.mask.Cols != steps) {
+		return nil, fmt.Errorf("mask shape mismatch: got %dx%d, expected %dx%d",
+			mha.mask.Rows, mha.mask.Cols, input.Rows, steps)
+	}
+
+	headDim := mha.ModelDim / mha.NumHeads
+	scale := 1 / math.Sqrt(float64(headDim))
+
+	mha.steps = steps
+	mha.samples = make([]*attentionCache[T], input.Rows)
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+
+	for b := 0; b < input.Rows; b++ {
+		c := &attentionCache[T]{x: rowToSequence(input.Data[b], steps, mha.ModelDim)}
+		c.q = mha.project(c.x, mha.QueryKernel, mha.QueryBias)
+		c.k = mha.project(c.x, mha.KeyKernel, mha.KeyBias)
+		c.v = mha.project(c.x, mha.ValueKernel, mha.ValueBias)
+		c.concat = NewMatrixOf[T](steps, mha.ModelDim)
+
+		for h := 0; h < mha.NumHeads; h++ {
+			start, end := h*headDim, (h+1)*headDim
+			scores, _ := columns(c.q, start, end).Multiply(columns(c.k, start, end).Transpose())
+			for i := 0; i < steps; i++ {
+				for j := 0; j < steps; j++ {
+					scores.Data[i][j] *= T(scale)
+					if (mha.Causal && j > i) || (mha.mask != nil && mha.mask.Data[b][j] == 0) {
+						scores.Data[i][j] = maskedScore
+					}
+				}
+			}
+
+			weights := softmaxRows(scores)
+			c.weights = append(c.weights, weights)
+			head, _ := weights.Multiply(columns(c.v, start, end))
+			setColumns(c.concat, start, head)
+		}
+
+		out := mha.project(c.concat, mha.OutputKernel, mha.OutputBias)
+		sequenceToRow(out, output.Data[b])
+		mha.samples[b] = c
+	}
+
+	return output, nil
+}
+
+// project computes x @ kernel + bias
+func (mha *MultiHeadAttentionOf[T]) project(x, kernel, bias *MatrixOf[T]) *MatrixOf[T] {
+	out, _ := x.Multiply(kernel)
+	addBias(out, bias)
+	return out
+}
+
+// Backward computes gradients of all projections and the input
+func (mha *MultiHeadAttentionOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gradOutput.Rows != len(mha.samples) || gradOutput.Cols != mha.steps*mha.ModelDim {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	for _, g := range mha.grads {
+		g.zero()
+	}
+	queryKernelGrad, queryBiasGrad := mha.grads[0], mha.grads[1]
+	keyKernelGrad, keyBiasGrad := mha.grads[2], mha.grads[3]
+	valueKernelGrad, valueBiasGrad := mha.grads[4], mha.grads[5]
+	outputKernelGrad, outputBiasGrad := mha.grads[6], mha.grads[7]
+
+	headDim := mha.ModelDim / mha.NumHeads
+	scale := 1 / math.Sqrt(float64(headDim))
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
+
+	for b, c := range mha.samples {
+		gradOut := rowToSequence(gradOutput.Data[b], mha.steps, mha.ModelDim)
+
+		// Output projection
+		gemm(outputKernelGrad, c.concat, gradOut, true, false)
+		addColumnSums(outputBiasGrad, gradOut)
+		gradConcat := NewMatrixOf[T](mha.steps, mha.ModelDim)
+		gemm(gradConcat, gradOut, mha.OutputKernel, false, true)
+
+		gradQ := NewMatrixOf[T](mha.steps, mha.ModelDim)
+		gradK := NewMatrixOf[T](mha.steps, mha.ModelDim)
+		gradV := NewMatrixOf[T](mha.steps, mha.ModelDim)
+		for h := 0; h < mha.NumHeads; h++ {
+			start, end := h*headDim, (h+1)*headDim
+			weights := c.weights[h]
+			gradHead := columns(gradConcat, start, end)
+
+			// head = weights @ v
+			gradWeights := NewMatrixOf[T](mha.steps, mha.steps)
+			gemm(gradWeights, gradHead, columns(c.v, start, end), false, true)
+			gradVh := NewMatrixOf[T](mha.steps, headDim)
+			gemm(gradVh, weights, gradHead, true, false)
+
+			// Softmax backward per query row; masked scores are constants
+			gradScores := NewMatrixOf[T](mha.steps, mha.steps)
+			for i := 0; i < mha.steps; i++ {
+				var dot T
+				for j := 0; j < mha.steps; j++ {
+					dot += gradWeights.Data[i][j] * weights.Data[i][j]
+				}
+				for j := 0; j < mha.steps; j++ {
+					if (mha.Causal && j > i) || (mha.mask != nil && mha.mask.Data[b][j] == 0) {
+						continue
+					}
+					gradScores.Data[i][j] = weights.Data[i][j] * (gradWeights.Data[i][j] - dot) * T(scale)
+				}
+			}
+
+			// scores = q @ kᵀ
+			gradQh := NewMatrixOf[T](mha.steps, headDim)
+			gemm(gradQh, gradScores, columns(c.k, start, end), false, false)
+			gradKh := NewMatrixOf[T](mha.steps, headDim)
+			gemm(gradKh, gradScores, columns(c.q, start, end), true, false)
+
+			setColumns(gradQ, start, gradQh)
+			setColumns(gradK, start, gradKh)
+			setColumns(gradV, start, gradVh)
+		}
+
+		// Input projections
+		gradX := NewMatrixOf[T](mha.steps, mha.ModelDim)
+		for _, p := range []struct{ grad, kernel, kernelGrad, biasGrad *MatrixOf[T] }{
+			{gradQ, mha.QueryKernel, queryKernelGrad, queryBiasGrad},
+			{gradK, mha.KeyKernel, keyKernelGrad, keyBiasGrad},
+			{gradV, mha.ValueKernel, valueKernelGrad, valueBiasGrad},
+		} {
+			gemm(p.kernelGrad, c.x, p.grad, true, false)
+			addColumnSums(p.biasGrad, p.grad)
+			gemm(gradX, p.grad, p.kernel, false, true)
+		}
+		sequenceToRow(gradX, gradInput.Data[b])
+	}
+
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (mha *MultiHeadAttentionOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{
+		mha.QueryKernel, mha.QueryBias,
+		mha.KeyKernel, mha.KeyBias,
+		mha.ValueKernel, mha.ValueBias,
+		mha.OutputKernel, mha.OutputBias,
+	}
+}
+
+// GetGrads returns the gradients of the parameters
+func (mha *MultiHeadAttentionOf[T]) GetGrads() []*MatrixOf[T] {
+	return mha.grads
+}
+
+// GetParamNames returns names for the parameters
+func (mha *MultiHeadAttentionOf[T]) GetParamNames() []string {
+	return []string{
+		"query_kernel", "query_bias",
+		"key_kernel", "key_bias",
+		"value_kernel", "value_bias",
+		"output_kernel", "output_bias",
+	}
+}
+
+// PositionalEncodingOf adds a position-dependent vector to every step of a
+// (batch, time*ModelDim) input. The table is either the fixed sinusoidal
+// encoding or a learned parameter.
+type PositionalEncodingOf[T Float] struct {
+	MaxLen   int
+	ModelDim int
+	Learned  bool
+	Table    *MatrixOf[T] // Shape: (MaxLen, ModelDim)
+
+	tableGrad *MatrixOf[T]
+}
+
+// PositionalEncoding is a PositionalEncodingOf with float64 values
+type PositionalEncoding = PositionalEncodingOf[float64]
+
+// NewSinusoidalPositionalEncoding creates a fixed encoding with
+// PE(t, 2i) = sin(t/10000^(2i/d)) and PE(t, 2i+1) = cos(t/10000^(2i/d))
+func NewSinusoidalPositionalEncoding(maxLen, modelDim int) *PositionalEncoding {
+	return NewSinusoidalPositionalEncodingOf[float64](maxLen, modelDim)
+}
+
+// NewSinusoidalPositionalEncodingOf creates a fixed encoding with element
+// type T, computed in float64 and rounded
+func NewSinusoidalPositionalEncodingOf[T Float](maxLen, modelDim int) *PositionalEncodingOf[T] {
+	table := NewMatrixOf[T](maxLen, modelDim)
+	for t := 0; t < maxLen; t++ {
+		for j := 0; j < modelDim; j++ {
+			angle := float64(t) / math.Pow(10000, float64(j/2*2)/float64(modelDim))
+			if j%2 == 0 {
+				table.Data[t][j] = T(math.Sin(angle))
+			} else {
+				table.Data[t][j] = T(math.Cos(angle))
+			}
+		}
+	}
+	return &PositionalEncodingOf[T]{MaxLen: maxLen, ModelDim: modelDim, Table: table}
+}
+
+// NewLearnedPositionalEncoding creates a trainable encoding table drawn
+// from Uniform(-0.05, 0.05), unless WithWeights selects another initializer
+func NewLearnedPositionalEncoding(maxLen, modelDim int, opts ...InitOption) *PositionalEncoding {
+	return NewLearnedPositionalEncodingOf[float64](maxLen, modelDim, opts...)
+}
+
+// NewLearnedPositionalEncodingOf creates a trainable encoding table with
+// element type T
+func NewLearnedPositionalEncodingOf[T Float](maxLen, modelDim int, opts ...InitOption) *PositionalEncodingOf[T] {
+	tableInit, _ := initializers(opts, Uniform{Min: -0.05, Max: 0.05}, nil)
+	return &PositionalEncodingOf[T]{
+		MaxLen:    maxLen,
+		ModelDim:  modelDim,
+		Learned:   true,
+		Table:     initializedMatrixOf[T](tableInit, maxLen, modelDim, maxLen, modelDim),
+		tableGrad: NewMatrixOf[T](maxLen, modelDim),
+	}
+}
+
+// Forward adds the encoding of every step
+func (pe *PositionalEncodingOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if input.Cols%pe.ModelDim != 0 {
+		return nil, fmt.Errorf("input size %d is not a multiple of %d", input.Cols, pe.ModelDim)
+	}
+	steps := input.Cols / pe.ModelDim
+	if steps > pe.MaxLen {
+		return nil, fmt.Errorf("sequence length %d exceeds maximum %d", steps, pe.MaxLen)
+	}
+
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+	for i := 0; i < input.Rows; i++ {
+		for t := 0; t < steps; t++ {
+			for j := 0; j < pe.ModelDim; j++ {
+				output.Data[i][t*pe.ModelDim+j] = input.Data[i][t*pe.ModelDim+j] + pe.Table.Data[t][j]
+			}
+		}
+	}
+	return output, nil
+}
+
+// Backward passes the gradient through and accumulates the table gradient
+// of a learned encoding
+func (pe *PositionalEncodingOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if pe.Learned {
+		pe.tableGrad.zero()
+		steps := gradOutput.Cols / pe.ModelDim
+		for i := 0; i < gradOutput.Rows; i++ {
+			for t := 0; t < steps; t++ {
+				for j := 0; j < pe.ModelDim; j++ {
+					pe.tableGrad.Data[t][j] += gradOutput.Data[i][t*pe.ModelDim+j]
+				}
+			}
+		}
+	}
+	return gradOutput, nil
+}
+
+// GetParams returns the table of a learned encoding
+func (pe *PositionalEncodingOf[T]) GetParams() []*MatrixOf[T] {
+	if !pe.Learned {
+		return []*MatrixOf[T]{}
+	}
+	return []*MatrixOf[T]{pe.Table}
+}
+
+// GetGrads returns the table gradient of a learned encoding
+func (pe *PositionalEncodingOf[T]) GetGrads() []*MatrixOf[T] {
+	if !pe.Learned {
+		return []*MatrixOf[T]{}
+	}
+	return []*MatrixOf[T]{pe.tableGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (pe *PositionalEncodingOf[T]) GetParamNames() []string {
+	if !pe.Learned {
+		return []string{}
+	}
+	return []string{"table"}
+}
+
+// TransformerEncoderLayerOf is a post-norm transformer block over inputs of
+// shape (batch, time*ModelDim):
+// x1 = LayerNorm(x + Dropout(Attention(x)))
+// y  = LayerNorm(x1 + Dropout(Dense(ReLU(Dense(x1)))))
+type TransformerEncoderLayerOf[T Float] struct {
+	Attention    *MultiHeadAttentionOf[T]
+	Norm1        *LayerNormOf[T]
+	Norm2        *LayerNormOf[T]
+	FeedForward1 *TimeDistributedOf[T]
+	FeedForward2 *TimeDistributedOf[T]
+	Activation   *ReLULayerOf[T]
+	Dropout1     *DropoutOf[T]
+	Dropout2     *DropoutOf[T]
+}
+
+// TransformerEncoderLayer is a TransformerEncoderLayerOf with float64 values
+type TransformerEncoderLayer = TransformerEncoderLayerOf[float64]
+
+// NewTransformerEncoderLayer creates a new encoder block with a feed-forward
+// hidden size of ffDim and the given dropout rate. opts select the
+// initializers of the attention and feed-forward layers; the layer norms
+// keep theirs.
+func NewTransformerEncoderLayer(modelDim, numHeads, ffDim int, dropout float64, opts ...InitOption) *TransformerEncoderLayer {
+	return NewTransformerEncoderLayerOf[float64](modelDim, numHeads, ffDim, dropout, opts...)
+}
+
+// NewTransformerEncoderLayerOf creates a new encoder block with element
+// type T
+func NewTransformerEncoderLayerOf[T Float](modelDim, numHeads, ffDim int, dropout float64, opts ...InitOption) *TransformerEncoderLayerOf[T] {
+	return &TransformerEncoderLayerOf[T]{
+		Attention:    NewMultiHeadAttentionOf[T](modelDim, numHeads, false, opts...),
+		Norm1:        NewLayerNormOf[T](modelDim),
+		Norm2:        NewLayerNormOf[T](modelDim),
+		FeedForward1: NewTimeDistributedOf[T](NewDenseOf[T](modelDim, ffDim, opts...), modelDim),
+		FeedForward2: NewTimeDistributedOf[T](NewDenseOf[T](ffDim, modelDim, opts...), ffDim),
+		Activation:   NewReLULayerOf[T](),
+		Dropout1:     NewDropoutOf[T](dropout),
+		Dropout2:     NewDropoutOf[T](dropout),
+	}
+}
+
+// SetMask sets the padding mask of the attention sublayer
+func (enc *TransformerEncoderLayerOf[T]) SetMask(mask *MatrixOf[T]) {
+	enc.Attention.SetMask(mask)
+}
+
+// SetTraining forwards the mode to the dropout sublayers
+func (enc *TransformerEncoderLayerOf[T]) SetTraining(training bool) {
+	enc.Dropout1.SetTraining(training)
+	enc.Dropout2.SetTraining(training)
+}
+
+// SetSeed seeds the dropout sublayers; a non-zero seed gives them distinct
+// sources
+func (enc *TransformerEncoderLayerOf[T]) SetSeed(seed int64) {
+	enc.Dropout1.SetSeed(seed)
+	if seed != 0 {
+		seed++
+	}
+	enc.Dropout2.SetSeed(seed)
+}
+
+// Forward runs the attention and feed-forward sublayers with residuals
+func (enc *TransformerEncoderLayerOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	attended, err := chainForward(input, enc.Attention, enc.Dropout1)
+	if err != nil {
+		return nil, err
+	}
+	residual, err := input.Add(attended)
+	if err != nil {
+		return nil, err
+	}
+	x1, err := enc.Norm1.Forward(residual)
+	if err != nil {
+		return nil, err
+	}
+
+	fed, err := chainForward(x1, enc.FeedForward1, enc.Activation, enc.FeedForward2, enc.Dropout2)
+	if err != nil {
+		return nil, err
+	}
+	residual, err = x1.Add(fed)
+	if err != nil {
+		return nil, err
+	}
+	return enc.Norm2.Forward(residual)
+}
+
+// Backward propagates through both sublayers and their residual paths
+func (enc *TransformerEncoderLayerOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	gradResidual, err := enc.Norm2.Backward(gradOutput)
+	if err != nil {
+		return nil, err
+	}
+	gradFed, err := chainBackward(gradResidual, enc.FeedForward1, enc.Activation, enc.FeedForward2, enc.Dropout2)
+	if err != nil {
+		return nil, err
+	}
+	gradX1, err := gradResidual.Add(gradFed)
+	if err != nil {
+		return nil, err
+	}
+
+	gradResidual, err = enc.Norm1.Backward(gradX1)
+	if err != nil {
+		return nil, err
+	}
+	gradAttended, err := chainBackward(gradResidual, enc.Attention, enc.Dropout1)
+	if err != nil {
+		return nil, err
+	}
+	return gradResidual.Add(gradAttended)
+}
+
+// sublayers lists the parameterized sublayers with their name prefixes
+func (enc *TransformerEncoderLayerOf[T]) sublayers() ([]LayerOf[T], []string) {
+	return []LayerOf[T]{enc.Attention, enc.Norm1, enc.FeedForward1, enc.FeedForward2, enc.Norm2},
+		[]string{"attention_", "norm1_", "ffn1_", "ffn2_", "norm2_"}
+}
+
+// GetParams returns the parameters of all sublayers
+func (enc *TransformerEncoderLayerOf[T]) GetParams() []*MatrixOf[T] {
+	var params []*MatrixOf[T]
+	layers, _ := enc.sublayers()
+	for _, l := range layers {
+		params = append(params, l.GetParams()...)
+	}
+	return params
+}
+
+// GetGrads returns the gradients of all sublayers
+func (enc *TransformerEncoderLayerOf[T]) GetGrads() []*MatrixOf[T] {
+	var grads []*MatrixOf[T]
+	layers, _ := enc.sublayers()
+	for _, l := range layers {
+		grads = append(grads, l.GetGrads()...)
+	}
+	return grads
+}
+
+// GetParamNames returns the sublayer parameter names with their prefixes
+func (enc *TransformerEncoderLayerOf[T]) GetParamNames() []string {
+	var names []string
+	layers, prefixes := enc.sublayers()
+	for i, l := range layers {
+		for _, name := range l.GetParamNames() {
+			names = append(names, prefixes[i]+name)
+		}
+	}
+	return names
+}
+
+// chainForward runs layers in order
+func chainForward[T Float](input *MatrixOf[T], layers ...LayerOf[T]) (*MatrixOf[T], error) {
+	output := input
+	for _, l := range layers {
+		var err error
+		output, err = l.Forward(output)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// chainBackward runs the backward passes of layers in reverse order
+func chainBackward[T Float](gradOutput *MatrixOf[T], layers ...LayerOf[T]) (*MatrixOf[T], error) {
+	grad := gradOutput
+	for i := len(layers) - 1; i >= 0; i-- {
+		var err error
+		grad, err = layers[i].Backward(grad)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return grad, nil
+}
@@ -0,0 +1,192 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// identityAttention returns single-head attention whose projections are the
+// identity, so Q = K = V = x
+func identityAttention(dim int, causal bool) *MultiHeadAttention {
+	mha := NewMultiHeadAttention(dim, 1, causal)
+	for _, k := range []*Matrix{mha.QueryKernel, mha.KeyKernel, mha.ValueKernel, mha.OutputKernel} {
+		for i := range k.Data {
+			for j := range k.Data[i] {
+				k.Data[i][j] = 0
+			}
+			k.Data[i][i] = 1
+		}
+	}
+	return mha
+}
+
+func TestMultiHeadAttentionValues(t *testing.T) {
+	// Two steps x0 = (1, 0) and x1 = (0, 1): the scores are 1/sqrt(2) on the
+	// diagonal and 0 elsewhere
+	input := &Matrix{Rows: 1, Cols: 4, Data: [][]float64{{1, 0, 0, 1}}}
+	e := math.Exp(1 / math.Sqrt2)
+	self, other := e/(e+1), 1/(e+1)
+
+	tests := []struct {
+		name   string
+		causal bool
+		mask   *Matrix
+		want   []float64
+	}{
+		{"full", false, nil, []float64{self, other, other, self}},
+		{"causal", true, nil, []float64{1, 0, other, self}},
+		{"masked", false, &Matrix{Rows: 1, Cols: 2, Data: [][]float64{{1, 0}}}, []float64{1, 0, 1, 0}},
+	}
+	for _, tt := range tests {
+		mha := identityAttention(2, tt.causal)
+		mha.SetMask(tt.mask)
+		out, err := mha.Forward(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertMatrix(t, tt.name, out, [][]float64{tt.want}, 1e-12)
+	}
+}
+
+func TestMultiHeadAttentionErrors(t *testing.T) {
+	if _, err := NewMultiHeadAttention(4, 3, false).Forward(NewMatrix(1, 8)); err == nil {
+		t.Error("expected an error for 4 dimensions in 3 heads")
+	}
+	if _, err := NewMultiHeadAttention(4, 2, false).Forward(NewMatrix(1, 6)); err == nil {
+		t.Error("expected an error for an input of 6 columns")
+	}
+	mha := NewMultiHeadAttention(4, 2, false)
+	mha.SetMask(NewMatrix(1, 3))
+	if _, err := mha.Forward(NewMatrix(1, 8)); err == nil {
+		t.Error("expected an error for a mask of 3 steps on 2")
+	}
+}
+
+func TestMaskedAttentionGradients(t *testing.T) {
+	mask := &Matrix{Rows: 2, Cols: 3, Data: [][]float64{{1, 1, 0}, {1, 1, 1}}}
+	layers := map[string]interface {
+		Layer
+		SetMask(*Matrix)
+	}{
+		"attention":        NewMultiHeadAttention(4, 2, false),
+		"causal_attention": NewMultiHeadAttention(4, 2, true),
+		"encoder":          NewTransformerEncoderLayer(4, 2, 6, 0.1),
+	}
+	for name, layer := range layers {
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(16))
+			randomizeParams(rng, layer)
+			layer.SetMask(mask)
+			result, err := GradCheck(layer, uniformMatrix(rng, 2, 3*4, -1, 1), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if worst := result.Max(); worst > layerTolerance {
+				t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+			}
+		})
+	}
+}
+
+func TestTransformerEncoderTraining(t *testing.T) {
+	rng := rand.New(rand.NewSource(17))
+	enc := NewTransformerEncoderLayer(4, 2, 6, 0.3)
+	randomizeParams(rng, enc)
+	enc.SetTraining(true)
+	input := uniformMatrix(rng, 2, 3*4, -1, 1)
+
+	// Seeded dropout makes training-mode passes repeatable and checkable
+	result, err := GradCheck(enc, input, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worst := result.Max(); worst > layerTolerance {
+		t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+	}
+
+	enc.SetSeed(5)
+	a, err := enc.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.SetSeed(5)
+	b, err := enc.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matricesEqual(a, b) {
+		t.Error("passes with the same seed differ")
+	}
+	enc.SetTraining(false)
+	c, err := enc.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matricesEqual(a, c) {
+		t.Error("dropout had no effect in training mode")
+	}
+}
+
+func TestTransformerEncoderNormalizesSteps(t *testing.T) {
+	rng := rand.New(rand.NewSource(18))
+	enc := NewTransformerEncoderLayer(4, 2, 8, 0)
+	out, err := enc.Forward(uniformMatrix(rng, 2, 5*4, -3, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The final LayerNorm has gamma 1 and beta 0, so every step of the
+	// output has zero mean and unit variance
+	for i := range out.Data {
+		for s := 0; s < 5; s++ {
+			mean, variance := meanVar(out.Data[i][s*4 : (s+1)*4])
+			if math.Abs(mean) > 1e-9 || math.Abs(variance-1) > 1e-3 {
+				t.Errorf("sample %d step %d: mean %v, variance %v", i, s, mean, variance)
+			}
+		}
+	}
+}
+
+func TestPositionalEncoding(t *testing.T) {
+	pe := NewSinusoidalPositionalEncoding(3, 4)
+	out, err := pe.Forward(NewMatrix(1, 2*4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PE(t, 2i) = sin(t / 10000^(2i/4)), PE(t, 2i+1) = cos(...)
+	want := []float64{0, 1, 0, 1, math.Sin(1), math.Cos(1), math.Sin(0.01), math.Cos(0.01)}
+	assertMatrix(t, "encoding", out, [][]float64{want}, 1e-12)
+
+	if _, err := pe.Forward(NewMatrix(1, 4*4)); err == nil {
+		t.Error("expected an error for 4 steps with a maximum of 3")
+	}
+	if len(pe.GetParams()) != 0 {
+		t.Error("sinusoidal encoding has parameters")
+	}
+	if names := NewLearnedPositionalEncoding(3, 4).GetParamNames(); fmt.Sprint(names) != "[table]" {
+		t.Errorf("learned encoding parameters %v, expected [table]", names)
+	}
+}
+
+func TestTimeDistributed(t *testing.T) {
+	rng := rand.New(rand.NewSource(19))
+	dense := NewDense(3, 2)
+	randomizeParams(rng, dense)
+	td := NewTimeDistributed(dense, 3)
+
+	input := uniformMatrix(rng, 2, 4*3, -1, 1)
+	out, err := td.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range input.Data {
+		for s := 0; s < 4; s++ {
+			step, err := dense.Forward(&Matrix{Rows: 1, Cols: 3, Data: [][]float64{input.Data[i][s*3 : (s+1)*3]}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertMatrix(t, "step", &Matrix{Rows: 1, Cols: 2, Data: [][]float64{out.Data[i][s*2 : (s+1)*2]}}, step.Data, 1e-12)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Augmentation transforms one image sample, drawing its randomness from
+// rng. It returns a new tensor and leaves its input unchanged.
+type Augmentation interface {
+	Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error)
+}
+
+// copyTensor returns a deep copy of t
+func copyTensor(t *Tensor3D) *Tensor3D {
+	result := NewTensor3D(t.Channels, t.Height, t.Width)
+	for c := range t.Data {
+		for i := range t.Data[c] {
+			copy(result.Data[c][i], t.Data[c][i])
+		}
+	}
+	return result
+}
+
+// Compose applies augmentations one after the other
+type Compose struct {
+	Steps []Augmentation
+}
+
+// NewCompose creates an augmentation applying steps in order
+func NewCompose(steps ...Augmentation) *Compose {
+	return &Compose{Steps: steps}
+}
+
+// Apply runs every step on the output of the previous one
+func (c *Compose) Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error) {
+	for i, step := range c.Steps {
+		var err error
+		if t, err = step.Apply(t, rng); err != nil {
+			return nil, fmt.Errorf("augmentation %d: %v", i, err)
+		}
+	}
+	return t, nil
+}
+
+// RandomCrop pads the image with zeros on every side and crops a random
+// Height x Width window
+type RandomCrop struct {
+	Height  int
+	Width   int
+	Padding int
+}
+
+// NewRandomCrop creates a random crop of the given size after padding
+func NewRandomCrop(height, width, padding int) *RandomCrop {
+	return &RandomCrop{Height: height, Width: width, Padding: padding}
+}
+
+// Apply crops a random window of the padded image
+func (rc *RandomCrop) Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error) {
+	paddedH, paddedW := t.Height+2*rc.Padding, t.Width+2*rc.Padding
+	if rc.Height > paddedH || rc.Width > paddedW {
+		return nil, fmt.Errorf("crop %dx%d larger than padded image %dx%d", rc.Height, rc.Width, paddedH, paddedW)
+	}
+
+	top := rng.Intn(paddedH-rc.Height+1) - rc.Padding
+	left := rng.Intn(paddedW-rc.Width+1) - rc.Padding
+	result := NewTensor3D(t.Channels, rc.Height, rc.Width)
+	for c := 0; c < t.Channels; c++ {
+		for i := 0; i < rc.Height; i++ {
+			y := top + i
+			if y < 0 || y >= t.Height {
+				continue
+			}
+			for j := 0; j < rc.Width; j++ {
+				if x := left + j; x >= 0 && x < t.Width {
+					result.Data[c][i][j] = t.Data[c][y][x]
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// RandomFlip mirrors the image left-right (Horizontal) or top-bottom with
+// the given probability
+type RandomFlip struct {
+	Horizontal  bool
+	Probability float64
+}
+
+// NewRandomHorizontalFlip mirrors images left-right with probability p
+func NewRandomHorizontalFlip(p float64) *RandomFlip {
+	return &RandomFlip{Horizontal: true, Probability: p}
+}
+
+// NewRandomVerticalFlip mirrors images top-bottom with probability p
+func NewRandomVerticalFlip(p float64) *RandomFlip {
+	return &RandomFlip{Probability: p}
+}
+
+// Apply flips a copy of the image or returns an unflipped copy
+func (f *RandomFlip) Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error) {
+	result := copyTensor(t)
+	if rng.Float64() >= f.Probability {
+		return result, nil
+	}
+	for c := range result.Data {
+		plane := result.Data[c]
+		if !f.Horizontal {
+			for i, j := 0, len(plane)-1; i < j; i, j = i+1, j-1 {
+				plane[i], plane[j] = plane[j], plane[i]
+			}
+			continue
+		}
+		for _, row := range plane {
+			for i, j := 0, len(row)-1; i < j; i, j = i+1, j-1 {
+				row[i], row[j] = row[j], row[i]
+			}
+		}
+	}
+	return result, nil
+}
+
+// RandomRotation rotates the image around its center by a random angle in
+// [-MaxDegrees, MaxDegrees] with bilinear interpolation. Pixels rotated in
+// from outside the image are zero.
+type RandomRotation struct {
+	MaxDegrees float64
+}
+
+// NewRandomRotation creates a random rotation of up to maxDegrees
+func NewRandomRotation(maxDegrees float64) *RandomRotation {
+	return &RandomRotation{MaxDegrees: maxDegrees}
+}
+
+// Apply rotates the image
+func (r *RandomRotation) Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error) {
+	angle := (rng.Float64()*2 - 1) * r.MaxDegrees * math.Pi / 180
+	sin, cos := math.Sincos(angle)
+	cy, cx := float64(t.Height-1)/2, float64(t.Width-1)/2
+
+	result := NewTensor3D(t.Channels, t.Height, t.Width)
+	for i := 0; i < t.Height; i++ {
+		for j := 0; j < t.Width; j++ {
+			// Rotate the output position back to find its source
+			dy, dx := float64(i)-cy, float64(j)-cx
+			sy := cos*dy - sin*dx + cy
+			sx := sin*dy + cos*dx + cx
+			for c := 0; c < t.Channels; c++ {
+				result.Data[c][i][j] = bilinear(t.Data[c], sy, sx)
+			}
+		}
+	}
+	return result, nil
+}
+
+// bilinear interpolates plane at a fractional position, treating pixels
+// outside the plane as zero
+func bilinear(plane [][]float64, y, x float64) float64 {
+	y0, x0 := int(math.Floor(y)), int(math.Floor(x))
+	fy, fx := y-float64(y0), x-float64(x0)
+	pixel := func(i, j int) float64 {
+		if i < 0 || i >= len(plane) || j < 0 || j >= len(plane[i]) {
+			return 0
+		}
+		return plane[i][j]
+	}
+	top := pixel(y0, x0)*(1-fx) + pixel(y0, x0+1)*fx
+	bottom := pixel(y0+1, x0)*(1-fx) + pixel(y0+1, x0+1)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// RandomTranslation shifts the image by a random whole number of pixels,
+// up to MaxDX horizontally and MaxDY vertically, filling with zeros
+type RandomTranslation struct {
+	MaxDX int
+	MaxDY int
+}
+
+// NewRandomTranslation creates a random shift of up to maxDX and maxDY
+// pixels
+func NewRandomTranslation(maxDX, maxDY int) *RandomTranslation {
+	return &RandomTranslation{MaxDX: maxDX, MaxDY: maxDY}
+}
+
+// Apply shifts the image
+func (rt *RandomTranslation) Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error) {
+	if rt.MaxDX < 0 || rt.MaxDY < 0 {
+		return nil, fmt.Errorf("translation limits must not be negative, got %d and %d", rt.MaxDX, rt.MaxDY)
+	}
+
+	dx := rng.Intn(2*rt.MaxDX+1) - rt.MaxDX
+	dy := rng.Intn(2*rt.MaxDY+1) - rt.MaxDY
+
+	result := NewTensor3D(t.Channels, t.Height, t.Width)
+	for c := 0; c < t.Channels; c++ {
+		for i := 0; i < t.Height; i++ {
+			y := i - dy
+			if y < 0 || y >= t.Height {
+				continue
+			}
+			for j := 0; j < t.Width; j++ {
+				if x := j - dx; x >= 0 && x < t.Width {
+					result.Data[c][i][j] = t.Data[c][y][x]
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// ColorJitter scales the brightness by a random factor in
+// [1-Brightness, 1+Brightness] and the contrast around the image mean by a
+// random factor in [1-Contrast, 1+Contrast]
+type ColorJitter struct {
+	Brightness float64
+	Contrast   float64
+}
+
+// NewColorJitter creates a brightness and contrast jitter
+func NewColorJitter(brightness, contrast float64) *ColorJitter {
+	return &ColorJitter{Brightness: brightness, Contrast: contrast}
+}
+
+// Apply jitters the brightness, then the contrast
+func (cj *ColorJitter) Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error) {
+	brightness := 1 + (rng.Float64()*2-1)*cj.Brightness
+	contrast := 1 + (rng.Float64()*2-1)*cj.Contrast
+
+	result := copyTensor(t)
+	sum := 0.0
+	for _, plane := range result.Data {
+		for _, row := range plane {
+			for j := range row {
+				row[j] *= brightness
+				sum += row[j]
+			}
+		}
+	}
+
+	mean := sum / float64(t.Channels*t.Height*t.Width)
+	for _, plane := range result.Data {
+		for _, row := range plane {
+			for j := range row {
+				row[j] = (row[j]-mean)*contrast + mean
+			}
+		}
+	}
+	return result, nil
+}
+
+// Cutout zeroes a Size x Size square at a random position in all
+// channels. The square is clipped at the image border.
+type Cutout struct {
+	Size int
+}
+
+// NewCutout creates a cutout of the given square size
+func NewCutout(size int) *Cutout {
+	return &Cutout{Size: size}
+}
+
+// Apply zeroes the square
+func (co *Cutout) Apply(t *Tensor3D, rng *rand.Rand) (*Tensor3D, error) {
+	if t.Height < 1 || t.Width < 1 {
+		return nil, fmt.Errorf("cutout needs a non-empty image, got %dx%d", t.Height, t.Width)
+	}
+
+	result := copyTensor(t)
+	top := rng.Intn(t.Height) - co.Size/2
+	left := rng.Intn(t.Width) - co.Size/2
+	for c := range result.Data {
+		for i := max(top, 0); i < min(top+co.Size, t.Height); i++ {
+			for j := max(left, 0); j < min(left+co.Size, t.Width); j++ {
+				result.Data[c][i][j] = 0
+			}
+		}
+	}
+	return result, nil
+}
+
+// Normalize subtracts a mean and divides by a standard deviation per
+// channel. It is deterministic and usually the last step.
+type Normalize struct {
+	Mean []float64
+	Std  []float64
+}
+
+// NewNormalize creates a per-channel normalization
+func NewNormalize(mean, std []float64) *Normalize {
+	return &Normalize{Mean: mean, Std: std}
+}
+
+// Apply normalizes every channel
+func (n *Normalize) Apply(t *Tensor3D, _ *rand.Rand) (*Tensor3D, error) {
+	if len(n.Mean) != t.Channels || len(n.Std) != t.Channels {
+		return nil, fmt.Errorf("normalize has %d means and %d stds for %d channels", len(n.Mean), len(n.Std), t.Channels)
+	}
+	result := copyTensor(t)
+	for c, plane := range result.Data {
+		for _, row := range plane {
+			for j := range row {
+				row[j] = (row[j] - n.Mean[c]) / nonZero(n.Std[c])
+			}
+		}
+	}
+	return result, nil
+}
+
+// EpochSetter is implemented by datasets whose samples depend on the epoch,
+// such as AugmentedDataset. A DataLoader calls SetEpoch before every epoch.
+type EpochSetter interface {
+	SetEpoch(epoch int)
+}
+
+// AugmentedDataset applies an augmentation to the features of every sample
+// of a dataset of flattened (Channels, Height, Width) images. The random
+// draws of a sample depend only on Seed, the epoch and the sample index,
+// so results are reproducible however many DataLoader workers are used.
+type AugmentedDataset struct {
+	Dataset  Dataset
+	Channels int
+	Height   int
+	Width    int
+	Augment  Augmentation
+	Seed     int64
+
+	epoch int
+}
+
+// NewAugmentedDataset wraps dataset with an augmentation
+func NewAugmentedDataset(dataset Dataset, channels, height, width int, augment Augmentation, seed int64) *AugmentedDataset {
+	return &AugmentedDataset{Dataset: dataset, Channels: channels, Height: height, Width: width, Augment: augment, Seed: seed}
+}
+
+// SetEpoch selects the random draws of an epoch
+func (d *AugmentedDataset) SetEpoch(epoch int) {
+	d.epoch = epoch
+}
+
+// Len returns the number of samples of the wrapped dataset
+func (d *AugmentedDataset) Len() int {
+	return d.Dataset.Len()
+}
+
+// Get returns sample i with augmented features
+func (d *AugmentedDataset) Get(i int) (Sample, error) {
+	sample, err := d.Dataset.Get(i)
+	if err != nil {
+		return Sample{}, err
+	}
+	image, err := TensorFromSlice(sample.Features, d.Channels, d.Height, d.Width)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	rng := rand.New(rand.NewSource(sampleSeed(d.Seed, d.epoch, i)))
+	augmented, err := d.Augment.Apply(image, rng)
+	if err != nil {
+		return Sample{}, err
+	}
+	return Sample{Features: augmented.Flatten(), Target: sample.Target}, nil
+}
+
+// sampleSeed mixes a seed, an epoch and a sample index into the seed of
+// the sample's random source (SplitMix64 finalizer)
+func sampleSeed(seed int64, epoch, index int) int64 {
+	z := uint64(seed) + uint64(epoch)*0x9e3779b97f4a7c15 + uint64(index)*0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
@@ -0,0 +1,185 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// imageDataset returns n flattened (2, 5, 5) images of distinct values
+func imageDataset(n int) *MatrixDataset {
+	rng := rand.New(rand.NewSource(25))
+	return &MatrixDataset{X: uniformMatrix(rng, n, 2*5*5, 0, 1), Y: uniformMatrix(rng, n, 1, 0, 1)}
+}
+
+// fullAugmentation uses every random augmentation
+func fullAugmentation() Augmentation {
+	return NewCompose(
+		NewRandomCrop(5, 5, 1),
+		NewRandomHorizontalFlip(0.5),
+		NewRandomVerticalFlip(0.5),
+		NewRandomRotation(20),
+		NewRandomTranslation(1, 1),
+		NewColorJitter(0.2, 0.2),
+		NewCutout(2),
+		NewNormalize([]float64{0.5, 0.5}, []float64{0.2, 0.2}),
+	)
+}
+
+// loadEpochs returns the features of every batch of several epochs
+func loadEpochs(t *testing.T, loader *DataLoader, epochs int) [][]*Matrix {
+	t.Helper()
+	result := make([][]*Matrix, epochs)
+	for e := range result {
+		err := loader.ForEach(func(batch *Batch) error {
+			result[e] = append(result[e], batch.X)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return result
+}
+
+func TestAugmentedDatasetDeterminism(t *testing.T) {
+	load := func(seed int64, workers int) [][]*Matrix {
+		dataset := NewAugmentedDataset(imageDataset(12), 2, 5, 5, fullAugmentation(), seed)
+		loader := NewDataLoader(dataset, DataLoaderConfig{BatchSize: 4, Shuffle: true, Seed: 9, Workers: workers})
+		return loadEpochs(t, loader, 2)
+	}
+	sequential := load(1, -1)
+
+	// The draws depend on the seed, epoch and index only, not on the
+	// number of workers
+	for _, workers := range []int{1, 4} {
+		parallel := load(1, workers)
+		for e := range sequential {
+			for b := range sequential[e] {
+				if !matricesEqual(sequential[e][b], parallel[e][b]) {
+					t.Errorf("%d workers: epoch %d batch %d differs", workers, e, b)
+				}
+			}
+		}
+	}
+
+	if matricesEqual(sequential[0][0], sequential[1][0]) {
+		t.Error("two epochs drew the same augmentations")
+	}
+	if other := load(2, 1); matricesEqual(sequential[0][0], other[0][0]) {
+		t.Error("two seeds drew the same augmentations")
+	}
+}
+
+func TestAugmentedDatasetGet(t *testing.T) {
+	base := imageDataset(3)
+	original := copyMatrix(base.X)
+	dataset := NewAugmentedDataset(base, 2, 5, 5, fullAugmentation(), 7)
+	dataset.SetEpoch(4)
+
+	// Reading sample 2 before or after sample 1 gives the same result
+	first, err := dataset.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dataset.Get(1); err != nil {
+		t.Fatal(err)
+	}
+	second, err := dataset.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := range first.Features {
+		if first.Features[j] != second.Features[j] {
+			t.Fatalf("feature %d differs between reads", j)
+		}
+	}
+	if &second.Target[0] != &base.Y.Data[2][0] {
+		t.Error("the target was copied or changed")
+	}
+	if !matricesEqual(base.X, original) {
+		t.Error("augmentation changed the wrapped dataset")
+	}
+
+	bad := NewAugmentedDataset(base, 3, 5, 5, fullAugmentation(), 7)
+	if _, err := bad.Get(0); err == nil {
+		t.Error("expected an error for 50 features in a 3x5x5 image")
+	}
+}
+
+func TestAugmentationValues(t *testing.T) {
+	image := &Tensor3D{Channels: 1, Height: 2, Width: 3, Data: [][][]float64{{{1, 2, 3}, {4, 5, 6}}}}
+	rng := rand.New(rand.NewSource(26))
+	tests := []struct {
+		name    string
+		augment Augmentation
+		want    [][]float64
+	}{
+		{"horizontal_flip", NewRandomHorizontalFlip(1), [][]float64{{3, 2, 1}, {6, 5, 4}}},
+		{"vertical_flip", NewRandomVerticalFlip(1), [][]float64{{4, 5, 6}, {1, 2, 3}}},
+		{"no_flip", NewRandomHorizontalFlip(0), [][]float64{{1, 2, 3}, {4, 5, 6}}},
+		{"full_crop", NewRandomCrop(2, 3, 0), [][]float64{{1, 2, 3}, {4, 5, 6}}},
+		{"zero_rotation", NewRandomRotation(0), [][]float64{{1, 2, 3}, {4, 5, 6}}},
+		{"zero_translation", NewRandomTranslation(0, 0), [][]float64{{1, 2, 3}, {4, 5, 6}}},
+		{"no_jitter", NewColorJitter(0, 0), [][]float64{{1, 2, 3}, {4, 5, 6}}},
+		{"normalize", NewNormalize([]float64{1}, []float64{2}), [][]float64{{0, 0.5, 1}, {1.5, 2, 2.5}}},
+	}
+	for _, tt := range tests {
+		out, err := tt.augment.Apply(image, rng)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		assertPlane(t, tt.name, out, tt.want)
+	}
+	assertPlane(t, "input", image, [][]float64{{1, 2, 3}, {4, 5, 6}})
+
+	out, err := NewCutout(2).Apply(image, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeros := 0
+	for _, row := range out.Data[0] {
+		for _, v := range row {
+			if v == 0 {
+				zeros++
+			}
+		}
+	}
+	if zeros < 1 || zeros > 4 {
+		t.Errorf("cutout zeroed %d pixels, expected 1 to 4", zeros)
+	}
+
+	// A 180 degree rotation of a square image maps every pixel exactly
+	square := &Tensor3D{Channels: 1, Height: 2, Width: 2, Data: [][][]float64{{{1, 2}, {3, 4}}}}
+	rotated, err := (&RandomRotation{MaxDegrees: 180}).Apply(square, rand.New(&constantSource{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range [][]float64{{4, 3}, {2, 1}} {
+		for j, v := range row {
+			if math.Abs(rotated.Data[0][i][j]-v) > 1e-9 {
+				t.Errorf("rotated[%d][%d] = %v, expected %v", i, j, rotated.Data[0][i][j], v)
+			}
+		}
+	}
+
+	if _, err := NewRandomCrop(4, 3, 0).Apply(image, rng); err == nil {
+		t.Error("expected an error for a crop larger than the image")
+	}
+	if _, err := NewNormalize([]float64{0, 0}, []float64{1, 1}).Apply(image, rng); err == nil {
+		t.Error("expected an error for 2 means on 1 channel")
+	}
+	if _, err := NewRandomTranslation(-1, 0).Apply(image, rng); err == nil {
+		t.Error("expected an error for a negative translation")
+	}
+	if _, err := NewCutout(2).Apply(NewTensor3D(1, 0, 3), rng); err == nil {
+		t.Error("expected an error for a cutout on an empty image")
+	}
+}
+
+// constantSource makes rand.Float64 return 0, so RandomRotation rotates by
+// -MaxDegrees
+type constantSource struct{}
+
+func (constantSource) Int63() int64 { return 0 }
+func (constantSource) Seed(int64)   {}
@@ -2,148 +2,796 @@ package nn
 
 import (
 	"fmt"
-	"math/rand"
+	"runtime"
+	"sync"
 )
 
-// Tensor3D represents a 3D tensor (channels, height, width)
-type Tensor3D struct {
+// Tensor3DOf represents a 3D tensor (channels, height, width) of elements
+// of type T
+type Tensor3DOf[T Float] struct {
 	Channels int
 	Height   int
 	Width    int
-	Data     [][][]float64
+	Data     [][][]T
 }
 
+// Tensor3D represents a 3D tensor of float64 values
+type Tensor3D = Tensor3DOf[float64]
+
 // NewTensor3D creates a new 3D tensor
 func NewTensor3D(channels, height, width int) *Tensor3D {
-	data := make([][][]float64, channels)
+	return NewTensor3DOf[float64](channels, height, width)
+}
+
+// NewTensor3DOf creates a new 3D tensor with element type T
+func NewTensor3DOf[T Float](channels, height, width int) *Tensor3DOf[T] {
+	data := make([][][]T, channels)
 	for c := range data {
-		data[c] = make([][]float64, height)
+		data[c] = make([][]T, height)
 		for h := range data[c] {
-			data[c][h] = make([]float64, width)
+			data[c][h] = make([]T, width)
 		}
 	}
-	return &Tensor3D{Channels: channels, Height: height, Width: width, Data: data}
+	return &Tensor3DOf[T]{Channels: channels, Height: height, Width: width, Data: data}
 }
 
-// ConvLayer represents a convolutional layer
-type ConvLayer struct {
-	NumFilters  int
-	FilterSize  int
-	Stride      int
-	Padding     int
-	InChannels  int
-	Filters     [][][][]float64 // [numFilters][inChannels][filterSize][filterSize]
-	Bias        []float64
-}
-
-// NewConvLayer creates a new convolutional layer
-func NewConvLayer(numFilters, inChannels, filterSize, stride, padding int) *ConvLayer {
-	// Initialize filters with small random values
-	filters := make([][][][]float64, numFilters)
-	for f := 0; f < numFilters; f++ {
-		filters[f] = make([][][]float64, inChannels)
-		for c := 0; c < inChannels; c++ {
-			filters[f][c] = make([][]float64, filterSize)
-			for i := 0; i < filterSize; i++ {
-				filters[f][c][i] = make([]float64, filterSize)
-				for j := 0; j < filterSize; j++ {
-					filters[f][c][i][j] = (rand.Float64()*2 - 1) * 0.1
+// TensorFromSlice builds a tensor from values laid out channel by channel,
+// row by row, as in the flattened (C, H, W) rows used by Sequential models
+func TensorFromSlice[T Float](values []T, channels, height, width int) (*Tensor3DOf[T], error) {
+	if len(values) != channels*height*width {
+		return nil, fmt.Errorf("got %d values for a %dx%dx%d tensor", len(values), channels, height, width)
+	}
+	t := NewTensor3DOf[T](channels, height, width)
+	for c := 0; c < channels; c++ {
+		for h := 0; h < height; h++ {
+			start := (c*height + h) * width
+			copy(t.Data[c][h], values[start:start+width])
+		}
+	}
+	return t, nil
+}
+
+// Flatten returns the values of the tensor in (C, H, W) order
+func (t *Tensor3DOf[T]) Flatten() []T {
+	values := make([]T, 0, t.Channels*t.Height*t.Width)
+	for c := 0; c < t.Channels; c++ {
+		for h := 0; h < t.Height; h++ {
+			values = append(values, t.Data[c][h]...)
+		}
+	}
+	return values
+}
+
+// Tensor4DOf represents a batch of 3D tensors
+// (batch, channels, height, width) of elements of type T
+type Tensor4DOf[T Float] struct {
+	Batch    int
+	Channels int
+	Height   int
+	Width    int
+	Data     [][][][]T
+}
+
+// Tensor4D represents a batch of float64 3D tensors
+type Tensor4D = Tensor4DOf[float64]
+
+// NewTensor4D creates a new 4D tensor
+func NewTensor4D(batch, channels, height, width int) *Tensor4D {
+	return NewTensor4DOf[float64](batch, channels, height, width)
+}
+
+// NewTensor4DOf creates a new 4D tensor with element type T
+func NewTensor4DOf[T Float](batch, channels, height, width int) *Tensor4DOf[T] {
+	data := make([][][][]T, batch)
+	for n := range data {
+		data[n] = NewTensor3DOf[T](channels, height, width).Data
+	}
+	return &Tensor4DOf[T]{Batch: batch, Channels: channels, Height: height, Width: width, Data: data}
+}
+
+// asBatch returns a batch holding only t, sharing its data
+func (t *Tensor3DOf[T]) asBatch() *Tensor4DOf[T] {
+	return &Tensor4DOf[T]{Batch: 1, Channels: t.Channels, Height: t.Height, Width: t.Width, Data: [][][][]T{t.Data}}
+}
+
+// StackTensors builds a batch from samples of the same shape. The batch
+// shares its data with the samples.
+func StackTensors[T Float](samples []*Tensor3DOf[T]) (*Tensor4DOf[T], error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples to stack")
+	}
+	first := samples[0]
+	t := &Tensor4DOf[T]{Batch: len(samples), Channels: first.Channels, Height: first.Height, Width: first.Width}
+	t.Data = make([][][][]T, len(samples))
+	for n, sample := range samples {
+		if sample.Channels != first.Channels || sample.Height != first.Height || sample.Width != first.Width {
+			return nil, fmt.Errorf("sample %d has shape %dx%dx%d, expected %dx%dx%d", n,
+				sample.Channels, sample.Height, sample.Width, first.Channels, first.Height, first.Width)
+		}
+		t.Data[n] = sample.Data
+	}
+	return t, nil
+}
+
+// TensorBatchFromMatrix builds a batch from rows holding flattened
+// (C, H, W) samples
+func TensorBatchFromMatrix[T Float](m *MatrixOf[T], channels, height, width int) (*Tensor4DOf[T], error) {
+	if m.Cols != channels*height*width {
+		return nil, fmt.Errorf("got %d values per row for %dx%dx%d samples", m.Cols, channels, height, width)
+	}
+	t := &Tensor4DOf[T]{Batch: m.Rows, Channels: channels, Height: height, Width: width}
+	t.Data = make([][][][]T, m.Rows)
+	for n := 0; n < m.Rows; n++ {
+		sample, _ := TensorFromSlice(m.Data[n], channels, height, width)
+		t.Data[n] = sample.Data
+	}
+	return t, nil
+}
+
+// Sample returns sample n, sharing its data with the batch
+func (t *Tensor4DOf[T]) Sample(n int) *Tensor3DOf[T] {
+	return &Tensor3DOf[T]{Channels: t.Channels, Height: t.Height, Width: t.Width, Data: t.Data[n]}
+}
+
+// Samples returns every sample, sharing their data with the batch
+func (t *Tensor4DOf[T]) Samples() []*Tensor3DOf[T] {
+	samples := make([]*Tensor3DOf[T], t.Batch)
+	for n := range samples {
+		samples[n] = t.Sample(n)
+	}
+	return samples
+}
+
+// ToMatrix returns the batch with one flattened (C, H, W) sample per row
+func (t *Tensor4DOf[T]) ToMatrix() *MatrixOf[T] {
+	m := NewMatrixOf[T](t.Batch, t.Channels*t.Height*t.Width)
+	for n := 0; n < t.Batch; n++ {
+		m.Data[n] = t.Sample(n).Flatten()
+	}
+	return m
+}
+
+// workerCount returns how many goroutines to use for n independent items
+func workerCount(n int) int {
+	workers := runtime.GOMAXPROCS(0)
+	if n < workers {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// parallelFor calls fn for every i in [0, n) from the given number of
+// goroutines. Worker w handles i = w, w+workers, ..., so per-worker buffers
+// indexed by w need no locking.
+func parallelFor(n, workers int, fn func(worker, i int)) {
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(0, i)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += workers {
+				fn(w, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// sumMatrices adds up matrices of the same shape into the first one
+func sumMatrices[T Float](ms []*MatrixOf[T]) *MatrixOf[T] {
+	sum := ms[0]
+	for _, m := range ms[1:] {
+		for i := range m.Data {
+			for j := range m.Data[i] {
+				sum.Data[i][j] += m.Data[i][j]
+			}
+		}
+	}
+	return sum
+}
+
+// SpatialLayerOf is a layer working on single (C, H, W) samples, such as
+// the convolution and 2D pooling layers. Wrap it with NewSpatial to use it
+// in a Sequential model.
+type SpatialLayerOf[T Float] interface {
+	Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error)
+	Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error)
+	GetParams() []*MatrixOf[T]
+	GetGrads() []*MatrixOf[T]
+	GetParamNames() []string
+}
+
+// SpatialLayer is a SpatialLayerOf with float64 values
+type SpatialLayer = SpatialLayerOf[float64]
+
+// BatchSpatialLayerOf is a SpatialLayerOf that also processes (N, C, H, W)
+// batches, summing parameter gradients over the batch. All convolution and
+// 2D pooling layers implement it.
+type BatchSpatialLayerOf[T Float] interface {
+	SpatialLayerOf[T]
+	ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error)
+	BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error)
+}
+
+// BatchSpatialLayer is a BatchSpatialLayerOf with float64 values
+type BatchSpatialLayer = BatchSpatialLayerOf[float64]
+
+// SpatialOf adapts a SpatialLayerOf to the LayerOf interface. Every row of
+// the input is a flattened (Channels, Height, Width) sample and every
+// output row the flattened output sample.
+type SpatialOf[T Float] struct {
+	Layer    SpatialLayerOf[T]
+	Channels int
+	Height   int
+	Width    int
+
+	grads []*MatrixOf[T]
+
+	// Cache for backward pass
+	lastInputs []*Tensor3DOf[T]
+	outShape   [3]int
+}
+
+// Spatial adapts a float64 SpatialLayer to the Layer interface
+type Spatial = SpatialOf[float64]
+
+// NewSpatial wraps a layer taking samples of the given shape
+func NewSpatial(layer SpatialLayer, channels, height, width int) *Spatial {
+	return NewSpatialOf[float64](layer, channels, height, width)
+}
+
+// NewSpatialOf wraps a layer with element type T taking samples of the
+// given shape
+func NewSpatialOf[T Float](layer SpatialLayerOf[T], channels, height, width int) *SpatialOf[T] {
+	return &SpatialOf[T]{Layer: layer, Channels: channels, Height: height, Width: width}
+}
+
+// OutputShaper is implemented by spatial layers that compute the (C, H, W)
+// shape of their output for an input shape without running. All
+// convolution and 2D pooling layers implement it.
+type OutputShaper interface {
+	OutputShape(channels, height, width int) (int, int, int, error)
+}
+
+// OutputShape returns the (C, H, W) shape of the output samples. The
+// wrapped layer must implement OutputShaper.
+func (s *SpatialOf[T]) OutputShape() (int, int, int, error) {
+	shaper, ok := s.Layer.(OutputShaper)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("%T does not report its output shape", s.Layer)
+	}
+	return shaper.OutputShape(s.Channels, s.Height, s.Width)
+}
+
+// Forward runs the wrapped layer on every sample, as a single batch when
+// the layer supports it
+func (s *SpatialOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if batched, ok := s.Layer.(BatchSpatialLayerOf[T]); ok {
+		x, err := TensorBatchFromMatrix(input, s.Channels, s.Height, s.Width)
+		if err != nil {
+			return nil, err
+		}
+		y, err := batched.ForwardBatch(x)
+		if err != nil {
+			return nil, err
+		}
+		s.outShape = [3]int{y.Channels, y.Height, y.Width}
+		return y.ToMatrix(), nil
+	}
+
+	s.lastInputs = make([]*Tensor3DOf[T], input.Rows)
+	var output *MatrixOf[T]
+
+	for i := 0; i < input.Rows; i++ {
+		x, err := TensorFromSlice(input.Data[i], s.Channels, s.Height, s.Width)
+		if err != nil {
+			return nil, err
+		}
+		s.lastInputs[i] = x
+
+		y, err := s.Layer.Forward(x)
+		if err != nil {
+			return nil, err
+		}
+		if output == nil {
+			s.outShape = [3]int{y.Channels, y.Height, y.Width}
+			output = NewMatrixOf[T](input.Rows, y.Channels*y.Height*y.Width)
+		}
+		output.Data[i] = y.Flatten()
+	}
+
+	if output == nil {
+		output = NewMatrixOf[T](0, 0)
+	}
+	return output, nil
+}
+
+// Backward computes the input gradient of every sample and sums the
+// parameter gradients over the batch. A layer without batch support only
+// caches one sample, so the forward pass of every sample is recomputed
+// before its backward pass.
+func (s *SpatialOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if batched, ok := s.Layer.(BatchSpatialLayerOf[T]); ok {
+		gy, err := TensorBatchFromMatrix(gradOutput, s.outShape[0], s.outShape[1], s.outShape[2])
+		if err != nil {
+			return nil, fmt.Errorf("gradient size mismatch")
+		}
+		gx, err := batched.BackwardBatch(gy)
+		if err != nil {
+			return nil, err
+		}
+		s.grads = s.Layer.GetGrads()
+		return gx.ToMatrix(), nil
+	}
+
+	if gradOutput.Rows != len(s.lastInputs) {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	s.grads = zeroGrads(s.Layer.GetParams())
+	gradInput := NewMatrixOf[T](gradOutput.Rows, s.Channels*s.Height*s.Width)
+
+	for i, x := range s.lastInputs {
+		if _, err := s.Layer.Forward(x); err != nil {
+			return nil, err
+		}
+		gy, err := TensorFromSlice(gradOutput.Data[i], s.outShape[0], s.outShape[1], s.outShape[2])
+		if err != nil {
+			return nil, fmt.Errorf("gradient size mismatch")
+		}
+		gx, err := s.Layer.Backward(gy)
+		if err != nil {
+			return nil, err
+		}
+		gradInput.Data[i] = gx.Flatten()
+
+		for k, g := range s.Layer.GetGrads() {
+			for r := range g.Data {
+				for c := range g.Data[r] {
+					s.grads[k].Data[r][c] += g.Data[r][c]
 				}
 			}
 		}
 	}
 
-	bias := make([]float64, numFilters)
+	return gradInput, nil
+}
 
-	return &ConvLayer{
-		NumFilters:  numFilters,
-		FilterSize:  filterSize,
-		Stride:      stride,
-		Padding:     padding,
-		InChannels:  inChannels,
-		Filters:     filters,
-		Bias:        bias,
+// GetParams returns the parameters of the wrapped layer
+func (s *SpatialOf[T]) GetParams() []*MatrixOf[T] {
+	return s.Layer.GetParams()
+}
+
+// GetGrads returns the parameter gradients summed over the last batch
+func (s *SpatialOf[T]) GetGrads() []*MatrixOf[T] {
+	if s.grads == nil {
+		return zeroGrads(s.Layer.GetParams())
 	}
+	return s.grads
 }
 
-// Forward performs the forward pass of convolution
-func (conv *ConvLayer) Forward(input *Tensor3D) (*Tensor3D, error) {
-	if input.Channels != conv.InChannels {
-		return nil, fmt.Errorf("input channels mismatch: got %d, expected %d", input.Channels, conv.InChannels)
+// zeroGrads returns zero matrices shaped like params
+func zeroGrads[T Float](params []*MatrixOf[T]) []*MatrixOf[T] {
+	grads := make([]*MatrixOf[T], len(params))
+	for i, p := range params {
+		grads[i] = NewMatrixOf[T](p.Rows, p.Cols)
+	}
+	return grads
+}
+
+// GetParamNames returns the parameter names of the wrapped layer
+func (s *SpatialOf[T]) GetParamNames() []string {
+	return s.Layer.GetParamNames()
+}
+
+// PaddingMode selects how convolutions pad their input
+type PaddingMode int
+
+const (
+	// PaddingExplicit pads every side by the configured amount
+	PaddingExplicit PaddingMode = iota
+	// PaddingValid does not pad; only complete windows are used
+	PaddingValid
+	// PaddingSame pads so that the output size is ceil(input / stride). When
+	// the total padding is odd the extra row or column goes at the end.
+	PaddingSame
+)
+
+// Conv2DConfig describes a 2D convolution. Zero strides, dilations and
+// groups mean 1.
+type Conv2DConfig struct {
+	InChannels  int
+	OutChannels int
+	KernelH     int
+	KernelW     int
+	StrideH     int
+	StrideW     int
+	Padding     PaddingMode
+	PadH        int // Rows added above and below with PaddingExplicit
+	PadW        int // Columns added left and right with PaddingExplicit
+	DilationH   int
+	DilationW   int
+	Groups      int // Channels are split into groups convolved separately
+}
+
+// withDefaults replaces zero strides, dilations and groups by 1
+func (cfg Conv2DConfig) withDefaults() Conv2DConfig {
+	for _, v := range []*int{&cfg.StrideH, &cfg.StrideW, &cfg.DilationH, &cfg.DilationW, &cfg.Groups} {
+		if *v == 0 {
+			*v = 1
+		}
+	}
+	return cfg
+}
+
+// checkChannels returns an error if a channel count is not positive or if
+// the channels cannot be split into groups
+func (cfg Conv2DConfig) checkChannels() error {
+	if cfg.InChannels < 1 || cfg.OutChannels < 1 {
+		return fmt.Errorf("channel counts must be positive, got %d input and %d output channels",
+			cfg.InChannels, cfg.OutChannels)
+	}
+	if cfg.InChannels%cfg.Groups != 0 || cfg.OutChannels%cfg.Groups != 0 {
+		return fmt.Errorf("%d input and %d output channels are not divisible by %d groups",
+			cfg.InChannels, cfg.OutChannels, cfg.Groups)
 	}
+	return nil
+}
 
-	// Calculate output dimensions
-	outHeight := (input.Height-conv.FilterSize+2*conv.Padding)/conv.Stride + 1
-	outWidth := (input.Width-conv.FilterSize+2*conv.Padding)/conv.Stride + 1
+// convOutputSize returns the output size of a convolution along one axis
+// and the padding added before the input
+func convOutputSize(in, kernel, stride, dilation, pad int, mode PaddingMode) (int, int, error) {
+	span := dilation*(kernel-1) + 1
+	var out, before int
+	switch mode {
+	case PaddingValid:
+		out = (in-span)/stride + 1
+	case PaddingSame:
+		out = (in + stride - 1) / stride
+		if total := (out-1)*stride + span - in; total > 0 {
+			before = total / 2
+		}
+	default:
+		out = (in+2*pad-span)/stride + 1
+		before = pad
+	}
+	if in < 1 || (mode != PaddingSame && in+2*before < span) {
+		return 0, 0, fmt.Errorf("input size %d is too small for kernel size %d", in, span)
+	}
+	return out, before, nil
+}
 
-	output := NewTensor3D(conv.NumFilters, outHeight, outWidth)
+// convGeometry is a 2D convolution between fixed input and output sizes.
+// Weights have one row per output channel holding the kernels of the input
+// channels of its group, laid out as (channel, row, column).
+type convGeometry struct {
+	inC, outC, groups int
+	kh, kw            int
+	strideH, strideW  int
+	dilH, dilW        int
+	padTop, padLeft   int
+	inH, inW          int
+	outH, outW        int
+}
 
-	// Perform convolution for each filter
-	for f := 0; f < conv.NumFilters; f++ {
-		for outH := 0; outH < outHeight; outH++ {
-			for outW := 0; outW < outWidth; outW++ {
-				sum := conv.Bias[f]
+// newConvGeometry resolves the padding of cfg for an input size
+func newConvGeometry(cfg Conv2DConfig, inH, inW int) (*convGeometry, error) {
+	if err := cfg.checkChannels(); err != nil {
+		return nil, err
+	}
+	outH, padTop, err := convOutputSize(inH, cfg.KernelH, cfg.StrideH, cfg.DilationH, cfg.PadH, cfg.Padding)
+	if err != nil {
+		return nil, err
+	}
+	outW, padLeft, err := convOutputSize(inW, cfg.KernelW, cfg.StrideW, cfg.DilationW, cfg.PadW, cfg.Padding)
+	if err != nil {
+		return nil, err
+	}
+	return &convGeometry{
+		inC: cfg.InChannels, outC: cfg.OutChannels, groups: cfg.Groups,
+		kh: cfg.KernelH, kw: cfg.KernelW,
+		strideH: cfg.StrideH, strideW: cfg.StrideW,
+		dilH: cfg.DilationH, dilW: cfg.DilationW,
+		padTop: padTop, padLeft: padLeft,
+		inH: inH, inW: inW, outH: outH, outW: outW,
+	}, nil
+}
 
-				// Convolve over all input channels
-				for c := 0; c < conv.InChannels; c++ {
-					for fh := 0; fh < conv.FilterSize; fh++ {
-						for fw := 0; fw < conv.FilterSize; fw++ {
-							inH := outH*conv.Stride + fh - conv.Padding
-							inW := outW*conv.Stride + fw - conv.Padding
+// convForward accumulates the convolution g of input into output
+func convForward[T Float](g *convGeometry, weights *MatrixOf[T], input, output *Tensor3DOf[T]) {
+	inPerGroup, outPerGroup := g.inC/g.groups, g.outC/g.groups
 
-							// Check bounds
-							if inH >= 0 && inH < input.Height && inW >= 0 && inW < input.Width {
-								sum += input.Data[c][inH][inW] * conv.Filters[f][c][fh][fw]
+	for f := 0; f < g.outC; f++ {
+		w := weights.Data[f]
+		base := (f / outPerGroup) * inPerGroup
+		for oh := 0; oh < g.outH; oh++ {
+			for ow := 0; ow < g.outW; ow++ {
+				var sum T
+				for c := 0; c < inPerGroup; c++ {
+					plane := input.Data[base+c]
+					for i := 0; i < g.kh; i++ {
+						ih := oh*g.strideH + i*g.dilH - g.padTop
+						if ih < 0 || ih >= g.inH {
+							continue
+						}
+						k := (c*g.kh + i) * g.kw
+						for j := 0; j < g.kw; j++ {
+							iw := ow*g.strideW + j*g.dilW - g.padLeft
+							if iw >= 0 && iw < g.inW {
+								sum += plane[ih][iw] * w[k+j]
 							}
 						}
 					}
 				}
+				output.Data[f][oh][ow] += sum
+			}
+		}
+	}
+}
 
-				output.Data[f][outH][outW] = sum
+// convBackward accumulates the gradients of the input and of the weights
+// of convForward. Either target may be nil when it is not needed.
+func convBackward[T Float](g *convGeometry, weights *MatrixOf[T], input, gradOutput, gradInput *Tensor3DOf[T], gradWeights *MatrixOf[T]) {
+	inPerGroup, outPerGroup := g.inC/g.groups, g.outC/g.groups
+
+	for f := 0; f < g.outC; f++ {
+		w := weights.Data[f]
+		base := (f / outPerGroup) * inPerGroup
+		for oh := 0; oh < g.outH; oh++ {
+			for ow := 0; ow < g.outW; ow++ {
+				grad := gradOutput.Data[f][oh][ow]
+				if grad == 0 {
+					continue
+				}
+				for c := 0; c < inPerGroup; c++ {
+					for i := 0; i < g.kh; i++ {
+						ih := oh*g.strideH + i*g.dilH - g.padTop
+						if ih < 0 || ih >= g.inH {
+							continue
+						}
+						k := (c*g.kh + i) * g.kw
+						for j := 0; j < g.kw; j++ {
+							iw := ow*g.strideW + j*g.dilW - g.padLeft
+							if iw < 0 || iw >= g.inW {
+								continue
+							}
+							if gradInput != nil {
+								gradInput.Data[base+c][ih][iw] += grad * w[k+j]
+							}
+							if gradWeights != nil {
+								gradWeights.Data[f][k+j] += grad * input.Data[base+c][ih][iw]
+							}
+						}
+					}
+				}
 			}
 		}
 	}
+}
 
-	return output, nil
+// filterView returns [rows][channels][kernelH][kernelW] slices aliasing the
+// rows of weights
+func filterView[T Float](weights *MatrixOf[T], channels, kernelH, kernelW int) [][][][]T {
+	filters := make([][][][]T, weights.Rows)
+	for f := range filters {
+		filters[f] = make([][][]T, channels)
+		for c := 0; c < channels; c++ {
+			filters[f][c] = make([][]T, kernelH)
+			for i := 0; i < kernelH; i++ {
+				start := (c*kernelH + i) * kernelW
+				filters[f][c][i] = weights.Data[f][start : start+kernelW : start+kernelW]
+			}
+		}
+	}
+	return filters
 }
 
-// MaxPool2D performs 2D max pooling
-type MaxPool2D struct {
-	PoolSize int
-	Stride   int
+// ConvLayerOf represents a 2D convolutional layer on (C, H, W) samples or
+// (N, C, H, W) batches of elements of type T
+type ConvLayerOf[T Float] struct {
+	Conv2DConfig
+	Weights *MatrixOf[T] // Shape: (OutChannels, InChannels/Groups*KernelH*KernelW)
+	Bias    *MatrixOf[T] // Shape: (1, OutChannels)
+	Filters [][][][]T    // [OutChannels][InChannels/Groups][KernelH][KernelW], aliases Weights
+
+	// Gradients
+	weightGrad *MatrixOf[T]
+	biasGrad   *MatrixOf[T]
+
+	// Cache for backward pass
+	lastInput *Tensor4DOf[T]
+	geometry  *convGeometry
 }
 
-// NewMaxPool2D creates a new max pooling layer
-func NewMaxPool2D(poolSize, stride int) *MaxPool2D {
-	return &MaxPool2D{PoolSize: poolSize, Stride: stride}
+// ConvLayer represents a 2D convolutional layer with float64 values
+type ConvLayer = ConvLayerOf[float64]
+
+// NewConvLayer creates a new convolutional layer with square filters and
+// explicit padding. opts select the initializers as in NewConv2D.
+func NewConvLayer(numFilters, inChannels, filterSize, stride, padding int, opts ...InitOption) *ConvLayer {
+	return NewConv2D(Conv2DConfig{
+		InChannels:  inChannels,
+		OutChannels: numFilters,
+		KernelH:     filterSize,
+		KernelW:     filterSize,
+		StrideH:     stride,
+		StrideW:     stride,
+		PadH:        padding,
+		PadW:        padding,
+	}, opts...)
 }
 
-// Forward performs max pooling
-func (pool *MaxPool2D) Forward(input *Tensor3D) *Tensor3D {
-	outHeight := (input.Height-pool.PoolSize)/pool.Stride + 1
-	outWidth := (input.Width-pool.PoolSize)/pool.Stride + 1
+// NewConv2D creates a convolutional layer from a full configuration, with
+// Uniform(-0.1, 0.1) filters and a zero bias unless opts select other
+// initializers. Fan-in and fan-out include the kernel area. Forward returns
+// an error if the channels are not divisible by the number of groups.
+func NewConv2D(cfg Conv2DConfig, opts ...InitOption) *ConvLayer {
+	return NewConv2DOf[float64](cfg, opts...)
+}
 
-	output := NewTensor3D(input.Channels, outHeight, outWidth)
+// NewConv2DOf creates a convolutional layer with element type T from a full
+// configuration
+func NewConv2DOf[T Float](cfg Conv2DConfig, opts ...InitOption) *ConvLayerOf[T] {
+	cfg = cfg.withDefaults()
+	weightInit, biasInit := initializers(opts, Uniform{Min: -0.1, Max: 0.1}, Zeros{})
+	area := cfg.KernelH * cfg.KernelW
+	cols := cfg.InChannels / cfg.Groups * area
+	fanOut := cfg.OutChannels / cfg.Groups * area
+	weights := initializedMatrixOf[T](weightInit, cfg.OutChannels, cols, cols, fanOut)
+	return &ConvLayerOf[T]{
+		Conv2DConfig: cfg,
+		Weights:      weights,
+		Bias:         initializedMatrixOf[T](biasInit, 1, cfg.OutChannels, cols, fanOut),
+		Filters:      filterView(weights, cfg.InChannels/cfg.Groups, cfg.KernelH, cfg.KernelW),
+		weightGrad:   NewMatrixOf[T](weights.Rows, weights.Cols),
+		biasGrad:     NewMatrixOf[T](1, cfg.OutChannels),
+	}
+}
 
-	for c := 0; c < input.Channels; c++ {
-		for outH := 0; outH < outHeight; outH++ {
-			for outW := 0; outW < outWidth; outW++ {
-				maxVal := input.Data[c][outH*pool.Stride][outW*pool.Stride]
+// NewDepthwiseConv2D creates a convolution applying multiplier separate
+// filters to every input channel. Follow it with a 1x1 NewConv2D to get a
+// depthwise separable convolution.
+func NewDepthwiseConv2D(channels, multiplier int, cfg Conv2DConfig, opts ...InitOption) *ConvLayer {
+	cfg.InChannels = channels
+	cfg.OutChannels = channels * multiplier
+	cfg.Groups = channels
+	return NewConv2D(cfg, opts...)
+}
 
-				for ph := 0; ph < pool.PoolSize; ph++ {
-					for pw := 0; pw < pool.PoolSize; pw++ {
-						inH := outH*pool.Stride + ph
-						inW := outW*pool.Stride + pw
-						if input.Data[c][inH][inW] > maxVal {
-							maxVal = input.Data[c][inH][inW]
-						}
-					}
-				}
+// OutputSize returns the output height and width for an input size
+func (conv *ConvLayerOf[T]) OutputSize(height, width int) (int, int, error) {
+	g, err := newConvGeometry(conv.Conv2DConfig, height, width)
+	if err != nil {
+		return 0, 0, err
+	}
+	return g.outH, g.outW, nil
+}
+
+// OutputShape returns the (C, H, W) output shape for an input shape
+func (conv *ConvLayerOf[T]) OutputShape(channels, height, width int) (int, int, int, error) {
+	if channels != conv.InChannels {
+		return 0, 0, 0, fmt.Errorf("input channels mismatch: got %d, expected %d", channels, conv.InChannels)
+	}
+	outH, outW, err := conv.OutputSize(height, width)
+	return conv.OutChannels, outH, outW, err
+}
+
+// Forward performs the forward pass of convolution
+func (conv *ConvLayerOf[T]) Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	output, err := conv.ForwardBatch(input.asBatch())
+	if err != nil {
+		return nil, err
+	}
+	return output.Sample(0), nil
+}
+
+// ForwardBatch convolves every sample of the batch, one goroutine per
+// group of samples
+func (conv *ConvLayerOf[T]) ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	if input.Channels != conv.InChannels {
+		return nil, fmt.Errorf("input channels mismatch: got %d, expected %d", input.Channels, conv.InChannels)
+	}
+
+	g, err := newConvGeometry(conv.Conv2DConfig, input.Height, input.Width)
+	if err != nil {
+		return nil, err
+	}
+	conv.lastInput = input
+	conv.geometry = g
 
-				output.Data[c][outH][outW] = maxVal
+	output := NewTensor4DOf[T](input.Batch, conv.OutChannels, g.outH, g.outW)
+	parallelFor(input.Batch, workerCount(input.Batch), func(_, n int) {
+		y := output.Sample(n)
+		for f := 0; f < conv.OutChannels; f++ {
+			for oh := 0; oh < g.outH; oh++ {
+				for ow := 0; ow < g.outW; ow++ {
+					y.Data[f][oh][ow] = conv.Bias.Data[0][f]
+				}
 			}
 		}
+		convForward(g, conv.Weights, input.Sample(n), y)
+	})
+
+	return output, nil
+}
+
+// Backward computes the gradients of the filters and the bias for the last
+// sample and returns the gradient of its input
+func (conv *ConvLayerOf[T]) Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	gradInput, err := conv.BackwardBatch(gradOutput.asBatch())
+	if err != nil {
+		return nil, err
+	}
+	return gradInput.Sample(0), nil
+}
+
+// BackwardBatch sums the filter and bias gradients over the last batch and
+// returns the gradient of every input sample. Every goroutine accumulates
+// into its own buffers, which are added up at the end.
+func (conv *ConvLayerOf[T]) BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	g := conv.geometry
+	if g == nil {
+		return nil, fmt.Errorf("backward called before forward")
+	}
+	if gradOutput.Batch != conv.lastInput.Batch || gradOutput.Channels != g.outC ||
+		gradOutput.Height != g.outH || gradOutput.Width != g.outW {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	workers := workerCount(gradOutput.Batch)
+	weightGrads := make([]*MatrixOf[T], workers)
+	biasGrads := make([]*MatrixOf[T], workers)
+	for w := 0; w < workers; w++ {
+		weightGrads[w] = NewMatrixOf[T](conv.Weights.Rows, conv.Weights.Cols)
+		biasGrads[w] = NewMatrixOf[T](1, conv.OutChannels)
 	}
 
-	return output
+	gradInput := NewTensor4DOf[T](gradOutput.Batch, g.inC, g.inH, g.inW)
+	parallelFor(gradOutput.Batch, workers, func(w, n int) {
+		gy := gradOutput.Sample(n)
+		for f := 0; f < conv.OutChannels; f++ {
+			biasGrads[w].Data[0][f] += sumPlane(gy.Data[f])
+		}
+		convBackward(g, conv.Weights, conv.lastInput.Sample(n), gy, gradInput.Sample(n), weightGrads[w])
+	})
+
+	conv.weightGrad = sumMatrices(weightGrads)
+	conv.biasGrad = sumMatrices(biasGrads)
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (conv *ConvLayerOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{conv.Weights, conv.Bias}
+}
+
+// GetGrads returns the gradients of the layer
+func (conv *ConvLayerOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{conv.weightGrad, conv.biasGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (conv *ConvLayerOf[T]) GetParamNames() []string {
+	return []string{"weights", "bias"}
+}
+
+// sumPlane adds up all values of a 2D slice
+func sumPlane[T Float](plane [][]T) T {
+	var sum T
+	for _, row := range plane {
+		for _, v := range row {
+			sum += v
+		}
+	}
+	return sum
 }
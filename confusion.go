@@ -0,0 +1,213 @@
+package nn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Confusion is a confusion matrix: the number of samples of every target
+// class predicted as every class. It marshals to JSON as its labels and
+// counts.
+type Confusion struct {
+	Labels []string `json:"labels"` // Class names, by default "0", "1", ...
+	Counts [][]int  `json:"counts"` // Counts[target][predicted]
+}
+
+// newConfusion creates an empty confusion matrix with numbered labels
+func newConfusion(classes int) *Confusion {
+	c := &Confusion{Labels: make([]string, classes), Counts: make([][]int, classes)}
+	for k := range c.Counts {
+		c.Labels[k] = strconv.Itoa(k)
+		c.Counts[k] = make([]int, classes)
+	}
+	return c
+}
+
+// add counts one sample
+func (c *Confusion) add(target, predicted int) {
+	c.Counts[target][predicted]++
+}
+
+// maxIndexClasses caps the number of classes ConfusionMatrix infers from
+// class index columns, so a stray large label cannot allocate a huge matrix
+const maxIndexClasses = 1024
+
+// ConfusionMatrix counts the samples by target and predicted class.
+// predictions and targets hold either one-hot or probability rows, read
+// by arg max, or a single column of class indices; a single column of
+// probabilities rounds to classes 0 and 1. When either side has several
+// columns, the class indices of the other side must be below its column
+// count; otherwise there can be at most 1024 classes.
+func ConfusionMatrix(predictions, targets *Matrix) (*Confusion, error) {
+	if predictions.Rows != targets.Rows {
+		return nil, fmt.Errorf("%d predictions but %d targets", predictions.Rows, targets.Rows)
+	}
+	if predictions.Cols > 1 && targets.Cols > 1 && predictions.Cols != targets.Cols {
+		return nil, fmt.Errorf("predictions have %d classes but targets have %d", predictions.Cols, targets.Cols)
+	}
+
+	predicted, actual := classIndices(predictions), classIndices(targets)
+	classes, limit := max(predictions.Cols, targets.Cols), maxIndexClasses
+	if classes > 1 {
+		limit = classes
+	} else {
+		classes = 2
+	}
+	for i := range actual {
+		for _, class := range []int{predicted[i], actual[i]} {
+			if class < 0 || class >= limit {
+				return nil, fmt.Errorf("class %d in row %d out of range [0, %d)", class, i, limit)
+			}
+			classes = max(classes, class+1)
+		}
+	}
+
+	c := newConfusion(classes)
+	for i := range actual {
+		c.add(actual[i], predicted[i])
+	}
+	return c, nil
+}
+
+// classCounts returns the true positives, false positives and false
+// negatives of class k
+func (c *Confusion) classCounts(k int) (tp, fp, fn int) {
+	tp = c.Counts[k][k]
+	for j := range c.Counts {
+		if j != k {
+			fp += c.Counts[j][k]
+			fn += c.Counts[k][j]
+		}
+	}
+	return tp, fp, fn
+}
+
+// Accuracy returns the fraction of samples on the diagonal
+func (c *Confusion) Accuracy() float64 {
+	correct, total := 0, 0
+	for k, row := range c.Counts {
+		correct += row[k]
+		for _, n := range row {
+			total += n
+		}
+	}
+	return ratio(correct, total)
+}
+
+// String formats the matrix as aligned text, one row per target class
+func (c *Confusion) String() string {
+	const corner = "true\\pred"
+	labelWidth := len(corner)
+	for _, label := range c.Labels {
+		labelWidth = max(labelWidth, len(label))
+	}
+	widths := make([]int, len(c.Labels))
+	for j, label := range c.Labels {
+		widths[j] = len(label)
+		for _, row := range c.Counts {
+			widths[j] = max(widths[j], len(strconv.Itoa(row[j])))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s", labelWidth, corner)
+	for j, label := range c.Labels {
+		fmt.Fprintf(&b, "  %*s", widths[j], label)
+	}
+	for k, row := range c.Counts {
+		fmt.Fprintf(&b, "\n%-*s", labelWidth, c.Labels[k])
+		for j, n := range row {
+			fmt.Fprintf(&b, "  %*d", widths[j], n)
+		}
+	}
+	return b.String()
+}
+
+// ClassScores holds the precision, recall and F1 score of a class, or
+// their average, and the number of target samples
+type ClassScores struct {
+	Label     string  `json:"label"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// ClassificationReport holds the scores of every class and their macro
+// and support-weighted averages. It marshals to JSON.
+type ClassificationReport struct {
+	Classes     []ClassScores `json:"classes"`
+	Accuracy    float64       `json:"accuracy"`
+	MacroAvg    ClassScores   `json:"macro_avg"`
+	WeightedAvg ClassScores   `json:"weighted_avg"`
+}
+
+// NewClassificationReport scores predictions against targets, read like
+// ConfusionMatrix does
+func NewClassificationReport(predictions, targets *Matrix) (*ClassificationReport, error) {
+	c, err := ConfusionMatrix(predictions, targets)
+	if err != nil {
+		return nil, err
+	}
+	return c.ClassificationReport(), nil
+}
+
+// ClassificationReport scores every class of the matrix
+func (c *Confusion) ClassificationReport() *ClassificationReport {
+	r := &ClassificationReport{
+		Accuracy:    c.Accuracy(),
+		MacroAvg:    ClassScores{Label: "macro avg"},
+		WeightedAvg: ClassScores{Label: "weighted avg"},
+	}
+
+	for k, label := range c.Labels {
+		tp, fp, fn := c.classCounts(k)
+		scores := ClassScores{
+			Label:     label,
+			Precision: precisionScore(tp, fp, fn),
+			Recall:    recallScore(tp, fp, fn),
+			F1:        f1Score(tp, fp, fn),
+			Support:   tp + fn,
+		}
+		r.Classes = append(r.Classes, scores)
+
+		r.MacroAvg.Support += scores.Support
+		r.MacroAvg.Precision += scores.Precision / float64(len(c.Labels))
+		r.MacroAvg.Recall += scores.Recall / float64(len(c.Labels))
+		r.MacroAvg.F1 += scores.F1 / float64(len(c.Labels))
+		r.WeightedAvg.Precision += scores.Precision * float64(scores.Support)
+		r.WeightedAvg.Recall += scores.Recall * float64(scores.Support)
+		r.WeightedAvg.F1 += scores.F1 * float64(scores.Support)
+	}
+
+	r.WeightedAvg.Support = r.MacroAvg.Support
+	if total := float64(r.WeightedAvg.Support); total > 0 {
+		r.WeightedAvg.Precision /= total
+		r.WeightedAvg.Recall /= total
+		r.WeightedAvg.F1 /= total
+	}
+	return r
+}
+
+// String formats the report as aligned text with one row per class
+// followed by the accuracy and the averages
+func (r *ClassificationReport) String() string {
+	labelWidth := len(r.WeightedAvg.Label)
+	for _, scores := range r.Classes {
+		labelWidth = max(labelWidth, len(scores.Label))
+	}
+	row := func(s ClassScores) string {
+		return fmt.Sprintf("%*s  %9.4f  %9.4f  %9.4f  %9d\n", labelWidth, s.Label, s.Precision, s.Recall, s.F1, s.Support)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s  %9s  %9s  %9s  %9s\n\n", labelWidth, "", "precision", "recall", "f1-score", "support")
+	for _, scores := range r.Classes {
+		b.WriteString(row(scores))
+	}
+	fmt.Fprintf(&b, "\n%*s  %9s  %9s  %9.4f  %9d\n", labelWidth, "accuracy", "", "", r.Accuracy, r.MacroAvg.Support)
+	b.WriteString(row(r.MacroAvg))
+	b.WriteString(row(r.WeightedAvg))
+	return strings.TrimSuffix(b.String(), "\n")
+}
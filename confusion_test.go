@@ -0,0 +1,192 @@
+package nn
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// animalConfusion returns the matrix of the README example
+func animalConfusion(t *testing.T) *Confusion {
+	t.Helper()
+	predictions := oneHotRows(t, 3, 0, 0, 0, 2, 1, 1)
+	targets := oneHotRows(t, 3, 0, 0, 1, 1, 2, 2)
+	c, err := ConfusionMatrix(predictions, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Labels = []string{"cat", "dog", "bird"}
+	return c
+}
+
+func TestConfusionMatrix(t *testing.T) {
+	c := animalConfusion(t)
+	if want := [][]int{{2, 0, 0}, {1, 0, 1}, {0, 2, 0}}; !reflect.DeepEqual(c.Counts, want) {
+		t.Errorf("counts %v, expected %v", c.Counts, want)
+	}
+	if got := c.Accuracy(); math.Abs(got-1.0/3) > 1e-12 {
+		t.Errorf("accuracy %v, expected 1/3", got)
+	}
+
+	// Class index columns may mix with probability rows, below their
+	// number of columns
+	predictions := &Matrix{Rows: 3, Cols: 3, Data: [][]float64{{0.7, 0.2, 0.1}, {0.1, 0.3, 0.6}, {0.2, 0.5, 0.3}}}
+	c, err := ConfusionMatrix(predictions, columnMatrix(0, 2, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]int{{1, 0, 0}, {0, 0, 0}, {0, 1, 1}}; !reflect.DeepEqual(c.Counts, want) {
+		t.Errorf("counts %v, expected %v", c.Counts, want)
+	}
+	if _, err := ConfusionMatrix(predictions, columnMatrix(0, 2, 3)); err == nil {
+		t.Error("expected an error for class 3 with 3 prediction columns")
+	}
+
+	// Index columns alone name as many classes as their largest index
+	c, err = ConfusionMatrix(columnMatrix(0, 3), columnMatrix(0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"0", "1", "2", "3"}; !reflect.DeepEqual(c.Labels, want) {
+		t.Errorf("labels %v, expected %v", c.Labels, want)
+	}
+	if _, err := ConfusionMatrix(columnMatrix(0, 1e9), columnMatrix(0, 1)); err == nil {
+		t.Error("expected an error for a class index beyond the class limit")
+	}
+
+	// A probability column rounds to two classes
+	c, err = ConfusionMatrix(columnMatrix(0.2, 0.8, 0.6), columnMatrix(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]int{{1, 1}, {0, 1}}; !reflect.DeepEqual(c.Counts, want) {
+		t.Errorf("binary counts %v, expected %v", c.Counts, want)
+	}
+
+	if _, err := ConfusionMatrix(columnMatrix(0, 1), columnMatrix(0)); err == nil {
+		t.Error("expected an error for mismatched rows")
+	}
+	if _, err := ConfusionMatrix(columnMatrix(0, -1), columnMatrix(0, 1)); err == nil {
+		t.Error("expected an error for a negative class")
+	}
+	if _, err := ConfusionMatrix(oneHotRows(t, 3, 0), oneHotRows(t, 4, 0)); err == nil {
+		t.Error("expected an error for different numbers of classes")
+	}
+}
+
+func TestConfusionString(t *testing.T) {
+	want := "true\\pred  cat  dog  bird\n" +
+		"cat          2    0     0\n" +
+		"dog          1    0     1\n" +
+		"bird         0    2     0"
+	if got := animalConfusion(t).String(); got != want {
+		t.Errorf("got\n%s\nexpected\n%s", got, want)
+	}
+
+	// Columns widen to their largest count and rows to the longest label
+	c := newConfusion(2)
+	c.Labels[1] = "positive class"
+	c.Counts[0][0] = 12345
+	c.Counts[1][0] = 7
+	want = "true\\pred           0  positive class\n" +
+		"0               12345               0\n" +
+		"positive class      7               0"
+	if got := c.String(); got != want {
+		t.Errorf("got\n%s\nexpected\n%s", got, want)
+	}
+}
+
+func TestConfusionJSON(t *testing.T) {
+	c := animalConfusion(t)
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"labels":["cat","dog","bird"],"counts":[[2,0,0],[1,0,1],[0,2,0]]}`; string(data) != want {
+		t.Errorf("got %s, expected %s", data, want)
+	}
+	var decoded Confusion
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, c) {
+		t.Errorf("decoded %+v, expected %+v", decoded, *c)
+	}
+}
+
+func TestClassificationReport(t *testing.T) {
+	r := animalConfusion(t).ClassificationReport()
+	want := "              precision     recall   f1-score    support\n" +
+		"\n" +
+		"         cat     0.6667     1.0000     0.8000          2\n" +
+		"         dog     0.0000     0.0000     0.0000          2\n" +
+		"        bird     0.0000     0.0000     0.0000          2\n" +
+		"\n" +
+		"    accuracy                           0.3333          6\n" +
+		"   macro avg     0.2222     0.3333     0.2667          6\n" +
+		"weighted avg     0.2222     0.3333     0.2667          6"
+	if got := r.String(); got != want {
+		t.Errorf("got\n%s\nexpected\n%s", got, want)
+	}
+
+	// The report agrees with the averaged metrics on unequal supports
+	predictions := oneHotRows(t, 3, 0, 0, 1, 1, 1)
+	targets := oneHotRows(t, 3, 0, 0, 0, 1, 2)
+	r, err := NewClassificationReport(predictions, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		got    float64
+		metric Metric
+	}{
+		{r.MacroAvg.Precision, NewPrecision(AverageMacro)},
+		{r.MacroAvg.Recall, NewRecall(AverageMacro)},
+		{r.MacroAvg.F1, NewF1Score(AverageMacro)},
+		{r.WeightedAvg.Precision, NewPrecision(AverageWeighted)},
+		{r.WeightedAvg.Recall, NewRecall(AverageWeighted)},
+		{r.WeightedAvg.F1, NewF1Score(AverageWeighted)},
+		{r.Accuracy, NewAccuracy()},
+	} {
+		if want := batchedResult(t, tt.metric, predictions, targets); math.Abs(tt.got-want) > 1e-12 {
+			t.Errorf("report %s = %v, expected %v", tt.metric.Name(), tt.got, want)
+		}
+	}
+	if supports := []int{r.Classes[0].Support, r.Classes[1].Support, r.Classes[2].Support}; !reflect.DeepEqual(supports, []int{3, 1, 1}) {
+		t.Errorf("supports %v, expected [3 1 1]", supports)
+	}
+
+	if _, err := NewClassificationReport(columnMatrix(0, 1), columnMatrix(0)); err == nil {
+		t.Error("expected an error for mismatched rows")
+	}
+}
+
+func TestClassificationReportJSON(t *testing.T) {
+	r := animalConfusion(t).ClassificationReport()
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"classes", "accuracy", "macro_avg", "weighted_avg"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("JSON lacks %q: %s", key, data)
+		}
+	}
+	if want := `{"label":"macro avg","precision":0.2222222222222222,"recall":0.3333333333333333,"f1":0.26666666666666666,"support":6}`; string(fields["macro_avg"]) != want {
+		t.Errorf("macro_avg %s, expected %s", fields["macro_avg"], want)
+	}
+
+	var decoded ClassificationReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, r) {
+		t.Errorf("decoded %+v, expected %+v", decoded, *r)
+	}
+}
@@ -0,0 +1,402 @@
+package nn
+
+import (
+	"fmt"
+)
+
+// Conv1DConfig describes a 1D convolution. Zero strides, dilations and
+// groups mean 1.
+type Conv1DConfig struct {
+	InChannels  int
+	OutChannels int
+	KernelSize  int
+	Stride      int
+	Padding     PaddingMode
+	Pad         int // Steps added at both ends with PaddingExplicit
+	Dilation    int
+	Groups      int
+}
+
+// conv2D returns the equivalent 2D configuration on inputs of height 1
+func (cfg Conv1DConfig) conv2D() Conv2DConfig {
+	return Conv2DConfig{
+		InChannels:  cfg.InChannels,
+		OutChannels: cfg.OutChannels,
+		KernelH:     1,
+		KernelW:     cfg.KernelSize,
+		StrideH:     1,
+		StrideW:     cfg.Stride,
+		Padding:     cfg.Padding,
+		PadW:        cfg.Pad,
+		DilationH:   1,
+		DilationW:   cfg.Dilation,
+		Groups:      cfg.Groups,
+	}
+}
+
+// Conv1DOf convolves sequences along time. Like the recurrent layers it
+// takes (batch, time*InChannels) rows laid out step by step and returns
+// (batch, outTime*OutChannels) rows.
+type Conv1DOf[T Float] struct {
+	Conv1DConfig
+	Weights *MatrixOf[T] // Shape: (OutChannels, InChannels/Groups*KernelSize)
+	Bias    *MatrixOf[T] // Shape: (1, OutChannels)
+
+	// Gradients
+	weightGrad *MatrixOf[T]
+	biasGrad   *MatrixOf[T]
+
+	// Cache for backward pass
+	lastInputs []*Tensor3DOf[T]
+	geometry   *convGeometry
+}
+
+// Conv1D is a Conv1DOf with float64 values
+type Conv1D = Conv1DOf[float64]
+
+// NewConv1D creates a new 1D convolution with a Uniform(-0.1, 0.1) kernel
+// and a zero bias, unless opts select other initializers. Forward returns an
+// error if the channels are not divisible by the number of groups.
+func NewConv1D(cfg Conv1DConfig, opts ...InitOption) *Conv1D {
+	return NewConv1DOf[float64](cfg, opts...)
+}
+
+// NewConv1DOf creates a new 1D convolution with element type T
+func NewConv1DOf[T Float](cfg Conv1DConfig, opts ...InitOption) *Conv1DOf[T] {
+	cfg2D := cfg.conv2D().withDefaults()
+	cfg.Stride, cfg.Dilation, cfg.Groups = cfg2D.StrideW, cfg2D.DilationW, cfg2D.Groups
+
+	weightInit, biasInit := initializers(opts, Uniform{Min: -0.1, Max: 0.1}, Zeros{})
+	cols := cfg.InChannels / cfg.Groups * cfg.KernelSize
+	fanOut := cfg.OutChannels / cfg.Groups * cfg.KernelSize
+	return &Conv1DOf[T]{
+		Conv1DConfig: cfg,
+		Weights:      initializedMatrixOf[T](weightInit, cfg.OutChannels, cols, cols, fanOut),
+		Bias:         initializedMatrixOf[T](biasInit, 1, cfg.OutChannels, cols, fanOut),
+		weightGrad:   NewMatrixOf[T](cfg.OutChannels, cols),
+		biasGrad:     NewMatrixOf[T](1, cfg.OutChannels),
+	}
+}
+
+// OutputLength returns the number of output steps for an input length
+func (c *Conv1DOf[T]) OutputLength(length int) (int, error) {
+	g, err := newConvGeometry(c.conv2D(), 1, length)
+	if err != nil {
+		return 0, err
+	}
+	return g.outW, nil
+}
+
+// Forward convolves every sequence of the batch
+func (c *Conv1DOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if err := c.conv2D().checkChannels(); err != nil {
+		return nil, err
+	}
+	if input.Cols%c.InChannels != 0 {
+		return nil, fmt.Errorf("input size %d is not a multiple of %d channels", input.Cols, c.InChannels)
+	}
+	g, err := newConvGeometry(c.conv2D(), 1, input.Cols/c.InChannels)
+	if err != nil {
+		return nil, err
+	}
+	c.geometry = g
+	c.lastInputs = make([]*Tensor3DOf[T], input.Rows)
+
+	output := NewMatrixOf[T](input.Rows, g.outW*c.OutChannels)
+	parallelFor(input.Rows, workerCount(input.Rows), func(_, i int) {
+		x := sequenceToTensor(input.Data[i], c.InChannels)
+		c.lastInputs[i] = x
+
+		y := NewTensor3DOf[T](c.OutChannels, 1, g.outW)
+		for f := 0; f < c.OutChannels; f++ {
+			for t := 0; t < g.outW; t++ {
+				y.Data[f][0][t] = c.Bias.Data[0][f]
+			}
+		}
+		convForward(g, c.Weights, x, y)
+		tensorToSequence(y, output.Data[i])
+	})
+
+	return output, nil
+}
+
+// Backward sums the kernel and bias gradients over the batch and returns
+// the gradient of the input sequences
+func (c *Conv1DOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	g := c.geometry
+	if g == nil || gradOutput.Rows != len(c.lastInputs) || gradOutput.Cols != g.outW*c.OutChannels {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	workers := workerCount(gradOutput.Rows)
+	weightGrads := make([]*MatrixOf[T], workers)
+	biasGrads := make([]*MatrixOf[T], workers)
+	for w := 0; w < workers; w++ {
+		weightGrads[w] = NewMatrixOf[T](c.Weights.Rows, c.Weights.Cols)
+		biasGrads[w] = NewMatrixOf[T](1, c.OutChannels)
+	}
+
+	gradInput := NewMatrixOf[T](gradOutput.Rows, g.inW*c.InChannels)
+	parallelFor(gradOutput.Rows, workers, func(w, i int) {
+		gy := sequenceToTensor(gradOutput.Data[i], c.OutChannels)
+		for f := 0; f < c.OutChannels; f++ {
+			biasGrads[w].Data[0][f] += sumPlane(gy.Data[f])
+		}
+
+		gx := NewTensor3DOf[T](c.InChannels, 1, g.inW)
+		convBackward(g, c.Weights, c.lastInputs[i], gy, gx, weightGrads[w])
+		tensorToSequence(gx, gradInput.Data[i])
+	})
+
+	c.weightGrad = sumMatrices(weightGrads)
+	c.biasGrad = sumMatrices(biasGrads)
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (c *Conv1DOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{c.Weights, c.Bias}
+}
+
+// GetGrads returns the gradients of the layer
+func (c *Conv1DOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{c.weightGrad, c.biasGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (c *Conv1DOf[T]) GetParamNames() []string {
+	return []string{"weights", "bias"}
+}
+
+// sequenceToTensor converts a step-major row into a (channels, 1, time) tensor
+func sequenceToTensor[T Float](row []T, channels int) *Tensor3DOf[T] {
+	steps := len(row) / channels
+	t := NewTensor3DOf[T](channels, 1, steps)
+	for s := 0; s < steps; s++ {
+		for ch := 0; ch < channels; ch++ {
+			t.Data[ch][0][s] = row[s*channels+ch]
+		}
+	}
+	return t
+}
+
+// tensorToSequence writes a (channels, 1, time) tensor into a step-major row
+func tensorToSequence[T Float](t *Tensor3DOf[T], row []T) {
+	for s := 0; s < t.Width; s++ {
+		for ch := 0; ch < t.Channels; ch++ {
+			row[s*t.Channels+ch] = t.Data[ch][0][s]
+		}
+	}
+}
+
+// transposeOutputSize returns the output size of a transposed convolution
+// along one axis and the padding of the equivalent convolution
+func transposeOutputSize(in, kernel, stride, dilation, pad, outputPad int, mode PaddingMode) (int, int, error) {
+	span := dilation*(kernel-1) + 1
+	var out, before int
+	switch mode {
+	case PaddingValid:
+		out = (in-1)*stride + span
+	case PaddingSame:
+		out = in * stride
+		if total := (in-1)*stride + span - out; total > 0 {
+			before = total / 2
+		}
+	default:
+		if outputPad >= stride && outputPad >= dilation {
+			return 0, 0, fmt.Errorf("output padding %d must be smaller than the stride or the dilation", outputPad)
+		}
+		out = (in-1)*stride - 2*pad + span + outputPad
+		before = pad
+	}
+	if in < 1 || out < 1 {
+		return 0, 0, fmt.Errorf("input size %d gives an empty output", in)
+	}
+	return out, before, nil
+}
+
+// Conv2DTransposeOf is the transpose (gradient) of a 2D convolution, used
+// to upsample (C, H, W) samples. With PaddingSame the output is Stride
+// times larger than the input.
+type Conv2DTransposeOf[T Float] struct {
+	Conv2DConfig
+	OutputPadH int          // Extra rows at the bottom with PaddingExplicit
+	OutputPadW int          // Extra columns at the right with PaddingExplicit
+	Weights    *MatrixOf[T] // Shape: (InChannels, OutChannels/Groups*KernelH*KernelW)
+	Bias       *MatrixOf[T] // Shape: (1, OutChannels)
+
+	// Gradients
+	weightGrad *MatrixOf[T]
+	biasGrad   *MatrixOf[T]
+
+	// Cache for backward pass
+	lastInput *Tensor4DOf[T]
+	geometry  *convGeometry
+}
+
+// Conv2DTranspose is a Conv2DTransposeOf with float64 values
+type Conv2DTranspose = Conv2DTransposeOf[float64]
+
+// NewConv2DTranspose creates a new transposed convolution with a
+// Uniform(-0.1, 0.1) kernel and a zero bias, unless opts select other
+// initializers. Forward returns an error if the channels are not divisible
+// by the number of groups.
+func NewConv2DTranspose(cfg Conv2DConfig, opts ...InitOption) *Conv2DTranspose {
+	return NewConv2DTransposeOf[float64](cfg, opts...)
+}
+
+// NewConv2DTransposeOf creates a new transposed convolution with element
+// type T
+func NewConv2DTransposeOf[T Float](cfg Conv2DConfig, opts ...InitOption) *Conv2DTransposeOf[T] {
+	cfg = cfg.withDefaults()
+	weightInit, biasInit := initializers(opts, Uniform{Min: -0.1, Max: 0.1}, Zeros{})
+	cols := cfg.OutChannels / cfg.Groups * cfg.KernelH * cfg.KernelW
+	fanOut := cfg.InChannels / cfg.Groups * cfg.KernelH * cfg.KernelW
+	return &Conv2DTransposeOf[T]{
+		Conv2DConfig: cfg,
+		Weights:      initializedMatrixOf[T](weightInit, cfg.InChannels, cols, cols, fanOut),
+		Bias:         initializedMatrixOf[T](biasInit, 1, cfg.OutChannels, cols, fanOut),
+		weightGrad:   NewMatrixOf[T](cfg.InChannels, cols),
+		biasGrad:     NewMatrixOf[T](1, cfg.OutChannels),
+	}
+}
+
+// newGeometry returns the convolution whose input gradient this layer
+// computes: it maps the output of the layer back to its input
+func (ct *Conv2DTransposeOf[T]) newGeometry(height, width int) (*convGeometry, error) {
+	if err := ct.checkChannels(); err != nil {
+		return nil, err
+	}
+	outH, padTop, err := transposeOutputSize(height, ct.KernelH, ct.StrideH, ct.DilationH, ct.PadH, ct.OutputPadH, ct.Padding)
+	if err != nil {
+		return nil, err
+	}
+	outW, padLeft, err := transposeOutputSize(width, ct.KernelW, ct.StrideW, ct.DilationW, ct.PadW, ct.OutputPadW, ct.Padding)
+	if err != nil {
+		return nil, err
+	}
+	return &convGeometry{
+		inC: ct.OutChannels, outC: ct.InChannels, groups: ct.Groups,
+		kh: ct.KernelH, kw: ct.KernelW,
+		strideH: ct.StrideH, strideW: ct.StrideW,
+		dilH: ct.DilationH, dilW: ct.DilationW,
+		padTop: padTop, padLeft: padLeft,
+		inH: outH, inW: outW, outH: height, outW: width,
+	}, nil
+}
+
+// OutputSize returns the output height and width for an input size
+func (ct *Conv2DTransposeOf[T]) OutputSize(height, width int) (int, int, error) {
+	g, err := ct.newGeometry(height, width)
+	if err != nil {
+		return 0, 0, err
+	}
+	return g.inH, g.inW, nil
+}
+
+// OutputShape returns the (C, H, W) output shape for an input shape
+func (ct *Conv2DTransposeOf[T]) OutputShape(channels, height, width int) (int, int, int, error) {
+	if channels != ct.InChannels {
+		return 0, 0, 0, fmt.Errorf("input channels mismatch: got %d, expected %d", channels, ct.InChannels)
+	}
+	outH, outW, err := ct.OutputSize(height, width)
+	return ct.OutChannels, outH, outW, err
+}
+
+// Forward spreads every input value over a kernel-sized output window
+func (ct *Conv2DTransposeOf[T]) Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	output, err := ct.ForwardBatch(input.asBatch())
+	if err != nil {
+		return nil, err
+	}
+	return output.Sample(0), nil
+}
+
+// ForwardBatch upsamples every sample of the batch, one goroutine per
+// group of samples
+func (ct *Conv2DTransposeOf[T]) ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	if input.Channels != ct.InChannels {
+		return nil, fmt.Errorf("input channels mismatch: got %d, expected %d", input.Channels, ct.InChannels)
+	}
+	g, err := ct.newGeometry(input.Height, input.Width)
+	if err != nil {
+		return nil, err
+	}
+	ct.lastInput = input
+	ct.geometry = g
+
+	output := NewTensor4DOf[T](input.Batch, ct.OutChannels, g.inH, g.inW)
+	parallelFor(input.Batch, workerCount(input.Batch), func(_, n int) {
+		y := output.Sample(n)
+		for f := 0; f < ct.OutChannels; f++ {
+			for h := 0; h < g.inH; h++ {
+				for w := 0; w < g.inW; w++ {
+					y.Data[f][h][w] = ct.Bias.Data[0][f]
+				}
+			}
+		}
+		convBackward(g, ct.Weights, nil, input.Sample(n), y, nil)
+	})
+
+	return output, nil
+}
+
+// Backward computes the kernel and bias gradients for the last sample and
+// returns the gradient of its input
+func (ct *Conv2DTransposeOf[T]) Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	gradInput, err := ct.BackwardBatch(gradOutput.asBatch())
+	if err != nil {
+		return nil, err
+	}
+	return gradInput.Sample(0), nil
+}
+
+// BackwardBatch sums the kernel and bias gradients over the last batch and
+// returns the gradient of every input sample
+func (ct *Conv2DTransposeOf[T]) BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	g := ct.geometry
+	if g == nil {
+		return nil, fmt.Errorf("backward called before forward")
+	}
+	if gradOutput.Batch != ct.lastInput.Batch || gradOutput.Channels != ct.OutChannels ||
+		gradOutput.Height != g.inH || gradOutput.Width != g.inW {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	workers := workerCount(gradOutput.Batch)
+	weightGrads := make([]*MatrixOf[T], workers)
+	biasGrads := make([]*MatrixOf[T], workers)
+	for w := 0; w < workers; w++ {
+		weightGrads[w] = NewMatrixOf[T](ct.Weights.Rows, ct.Weights.Cols)
+		biasGrads[w] = NewMatrixOf[T](1, ct.OutChannels)
+	}
+
+	gradInput := NewTensor4DOf[T](gradOutput.Batch, ct.InChannels, g.outH, g.outW)
+	parallelFor(gradOutput.Batch, workers, func(w, n int) {
+		gy := gradOutput.Sample(n)
+		for f := 0; f < ct.OutChannels; f++ {
+			biasGrads[w].Data[0][f] += sumPlane(gy.Data[f])
+		}
+		convBackward(g, ct.Weights, gy, ct.lastInput.Sample(n), nil, weightGrads[w])
+		convForward(g, ct.Weights, gy, gradInput.Sample(n))
+	})
+
+	ct.weightGrad = sumMatrices(weightGrads)
+	ct.biasGrad = sumMatrices(biasGrads)
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (ct *Conv2DTransposeOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{ct.Weights, ct.Bias}
+}
+
+// GetGrads returns the gradients of the layer
+func (ct *Conv2DTransposeOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{ct.weightGrad, ct.biasGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (ct *Conv2DTransposeOf[T]) GetParamNames() []string {
+	return []string{"weights", "bias"}
+}
@@ -0,0 +1,197 @@
+package nn
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestConvGroupsError(t *testing.T) {
+	// 3 input channels cannot be split into 2 groups. The constructors
+	// succeed and the forward pass reports the configuration error.
+	cfg := Conv2DConfig{InChannels: 3, OutChannels: 4, KernelH: 1, KernelW: 1, Groups: 2}
+	conv1D := NewConv1D(Conv1DConfig{InChannels: 3, OutChannels: 4, KernelSize: 1, Groups: 2})
+
+	errs := map[string]func() error{
+		"conv_2d": func() error {
+			_, err := NewConv2D(cfg).Forward(NewTensor3D(3, 2, 2))
+			return err
+		},
+		"conv_2d_batch": func() error {
+			_, err := NewConv2D(cfg).ForwardBatch(NewTensor4D(2, 3, 2, 2))
+			return err
+		},
+		"conv_2d_output_size": func() error {
+			_, _, err := NewConv2D(cfg).OutputSize(2, 2)
+			return err
+		},
+		"conv_1d": func() error {
+			_, err := conv1D.Forward(NewMatrix(2, 3*4))
+			return err
+		},
+		"conv_2d_transpose": func() error {
+			_, err := NewConv2DTranspose(cfg).Forward(NewTensor3D(3, 2, 2))
+			return err
+		},
+	}
+	for name, forward := range errs {
+		if err := forward(); err == nil || !strings.Contains(err.Error(), "groups") {
+			t.Errorf("%s: expected a groups error, got %v", name, err)
+		}
+	}
+}
+
+func TestConvChannelsError(t *testing.T) {
+	for name, forward := range map[string]func() error{
+		"conv_1d": func() error {
+			_, err := NewConv1D(Conv1DConfig{OutChannels: 2, KernelSize: 1}).Forward(NewMatrix(2, 4))
+			return err
+		},
+		"conv_2d": func() error {
+			_, err := NewConv2D(Conv2DConfig{InChannels: 2, KernelH: 1, KernelW: 1}).Forward(NewTensor3D(2, 2, 2))
+			return err
+		},
+	} {
+		if err := forward(); err == nil || !strings.Contains(err.Error(), "positive") {
+			t.Errorf("%s: expected a channel count error, got %v", name, err)
+		}
+	}
+}
+
+func TestConvGradsBeforeBackward(t *testing.T) {
+	layers := map[string]Layer{
+		"conv_1d":           NewConv1D(Conv1DConfig{InChannels: 2, OutChannels: 3, KernelSize: 2}),
+		"conv_2d":           NewSpatial(NewConvLayer(3, 2, 3, 1, 1), 2, 4, 4),
+		"conv_2d_transpose": NewSpatial(NewConv2DTranspose(Conv2DConfig{InChannels: 2, OutChannels: 3, KernelH: 2, KernelW: 2}), 2, 3, 3),
+	}
+	for name, layer := range layers {
+		params, grads := layer.GetParams(), layer.GetGrads()
+		for k, grad := range grads {
+			if grad == nil || grad.Rows != params[k].Rows || grad.Cols != params[k].Cols {
+				t.Fatalf("%s: gradient %d is %v before the first backward pass", name, k, grad)
+			}
+		}
+
+		// An optimizer step on zero gradients leaves the parameters as
+		// they are
+		before := copyMatrix(params[0])
+		model := NewSequential()
+		model.Add(layer)
+		model.Compile(NewMSE(), NewSGD(0.1, 0))
+		model.UpdateWeights()
+		if !matricesEqual(layer.GetParams()[0], before) {
+			t.Errorf("%s: a step without gradients changed the weights", name)
+		}
+	}
+}
+
+func TestSpatialOutputShape(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer *Spatial
+		want  [3]int
+	}{
+		{"conv_2d", NewSpatial(NewConvLayer(3, 2, 3, 2, 1), 2, 5, 5), [3]int{3, 3, 3}},
+		{"conv_2d_transpose", NewSpatial(NewConv2DTranspose(Conv2DConfig{InChannels: 2, OutChannels: 3, KernelH: 2, KernelW: 2}), 2, 3, 3), [3]int{3, 4, 4}},
+		{"max_pool_2d", NewSpatial(NewCheckedMaxPool2D(2, 2), 2, 5, 5), [3]int{2, 2, 2}},
+	}
+	for _, tt := range tests {
+		c, h, w, err := tt.layer.OutputShape()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := [3]int{c, h, w}; got != tt.want {
+			t.Errorf("%s: output shape %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, _, _, err := NewSpatial(NewConvLayer(3, 4, 3, 1, 1), 2, 5, 5).OutputShape(); err == nil {
+		t.Error("expected an error for 2 input channels on a 4 channel convolution")
+	}
+}
+
+func TestSpatialOutputShapeKeepsCaches(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	input := uniformMatrix(rng, 2, 2*4*4, -1, 1)
+	gradOutput := uniformMatrix(rng, 2, 3*4*4, -1, 1)
+	layer := NewSpatial(NewConvLayer(3, 2, 3, 1, 1), 2, 4, 4)
+	backward := func(shape bool) *Matrix {
+		if _, err := layer.Forward(input); err != nil {
+			t.Fatal(err)
+		}
+		if shape {
+			if _, _, _, err := layer.OutputShape(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		gradInput, err := layer.Backward(gradOutput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gradInput
+	}
+	if !matricesEqual(backward(true), backward(false)) {
+		t.Error("OutputShape between Forward and Backward changed the input gradient")
+	}
+}
+
+func TestBatchMatchesSamples(t *testing.T) {
+	layers := []struct {
+		name  string
+		layer BatchSpatialLayer
+	}{
+		{"conv_2d", NewConv2D(Conv2DConfig{InChannels: 4, OutChannels: 2, KernelH: 3, KernelW: 2, StrideH: 2, StrideW: 1, PadH: 1, PadW: 1, Groups: 2})},
+		{"conv_2d_transpose", NewConv2DTranspose(Conv2DConfig{InChannels: 4, OutChannels: 3, KernelH: 2, KernelW: 2, StrideH: 2, StrideW: 2})},
+		{"max_pool_2d", NewCheckedMaxPool2D(2, 2)},
+		{"avg_pool_2d", NewAvgPool2D(2, 1)},
+		{"adaptive_avg_pool_2d", NewAdaptiveAvgPool2D(2, 3)},
+		{"global_average_pool_2d", NewGlobalAveragePool2D()},
+		{"global_max_pool_2d", NewGlobalMaxPool2D()},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	const batch = 5
+	for _, tt := range layers {
+		input, _ := TensorBatchFromMatrix(uniformMatrix(rng, batch, 4*5*6, -1, 1), 4, 5, 6)
+		output, err := tt.layer.ForwardBatch(input)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		gradOutput, _ := TensorBatchFromMatrix(uniformMatrix(rng, batch, output.Channels*output.Height*output.Width, -1, 1),
+			output.Channels, output.Height, output.Width)
+		gradInput, err := tt.layer.BackwardBatch(gradOutput)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var batchGrads []*Matrix
+		for _, grad := range tt.layer.GetGrads() {
+			batchGrads = append(batchGrads, copyMatrix(grad))
+		}
+
+		// The batch gives the per-sample outputs and input gradients, and
+		// its parameter gradients are the sum over the samples
+		var sampleGrads []*Matrix
+		for n := 0; n < batch; n++ {
+			y, err := tt.layer.Forward(input.Sample(n))
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			gx, err := tt.layer.Backward(gradOutput.Sample(n))
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			assertMatrix(t, tt.name+" output", output.Sample(n).asBatch().ToMatrix(), [][]float64{y.Flatten()}, 1e-12)
+			assertMatrix(t, tt.name+" input gradient", gradInput.Sample(n).asBatch().ToMatrix(), [][]float64{gx.Flatten()}, 1e-12)
+			for k, grad := range tt.layer.GetGrads() {
+				if n == 0 {
+					sampleGrads = append(sampleGrads, copyMatrix(grad))
+					continue
+				}
+				sampleGrads[k] = sumMatrices([]*Matrix{sampleGrads[k], grad})
+			}
+		}
+		for k := range batchGrads {
+			assertMatrix(t, tt.name+" parameter gradient", batchGrads[k], sampleGrads[k].Data, 1e-9)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package nn
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Column selects a CSV column by header name or by zero-based index. Name
+// is used when it is not empty.
+type Column struct {
+	Name  string
+	Index int
+}
+
+// ColumnName selects the column with the given header name
+func ColumnName(name string) Column {
+	return Column{Name: name}
+}
+
+// ColumnIndex selects the column at a zero-based index
+func ColumnIndex(index int) Column {
+	return Column{Index: index}
+}
+
+// MissingPolicy says what to do with empty or missing numeric fields
+type MissingPolicy int
+
+const (
+	// MissingError fails on the first missing field (the default)
+	MissingError MissingPolicy = iota
+	// MissingDrop skips rows with a missing field
+	MissingDrop
+	// MissingFill replaces missing fields by CSVConfig.FillValue
+	MissingFill
+	// MissingNaN stores missing fields as NaN, e.g. for a later imputer
+	MissingNaN
+)
+
+// defaultMissingValues are the fields treated as missing besides the empty
+// field
+var defaultMissingValues = []string{"NA", "N/A", "NaN", "nan", "null", "?"}
+
+// CSVConfig configures reading a CSV or TSV file
+type CSVConfig struct {
+	Comma   rune // Field separator, ',' when 0; use '\t' for TSV
+	Comment rune // Lines starting with it are ignored when not 0
+	Header  bool // The first record holds the column names
+
+	// Features are the input columns; nil selects every column that is
+	// not a label
+	Features []Column
+	// Labels are the target columns; nil reads an unlabelled file, e.g.
+	// for inference, whose samples have empty targets
+	Labels []Column
+
+	// OneHot encodes a single categorical label column as one column per
+	// class, the layout CategoricalCrossEntropy expects
+	OneHot bool
+	// Classes fixes the class order of OneHot labels. When nil, ReadCSV
+	// uses the sorted classes found in the file; a CSVReader requires it.
+	Classes []string
+
+	Missing       MissingPolicy
+	FillValue     float64
+	MissingValues []string // Fields treated as missing besides ""; defaults to NA, N/A, NaN, nan, null and ?
+}
+
+// CSVReader streams samples from a CSV or TSV file, holding one record at
+// a time in memory. It reads forward only and is not a Dataset; load the
+// file with ReadCSV and use CSVData.Dataset for random access.
+type CSVReader struct {
+	cfg     CSVConfig
+	reader  *csv.Reader
+	missing map[string]bool
+
+	features     []int
+	labels       []int
+	featureNames []string
+	labelNames   []string
+	classIndex   map[string]int
+
+	pending []string // First record when it was read to find the width
+	line    int
+}
+
+// NewCSVReader reads the header, if any, and resolves the columns of cfg
+func NewCSVReader(r io.Reader, cfg CSVConfig) (*CSVReader, error) {
+	reader, err := newCSVReader(r, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.OneHot && cfg.Classes == nil {
+		return nil, fmt.Errorf("streaming one-hot labels requires Classes")
+	}
+	return reader, reader.setClasses(cfg.Classes)
+}
+
+// newCSVReader is NewCSVReader without the class check, for ReadCSV
+func newCSVReader(r io.Reader, cfg CSVConfig) (*CSVReader, error) {
+	if cfg.Comma == 0 {
+		cfg.Comma = ','
+	}
+	if cfg.MissingValues == nil {
+		cfg.MissingValues = defaultMissingValues
+	}
+	if cfg.OneHot && len(cfg.Labels) != 1 {
+		return nil, fmt.Errorf("one-hot encoding needs exactly one label column, got %d", len(cfg.Labels))
+	}
+
+	c := &CSVReader{cfg: cfg, reader: csv.NewReader(r), missing: map[string]bool{"": true}}
+	c.reader.Comma = cfg.Comma
+	c.reader.Comment = cfg.Comment
+	c.reader.ReuseRecord = true
+	for _, v := range cfg.MissingValues {
+		c.missing[v] = true
+	}
+
+	first, err := c.reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("empty CSV input")
+		}
+		return nil, err
+	}
+	c.line, _ = c.reader.FieldPos(0)
+
+	var header []string
+	if cfg.Header {
+		header = make([]string, len(first))
+		for i, name := range first {
+			header[i] = strings.TrimSpace(name)
+		}
+	} else {
+		c.pending = append([]string(nil), first...)
+	}
+
+	return c, c.resolveColumns(header, len(first))
+}
+
+// resolveColumns turns the selected columns into indices and names
+func (c *CSVReader) resolveColumns(header []string, width int) error {
+	resolve := func(col Column) (int, error) {
+		if col.Name != "" {
+			for i, name := range header {
+				if name == col.Name {
+					return i, nil
+				}
+			}
+			if header == nil {
+				return 0, fmt.Errorf("column %q selected by name but the file has no header", col.Name)
+			}
+			return 0, fmt.Errorf("no column named %q", col.Name)
+		}
+		if col.Index < 0 || col.Index >= width {
+			return 0, fmt.Errorf("column index %d out of range [0, %d)", col.Index, width)
+		}
+		return col.Index, nil
+	}
+	name := func(i int) string {
+		if header != nil {
+			return header[i]
+		}
+		return strconv.Itoa(i)
+	}
+
+	isLabel := make(map[int]bool)
+	for _, col := range c.cfg.Labels {
+		i, err := resolve(col)
+		if err != nil {
+			return err
+		}
+		isLabel[i] = true
+		c.labels = append(c.labels, i)
+		c.labelNames = append(c.labelNames, name(i))
+	}
+
+	if c.cfg.Features == nil {
+		for i := 0; i < width; i++ {
+			if !isLabel[i] {
+				c.features = append(c.features, i)
+				c.featureNames = append(c.featureNames, name(i))
+			}
+		}
+		return nil
+	}
+	for _, col := range c.cfg.Features {
+		i, err := resolve(col)
+		if err != nil {
+			return err
+		}
+		c.features = append(c.features, i)
+		c.featureNames = append(c.featureNames, name(i))
+	}
+	return nil
+}
+
+// setClasses fixes the order of one-hot classes
+func (c *CSVReader) setClasses(classes []string) error {
+	if !c.cfg.OneHot {
+		return nil
+	}
+	c.classIndex = make(map[string]int, len(classes))
+	for k, class := range classes {
+		if _, ok := c.classIndex[class]; ok {
+			return fmt.Errorf("duplicate class %q", class)
+		}
+		c.classIndex[class] = k
+	}
+	c.labelNames = append([]string(nil), classes...)
+	return nil
+}
+
+// FeatureNames returns the names of the feature columns, or their indices
+// without a header
+func (c *CSVReader) FeatureNames() []string {
+	return c.featureNames
+}
+
+// LabelNames returns the names of the label columns, or the classes for
+// one-hot labels
+func (c *CSVReader) LabelNames() []string {
+	return c.labelNames
+}
+
+// parseField converts a numeric field, applying the missing-value policy.
+// It reports false when the row must be dropped.
+func (c *CSVReader) parseField(field string, col int) (float64, bool, error) {
+	field = strings.TrimSpace(field)
+	if c.missing[field] {
+		switch c.cfg.Missing {
+		case MissingDrop:
+			return 0, false, nil
+		case MissingFill:
+			return c.cfg.FillValue, true, nil
+		case MissingNaN:
+			return math.NaN(), true, nil
+		default:
+			return 0, false, fmt.Errorf("line %d: missing value in column %d", c.line, col)
+		}
+	}
+	v, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("line %d: column %d: cannot parse %q as a number", c.line, col, field)
+	}
+	return v, true, nil
+}
+
+// nextRecord parses the next kept row into features and either numeric
+// targets or, for one-hot labels, the raw class
+func (c *CSVReader) nextRecord() ([]float64, []float64, string, error) {
+	for {
+		record := c.pending
+		c.pending = nil
+		if record == nil {
+			var err error
+			if record, err = c.reader.Read(); err != nil {
+				return nil, nil, "", err
+			}
+			c.line, _ = c.reader.FieldPos(0)
+		}
+
+		keep := true
+		features := make([]float64, len(c.features))
+		for k, col := range c.features {
+			v, ok, err := c.parseField(record[col], col)
+			if err != nil {
+				return nil, nil, "", err
+			}
+			keep = keep && ok
+			features[k] = v
+		}
+
+		if c.cfg.OneHot {
+			class := strings.TrimSpace(record[c.labels[0]])
+			if c.missing[class] {
+				if c.cfg.Missing != MissingDrop {
+					return nil, nil, "", fmt.Errorf("line %d: missing label", c.line)
+				}
+				keep = false
+			}
+			if keep {
+				return features, nil, class, nil
+			}
+			continue
+		}
+
+		targets := make([]float64, len(c.labels))
+		for k, col := range c.labels {
+			v, ok, err := c.parseField(record[col], col)
+			if err != nil {
+				return nil, nil, "", err
+			}
+			keep = keep && ok
+			targets[k] = v
+		}
+		if keep {
+			return features, targets, "", nil
+		}
+	}
+}
+
+// oneHot encodes a class as a one-hot row
+func (c *CSVReader) oneHot(class string) ([]float64, error) {
+	k, ok := c.classIndex[class]
+	if !ok {
+		return nil, fmt.Errorf("line %d: unknown class %q", c.line, class)
+	}
+	target := make([]float64, len(c.classIndex))
+	target[k] = 1
+	return target, nil
+}
+
+// Next returns the next sample, or io.EOF after the last one
+func (c *CSVReader) Next() (Sample, error) {
+	features, targets, class, err := c.nextRecord()
+	if err != nil {
+		return Sample{}, err
+	}
+	if c.cfg.OneHot {
+		if targets, err = c.oneHot(class); err != nil {
+			return Sample{}, err
+		}
+	}
+	return Sample{Features: features, Target: targets}, nil
+}
+
+// ReadBatch returns the next batch of up to n samples, or io.EOF when no
+// samples are left
+func (c *CSVReader) ReadBatch(n int) (*Batch, error) {
+	samples := make([]Sample, 0, n)
+	for len(samples) < n {
+		sample, err := c.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		return nil, io.EOF
+	}
+	return DefaultCollate(samples)
+}
+
+// CSVData is a CSV file loaded into memory
+type CSVData struct {
+	X            *Matrix
+	Y            *Matrix
+	FeatureNames []string
+	LabelNames   []string // Label columns, or the classes for one-hot labels
+}
+
+// Dataset returns the rows of X and Y as a Dataset, e.g. for a DataLoader.
+// It shares the matrices of d.
+func (d *CSVData) Dataset() *MatrixDataset {
+	return &MatrixDataset{X: d.X, Y: d.Y}
+}
+
+// ReadCSV reads a whole CSV or TSV file into feature and label matrices
+func ReadCSV(r io.Reader, cfg CSVConfig) (*CSVData, error) {
+	c, err := newCSVReader(r, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var features, targets [][]float64
+	var classes []string
+	for {
+		x, t, class, err := c.nextRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, x)
+		targets = append(targets, t)
+		classes = append(classes, class)
+	}
+
+	if cfg.OneHot {
+		order := cfg.Classes
+		if order == nil {
+			order = sortedClasses(classes)
+		}
+		if err := c.setClasses(order); err != nil {
+			return nil, err
+		}
+		for i, class := range classes {
+			if targets[i], err = c.oneHot(class); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	data := &CSVData{
+		X:            &Matrix{Rows: len(features), Cols: len(c.features), Data: features},
+		Y:            &Matrix{Rows: len(targets), Cols: len(c.labelNames), Data: targets},
+		FeatureNames: c.FeatureNames(),
+		LabelNames:   c.LabelNames(),
+	}
+	if data.X.Data == nil {
+		data.X.Data, data.Y.Data = [][]float64{}, [][]float64{}
+	}
+	return data, nil
+}
+
+// sortedClasses returns the distinct classes, in numeric order when they
+// are all numbers and in lexical order otherwise
+func sortedClasses(classes []string) []string {
+	seen := make(map[string]bool)
+	var distinct []string
+	numeric := true
+	for _, class := range classes {
+		if !seen[class] {
+			seen[class] = true
+			distinct = append(distinct, class)
+			if _, err := strconv.ParseFloat(class, 64); err != nil {
+				numeric = false
+			}
+		}
+	}
+
+	sort.Slice(distinct, func(i, j int) bool {
+		if numeric {
+			a, _ := strconv.ParseFloat(distinct[i], 64)
+			b, _ := strconv.ParseFloat(distinct[j], 64)
+			return a < b
+		}
+		return distinct[i] < distinct[j]
+	})
+	return distinct
+}
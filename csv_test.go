@@ -0,0 +1,104 @@
+package nn
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+const irisCSV = `sepal,petal,species
+5.1,1.4,setosa
+NA,4.7,versicolor
+6.3,6.0,virginica
+4.9,1.5,setosa
+`
+
+func TestReadCSV(t *testing.T) {
+	data, err := ReadCSV(strings.NewReader(irisCSV), CSVConfig{
+		Header:  true,
+		Labels:  []Column{ColumnName("species")},
+		OneHot:  true,
+		Missing: MissingDrop,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "X", data.X, [][]float64{{5.1, 1.4}, {6.3, 6.0}, {4.9, 1.5}}, 0)
+	// Classes are sorted; the dropped row's class is not among them
+	assertMatrix(t, "Y", data.Y, [][]float64{{1, 0}, {0, 1}, {1, 0}}, 0)
+	if got := fmt.Sprint(data.FeatureNames, data.LabelNames); got != "[sepal petal] [setosa virginica]" {
+		t.Errorf("names %s", got)
+	}
+
+	filled, err := ReadCSV(strings.NewReader(irisCSV), CSVConfig{
+		Header: true, Features: []Column{ColumnIndex(0)}, Labels: []Column{ColumnIndex(1)}, Missing: MissingNaN,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(filled.X.Data[1][0]) || filled.Y.Data[1][0] != 4.7 {
+		t.Errorf("row 1 is %v -> %v, expected NaN -> 4.7", filled.X.Data[1], filled.Y.Data[1])
+	}
+
+	if _, err := ReadCSV(strings.NewReader(irisCSV), CSVConfig{Header: true, Labels: []Column{ColumnName("species")}}); err == nil {
+		t.Error("expected an error for a missing value and a non-numeric label")
+	}
+}
+
+func TestReadCSVUnlabelled(t *testing.T) {
+	input := "1,2,3\n4,5,6\n"
+	data, err := ReadCSV(strings.NewReader(input), CSVConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "X", data.X, [][]float64{{1, 2, 3}, {4, 5, 6}}, 0)
+	if data.Y.Rows != 2 || data.Y.Cols != 0 || len(data.LabelNames) != 0 {
+		t.Errorf("Y is %dx%d with labels %v, expected 2x0 without labels", data.Y.Rows, data.Y.Cols, data.LabelNames)
+	}
+
+	reader, err := NewCSVReader(strings.NewReader(input), CSVConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := reader.ReadBatch(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.X.Rows != 2 || batch.X.Cols != 3 || batch.Y.Cols != 0 {
+		t.Errorf("batch X %dx%d, Y %dx%d", batch.X.Rows, batch.X.Cols, batch.Y.Rows, batch.Y.Cols)
+	}
+	if _, err := reader.ReadBatch(5); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	if _, err := ReadCSV(strings.NewReader(input), CSVConfig{OneHot: true}); err == nil {
+		t.Error("expected an error for one-hot labels without a label column")
+	}
+}
+
+func TestCSVDataset(t *testing.T) {
+	data, err := ReadCSV(strings.NewReader("x,y\n1,10\n2,20\n3,30\n"), CSVConfig{Header: true, Labels: []Column{ColumnName("y")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataset := data.Dataset()
+	if dataset.Len() != 3 {
+		t.Fatalf("dataset has %d samples, expected 3", dataset.Len())
+	}
+
+	var rows [][]float64
+	err = NewDataLoader(dataset, DataLoaderConfig{BatchSize: 2}).ForEach(func(batch *Batch) error {
+		for i := range batch.X.Data {
+			rows = append(rows, []float64{batch.X.Data[i][0], batch.Y.Data[i][0]})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(rows) != "[[1 10] [2 20] [3 30]]" {
+		t.Errorf("loaded %v", rows)
+	}
+}
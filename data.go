@@ -0,0 +1,357 @@
+package nn
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Sample is one example of a dataset: a flattened feature row and its
+// target row
+type Sample struct {
+	Features []float64
+	Target   []float64
+}
+
+// Dataset is an indexed collection of samples. Implementations may read
+// samples lazily, e.g. from disk, so a dataset does not need to fit in
+// memory. Get must be safe for concurrent use when a DataLoader has more
+// than one worker.
+type Dataset interface {
+	Len() int
+	Get(i int) (Sample, error)
+}
+
+// MatrixDataset is a Dataset over the rows of in-memory matrices
+type MatrixDataset struct {
+	X *Matrix
+	Y *Matrix
+}
+
+// NewMatrixDataset creates a dataset whose sample i is row i of X and y
+func NewMatrixDataset(X, y *Matrix) (*MatrixDataset, error) {
+	if X.Rows != y.Rows {
+		return nil, fmt.Errorf("X has %d rows but y has %d", X.Rows, y.Rows)
+	}
+	return &MatrixDataset{X: X, Y: y}, nil
+}
+
+// Len returns the number of rows
+func (d *MatrixDataset) Len() int {
+	return d.X.Rows
+}
+
+// Get returns row i, sharing its data with the matrices
+func (d *MatrixDataset) Get(i int) (Sample, error) {
+	if i < 0 || i >= d.X.Rows {
+		return Sample{}, fmt.Errorf("index %d out of range [0, %d)", i, d.X.Rows)
+	}
+	return Sample{Features: d.X.Data[i], Target: d.Y.Data[i]}, nil
+}
+
+// Batch is a collated group of samples
+type Batch struct {
+	X *Matrix
+	Y *Matrix
+}
+
+// CollateFunc turns the samples of a batch into matrices
+type CollateFunc func(samples []Sample) (*Batch, error)
+
+// DefaultCollate copies the features and targets of the samples into the
+// rows of X and Y. All samples must have the same sizes.
+func DefaultCollate(samples []Sample) (*Batch, error) {
+	if len(samples) == 0 {
+		return &Batch{X: NewMatrix(0, 0), Y: NewMatrix(0, 0)}, nil
+	}
+
+	features, targets := len(samples[0].Features), len(samples[0].Target)
+	batch := &Batch{X: NewMatrix(len(samples), features), Y: NewMatrix(len(samples), targets)}
+	for i, s := range samples {
+		if len(s.Features) != features || len(s.Target) != targets {
+			return nil, fmt.Errorf("sample %d has %d features and %d targets, expected %d and %d",
+				i, len(s.Features), len(s.Target), features, targets)
+		}
+		copy(batch.X.Data[i], s.Features)
+		copy(batch.Y.Data[i], s.Target)
+	}
+	return batch, nil
+}
+
+// DataLoaderConfig configures a DataLoader. Zero values select the
+// defaults: batches of 32, no shuffling, DefaultCollate, one worker and
+// two prefetched batches.
+type DataLoaderConfig struct {
+	BatchSize int
+	Shuffle   bool        // Draw a new sample order every epoch
+	DropLast  bool        // Skip the last batch when it is incomplete
+	Collate   CollateFunc // Builds a batch from its samples
+	Workers   int         // Goroutines loading batches; negative loads on the caller's goroutine
+	Prefetch  int         // Batches loaded ahead of the consumer
+	Seed      int64       // Seed of the shuffling; 0 uses the global math/rand source
+}
+
+// withDefaults replaces zero values by their defaults
+func (cfg DataLoaderConfig) withDefaults() DataLoaderConfig {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 32
+	}
+	if cfg.Collate == nil {
+		cfg.Collate = DefaultCollate
+	}
+	if cfg.Workers == 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Prefetch <= 0 {
+		cfg.Prefetch = 2
+	}
+	return cfg
+}
+
+// DataLoader splits a Dataset into batches, loading them on a pool of
+// goroutines ahead of the training loop. Batches are always delivered in
+// order.
+type DataLoader struct {
+	DataLoaderConfig
+	Dataset Dataset
+
+	rng   *rand.Rand
+	epoch int
+}
+
+// NewDataLoader creates a loader over dataset
+func NewDataLoader(dataset Dataset, cfg DataLoaderConfig) *DataLoader {
+	loader := &DataLoader{DataLoaderConfig: cfg.withDefaults(), Dataset: dataset}
+	if cfg.Seed != 0 {
+		loader.rng = rand.New(rand.NewSource(cfg.Seed))
+	}
+	return loader
+}
+
+// NumBatches returns the number of batches of an epoch
+func (l *DataLoader) NumBatches() int {
+	n := l.Dataset.Len()
+	if l.DropLast {
+		return n / l.BatchSize
+	}
+	return (n + l.BatchSize - 1) / l.BatchSize
+}
+
+// order returns the sample indices of the next epoch
+func (l *DataLoader) order() []int {
+	n := l.Dataset.Len()
+	if !l.Shuffle {
+		indices := make([]int, n)
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+	if l.rng != nil {
+		return l.rng.Perm(n)
+	}
+	return rand.Perm(n)
+}
+
+// load reads and collates the samples at indices
+func (l *DataLoader) load(indices []int) (*Batch, error) {
+	samples := make([]Sample, len(indices))
+	for k, i := range indices {
+		sample, err := l.Dataset.Get(i)
+		if err != nil {
+			return nil, fmt.Errorf("loading sample %d: %v", i, err)
+		}
+		samples[k] = sample
+	}
+	return l.Collate(samples)
+}
+
+// loadResult is a loaded batch or the error that prevented it
+type loadResult struct {
+	batch *Batch
+	err   error
+}
+
+// loadJob asks a worker to load one batch into its slot
+type loadJob struct {
+	indices []int
+	slot    chan loadResult
+}
+
+// ForEach runs one epoch, calling fn with every batch in order. It stops
+// at the first error of fn or of the loading and returns it; all loading
+// goroutines have exited when it returns. A dataset implementing
+// EpochSetter is told the epoch number first.
+func (l *DataLoader) ForEach(fn func(batch *Batch) error) error {
+	if setter, ok := l.Dataset.(EpochSetter); ok {
+		setter.SetEpoch(l.epoch)
+	}
+	l.epoch++
+
+	order := l.order()
+	numBatches := l.NumBatches()
+	batchIndices := func(b int) []int {
+		end := (b + 1) * l.BatchSize
+		if end > len(order) {
+			end = len(order)
+		}
+		return order[b*l.BatchSize : end]
+	}
+
+	if l.Workers < 0 {
+		for b := 0; b < numBatches; b++ {
+			batch, err := l.load(batchIndices(b))
+			if err != nil {
+				return err
+			}
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	jobs := make(chan loadJob)
+	// Slots are queued in batch order; the capacity bounds the prefetching
+	slots := make(chan chan loadResult, l.Prefetch)
+
+	var wg sync.WaitGroup
+	wg.Add(l.Workers + 1)
+	go func() {
+		defer wg.Done()
+		defer close(slots)
+		defer close(jobs)
+		for b := 0; b < numBatches; b++ {
+			slot := make(chan loadResult, 1)
+			select {
+			case slots <- slot:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- loadJob{indices: batchIndices(b), slot: slot}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for w := 0; w < l.Workers; w++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				batch, err := l.load(job.indices)
+				job.slot <- loadResult{batch: batch, err: err}
+			}
+		}()
+	}
+
+	var err error
+	for slot := range slots {
+		result := <-slot
+		if err = result.err; err == nil {
+			err = fn(result.batch)
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+	return err
+}
+
+// asMatrixOf returns m as a matrix of element type T, converting it only
+// when T is not float64
+func asMatrixOf[T Float](m *Matrix) *MatrixOf[T] {
+	if same, ok := any(m).(*MatrixOf[T]); ok {
+		return same
+	}
+	return ConvertMatrix[T](m)
+}
+
+// FitLoader trains the model for multiple epochs on the batches of loader
+// and leaves it in inference mode, appending a report per epoch to History
+func (s *SequentialOf[T]) FitLoader(loader *DataLoader, epochs int, verbose bool) error {
+	defer s.Eval()
+
+	for epoch := 0; epoch < epochs; epoch++ {
+		s.resetMetrics()
+		totalLoss := 0.0
+		numBatches := 0
+
+		err := loader.ForEach(func(batch *Batch) error {
+			loss, err := s.TrainOnBatch(asMatrixOf[T](batch.X), asMatrixOf[T](batch.Y))
+			if err != nil {
+				return err
+			}
+			totalLoss += loss
+			numBatches++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if numBatches == 0 {
+			continue
+		}
+		report := s.report(totalLoss / float64(numBatches))
+		s.History = append(s.History, report)
+		if verbose {
+			fmt.Printf("Epoch %d/%d - %s\n", epoch+1, epochs, report)
+		}
+	}
+
+	return nil
+}
+
+// EvaluateLoader computes the loss over all batches of loader as if they
+// were one batch: the per-sample losses are added up and, with
+// ReductionMean, divided by the number of samples
+func (s *SequentialOf[T]) EvaluateLoader(loader *DataLoader) (float64, error) {
+	report, err := s.EvaluateLoaderReport(loader)
+	if err != nil {
+		return 0, err
+	}
+	return report.Loss, nil
+}
+
+// EvaluateLoaderReport computes the loss, reduced like EvaluateLoader, and
+// the metrics over all batches of loader
+func (s *SequentialOf[T]) EvaluateLoaderReport(loader *DataLoader) (*Report, error) {
+	totalLoss := 0.0
+	samples := 0
+
+	s.resetMetrics()
+	err := loader.ForEach(func(batch *Batch) error {
+		y := asMatrixOf[T](batch.Y)
+		predictions, err := s.Predict(asMatrixOf[T](batch.X))
+		if err != nil {
+			return err
+		}
+		perSample, err := s.Loss.PerSample(predictions, y)
+		if err != nil {
+			return err
+		}
+		if err := s.updateMetrics(predictions, y); err != nil {
+			return err
+		}
+		for i := 0; i < perSample.Rows; i++ {
+			totalLoss += float64(perSample.Data[i][0])
+		}
+		samples += batch.X.Rows
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if samples == 0 {
+		return nil, fmt.Errorf("loader produced no samples")
+	}
+
+	if reductionOf(s.Loss) == ReductionMean {
+		totalLoss /= float64(samples)
+	}
+	return s.report(totalLoss), nil
+}
@@ -0,0 +1,179 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvaluateLoaderReduction(t *testing.T) {
+	rng := rand.New(rand.NewSource(24))
+	X, y := uniformMatrix(rng, 7, 3, -1, 1), uniformMatrix(rng, 7, 2, -1, 1)
+	dataset, err := NewMatrixDataset(X, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reduction := range []Reduction{ReductionMean, ReductionSum, ReductionNone} {
+		model := NewSequential()
+		model.Add(NewDense(3, 2))
+		randomizeParams(rng, model.Layers[0])
+		loss := NewMSE()
+		loss.Reduction = reduction
+		model.Compile(loss, NewSGD(0.1, 0))
+
+		// Batches of 3, 3 and 1 samples must give the loss of one batch
+		want, err := model.Evaluate(X, y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := model.EvaluateLoader(NewDataLoader(dataset, DataLoaderConfig{BatchSize: 3}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-want) > 1e-12 {
+			t.Errorf("reduction %d: loader loss %v, expected %v", reduction, got, want)
+		}
+	}
+}
+
+// sequenceDataset returns sample i as features {i} and target {2i}. Get
+// sleeps longer for earlier samples so that workers finish out of order,
+// and fails at index failAt when it is not negative.
+type sequenceDataset struct {
+	n, failAt int
+
+	mu    sync.Mutex
+	loads int
+}
+
+func (d *sequenceDataset) Len() int {
+	return d.n
+}
+
+func (d *sequenceDataset) Get(i int) (Sample, error) {
+	d.mu.Lock()
+	d.loads++
+	d.mu.Unlock()
+	time.Sleep(time.Duration(d.n-i) * 20 * time.Microsecond)
+	if i == d.failAt {
+		return Sample{}, fmt.Errorf("corrupt record")
+	}
+	return Sample{Features: []float64{float64(i)}, Target: []float64{float64(2 * i)}}, nil
+}
+
+func (d *sequenceDataset) loadCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.loads
+}
+
+func TestDataLoaderOrder(t *testing.T) {
+	for _, workers := range []int{-1, 1, 4} {
+		dataset := &sequenceDataset{n: 50, failAt: -1}
+		loader := NewDataLoader(dataset, DataLoaderConfig{BatchSize: 4, Workers: workers, Prefetch: 3})
+
+		next := 0
+		err := loader.ForEach(func(batch *Batch) error {
+			for i := range batch.X.Data {
+				if batch.X.Data[i][0] != float64(next) || batch.Y.Data[i][0] != float64(2*next) {
+					return fmt.Errorf("got sample %v, expected %d", batch.X.Data[i][0], next)
+				}
+				next++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%d workers: %v", workers, err)
+		}
+		if next != 50 {
+			t.Errorf("%d workers: delivered %d samples, expected 50", workers, next)
+		}
+	}
+
+	// A seeded shuffle delivers every sample once, in the same order for
+	// loaders with the same seed
+	orders := make([][]float64, 2)
+	for k := range orders {
+		loader := NewDataLoader(&sequenceDataset{n: 30, failAt: -1},
+			DataLoaderConfig{BatchSize: 7, Shuffle: true, Seed: 3, Workers: 3})
+		err := loader.ForEach(func(batch *Batch) error {
+			for _, row := range batch.X.Data {
+				orders[k] = append(orders[k], row[0])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := make(map[float64]bool)
+	for i, v := range orders[0] {
+		seen[v] = true
+		if orders[1][i] != v {
+			t.Fatalf("shuffled orders differ at %d", i)
+		}
+	}
+	if len(seen) != 30 {
+		t.Errorf("shuffle delivered %d distinct samples, expected 30", len(seen))
+	}
+}
+
+func TestDataLoaderLoadError(t *testing.T) {
+	for _, workers := range []int{-1, 1, 4} {
+		loader := NewDataLoader(&sequenceDataset{n: 40, failAt: 13}, DataLoaderConfig{BatchSize: 4, Workers: workers})
+
+		batches := 0
+		err := loader.ForEach(func(batch *Batch) error {
+			batches++
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "loading sample 13") {
+			t.Errorf("%d workers: expected the error of sample 13, got %v", workers, err)
+		}
+		// Sample 13 is in batch 3; the batches before it are delivered
+		if batches != 3 {
+			t.Errorf("%d workers: delivered %d batches before the error, expected 3", workers, batches)
+		}
+	}
+}
+
+func TestDataLoaderEarlyStop(t *testing.T) {
+	const batchSize, workers, prefetch = 2, 3, 2
+	dataset := &sequenceDataset{n: 200, failAt: -1}
+	loader := NewDataLoader(dataset, DataLoaderConfig{BatchSize: batchSize, Workers: workers, Prefetch: prefetch})
+	goroutines := runtime.NumGoroutine()
+
+	stop := errors.New("stop")
+	batches := 0
+	err := loader.ForEach(func(batch *Batch) error {
+		batches++
+		if batches == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("ForEach returned %v, expected the error of fn", err)
+	}
+
+	// Loading stops within the prefetch window and every goroutine exits
+	// before ForEach returns
+	loads := dataset.loadCount()
+	if limit := (batches + prefetch + workers + 1) * batchSize; loads > limit {
+		t.Errorf("loaded %d samples after stopping at %d, expected at most %d", loads, batches*batchSize, limit)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if dataset.loadCount() != loads {
+		t.Error("samples were loaded after ForEach returned")
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("%d goroutines running after ForEach, %d before", n, goroutines)
+	}
+}
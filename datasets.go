@@ -0,0 +1,295 @@
+package nn
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// IDXArray is an array read from an IDX file, the format of MNIST
+type IDXArray struct {
+	Dims []int
+	Data []float64 // Row-major values converted to float64
+}
+
+// idxTypes maps IDX type codes to their element size
+var idxTypes = map[byte]int{
+	0x08: 1, // unsigned byte
+	0x09: 1, // signed byte
+	0x0B: 2, // short
+	0x0C: 4, // int
+	0x0D: 4, // float
+	0x0E: 8, // double
+}
+
+// maxIDXValues bounds the number of values ReadIDX accepts, 2 GiB once
+// converted to float64, so that a corrupt header cannot trigger a huge
+// allocation
+const maxIDXValues = 1 << 28
+
+// maybeGzip returns a reader decompressing r when it starts with the gzip
+// magic number and reading it unchanged otherwise
+func maybeGzip(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// ReadIDX reads an IDX file, gzipped or not. Files with more than 2^28
+// values are rejected.
+func ReadIDX(r io.Reader) (*IDXArray, error) {
+	r, err := maybeGzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading IDX header: %v", err)
+	}
+	size, ok := idxTypes[magic[2]]
+	if magic[0] != 0 || magic[1] != 0 || !ok || magic[3] == 0 {
+		return nil, fmt.Errorf("not an IDX file: magic % x", magic)
+	}
+
+	array := &IDXArray{Dims: make([]int, magic[3])}
+	count := 1
+	for i := range array.Dims {
+		var dim uint32
+		if err := binary.Read(r, binary.BigEndian, &dim); err != nil {
+			return nil, fmt.Errorf("reading IDX dimensions: %v", err)
+		}
+		array.Dims[i] = int(dim)
+		if dim != 0 && count > maxIDXValues/int(dim) {
+			return nil, fmt.Errorf("IDX dimensions %v hold more than %d values", array.Dims[:i+1], maxIDXValues)
+		}
+		count *= int(dim)
+	}
+
+	raw := make([]byte, count*size)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("reading IDX data: expected %d values: %v", count, err)
+	}
+
+	array.Data = make([]float64, count)
+	for i := range array.Data {
+		b := raw[i*size : (i+1)*size]
+		switch magic[2] {
+		case 0x08:
+			array.Data[i] = float64(b[0])
+		case 0x09:
+			array.Data[i] = float64(int8(b[0]))
+		case 0x0B:
+			array.Data[i] = float64(int16(binary.BigEndian.Uint16(b)))
+		case 0x0C:
+			array.Data[i] = float64(int32(binary.BigEndian.Uint32(b)))
+		case 0x0D:
+			array.Data[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		case 0x0E:
+			array.Data[i] = math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	}
+	return array, nil
+}
+
+// oneHotLabels encodes integer labels as rows of a (len(labels), classes)
+// matrix
+func oneHotLabels(labels []int, classes int) (*Matrix, error) {
+	m := NewMatrix(len(labels), classes)
+	for i, label := range labels {
+		if label < 0 || label >= classes {
+			return nil, fmt.Errorf("label %d of sample %d out of range [0, %d)", label, i, classes)
+		}
+		m.Data[i][label] = 1
+	}
+	return m, nil
+}
+
+// ReadMNISTImages reads an IDX image file of shape (N, H, W) into an
+// (N, 1, H, W) batch with pixels scaled to [0, 1]
+func ReadMNISTImages(r io.Reader) (*Tensor4D, error) {
+	array, err := ReadIDX(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(array.Dims) != 3 {
+		return nil, fmt.Errorf("MNIST images must have 3 dimensions, got %d", len(array.Dims))
+	}
+
+	n, h, w := array.Dims[0], array.Dims[1], array.Dims[2]
+	images := NewTensor4D(n, 1, h, w)
+	for i := 0; i < n; i++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				images.Data[i][0][y][x] = array.Data[(i*h+y)*w+x] / 255
+			}
+		}
+	}
+	return images, nil
+}
+
+// ReadMNISTLabels reads an IDX label file into a one-hot (N, 10) matrix
+func ReadMNISTLabels(r io.Reader) (*Matrix, error) {
+	array, err := ReadIDX(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(array.Dims) != 1 {
+		return nil, fmt.Errorf("MNIST labels must have 1 dimension, got %d", len(array.Dims))
+	}
+
+	labels := make([]int, len(array.Data))
+	for i, v := range array.Data {
+		labels[i] = int(v)
+	}
+	return oneHotLabels(labels, 10)
+}
+
+// LoadMNIST reads an image file and a label file such as
+// train-images-idx3-ubyte.gz and train-labels-idx1-ubyte.gz
+func LoadMNIST(imagesPath, labelsPath string) (*Tensor4D, *Matrix, error) {
+	imagesFile, err := os.Open(imagesPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer imagesFile.Close()
+	images, err := ReadMNISTImages(imagesFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", imagesPath, err)
+	}
+
+	labelsFile, err := os.Open(labelsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer labelsFile.Close()
+	labels, err := ReadMNISTLabels(labelsFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", labelsPath, err)
+	}
+
+	if images.Batch != labels.Rows {
+		return nil, nil, fmt.Errorf("%d images but %d labels", images.Batch, labels.Rows)
+	}
+	return images, labels, nil
+}
+
+// CIFARKind selects the CIFAR binary format and which label to use
+type CIFARKind int
+
+const (
+	// CIFAR10 records hold one label byte for 10 classes
+	CIFAR10 CIFARKind = iota
+	// CIFAR100Fine records hold a coarse and a fine label byte; this uses
+	// the 100 fine classes
+	CIFAR100Fine
+	// CIFAR100Coarse uses the 20 coarse classes of CIFAR-100 records
+	CIFAR100Coarse
+)
+
+// CIFAR images are 32x32 RGB stored channel by channel
+const (
+	cifarSide   = 32
+	cifarPixels = 3 * cifarSide * cifarSide
+)
+
+// layout returns the number of label bytes, the index of the used label
+// and the number of classes
+func (k CIFARKind) layout() (int, int, int) {
+	switch k {
+	case CIFAR100Fine:
+		return 2, 1, 100
+	case CIFAR100Coarse:
+		return 2, 0, 20
+	default:
+		return 1, 0, 10
+	}
+}
+
+// ReadCIFAR reads a CIFAR binary batch file into an (N, 3, 32, 32) batch
+// with pixels scaled to [0, 1] and one-hot labels
+func ReadCIFAR(r io.Reader, kind CIFARKind) (*Tensor4D, *Matrix, error) {
+	r, err := maybeGzip(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	labelBytes, labelIndex, classes := kind.layout()
+	record := make([]byte, labelBytes+cifarPixels)
+
+	var samples []*Tensor3D
+	var labels []int
+	for {
+		_, err := io.ReadFull(r, record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("record %d is truncated: %v", len(samples), err)
+		}
+
+		labels = append(labels, int(record[labelIndex]))
+		image := NewTensor3D(3, cifarSide, cifarSide)
+		pixels := record[labelBytes:]
+		for c := 0; c < 3; c++ {
+			for y := 0; y < cifarSide; y++ {
+				for x := 0; x < cifarSide; x++ {
+					image.Data[c][y][x] = float64(pixels[(c*cifarSide+y)*cifarSide+x]) / 255
+				}
+			}
+		}
+		samples = append(samples, image)
+	}
+
+	onehot, err := oneHotLabels(labels, classes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(samples) == 0 {
+		return NewTensor4D(0, 3, cifarSide, cifarSide), onehot, nil
+	}
+	images, err := StackTensors(samples)
+	if err != nil {
+		return nil, nil, err
+	}
+	return images, onehot, nil
+}
+
+// LoadCIFAR reads and concatenates CIFAR binary batch files, such as
+// data_batch_1.bin to data_batch_5.bin
+func LoadCIFAR(kind CIFARKind, paths ...string) (*Tensor4D, *Matrix, error) {
+	var images *Tensor4D
+	var labels *Matrix
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		batch, batchLabels, err := ReadCIFAR(file, kind)
+		file.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		if images == nil {
+			images, labels = batch, batchLabels
+			continue
+		}
+		images.Data = append(images.Data, batch.Data...)
+		images.Batch += batch.Batch
+		labels.Data = append(labels.Data, batchLabels.Data...)
+		labels.Rows += batchLabels.Rows
+	}
+
+	if images == nil {
+		return nil, nil, fmt.Errorf("no CIFAR files given")
+	}
+	return images, labels, nil
+}
@@ -0,0 +1,192 @@
+package nn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// idxFile encodes an IDX file with the given type code, dimensions and raw
+// big-endian values
+func idxFile(typeCode byte, dims []uint32, values []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, typeCode, byte(len(dims))})
+	for _, d := range dims {
+		binary.Write(&buf, binary.BigEndian, d)
+	}
+	buf.Write(values)
+	return buf.Bytes()
+}
+
+// gzipped compresses data
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadIDX(t *testing.T) {
+	images := idxFile(0x08, []uint32{2, 1, 3}, []byte{0, 51, 255, 102, 153, 204})
+	for name, data := range map[string][]byte{"plain": images, "gzip": gzipped(t, images)} {
+		batch, err := ReadMNISTImages(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if batch.Batch != 2 || batch.Channels != 1 || batch.Height != 1 || batch.Width != 3 {
+			t.Fatalf("%s: shape (%d, %d, %d, %d)", name, batch.Batch, batch.Channels, batch.Height, batch.Width)
+		}
+		want := [][]float64{{0, 0.2, 1}, {0.4, 0.6, 0.8}}
+		for i := range want {
+			for x, v := range want[i] {
+				if math.Abs(batch.Data[i][0][0][x]-v) > 1e-12 {
+					t.Errorf("%s: pixel %d,%d = %v, expected %v", name, i, x, batch.Data[i][0][0][x], v)
+				}
+			}
+		}
+	}
+
+	var floats bytes.Buffer
+	binary.Write(&floats, binary.BigEndian, []float32{1.5, -2})
+	typed := map[string]struct {
+		data []byte
+		want []float64
+	}{
+		"signed": {idxFile(0x09, []uint32{2}, []byte{0xff, 0x7f}), []float64{-1, 127}},
+		"short":  {idxFile(0x0B, []uint32{2}, []byte{0xff, 0xfe, 0x01, 0x00}), []float64{-2, 256}},
+		"float":  {idxFile(0x0D, []uint32{2}, floats.Bytes()), []float64{1.5, -2}},
+	}
+	for name, tt := range typed {
+		array, err := ReadIDX(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for i, v := range tt.want {
+			if array.Data[i] != v {
+				t.Errorf("%s: value %d = %v, expected %v", name, i, array.Data[i], v)
+			}
+		}
+	}
+
+	labels, err := ReadMNISTLabels(bytes.NewReader(idxFile(0x08, []uint32{2}, []byte{3, 9})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels.Rows != 2 || labels.Data[0][3] != 1 || labels.Data[1][9] != 1 {
+		t.Errorf("one-hot labels %v", labels.Data)
+	}
+	if _, err := ReadMNISTLabels(bytes.NewReader(idxFile(0x08, []uint32{1}, []byte{10}))); err == nil {
+		t.Error("expected an error for label 10")
+	}
+}
+
+func TestReadIDXErrors(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+		want string
+	}{
+		"bad magic":  {[]byte{1, 0, 0x08, 1, 0, 0, 0, 0}, "not an IDX file"},
+		"bad type":   {idxFile(0x07, []uint32{1}, []byte{0}), "not an IDX file"},
+		"truncated":  {idxFile(0x08, []uint32{2, 3}, []byte{1, 2, 3, 4}), "expected 6 values"},
+		"no dims":    {[]byte{0, 0, 0x08, 2, 0, 0, 0, 1}, "reading IDX dimensions"},
+		"too large":  {idxFile(0x08, []uint32{1 << 15, 1 << 15}, nil), "more than"},
+		"overflow":   {idxFile(0x0E, []uint32{math.MaxUint32, math.MaxUint32, math.MaxUint32, math.MaxUint32}, nil), "more than"},
+		"gzip error": {[]byte{0x1f, 0x8b, 0}, "EOF"},
+	}
+	for name, tt := range tests {
+		_, err := ReadIDX(bytes.NewReader(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tt.want, err)
+		}
+	}
+}
+
+// cifarRecord encodes one CIFAR record whose pixels all equal value
+func cifarRecord(labels []byte, value byte) []byte {
+	return append(append([]byte{}, labels...), bytes.Repeat([]byte{value}, cifarPixels)...)
+}
+
+func TestReadCIFAR(t *testing.T) {
+	cifar10 := append(cifarRecord([]byte{7}, 255), cifarRecord([]byte{2}, 51)...)
+	for name, data := range map[string][]byte{"plain": cifar10, "gzip": gzipped(t, cifar10)} {
+		images, labels, err := ReadCIFAR(bytes.NewReader(data), CIFAR10)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if images.Batch != 2 || images.Channels != 3 || images.Height != 32 || images.Width != 32 {
+			t.Fatalf("%s: shape (%d, %d, %d, %d)", name, images.Batch, images.Channels, images.Height, images.Width)
+		}
+		if images.Data[0][2][31][31] != 1 || math.Abs(images.Data[1][0][0][0]-0.2) > 1e-12 {
+			t.Errorf("%s: pixels %v and %v", name, images.Data[0][2][31][31], images.Data[1][0][0][0])
+		}
+		if labels.Cols != 10 || labels.Data[0][7] != 1 || labels.Data[1][2] != 1 {
+			t.Errorf("%s: labels %v", name, labels.Data)
+		}
+	}
+
+	// CIFAR-100 records hold the coarse label, then the fine label
+	cifar100 := cifarRecord([]byte{4, 85}, 0)
+	for kind, want := range map[CIFARKind][2]int{CIFAR100Fine: {100, 85}, CIFAR100Coarse: {20, 4}} {
+		_, labels, err := ReadCIFAR(bytes.NewReader(cifar100), kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if labels.Cols != want[0] || labels.Data[0][want[1]] != 1 {
+			t.Errorf("kind %d: expected class %d of %d", kind, want[1], want[0])
+		}
+	}
+
+	truncated := append(cifarRecord([]byte{1}, 0), cifarRecord([]byte{1}, 0)[:100]...)
+	if _, _, err := ReadCIFAR(bytes.NewReader(truncated), CIFAR10); err == nil || !strings.Contains(err.Error(), "record 1 is truncated") {
+		t.Errorf("expected a truncated record error, got %v", err)
+	}
+	if _, _, err := ReadCIFAR(bytes.NewReader(cifarRecord([]byte{10}, 0)), CIFAR10); err == nil {
+		t.Error("expected an error for label 10")
+	}
+}
+
+func TestLoadDatasetFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	imagesPath := write("images.gz", gzipped(t, idxFile(0x08, []uint32{2, 2, 2}, make([]byte, 8))))
+	labelsPath := write("labels", idxFile(0x08, []uint32{2}, []byte{0, 1}))
+	images, labels, err := LoadMNIST(imagesPath, labelsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images.Batch != 2 || labels.Rows != 2 {
+		t.Errorf("%d images and %d labels", images.Batch, labels.Rows)
+	}
+	shortLabels := write("short", idxFile(0x08, []uint32{1}, []byte{0}))
+	if _, _, err := LoadMNIST(imagesPath, shortLabels); err == nil {
+		t.Error("expected an error for 2 images and 1 label")
+	}
+
+	first := write("batch_1.bin", cifarRecord([]byte{1}, 0))
+	second := write("batch_2.bin", append(cifarRecord([]byte{2}, 0), cifarRecord([]byte{3}, 0)...))
+	cifar, cifarLabels, err := LoadCIFAR(CIFAR10, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cifar.Batch != 3 || cifarLabels.Rows != 3 || cifarLabels.Data[2][3] != 1 {
+		t.Errorf("concatenated %d images and %d labels", cifar.Batch, cifarLabels.Rows)
+	}
+}
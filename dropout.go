@@ -0,0 +1,314 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// TrainingSetter is implemented by layers that behave differently during
+// training and inference. Sequential.Train and Sequential.Eval propagate
+// the mode to every layer implementing it.
+type TrainingSetter interface {
+	SetTraining(training bool)
+}
+
+// Seeder is implemented by layers drawing random numbers during training.
+// SetSeed gives them their own source seeded with seed so runs can be
+// reproduced; a seed of 0 goes back to the global math/rand source.
+type Seeder interface {
+	SetSeed(seed int64)
+}
+
+// newRand returns a source seeded with seed, or nil to use the global
+// math/rand source when seed is 0
+func newRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		return nil
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// randFloat64 draws from rng, or from the global source when rng is nil
+func randFloat64(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
+
+// DropoutOf zeroes each activation with probability Rate during training
+// and scales the kept ones by 1/(1-Rate) (inverted dropout), so it is the
+// identity in inference mode
+type DropoutOf[T Float] struct {
+	Rate float64
+
+	training bool
+	rng      *rand.Rand
+	mask     *MatrixOf[T] // Scale factor per element: 0 or 1/(1-Rate)
+}
+
+// Dropout layer with float64 activations
+type Dropout = DropoutOf[float64]
+
+// NewDropout creates a new dropout layer
+func NewDropout(rate float64) *Dropout {
+	return NewDropoutOf[float64](rate)
+}
+
+// NewDropoutOf creates a new dropout layer with element type T
+func NewDropoutOf[T Float](rate float64) *DropoutOf[T] {
+	return &DropoutOf[T]{Rate: rate}
+}
+
+// SetTraining switches between training and inference behaviour
+func (d *DropoutOf[T]) SetTraining(training bool) {
+	d.training = training
+}
+
+// SetSeed makes the masks reproducible
+func (d *DropoutOf[T]) SetSeed(seed int64) {
+	d.rng = newRand(seed)
+}
+
+// Forward applies the dropout mask in training mode
+func (d *DropoutOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if d.Rate < 0 || d.Rate >= 1 {
+		return nil, fmt.Errorf("dropout rate must be in [0, 1), got %f", d.Rate)
+	}
+	if !d.training || d.Rate == 0 {
+		d.mask = nil
+		return input, nil
+	}
+
+	keep := 1 - d.Rate
+	scale := T(1 / keep)
+	d.mask = NewMatrixOf[T](input.Rows, input.Cols)
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+	for i := 0; i < input.Rows; i++ {
+		for j := 0; j < input.Cols; j++ {
+			if randFloat64(d.rng) < keep {
+				d.mask.Data[i][j] = scale
+				output.Data[i][j] = input.Data[i][j] / T(keep)
+			}
+		}
+	}
+	return output, nil
+}
+
+// Backward applies the same mask to the gradient
+func (d *DropoutOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return applyMask(d.mask, gradOutput)
+}
+
+// GetParams returns empty slice (no learnable parameters)
+func (d *DropoutOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetGrads returns empty slice
+func (d *DropoutOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetParamNames returns empty slice
+func (d *DropoutOf[T]) GetParamNames() []string {
+	return []string{}
+}
+
+// SELU constants used by AlphaDropout
+const (
+	seluAlpha = 1.6732632423543772
+	seluScale = 1.0507009873554805
+)
+
+// AlphaDropout layer for self-normalizing networks. Dropped activations are
+// set to the negative saturation value of SELU and the result is affinely
+// rescaled so that zero mean and unit variance are preserved.
+type AlphaDropoutOf[T Float] struct {
+	Rate float64
+
+	training bool
+	rng      *rand.Rand
+	mask     *MatrixOf[T] // Gradient factor per element: 0 or a
+}
+
+// AlphaDropout layer with float64 activations
+type AlphaDropout = AlphaDropoutOf[float64]
+
+// NewAlphaDropout creates a new alpha dropout layer
+func NewAlphaDropout(rate float64) *AlphaDropout {
+	return NewAlphaDropoutOf[float64](rate)
+}
+
+// NewAlphaDropoutOf creates a new alpha dropout layer with element type T
+func NewAlphaDropoutOf[T Float](rate float64) *AlphaDropoutOf[T] {
+	return &AlphaDropoutOf[T]{Rate: rate}
+}
+
+// SetTraining switches between training and inference behaviour
+func (d *AlphaDropoutOf[T]) SetTraining(training bool) {
+	d.training = training
+}
+
+// SetSeed makes the masks reproducible
+func (d *AlphaDropoutOf[T]) SetSeed(seed int64) {
+	d.rng = newRand(seed)
+}
+
+// Forward computes a*(x*m + α'*(1-m)) + b in training mode
+func (d *AlphaDropoutOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if d.Rate < 0 || d.Rate >= 1 {
+		return nil, fmt.Errorf("dropout rate must be in [0, 1), got %f", d.Rate)
+	}
+	if !d.training || d.Rate == 0 {
+		d.mask = nil
+		return input, nil
+	}
+
+	keep := 1 - d.Rate
+	alphaP := -seluAlpha * seluScale
+	a := 1 / math.Sqrt(keep+alphaP*alphaP*keep*d.Rate)
+	b := -a * alphaP * d.Rate
+
+	d.mask = NewMatrixOf[T](input.Rows, input.Cols)
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+	for i := 0; i < input.Rows; i++ {
+		for j := 0; j < input.Cols; j++ {
+			if randFloat64(d.rng) < keep {
+				d.mask.Data[i][j] = T(a)
+				output.Data[i][j] = T(a)*input.Data[i][j] + T(b)
+			} else {
+				output.Data[i][j] = T(a*alphaP + b)
+			}
+		}
+	}
+	return output, nil
+}
+
+// Backward applies the kept-element scale to the gradient
+func (d *AlphaDropoutOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return applyMask(d.mask, gradOutput)
+}
+
+// GetParams returns empty slice (no learnable parameters)
+func (d *AlphaDropoutOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetGrads returns empty slice
+func (d *AlphaDropoutOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetParamNames returns empty slice
+func (d *AlphaDropoutOf[T]) GetParamNames() []string {
+	return []string{}
+}
+
+// SpatialDropout2D drops entire feature maps instead of single activations.
+// Each row holds one sample flattened channel-major as (Channels, H, W),
+// so every channel is a contiguous block of Cols/Channels values.
+type SpatialDropout2DOf[T Float] struct {
+	Rate     float64
+	Channels int
+
+	training bool
+	rng      *rand.Rand
+	mask     *MatrixOf[T]
+}
+
+// SpatialDropout2D layer with float64 activations
+type SpatialDropout2D = SpatialDropout2DOf[float64]
+
+// NewSpatialDropout2D creates a new spatial dropout layer
+func NewSpatialDropout2D(rate float64, channels int) *SpatialDropout2D {
+	return NewSpatialDropout2DOf[float64](rate, channels)
+}
+
+// NewSpatialDropout2DOf creates a new spatial dropout layer with element
+// type T
+func NewSpatialDropout2DOf[T Float](rate float64, channels int) *SpatialDropout2DOf[T] {
+	return &SpatialDropout2DOf[T]{Rate: rate, Channels: channels}
+}
+
+// SetTraining switches between training and inference behaviour
+func (d *SpatialDropout2DOf[T]) SetTraining(training bool) {
+	d.training = training
+}
+
+// SetSeed makes the masks reproducible
+func (d *SpatialDropout2DOf[T]) SetSeed(seed int64) {
+	d.rng = newRand(seed)
+}
+
+// Forward drops whole channels in training mode
+func (d *SpatialDropout2DOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if d.Rate < 0 || d.Rate >= 1 {
+		return nil, fmt.Errorf("dropout rate must be in [0, 1), got %f", d.Rate)
+	}
+	if d.Channels <= 0 || input.Cols%d.Channels != 0 {
+		return nil, fmt.Errorf("input size %d is not divisible into %d channels", input.Cols, d.Channels)
+	}
+	if !d.training || d.Rate == 0 {
+		d.mask = nil
+		return input, nil
+	}
+
+	keep := 1 - d.Rate
+	scale := T(1 / keep)
+	size := input.Cols / d.Channels
+	d.mask = NewMatrixOf[T](input.Rows, input.Cols)
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+	for i := 0; i < input.Rows; i++ {
+		for c := 0; c < d.Channels; c++ {
+			if randFloat64(d.rng) >= keep {
+				continue
+			}
+			for k := c * size; k < (c+1)*size; k++ {
+				d.mask.Data[i][k] = scale
+				output.Data[i][k] = input.Data[i][k] / T(keep)
+			}
+		}
+	}
+	return output, nil
+}
+
+// Backward applies the same channel mask to the gradient
+func (d *SpatialDropout2DOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return applyMask(d.mask, gradOutput)
+}
+
+// GetParams returns empty slice (no learnable parameters)
+func (d *SpatialDropout2DOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetGrads returns empty slice
+func (d *SpatialDropout2DOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetParamNames returns empty slice
+func (d *SpatialDropout2DOf[T]) GetParamNames() []string {
+	return []string{}
+}
+
+// applyMask multiplies the gradient element-wise by mask; a nil mask means
+// the forward pass was the identity
+func applyMask[T Float](mask, gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if mask == nil {
+		return gradOutput, nil
+	}
+	if gradOutput.Rows != mask.Rows || gradOutput.Cols != mask.Cols {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
+	for i := 0; i < gradOutput.Rows; i++ {
+		for j := 0; j < gradOutput.Cols; j++ {
+			gradInput.Data[i][j] = gradOutput.Data[i][j] * mask.Data[i][j]
+		}
+	}
+	return gradInput, nil
+}
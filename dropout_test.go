@@ -0,0 +1,229 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// stochasticLayer is a layer drawing masks in training mode
+type stochasticLayer interface {
+	Layer
+	TrainingSetter
+	Seeder
+}
+
+func TestDropoutSeed(t *testing.T) {
+	input := uniformMatrix(rand.New(rand.NewSource(8)), 4, 50, 1, 2)
+	layers := map[string]func() stochasticLayer{
+		"dropout":            func() stochasticLayer { return NewDropout(0.3) },
+		"alpha_dropout":      func() stochasticLayer { return NewAlphaDropout(0.3) },
+		"spatial_dropout_2d": func() stochasticLayer { return NewSpatialDropout2D(0.3, 10) },
+	}
+	for name, create := range layers {
+		t.Run(name, func(t *testing.T) {
+			a, b := create(), create()
+			a.SetTraining(true)
+			b.SetTraining(true)
+			a.SetSeed(42)
+			b.SetSeed(42)
+
+			outA, err := a.Forward(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outB, err := b.Forward(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !matricesEqual(outA, outB) {
+				t.Error("layers with the same seed drew different masks")
+			}
+
+			b.SetSeed(43)
+			if outB, err = b.Forward(input); err != nil {
+				t.Fatal(err)
+			}
+			if matricesEqual(outA, outB) {
+				t.Error("layers with different seeds drew the same mask")
+			}
+
+			a.SetTraining(false)
+			out, err := a.Forward(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !matricesEqual(out, input) {
+				t.Error("inference mode is not the identity")
+			}
+		})
+	}
+}
+
+// matricesEqual reports whether a and b hold the same values
+func matricesEqual(a, b *Matrix) bool {
+	if a.Rows != b.Rows || a.Cols != b.Cols {
+		return false
+	}
+	for i := range a.Data {
+		for j := range a.Data[i] {
+			if a.Data[i][j] != b.Data[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestDropoutScaling(t *testing.T) {
+	d := NewDropout(0.25)
+	d.SetTraining(true)
+	d.SetSeed(1)
+
+	input := NewMatrix(100, 100)
+	for i := range input.Data {
+		for j := range input.Data[i] {
+			input.Data[i][j] = 3
+		}
+	}
+	out, err := d.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := 0
+	for i := range out.Data {
+		for _, v := range out.Data[i] {
+			switch v {
+			case 0:
+			case 4: // 3 / (1 - 0.25)
+				kept++
+			default:
+				t.Fatalf("unexpected output %v", v)
+			}
+		}
+	}
+	if fraction := float64(kept) / 10000; math.Abs(fraction-0.75) > 0.02 {
+		t.Errorf("kept %.3f of the activations, expected about 0.75", fraction)
+	}
+}
+
+func TestAlphaDropoutMoments(t *testing.T) {
+	d := NewAlphaDropout(0.2)
+	d.SetTraining(true)
+	d.SetSeed(2)
+
+	rng := rand.New(rand.NewSource(3))
+	input := NewMatrix(200, 100)
+	for i := range input.Data {
+		for j := range input.Data[i] {
+			input.Data[i][j] = rng.NormFloat64()
+		}
+	}
+	out, err := d.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mean, sq := 0.0, 0.0
+	for i := range out.Data {
+		for _, v := range out.Data[i] {
+			mean += v
+			sq += v * v
+		}
+	}
+	n := float64(out.Rows * out.Cols)
+	mean /= n
+	variance := sq/n - mean*mean
+	if math.Abs(mean) > 0.05 || math.Abs(variance-1) > 0.05 {
+		t.Errorf("mean %.3f and variance %.3f, expected 0 and 1", mean, variance)
+	}
+}
+
+func TestSpatialDropoutDropsChannels(t *testing.T) {
+	d := NewSpatialDropout2D(0.5, 4)
+	d.SetTraining(true)
+	d.SetSeed(4)
+
+	input := uniformMatrix(rand.New(rand.NewSource(5)), 10, 4*6, 1, 2)
+	out, err := d.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range out.Data {
+		for c := 0; c < 4; c++ {
+			block := out.Data[i][c*6 : (c+1)*6]
+			dropped := block[0] == 0
+			for k, v := range block {
+				if (v == 0) != dropped {
+					t.Fatalf("sample %d channel %d is partly dropped", i, c)
+				}
+				if !dropped && math.Abs(v-2*input.Data[i][c*6+k]) > 1e-12 {
+					t.Fatalf("kept value %v, expected %v", v, 2*input.Data[i][c*6+k])
+				}
+			}
+		}
+	}
+}
+
+func TestDropoutGradientsInTraining(t *testing.T) {
+	layers := map[string]stochasticLayer{
+		"dropout":            NewDropout(0.4),
+		"alpha_dropout":      NewAlphaDropout(0.4),
+		"spatial_dropout_2d": NewSpatialDropout2D(0.4, 3),
+	}
+	for name, layer := range layers {
+		t.Run(name, func(t *testing.T) {
+			layer.SetTraining(true)
+			result, err := GradCheck(layer, uniformMatrix(rand.New(rand.NewSource(6)), 4, 3*4, -1, 1), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if worst := result.Max(); worst > gradTolerance {
+				t.Errorf("relative gradient error %.3e exceeds %.0e", worst, gradTolerance)
+			}
+		})
+	}
+}
+
+func TestGradCheckModelTrainingMode(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	X, y := uniformMatrix(rng, 6, 4, -1, 1), oneHotMatrix(rng, 6, 3)
+
+	model := NewSequential()
+	model.Add(NewDense(4, 5))
+	model.Add(NewBatchNorm1D(5))
+	model.Add(NewDropout(0.3))
+	model.Add(NewDense(5, 3))
+	model.Add(NewSoftmaxLayer())
+	model.Compile(NewCategoricalCrossEntropy(), NewSGD(0.1, 0))
+	for _, layer := range model.Layers {
+		randomizeParams(rng, layer)
+	}
+	bn := model.Layers[1].(*BatchNorm1D)
+	before := snapshotState(model.Layers)
+
+	for _, training := range []bool{false, true} {
+		if training {
+			model.Train()
+		} else {
+			model.Eval()
+		}
+
+		result, err := GradCheckModel(model, X, y, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if worst := result.Max(); worst > layerTolerance {
+			t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+		}
+		if model.IsTraining() != training {
+			t.Errorf("training mode %v was not restored", training)
+		}
+		for k, m := range bn.GetState() {
+			if !matricesEqual(m, before[1][k]) {
+				t.Errorf("running statistic %s was not restored", bn.GetStateNames()[k])
+			}
+		}
+	}
+}
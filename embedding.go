@@ -0,0 +1,160 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SparseGrad holds the gradient of a parameter restricted to a few rows
+type SparseGrad struct {
+	Indices []int   // Unique row indices of the parameter, sorted
+	Values  *Matrix // Shape: (len(Indices), parameter Cols)
+}
+
+// SparseLayer is implemented by layers whose gradients only touch a few
+// rows of their parameters. GetSparseGrads returns one entry per parameter
+// in GetParams order; a nil entry means the dense gradient from GetGrads
+// should be used.
+type SparseLayer interface {
+	GetSparseGrads() []*SparseGrad
+}
+
+// EmbeddingOf maps integer ids to dense vectors. The input has shape
+// (batch, seqLen) holding ids as values of type T, and the output has shape
+// (batch, seqLen*Dim) with the vectors of a row laid out step by step. The
+// sparse gradient is accumulated in float64 whatever T is.
+type EmbeddingOf[T Float] struct {
+	VocabSize int
+	Dim       int
+	Weights   *MatrixOf[T] // Shape: (VocabSize, Dim)
+
+	// Cache for backward pass
+	lastIndices [][]int
+	sparseGrad  *SparseGrad
+
+	// Dense gradient built by GetGrads, and the rows it holds
+	denseGrad *MatrixOf[T]
+	denseRows []int
+}
+
+// Embedding layer with float64 vectors
+type Embedding = EmbeddingOf[float64]
+
+// NewEmbedding creates a new embedding layer with a table drawn from
+// Uniform(-0.05, 0.05), unless WithWeights selects another initializer
+func NewEmbedding(vocabSize, dim int, opts ...InitOption) *Embedding {
+	return NewEmbeddingOf[float64](vocabSize, dim, opts...)
+}
+
+// NewEmbeddingOf creates a new embedding layer with element type T
+func NewEmbeddingOf[T Float](vocabSize, dim int, opts ...InitOption) *EmbeddingOf[T] {
+	weightInit, _ := initializers(opts, Uniform{Min: -0.05, Max: 0.05}, nil)
+	return &EmbeddingOf[T]{
+		VocabSize: vocabSize,
+		Dim:       dim,
+		Weights:   initializedMatrixOf[T](weightInit, vocabSize, dim, vocabSize, dim),
+	}
+}
+
+// Forward looks up the vector of every id
+func (e *EmbeddingOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	e.lastIndices = make([][]int, input.Rows)
+	output := NewMatrixOf[T](input.Rows, input.Cols*e.Dim)
+
+	for i := 0; i < input.Rows; i++ {
+		e.lastIndices[i] = make([]int, input.Cols)
+		for t := 0; t < input.Cols; t++ {
+			idx := int(math.Round(float64(input.Data[i][t])))
+			if idx < 0 || idx >= e.VocabSize {
+				return nil, fmt.Errorf("embedding index %d out of range [0, %d)", idx, e.VocabSize)
+			}
+			e.lastIndices[i][t] = idx
+			copy(output.Data[i][t*e.Dim:(t+1)*e.Dim], e.Weights.Data[idx])
+		}
+	}
+
+	return output, nil
+}
+
+// Backward accumulates the gradient of every looked-up row. Ids are not
+// differentiable, so the returned input gradient is zero.
+func (e *EmbeddingOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gradOutput.Rows != len(e.lastIndices) || gradOutput.Cols%e.Dim != 0 {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+	seqLen := gradOutput.Cols / e.Dim
+
+	// Collect the unique rows first so Values can be allocated once
+	positions := make(map[int]int)
+	for _, row := range e.lastIndices {
+		for _, idx := range row {
+			positions[idx] = 0
+		}
+	}
+	indices := make([]int, 0, len(positions))
+	for idx := range positions {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+	for pos, idx := range indices {
+		positions[idx] = pos
+	}
+
+	values := NewMatrix(len(indices), e.Dim)
+	for i, row := range e.lastIndices {
+		if len(row) != seqLen {
+			return nil, fmt.Errorf("gradient size mismatch")
+		}
+		for t, idx := range row {
+			pos := positions[idx]
+			for j := 0; j < e.Dim; j++ {
+				values.Data[pos][j] += float64(gradOutput.Data[i][t*e.Dim+j])
+			}
+		}
+	}
+	e.sparseGrad = &SparseGrad{Indices: indices, Values: values}
+
+	return NewMatrixOf[T](gradOutput.Rows, seqLen), nil
+}
+
+// GetParams returns the parameters of the layer
+func (e *EmbeddingOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{e.Weights}
+}
+
+// GetGrads returns the gradient as a dense (VocabSize, Dim) matrix. The
+// matrix is allocated on the first call and reused afterwards, with only
+// the rows of the previous and current batch rewritten; optimizers
+// implementing SparseOptimizer use GetSparseGrads and never allocate it.
+func (e *EmbeddingOf[T]) GetGrads() []*MatrixOf[T] {
+	if e.denseGrad == nil {
+		e.denseGrad = NewMatrixOf[T](e.VocabSize, e.Dim)
+	}
+	for _, idx := range e.denseRows {
+		clear(e.denseGrad.Data[idx])
+	}
+	e.denseRows = nil
+	if e.sparseGrad != nil {
+		for pos, idx := range e.sparseGrad.Indices {
+			for j, v := range e.sparseGrad.Values.Data[pos] {
+				e.denseGrad.Data[idx][j] = T(v)
+			}
+		}
+		e.denseRows = e.sparseGrad.Indices
+	}
+	return []*MatrixOf[T]{e.denseGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (e *EmbeddingOf[T]) GetParamNames() []string {
+	return []string{"weights"}
+}
+
+// GetSparseGrads returns the gradient of the rows used in the last batch
+func (e *EmbeddingOf[T]) GetSparseGrads() []*SparseGrad {
+	if e.sparseGrad == nil {
+		return []*SparseGrad{{Values: NewMatrix(0, e.Dim)}}
+	}
+	return []*SparseGrad{e.sparseGrad}
+}
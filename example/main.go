@@ -133,6 +133,11 @@ func regressionExample() {
 		y.Data[i][0] = 2*x + 1 + rand.NormFloat64()*0.5
 	}
 
+	// Standardize inputs and targets; the scalers are saved with the weights
+	xScaler, yScaler := nn.NewStandardScaler(), nn.NewStandardScaler()
+	Xs, _ := nn.FitTransform(xScaler, X)
+	ys, _ := nn.FitTransform(yScaler, y)
+
 	// Build model
 	model := nn.NewSequential()
 	model.Add(nn.NewDense(1, 8))
@@ -147,7 +152,8 @@ func regressionExample() {
 
 	// Train
 	fmt.Println("Training regression model...")
-	err := model.Fit(X, y, 200, 20, true)
+	model.Preprocessing = nn.NewPipeline(xScaler)
+	err := model.Fit(Xs, ys, 200, 20, true)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
@@ -161,7 +167,9 @@ func regressionExample() {
 	testX.Data[3][0] = 4.0
 	testX.Data[4][0] = 5.0
 
-	predictions, _ := model.Predict(testX)
+	scaled, _ := model.Preprocessing.Transform(testX)
+	predictions, _ := model.Predict(scaled)
+	predictions, _ = yScaler.InverseTransform(predictions)
 	fmt.Println("\nRegression predictions (y ≈ 2x + 1):")
 	for i := 0; i < 5; i++ {
 		expected := 2*testX.Data[i][0] + 1
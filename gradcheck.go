@@ -0,0 +1,281 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultGradCheckEpsilon is the finite-difference step used when a
+// gradient check is called with epsilon <= 0
+const DefaultGradCheckEpsilon = 1e-5
+
+// GradCheckResult holds the worst relative error between analytic and
+// numerical gradients for every checked tensor
+type GradCheckResult struct {
+	Errors map[string]float64 // Keyed by parameter name, "input" for the input gradient
+}
+
+// Max returns the worst relative error over all checked tensors
+func (r *GradCheckResult) Max() float64 {
+	worst := 0.0
+	for _, e := range r.Errors {
+		worst = math.Max(worst, e)
+	}
+	return worst
+}
+
+// String lists the worst relative error per tensor, sorted by name
+func (r *GradCheckResult) String() string {
+	names := make([]string, 0, len(r.Errors))
+	for name := range r.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%s: %.3e\n", name, r.Errors[name])
+	}
+	return sb.String()
+}
+
+// relativeError returns |a-b| / (|a|+|b|), falling back to the absolute
+// error when both values are close to zero
+func relativeError(a, b float64) float64 {
+	denom := math.Abs(a) + math.Abs(b)
+	if denom < 1e-8 {
+		return math.Abs(a - b)
+	}
+	return math.Abs(a-b) / denom
+}
+
+// checkMatrix perturbs every element of m, evaluates objective with central
+// differences and returns the worst relative error against analytic
+func checkMatrix(m, analytic *Matrix, objective func() (float64, error), epsilon float64) (float64, error) {
+	worst := 0.0
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			original := m.Data[i][j]
+
+			m.Data[i][j] = original + epsilon
+			plus, err := objective()
+			if err != nil {
+				m.Data[i][j] = original
+				return 0, err
+			}
+
+			m.Data[i][j] = original - epsilon
+			minus, err := objective()
+			m.Data[i][j] = original
+			if err != nil {
+				return 0, err
+			}
+
+			numeric := (plus - minus) / (2 * epsilon)
+			worst = math.Max(worst, relativeError(analytic.Data[i][j], numeric))
+		}
+	}
+	return worst, nil
+}
+
+// copyMatrix returns a deep copy of m
+func copyMatrix[T Float](m *MatrixOf[T]) *MatrixOf[T] {
+	result := NewMatrixOf[T](m.Rows, m.Cols)
+	for i := 0; i < m.Rows; i++ {
+		copy(result.Data[i], m.Data[i])
+	}
+	return result
+}
+
+// CheckLossGradient compares loss.Backward against central finite
+// differences of loss.Forward and returns the largest relative error
+func CheckLossGradient(loss Loss, predictions, targets *Matrix, epsilon float64) (float64, error) {
+	if epsilon <= 0 {
+		epsilon = DefaultGradCheckEpsilon
+	}
+
+	analytic, err := loss.Backward(predictions, targets)
+	if err != nil {
+		return 0, err
+	}
+
+	return checkMatrix(predictions, analytic, func() (float64, error) {
+		return loss.Forward(predictions, targets)
+	}, epsilon)
+}
+
+// gradCheckSeed is the seed given to layers implementing Seeder before every
+// forward pass of a gradient check, so dropout draws the same mask each time
+const gradCheckSeed = 1
+
+// reseed seeds every layer implementing Seeder with its own fixed seed
+func reseed(layers ...Layer) {
+	for i, layer := range layers {
+		if s, ok := layer.(Seeder); ok {
+			s.SetSeed(gradCheckSeed + int64(i))
+		}
+	}
+}
+
+// GradCheck verifies the Backward pass of a layer. The layer output is
+// projected onto a fixed random matrix R to obtain the scalar L = Σ(out*R);
+// every parameter from GetParams and every input element is then perturbed
+// and the central difference of L is compared with GetGrads and the
+// returned input gradient. A layer implementing Seeder is reseeded before
+// every forward pass and keeps that seed afterwards.
+func GradCheck(layer Layer, input *Matrix, epsilon float64) (*GradCheckResult, error) {
+	if epsilon <= 0 {
+		epsilon = DefaultGradCheckEpsilon
+	}
+
+	reseed(layer)
+	output, err := layer.Forward(input)
+	if err != nil {
+		return nil, err
+	}
+	projection := RandomMatrix(output.Rows, output.Cols)
+
+	objective := func() (float64, error) {
+		reseed(layer)
+		out, err := layer.Forward(input)
+		if err != nil {
+			return 0, err
+		}
+		total := 0.0
+		for i := 0; i < out.Rows; i++ {
+			for j := 0; j < out.Cols; j++ {
+				total += out.Data[i][j] * projection.Data[i][j]
+			}
+		}
+		return total, nil
+	}
+
+	// Recompute the forward pass so the caches match the unperturbed input
+	if _, err := objective(); err != nil {
+		return nil, err
+	}
+	gradInput, err := layer.Backward(projection)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkAll(layer.GetParams(), layer.GetGrads(), layer.GetParamNames(), input, gradInput, objective, epsilon)
+}
+
+// GradCheckModel verifies the end-to-end gradients of a compiled model
+// against central finite differences of its loss on (X, y). Parameters are
+// reported with the same names UpdateWeights passes to the optimizer.
+//
+// The model runs in training mode, so batch normalization is checked with
+// batch statistics, and its layers implementing Seeder are reseeded before
+// every forward pass so dropout masks stay fixed. The mode of the model and
+// the state of its StatefulLayers, such as running statistics, are restored
+// afterwards; the seeds are kept.
+func GradCheckModel(s *Sequential, X, y *Matrix, epsilon float64) (*GradCheckResult, error) {
+	if s.Loss == nil {
+		return nil, fmt.Errorf("model must be compiled with a loss before gradient checking")
+	}
+	if epsilon <= 0 {
+		epsilon = DefaultGradCheckEpsilon
+	}
+
+	defer s.setTraining(s.training)
+	defer restoreState(s.Layers, snapshotState(s.Layers))
+	s.Train()
+
+	objective := func() (float64, error) {
+		reseed(s.Layers...)
+		predictions, err := s.Forward(X)
+		if err != nil {
+			return 0, err
+		}
+		return s.Loss.Forward(predictions, y)
+	}
+
+	reseed(s.Layers...)
+	predictions, err := s.Forward(X)
+	if err != nil {
+		return nil, err
+	}
+	grad, err := s.Loss.Backward(predictions, y)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		grad, err = s.Layers[i].Backward(grad)
+		if err != nil {
+			return nil, fmt.Errorf("error in backward pass at layer %d: %v", i, err)
+		}
+	}
+
+	var params, grads []*Matrix
+	var names []string
+	for layerIdx, layer := range s.Layers {
+		params = append(params, layer.GetParams()...)
+		grads = append(grads, layer.GetGrads()...)
+		for _, name := range layer.GetParamNames() {
+			names = append(names, fmt.Sprintf("layer_%d_%s", layerIdx, name))
+		}
+	}
+
+	return checkAll(params, grads, names, X, grad, objective, epsilon)
+}
+
+// snapshotState copies the state of every StatefulLayer among layers
+func snapshotState(layers []Layer) [][]*Matrix {
+	snapshot := make([][]*Matrix, len(layers))
+	for i, layer := range layers {
+		if stateful, ok := layer.(StatefulLayer); ok {
+			for _, m := range stateful.GetState() {
+				snapshot[i] = append(snapshot[i], copyMatrix(m))
+			}
+		}
+	}
+	return snapshot
+}
+
+// restoreState copies a snapshot back into the state of the layers
+func restoreState(layers []Layer, snapshot [][]*Matrix) {
+	for i, layer := range layers {
+		if stateful, ok := layer.(StatefulLayer); ok {
+			for k, m := range stateful.GetState() {
+				for r := range m.Data {
+					copy(m.Data[r], snapshot[i][k].Data[r])
+				}
+			}
+		}
+	}
+}
+
+// checkAll runs checkMatrix over the parameters and the input
+func checkAll(params, grads []*Matrix, names []string, input, gradInput *Matrix, objective func() (float64, error), epsilon float64) (*GradCheckResult, error) {
+	if len(params) != len(grads) || len(params) != len(names) {
+		return nil, fmt.Errorf("got %d params, %d grads and %d names", len(params), len(grads), len(names))
+	}
+
+	// Snapshot the analytic gradients before the perturbed forward passes
+	analytic := make([]*Matrix, len(grads))
+	for i, g := range grads {
+		analytic[i] = copyMatrix(g)
+	}
+	analyticInput := copyMatrix(gradInput)
+
+	result := &GradCheckResult{Errors: make(map[string]float64)}
+	for i, p := range params {
+		worst, err := checkMatrix(p, analytic[i], objective, epsilon)
+		if err != nil {
+			return nil, err
+		}
+		result.Errors[names[i]] = math.Max(result.Errors[names[i]], worst)
+	}
+
+	worst, err := checkMatrix(input, analyticInput, objective, epsilon)
+	if err != nil {
+		return nil, err
+	}
+	result.Errors["input"] = worst
+
+	return result, nil
+}
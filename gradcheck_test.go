@@ -0,0 +1,132 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRelativeError(t *testing.T) {
+	tests := []struct {
+		a, b, want float64
+	}{
+		{1, 1, 0},
+		{1, 3, 0.5},
+		{-2, 2, 1},
+		{0, 1e-9, 1e-9}, // Absolute error below the floor
+	}
+	for _, tt := range tests {
+		if got := relativeError(tt.a, tt.b); math.Abs(got-tt.want) > 1e-15 {
+			t.Errorf("relativeError(%g, %g) = %g, expected %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGradCheckResult(t *testing.T) {
+	r := &GradCheckResult{Errors: map[string]float64{"weights": 2e-3, "bias": 1e-7, "input": 5e-4}}
+	if r.Max() != 2e-3 {
+		t.Errorf("Max() = %g, expected 2e-3", r.Max())
+	}
+	want := "bias: 1.000e-07\ninput: 5.000e-04\nweights: 2.000e-03\n"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, expected %q", got, want)
+	}
+}
+
+// doubledLoss is MSE with a Backward twice too large
+type doubledLoss struct{ *MSE }
+
+func (l doubledLoss) Backward(predictions, targets *Matrix) (*Matrix, error) {
+	grad, err := l.MSE.Backward(predictions, targets)
+	if err != nil {
+		return nil, err
+	}
+	return grad.Scale(2), nil
+}
+
+func TestCheckLossGradientDetectsWrongBackward(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	predictions, targets := uniformMatrix(rng, 4, 3, -1, 1), uniformMatrix(rng, 4, 3, -1, 1)
+
+	worst, err := CheckLossGradient(doubledLoss{NewMSE()}, predictions, targets, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// |2g - g| / (|2g| + |g|) = 1/3 for every element
+	if math.Abs(worst-1.0/3) > 1e-6 {
+		t.Errorf("relative error %g, expected 1/3", worst)
+	}
+}
+
+// batchScaledDense is a Dense layer dividing its input gradient by the
+// batch size, the mistake the gradient convention rules out
+type batchScaledDense struct{ *Dense }
+
+func (d batchScaledDense) Backward(gradOutput *Matrix) (*Matrix, error) {
+	grad, err := d.Dense.Backward(gradOutput)
+	if err != nil {
+		return nil, err
+	}
+	return grad.Scale(1 / float64(gradOutput.Rows)), nil
+}
+
+func TestGradCheck(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	input := uniformMatrix(rng, 3, 4, -1, 1)
+
+	dense := NewDense(4, 2)
+	result, err := GradCheck(dense, input, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"weights", "bias", "input"} {
+		worst, ok := result.Errors[name]
+		if !ok {
+			t.Fatalf("%q was not checked", name)
+		}
+		if worst > gradTolerance {
+			t.Errorf("%s: relative error %.3e exceeds %.0e", name, worst, gradTolerance)
+		}
+	}
+
+	result, err = GradCheck(batchScaledDense{NewDense(4, 2)}, input, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// |g/3 - g| / (|g/3| + |g|) = 1/2 for the input, parameters unaffected
+	if math.Abs(result.Errors["input"]-0.5) > 1e-6 {
+		t.Errorf("input relative error %g, expected 0.5", result.Errors["input"])
+	}
+	if result.Errors["weights"] > gradTolerance {
+		t.Errorf("weights relative error %.3e exceeds %.0e", result.Errors["weights"], gradTolerance)
+	}
+}
+
+func TestGradCheckModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	X, y := uniformMatrix(rng, 4, 3, -1, 1), oneHotMatrix(rng, 4, 2)
+
+	model := NewSequential()
+	model.Add(NewDense(3, 2))
+	model.Add(NewSoftmaxLayer())
+	if _, err := GradCheckModel(model, X, y, 0); err == nil {
+		t.Error("expected an error for a model without a loss")
+	}
+
+	model.Compile(NewCategoricalCrossEntropy(), NewSGD(0.1, 0))
+	result, err := GradCheckModel(model, X, y, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"layer_0_weights", "layer_0_bias", "input"} {
+		if _, ok := result.Errors[name]; !ok {
+			t.Errorf("%q was not checked", name)
+		}
+	}
+	if len(result.Errors) != 3 {
+		t.Errorf("checked %d tensors, expected 3", len(result.Errors))
+	}
+	if worst := result.Max(); worst > layerTolerance {
+		t.Errorf("relative error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+	}
+}
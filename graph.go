@@ -0,0 +1,667 @@
+package nn
+
+import (
+	"fmt"
+)
+
+// MergeLayer combines several inputs of a graph model into one output
+type MergeLayer interface {
+	ForwardMerge(inputs []*Matrix) (*Matrix, error)
+	BackwardMerge(gradOutput *Matrix) ([]*Matrix, error)
+}
+
+// Node is a symbolic tensor of a graph model: a model input, the output of
+// a Layer applied to one node or of a MergeLayer applied to several
+type Node struct {
+	size   int // Feature count of an input node
+	layer  Layer
+	merge  MergeLayer
+	inputs []*Node
+}
+
+// Input creates a model input with the given number of features
+func Input(size int) *Node {
+	return &Node{size: size}
+}
+
+// Apply calls layer on a symbolic input and returns its output node. A layer
+// keeps the state of a single forward pass, so each layer instance can be
+// applied only once per model.
+func Apply(layer Layer, input *Node) *Node {
+	return &Node{layer: layer, inputs: []*Node{input}}
+}
+
+// ApplyMerge calls a merge layer on several symbolic inputs. Like a layer, a
+// merge layer instance can be applied only once per model.
+func ApplyMerge(merge MergeLayer, inputs ...*Node) *Node {
+	return &Node{merge: merge, inputs: inputs}
+}
+
+// Model is a directed acyclic graph of layers built from input nodes to
+// output nodes. It supports residual connections, multiple inputs and
+// multiple outputs.
+type Model struct {
+	Inputs    []*Node
+	Outputs   []*Node
+	Layers    []Layer // Layers in topological order
+	Heads     []Head  // One per output
+	Optimizer Optimizer
+	History   []*Report // Training report of every epoch run by Fit
+
+	// Preprocessing is saved and loaded with the weights; it is not
+	// applied by Predict
+	Preprocessing *Pipeline
+
+	order    []*Node // Non-input nodes in topological order
+	training bool
+
+	// Cache for backward pass
+	values map[*Node]*Matrix
+}
+
+// NewModel builds a model computing outputs from inputs. Every node the
+// outputs depend on must be reachable from the given inputs.
+func NewModel(inputs, outputs []*Node) (*Model, error) {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return nil, fmt.Errorf("a model needs at least one input and one output")
+	}
+
+	isInput := make(map[*Node]bool)
+	for _, in := range inputs {
+		if in.layer != nil || in.merge != nil {
+			return nil, fmt.Errorf("model inputs must be created with Input")
+		}
+		isInput[in] = true
+	}
+
+	m := &Model{Inputs: inputs, Outputs: outputs}
+	visited := make(map[*Node]bool)
+	onStack := make(map[*Node]bool)
+	usedLayers := make(map[interface{}]bool) // Layer and MergeLayer instances
+
+	var visit func(n *Node) error
+	visit = func(n *Node) error {
+		if visited[n] {
+			return nil
+		}
+		if onStack[n] {
+			return fmt.Errorf("graph contains a cycle")
+		}
+		if n.layer == nil && n.merge == nil {
+			if !isInput[n] {
+				return fmt.Errorf("graph depends on an input that was not passed to NewModel")
+			}
+			visited[n] = true
+			return nil
+		}
+
+		onStack[n] = true
+		for _, in := range n.inputs {
+			if err := visit(in); err != nil {
+				return err
+			}
+		}
+		onStack[n] = false
+		visited[n] = true
+
+		if n.layer != nil {
+			if usedLayers[n.layer] {
+				return fmt.Errorf("layer %T is applied more than once", n.layer)
+			}
+			usedLayers[n.layer] = true
+			m.Layers = append(m.Layers, n.layer)
+		} else {
+			if usedLayers[n.merge] {
+				return fmt.Errorf("merge layer %T is applied more than once", n.merge)
+			}
+			usedLayers[n.merge] = true
+		}
+		m.order = append(m.order, n)
+		return nil
+	}
+
+	for _, out := range outputs {
+		if err := visit(out); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Head configures the loss, loss weight and metrics of one model output.
+// Metric instances hold state and must not be shared between heads.
+type Head struct {
+	Name    string
+	Loss    Loss
+	Weight  float64 // Multiplies the loss in the total; 0 ignores the head
+	Metrics []Metric
+}
+
+// Compile uses the same loss with weight 1 for every output
+func (m *Model) Compile(loss Loss, optimizer Optimizer) {
+	m.Heads = make([]Head, len(m.Outputs))
+	for i := range m.Heads {
+		m.Heads[i] = Head{Name: fmt.Sprintf("output_%d", i), Loss: loss, Weight: 1}
+	}
+	m.Optimizer = optimizer
+}
+
+// CompileHeads sets one head per output, in the order of the outputs
+// passed to NewModel, and the optimizer. Empty names default to output_<i>.
+func (m *Model) CompileHeads(optimizer Optimizer, heads ...Head) error {
+	if len(heads) != len(m.Outputs) {
+		return fmt.Errorf("expected %d heads, got %d", len(m.Outputs), len(heads))
+	}
+
+	seen := make(map[string]bool)
+	m.Heads = make([]Head, len(heads))
+	for i, head := range heads {
+		if head.Loss == nil {
+			return fmt.Errorf("head %d has no loss", i)
+		}
+		if head.Name == "" {
+			head.Name = fmt.Sprintf("output_%d", i)
+		}
+		if seen[head.Name] {
+			return fmt.Errorf("duplicate head name %q", head.Name)
+		}
+		seen[head.Name] = true
+		m.Heads[i] = head
+	}
+	m.Optimizer = optimizer
+	return nil
+}
+
+// Train switches the model and its layers to training mode
+func (m *Model) Train() {
+	m.training = true
+	setLayersTraining(m.Layers, true)
+}
+
+// Eval switches the model and its layers to inference mode
+func (m *Model) Eval() {
+	m.training = false
+	setLayersTraining(m.Layers, false)
+}
+
+// IsTraining reports whether the model is in training mode
+func (m *Model) IsTraining() bool {
+	return m.training
+}
+
+// Forward evaluates the graph in topological order
+func (m *Model) Forward(inputs []*Matrix) ([]*Matrix, error) {
+	if len(inputs) != len(m.Inputs) {
+		return nil, fmt.Errorf("expected %d inputs, got %d", len(m.Inputs), len(inputs))
+	}
+
+	m.values = make(map[*Node]*Matrix)
+	for i, in := range m.Inputs {
+		if inputs[i].Cols != in.size {
+			return nil, fmt.Errorf("input %d size mismatch: got %d, expected %d", i, inputs[i].Cols, in.size)
+		}
+		m.values[in] = inputs[i]
+	}
+
+	for idx, n := range m.order {
+		args := make([]*Matrix, len(n.inputs))
+		for i, in := range n.inputs {
+			args[i] = m.values[in]
+		}
+
+		var out *Matrix
+		var err error
+		if n.layer != nil {
+			out, err = n.layer.Forward(args[0])
+		} else {
+			out, err = n.merge.ForwardMerge(args)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error in node %d: %v", idx, err)
+		}
+		m.values[n] = out
+	}
+
+	outputs := make([]*Matrix, len(m.Outputs))
+	for i, out := range m.Outputs {
+		outputs[i] = m.values[out]
+	}
+	return outputs, nil
+}
+
+// Backward propagates the output gradients through the graph in reverse
+// topological order, summing gradients of nodes used more than once
+func (m *Model) Backward(gradOutputs []*Matrix) error {
+	if len(gradOutputs) != len(m.Outputs) {
+		return fmt.Errorf("expected %d output gradients, got %d", len(m.Outputs), len(gradOutputs))
+	}
+
+	grads := make(map[*Node]*Matrix)
+	accumulate := func(n *Node, grad *Matrix) error {
+		if grads[n] == nil {
+			grads[n] = grad
+			return nil
+		}
+		sum, err := grads[n].Add(grad)
+		if err != nil {
+			return err
+		}
+		grads[n] = sum
+		return nil
+	}
+
+	for i, out := range m.Outputs {
+		if err := accumulate(out, gradOutputs[i]); err != nil {
+			return err
+		}
+	}
+
+	for idx := len(m.order) - 1; idx >= 0; idx-- {
+		n := m.order[idx]
+		grad := grads[n]
+		if grad == nil {
+			// The node does not contribute to any output
+			continue
+		}
+
+		var inputGrads []*Matrix
+		if n.layer != nil {
+			g, err := n.layer.Backward(grad)
+			if err != nil {
+				return fmt.Errorf("error in backward pass at node %d: %v", idx, err)
+			}
+			inputGrads = []*Matrix{g}
+		} else {
+			var err error
+			inputGrads, err = n.merge.BackwardMerge(grad)
+			if err != nil {
+				return fmt.Errorf("error in backward pass at node %d: %v", idx, err)
+			}
+			if len(inputGrads) != len(n.inputs) {
+				return fmt.Errorf("error in backward pass at node %d: got %d input gradients, expected %d", idx, len(inputGrads), len(n.inputs))
+			}
+		}
+
+		for i, in := range n.inputs {
+			if err := accumulate(in, inputGrads[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpdateWeights updates all parameters using the optimizer
+func (m *Model) UpdateWeights() {
+	updateLayers(m.Optimizer, m.Layers)
+}
+
+// Report holds the losses and metrics of a model over some data. A
+// Sequential model has no head losses and keys metrics by name.
+type Report struct {
+	Loss       float64            // Weighted sum of the head losses
+	HeadLosses map[string]float64 // Unweighted loss of each head
+	Metrics    map[string]float64 // Keyed <head>_<metric>
+
+	keys []string // Head losses and metrics in display order
+}
+
+// String formats the report on one line, heads in output order
+func (r *Report) String() string {
+	s := fmt.Sprintf("loss: %.6f", r.Loss)
+	for _, key := range r.keys {
+		if loss, ok := r.HeadLosses[key]; ok {
+			s += fmt.Sprintf(" - %s_loss: %.6f", key, loss)
+		} else {
+			s += fmt.Sprintf(" - %s: %.4f", key, r.Metrics[key])
+		}
+	}
+	return s
+}
+
+// headLosses computes the loss of every head and the weighted total
+func (m *Model) headLosses(predictions, y []*Matrix) (float64, []float64, error) {
+	if len(m.Heads) != len(m.Outputs) {
+		return 0, nil, fmt.Errorf("model is not compiled")
+	}
+	if len(y) != len(predictions) {
+		return 0, nil, fmt.Errorf("expected %d targets, got %d", len(predictions), len(y))
+	}
+
+	total := 0.0
+	losses := make([]float64, len(predictions))
+	for i, head := range m.Heads {
+		loss, err := head.Loss.Forward(predictions[i], y[i])
+		if err != nil {
+			return 0, nil, fmt.Errorf("head %s: %v", head.Name, err)
+		}
+		losses[i] = loss
+		total += head.Weight * loss
+	}
+	return total, losses, nil
+}
+
+// resetMetrics resets the metrics of every head
+func (m *Model) resetMetrics() {
+	for _, head := range m.Heads {
+		for _, metric := range head.Metrics {
+			metric.Reset()
+		}
+	}
+}
+
+// updateMetrics adds a batch to the metrics of every head
+func (m *Model) updateMetrics(predictions, y []*Matrix) error {
+	for i, head := range m.Heads {
+		for _, metric := range head.Metrics {
+			if err := metric.Update(predictions[i], y[i]); err != nil {
+				return fmt.Errorf("head %s: %v", head.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// report builds a Report from the total and head losses and the current
+// metric results
+func (m *Model) report(total float64, losses []float64) *Report {
+	r := &Report{
+		Loss:       total,
+		HeadLosses: make(map[string]float64),
+		Metrics:    make(map[string]float64),
+	}
+	for i, head := range m.Heads {
+		r.HeadLosses[head.Name] = losses[i]
+		r.keys = append(r.keys, head.Name)
+		for _, metric := range head.Metrics {
+			key := head.Name + "_" + metric.Name()
+			r.Metrics[key] = metric.Result()
+			r.keys = append(r.keys, key)
+		}
+	}
+	return r
+}
+
+// TrainOnBatch trains the model on a single batch in training mode and
+// returns the weighted total loss
+func (m *Model) TrainOnBatch(X, y []*Matrix) (float64, error) {
+	total, _, err := m.trainOnBatch(X, y)
+	return total, err
+}
+
+// trainOnBatch runs one training step and also returns the head losses
+func (m *Model) trainOnBatch(X, y []*Matrix) (float64, []float64, error) {
+	m.Train()
+
+	predictions, err := m.Forward(X)
+	if err != nil {
+		return 0, nil, err
+	}
+	total, losses, err := m.headLosses(predictions, y)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := m.updateMetrics(predictions, y); err != nil {
+		return 0, nil, err
+	}
+
+	gradOutputs := make([]*Matrix, len(predictions))
+	for i, head := range m.Heads {
+		grad, err := head.Loss.Backward(predictions[i], y[i])
+		if err != nil {
+			return 0, nil, fmt.Errorf("head %s: %v", head.Name, err)
+		}
+		gradOutputs[i] = grad.Scale(head.Weight)
+	}
+
+	if err := m.Backward(gradOutputs); err != nil {
+		return 0, nil, err
+	}
+	m.UpdateWeights()
+
+	return total, losses, nil
+}
+
+// Fit trains the model for multiple epochs and leaves it in inference mode.
+// X holds one matrix per input and y one matrix per output, all with the
+// same number of rows. The training report of every epoch, with losses
+// averaged over batches, is appended to History.
+func (m *Model) Fit(X, y []*Matrix, epochs int, batchSize int, verbose bool) error {
+	defer m.Eval()
+
+	if len(X) == 0 {
+		return fmt.Errorf("no input data")
+	}
+	numSamples := X[0].Rows
+	for _, data := range append(append([]*Matrix{}, X...), y...) {
+		if data.Rows != numSamples {
+			return fmt.Errorf("all inputs and targets must have %d rows, got %d", numSamples, data.Rows)
+		}
+	}
+
+	for epoch := 0; epoch < epochs; epoch++ {
+		m.resetMetrics()
+		totalLoss := 0.0
+		headLosses := make([]float64, len(m.Outputs))
+		numBatches := 0
+
+		for i := 0; i < numSamples; i += batchSize {
+			end := i + batchSize
+			if end > numSamples {
+				end = numSamples
+			}
+
+			loss, losses, err := m.trainOnBatch(rowRanges(X, i, end), rowRanges(y, i, end))
+			if err != nil {
+				return err
+			}
+
+			totalLoss += loss
+			for h, l := range losses {
+				headLosses[h] += l
+			}
+			numBatches++
+		}
+
+		for h := range headLosses {
+			headLosses[h] /= float64(numBatches)
+		}
+		report := m.report(totalLoss/float64(numBatches), headLosses)
+		m.History = append(m.History, report)
+
+		if verbose {
+			fmt.Printf("Epoch %d/%d - %s\n", epoch+1, epochs, report)
+		}
+	}
+
+	return nil
+}
+
+// Predict makes predictions on input data in inference mode
+func (m *Model) Predict(X []*Matrix) ([]*Matrix, error) {
+	m.Eval()
+	return m.Forward(X)
+}
+
+// Evaluate computes the weighted total loss on test data
+func (m *Model) Evaluate(X, y []*Matrix) (float64, error) {
+	report, err := m.EvaluateReport(X, y)
+	if err != nil {
+		return 0, err
+	}
+	return report.Loss, nil
+}
+
+// EvaluateReport computes the total loss, the loss of every head and the
+// head metrics on test data
+func (m *Model) EvaluateReport(X, y []*Matrix) (*Report, error) {
+	predictions, err := m.Predict(X)
+	if err != nil {
+		return nil, err
+	}
+	total, losses, err := m.headLosses(predictions, y)
+	if err != nil {
+		return nil, err
+	}
+
+	m.resetMetrics()
+	if err := m.updateMetrics(predictions, y); err != nil {
+		return nil, err
+	}
+	return m.report(total, losses), nil
+}
+
+// rowRanges applies rowRange to every matrix
+func rowRanges(ms []*Matrix, start, end int) []*Matrix {
+	result := make([]*Matrix, len(ms))
+	for i, m := range ms {
+		result[i] = rowRange(m, start, end)
+	}
+	return result
+}
+
+// AddLayer merges inputs of the same shape by element-wise addition
+type AddLayer struct {
+	numInputs int
+}
+
+// NewAddLayer creates a new addition merge layer
+func NewAddLayer() *AddLayer {
+	return &AddLayer{}
+}
+
+// ForwardMerge adds all inputs
+func (a *AddLayer) ForwardMerge(inputs []*Matrix) (*Matrix, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("add needs at least one input")
+	}
+	a.numInputs = len(inputs)
+
+	output := copyMatrix(inputs[0])
+	for _, in := range inputs[1:] {
+		var err error
+		output, err = output.Add(in)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// BackwardMerge passes the gradient to every input
+func (a *AddLayer) BackwardMerge(gradOutput *Matrix) ([]*Matrix, error) {
+	grads := make([]*Matrix, a.numInputs)
+	for i := range grads {
+		grads[i] = gradOutput
+	}
+	return grads, nil
+}
+
+// MultiplyLayer merges inputs of the same shape by element-wise product
+type MultiplyLayer struct {
+	lastInputs []*Matrix
+}
+
+// NewMultiplyLayer creates a new multiplication merge layer
+func NewMultiplyLayer() *MultiplyLayer {
+	return &MultiplyLayer{}
+}
+
+// ForwardMerge multiplies all inputs element-wise
+func (mul *MultiplyLayer) ForwardMerge(inputs []*Matrix) (*Matrix, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("multiply needs at least one input")
+	}
+	for _, in := range inputs[1:] {
+		if in.Rows != inputs[0].Rows || in.Cols != inputs[0].Cols {
+			return nil, fmt.Errorf("incompatible dimensions")
+		}
+	}
+	mul.lastInputs = inputs
+
+	output := copyMatrix(inputs[0])
+	for _, in := range inputs[1:] {
+		for i := 0; i < output.Rows; i++ {
+			for j := 0; j < output.Cols; j++ {
+				output.Data[i][j] *= in.Data[i][j]
+			}
+		}
+	}
+	return output, nil
+}
+
+// BackwardMerge multiplies the gradient by the product of the other inputs
+func (mul *MultiplyLayer) BackwardMerge(gradOutput *Matrix) ([]*Matrix, error) {
+	grads := make([]*Matrix, len(mul.lastInputs))
+	for k := range mul.lastInputs {
+		grad := copyMatrix(gradOutput)
+		for other, in := range mul.lastInputs {
+			if other == k {
+				continue
+			}
+			for i := 0; i < grad.Rows; i++ {
+				for j := 0; j < grad.Cols; j++ {
+					grad.Data[i][j] *= in.Data[i][j]
+				}
+			}
+		}
+		grads[k] = grad
+	}
+	return grads, nil
+}
+
+// ConcatenateLayer merges inputs with the same number of rows by joining
+// their features
+type ConcatenateLayer struct {
+	widths []int
+}
+
+// NewConcatenateLayer creates a new concatenation merge layer
+func NewConcatenateLayer() *ConcatenateLayer {
+	return &ConcatenateLayer{}
+}
+
+// ForwardMerge joins the inputs along the feature axis
+func (cat *ConcatenateLayer) ForwardMerge(inputs []*Matrix) (*Matrix, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("concatenate needs at least one input")
+	}
+
+	cat.widths = make([]int, len(inputs))
+	total := 0
+	for i, in := range inputs {
+		if in.Rows != inputs[0].Rows {
+			return nil, fmt.Errorf("row count mismatch: got %d, expected %d", in.Rows, inputs[0].Rows)
+		}
+		cat.widths[i] = in.Cols
+		total += in.Cols
+	}
+
+	output := NewMatrix(inputs[0].Rows, total)
+	offset := 0
+	for _, in := range inputs {
+		setColumns(output, offset, in)
+		offset += in.Cols
+	}
+	return output, nil
+}
+
+// BackwardMerge splits the gradient back into the input widths
+func (cat *ConcatenateLayer) BackwardMerge(gradOutput *Matrix) ([]*Matrix, error) {
+	total := 0
+	for _, w := range cat.widths {
+		total += w
+	}
+	if gradOutput.Cols != total {
+		return nil, fmt.Errorf("gradient size mismatch: got %d columns, expected %d", gradOutput.Cols, total)
+	}
+
+	grads := make([]*Matrix, len(cat.widths))
+	offset := 0
+	for i, w := range cat.widths {
+		grads[i] = columns(gradOutput, offset, offset+w)
+		offset += w
+	}
+	return grads, nil
+}
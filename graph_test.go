@@ -0,0 +1,258 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNewModelRejectsReuse(t *testing.T) {
+	a, b, c := Input(2), Input(2), Input(2)
+
+	dense := NewDense(2, 2)
+	if _, err := NewModel([]*Node{a, b}, []*Node{Apply(dense, a), Apply(dense, b)}); err == nil {
+		t.Error("expected an error for a layer applied twice")
+	}
+
+	// Reusing a concatenation would overwrite the widths of its first call
+	// and break the backward pass
+	cat := NewConcatenateLayer()
+	first := ApplyMerge(cat, a, b)
+	second := ApplyMerge(cat, c, first)
+	_, err := NewModel([]*Node{a, b, c}, []*Node{second})
+	if err == nil || !strings.Contains(err.Error(), "merge layer") {
+		t.Errorf("expected an error for a merge layer applied twice, got %v", err)
+	}
+
+	// A node may feed several layers
+	if _, err := NewModel([]*Node{a}, []*Node{ApplyMerge(NewAddLayer(), a, a)}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConcatenateGradientSize(t *testing.T) {
+	cat := NewConcatenateLayer()
+	if _, err := cat.ForwardMerge([]*Matrix{NewMatrix(2, 3), NewMatrix(2, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cols := range []int{3, 5} {
+		if _, err := cat.BackwardMerge(NewMatrix(2, cols)); err == nil {
+			t.Errorf("expected an error for a gradient of %d columns on 4", cols)
+		}
+	}
+	grads, err := cat.BackwardMerge(NewMatrix(2, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grads[0].Cols != 3 || grads[1].Cols != 1 {
+		t.Errorf("gradient widths %d and %d, expected 3 and 1", grads[0].Cols, grads[1].Cols)
+	}
+}
+
+func TestModelGraphGradients(t *testing.T) {
+	rng := rand.New(rand.NewSource(20))
+	a, b := Input(3), Input(2)
+	left := Apply(NewDense(3, 4), a)
+	right := Apply(NewLayerNorm(4), Apply(NewDense(2, 4), b))
+	residual := ApplyMerge(NewAddLayer(), left, right)
+	gated := ApplyMerge(NewMultiplyLayer(), residual, left)
+	joined := ApplyMerge(NewConcatenateLayer(), gated, b)
+	out := Apply(NewDense(6, 2), joined)
+
+	model, err := NewModel([]*Node{a, b}, []*Node{out, residual})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range model.Layers {
+		randomizeParams(rng, layer)
+	}
+
+	X := []*Matrix{uniformMatrix(rng, 3, 3, -1, 1), uniformMatrix(rng, 3, 2, -1, 1)}
+	projections := []*Matrix{uniformMatrix(rng, 3, 2, -1, 1), uniformMatrix(rng, 3, 4, -1, 1)}
+	objective := func() (float64, error) {
+		outputs, err := model.Forward(X)
+		if err != nil {
+			return 0, err
+		}
+		total := 0.0
+		for k, o := range outputs {
+			for i := range o.Data {
+				for j, v := range o.Data[i] {
+					total += v * projections[k].Data[i][j]
+				}
+			}
+		}
+		return total, nil
+	}
+	if _, err := objective(); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Backward(projections); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, layer := range model.Layers {
+		grads := layer.GetGrads()
+		for k, p := range layer.GetParams() {
+			worst, err := checkMatrix(p, copyMatrix(grads[k]), objective, DefaultGradCheckEpsilon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if worst > layerTolerance {
+				t.Errorf("layer %d %s: relative gradient error %.3e exceeds %.0e", i, layer.GetParamNames()[k], worst, layerTolerance)
+			}
+		}
+	}
+}
+
+// twoHeadModel builds a model with a shared trunk, a regression head and a
+// softmax head with a layer of its own
+func twoHeadModel(rng *rand.Rand) *Model {
+	in := Input(3)
+	trunk := Apply(NewDense(3, 4), in)
+	regression := Apply(NewDense(4, 2), trunk)
+	classes := Apply(NewSoftmaxLayer(), Apply(NewDense(4, 3), trunk))
+	model, _ := NewModel([]*Node{in}, []*Node{regression, classes})
+	for _, layer := range model.Layers {
+		randomizeParams(rng, layer)
+	}
+	return model
+}
+
+func TestHeadLossWeighting(t *testing.T) {
+	rng := rand.New(rand.NewSource(21))
+	model := twoHeadModel(rng)
+	err := model.CompileHeads(NewSGD(1, 0),
+		Head{Name: "value", Loss: NewMSE(), Weight: 2},
+		Head{Name: "class", Loss: NewCategoricalCrossEntropy(), Weight: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	X := []*Matrix{uniformMatrix(rng, 4, 3, -1, 1)}
+	y := []*Matrix{uniformMatrix(rng, 4, 2, -1, 1), oneHotMatrix(rng, 4, 3)}
+
+	predictions, err := model.Predict(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mse, err := NewMSE().Forward(predictions[0], y[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cce, err := NewCategoricalCrossEntropy().Forward(predictions[1], y[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := model.EvaluateReport(X, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Head losses are reported unweighted, the total is weighted
+	if report.HeadLosses["value"] != mse || report.HeadLosses["class"] != cce {
+		t.Errorf("head losses %v, expected value %v and class %v", report.HeadLosses, mse, cce)
+	}
+	if want := 2*mse + 0.5*cce; math.Abs(report.Loss-want) > 1e-12 {
+		t.Errorf("total loss %v, expected %v", report.Loss, want)
+	}
+
+	// The gradients of a training step are those of the weighted total
+	params := snapshotParams(model.Layers)
+	if _, err := model.TrainOnBatch(X, y); err != nil {
+		t.Fatal(err)
+	}
+	grads := snapshotGrads(model.Layers)
+	for i, layer := range model.Layers {
+		for k, p := range layer.GetParams() {
+			for r := range p.Data {
+				copy(p.Data[r], params[i][k].Data[r])
+			}
+		}
+	}
+
+	objective := func() (float64, error) {
+		predictions, err := model.Forward(X)
+		if err != nil {
+			return 0, err
+		}
+		total, _, err := model.headLosses(predictions, y)
+		return total, err
+	}
+	for i, layer := range model.Layers {
+		for k, p := range layer.GetParams() {
+			worst, err := checkMatrix(p, grads[i][k], objective, DefaultGradCheckEpsilon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if worst > layerTolerance {
+				t.Errorf("layer %d %s: relative gradient error %.3e exceeds %.0e", i, layer.GetParamNames()[k], worst, layerTolerance)
+			}
+		}
+	}
+}
+
+func TestZeroWeightHeadIsIgnored(t *testing.T) {
+	rng := rand.New(rand.NewSource(22))
+	model := twoHeadModel(rng)
+	err := model.CompileHeads(NewSGD(0.1, 0),
+		Head{Loss: NewMSE(), Weight: 1},
+		Head{Loss: NewCategoricalCrossEntropy(), Weight: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	X := []*Matrix{uniformMatrix(rng, 4, 3, -1, 1)}
+	y := []*Matrix{uniformMatrix(rng, 4, 2, -1, 1), oneHotMatrix(rng, 4, 3)}
+
+	// Layers in topological order: trunk, regression, class dense, softmax
+	classDense := model.Layers[2]
+	if classDense.(*Dense).Weights.Cols != 3 {
+		t.Fatal("unexpected layer order")
+	}
+	before := snapshotParams([]Layer{classDense})
+	total, err := model.TrainOnBatch(X, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, p := range classDense.GetParams() {
+		if !matricesEqual(p, before[0][k]) {
+			t.Errorf("%s of the ignored head changed", classDense.GetParamNames()[k])
+		}
+	}
+
+	report, err := model.EvaluateReport(X, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.HeadLosses["output_1"] <= 0 {
+		t.Error("the ignored head reports no loss")
+	}
+	if report.Loss != report.HeadLosses["output_0"] {
+		t.Errorf("total loss %v, expected the first head loss %v", report.Loss, report.HeadLosses["output_0"])
+	}
+	if total <= 0 {
+		t.Errorf("training loss %v", total)
+	}
+}
+
+// snapshotParams copies the parameters of every layer
+func snapshotParams(layers []Layer) [][]*Matrix {
+	snapshot := make([][]*Matrix, len(layers))
+	for i, layer := range layers {
+		for _, p := range layer.GetParams() {
+			snapshot[i] = append(snapshot[i], copyMatrix(p))
+		}
+	}
+	return snapshot
+}
+
+// snapshotGrads copies the gradients of every layer
+func snapshotGrads(layers []Layer) [][]*Matrix {
+	snapshot := make([][]*Matrix, len(layers))
+	for i, layer := range layers {
+		for _, g := range layer.GetGrads() {
+			snapshot[i] = append(snapshot[i], copyMatrix(g))
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,293 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Initializer fills a parameter matrix in place. fanIn and fanOut are the
+// number of inputs and outputs connected to each unit of the layer; for
+// convolution kernels they include the receptive field size.
+type Initializer interface {
+	Initialize(m *Matrix, fanIn, fanOut int)
+}
+
+// Zeros initializes every element to 0
+type Zeros struct{}
+
+// Initialize fills m with zeros
+func (Zeros) Initialize(m *Matrix, fanIn, fanOut int) {
+	Constant{Value: 0}.Initialize(m, fanIn, fanOut)
+}
+
+// Ones initializes every element to 1
+type Ones struct{}
+
+// Initialize fills m with ones
+func (Ones) Initialize(m *Matrix, fanIn, fanOut int) {
+	Constant{Value: 1}.Initialize(m, fanIn, fanOut)
+}
+
+// Constant initializes every element to Value
+type Constant struct {
+	Value float64
+}
+
+// Initialize fills m with Value
+func (c Constant) Initialize(m *Matrix, fanIn, fanOut int) {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			m.Data[i][j] = c.Value
+		}
+	}
+}
+
+// Uniform samples from U(Min, Max)
+type Uniform struct {
+	Min float64
+	Max float64
+}
+
+// Initialize fills m with uniform samples
+func (u Uniform) Initialize(m *Matrix, fanIn, fanOut int) {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			m.Data[i][j] = u.Min + rand.Float64()*(u.Max-u.Min)
+		}
+	}
+}
+
+// Normal samples from N(Mean, StdDev²)
+type Normal struct {
+	Mean   float64
+	StdDev float64
+}
+
+// Initialize fills m with normal samples
+func (n Normal) Initialize(m *Matrix, fanIn, fanOut int) {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			m.Data[i][j] = n.Mean + rand.NormFloat64()*n.StdDev
+		}
+	}
+}
+
+// TruncatedNormal samples from N(Mean, StdDev²), redrawing values that fall
+// more than two standard deviations from the mean
+type TruncatedNormal struct {
+	Mean   float64
+	StdDev float64
+}
+
+// Initialize fills m with truncated normal samples
+func (t TruncatedNormal) Initialize(m *Matrix, fanIn, fanOut int) {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			z := rand.NormFloat64()
+			for math.Abs(z) > 2 {
+				z = rand.NormFloat64()
+			}
+			m.Data[i][j] = t.Mean + z*t.StdDev
+		}
+	}
+}
+
+// varianceScaling samples with variance scale/n from a normal distribution
+// or from a uniform distribution of the same variance
+func varianceScaling(m *Matrix, scale float64, n int, uniform bool) {
+	if n < 1 {
+		n = 1
+	}
+	variance := scale / float64(n)
+	if uniform {
+		limit := math.Sqrt(3 * variance)
+		Uniform{Min: -limit, Max: limit}.Initialize(m, 0, 0)
+		return
+	}
+	Normal{StdDev: math.Sqrt(variance)}.Initialize(m, 0, 0)
+}
+
+// GlorotUniform samples from U(-l, l) with l = sqrt(6/(fanIn+fanOut))
+type GlorotUniform struct{}
+
+// Initialize fills m with Glorot uniform samples
+func (GlorotUniform) Initialize(m *Matrix, fanIn, fanOut int) {
+	varianceScaling(m, 2, fanIn+fanOut, true)
+}
+
+// GlorotNormal samples from N(0, 2/(fanIn+fanOut))
+type GlorotNormal struct{}
+
+// Initialize fills m with Glorot normal samples
+func (GlorotNormal) Initialize(m *Matrix, fanIn, fanOut int) {
+	varianceScaling(m, 2, fanIn+fanOut, false)
+}
+
+// XavierUniform is another name for GlorotUniform
+type XavierUniform = GlorotUniform
+
+// XavierNormal is another name for GlorotNormal
+type XavierNormal = GlorotNormal
+
+// HeUniform samples from U(-l, l) with l = sqrt(6/fanIn)
+type HeUniform struct{}
+
+// Initialize fills m with He uniform samples
+func (HeUniform) Initialize(m *Matrix, fanIn, fanOut int) {
+	varianceScaling(m, 2, fanIn, true)
+}
+
+// HeNormal samples from N(0, 2/fanIn)
+type HeNormal struct{}
+
+// Initialize fills m with He normal samples
+func (HeNormal) Initialize(m *Matrix, fanIn, fanOut int) {
+	varianceScaling(m, 2, fanIn, false)
+}
+
+// LeCunUniform samples from U(-l, l) with l = sqrt(3/fanIn)
+type LeCunUniform struct{}
+
+// Initialize fills m with LeCun uniform samples
+func (LeCunUniform) Initialize(m *Matrix, fanIn, fanOut int) {
+	varianceScaling(m, 1, fanIn, true)
+}
+
+// LeCunNormal samples from N(0, 1/fanIn)
+type LeCunNormal struct{}
+
+// Initialize fills m with LeCun normal samples
+func (LeCunNormal) Initialize(m *Matrix, fanIn, fanOut int) {
+	varianceScaling(m, 1, fanIn, false)
+}
+
+// Orthogonal produces a (semi-)orthogonal matrix scaled by Gain: the rows
+// are orthonormal if Rows <= Cols, the columns otherwise. A zero Gain is
+// treated as 1.
+type Orthogonal struct {
+	Gain float64
+}
+
+// Initialize fills m by orthonormalizing a random normal matrix with
+// modified Gram-Schmidt
+func (o Orthogonal) Initialize(m *Matrix, fanIn, fanOut int) {
+	gain := o.Gain
+	if gain == 0 {
+		gain = 1
+	}
+
+	// Orthonormalize the shorter dimension as vectors of the longer one
+	count, length := m.Rows, m.Cols
+	transposed := m.Rows > m.Cols
+	if transposed {
+		count, length = m.Cols, m.Rows
+	}
+
+	vectors := randomNormalVectors(count, length)
+	for i := 0; i < count; i++ {
+		for k := 0; k < i; k++ {
+			dot := 0.0
+			for j := 0; j < length; j++ {
+				dot += vectors[i][j] * vectors[k][j]
+			}
+			for j := 0; j < length; j++ {
+				vectors[i][j] -= dot * vectors[k][j]
+			}
+		}
+		norm := 0.0
+		for j := 0; j < length; j++ {
+			norm += vectors[i][j] * vectors[i][j]
+		}
+		norm = math.Sqrt(norm)
+		for j := 0; j < length; j++ {
+			vectors[i][j] /= norm
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		for j := 0; j < length; j++ {
+			if transposed {
+				m.Data[j][i] = gain * vectors[i][j]
+			} else {
+				m.Data[i][j] = gain * vectors[i][j]
+			}
+		}
+	}
+}
+
+// randomNormalVectors returns count vectors of standard normal samples
+func randomNormalVectors(count, length int) [][]float64 {
+	vectors := make([][]float64, count)
+	for i := range vectors {
+		vectors[i] = make([]float64, length)
+		for j := range vectors[i] {
+			vectors[i][j] = rand.NormFloat64()
+		}
+	}
+	return vectors
+}
+
+// InitOption selects the initializer of the weights or the bias in a layer
+// constructor, e.g. NewDense(4, 2, WithWeights(GlorotUniform{})). Without
+// options every layer uses its documented defaults.
+type InitOption func(*initOptions)
+
+// initOptions holds the initializers chosen by InitOptions; nil keeps the
+// default of the layer
+type initOptions struct {
+	weights Initializer
+	bias    Initializer
+}
+
+// WithWeights initializes the weights of a layer: dense, convolution,
+// attention and recurrent input kernels, embedding and learned positional
+// tables, and the gamma of normalization layers
+func WithWeights(init Initializer) InitOption {
+	return func(o *initOptions) {
+		o.weights = init
+	}
+}
+
+// WithBias initializes the biases of a layer, including the beta of
+// normalization layers. Layers without a bias ignore it.
+func WithBias(init Initializer) InitOption {
+	return func(o *initOptions) {
+		o.bias = init
+	}
+}
+
+// initializers returns the weight and bias initializers chosen by opts,
+// falling back to defWeights and defBias
+func initializers(opts []InitOption, defWeights, defBias Initializer) (Initializer, Initializer) {
+	var o initOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.weights == nil {
+		o.weights = defWeights
+	}
+	if o.bias == nil {
+		o.bias = defBias
+	}
+	return o.weights, o.bias
+}
+
+// initializedMatrix returns a rows x cols matrix filled by init
+func initializedMatrix(init Initializer, rows, cols, fanIn, fanOut int) *Matrix {
+	m := NewMatrix(rows, cols)
+	init.Initialize(m, fanIn, fanOut)
+	return m
+}
+
+// initializedMatrixOf is initializedMatrix for element type T. Values are
+// drawn in float64 and rounded.
+func initializedMatrixOf[T Float](init Initializer, rows, cols, fanIn, fanOut int) *MatrixOf[T] {
+	values := initializedMatrix(init, rows, cols, fanIn, fanOut)
+	m := NewMatrixOf[T](rows, cols)
+	for i := range values.Data {
+		for j, v := range values.Data[i] {
+			m.Data[i][j] = T(v)
+		}
+	}
+	return m
+}
@@ -0,0 +1,136 @@
+package nn
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConstantInitializers(t *testing.T) {
+	m := NewMatrix(2, 3)
+	Constant{Value: 0.5}.Initialize(m, 2, 3)
+	assertMatrix(t, "constant", m, [][]float64{{0.5, 0.5, 0.5}, {0.5, 0.5, 0.5}}, 0)
+	Ones{}.Initialize(m, 2, 3)
+	assertMatrix(t, "ones", m, [][]float64{{1, 1, 1}, {1, 1, 1}}, 0)
+	Zeros{}.Initialize(m, 2, 3)
+	assertMatrix(t, "zeros", m, [][]float64{{0, 0, 0}, {0, 0, 0}}, 0)
+}
+
+func TestVarianceScalingBounds(t *testing.T) {
+	tests := []struct {
+		name  string
+		init  Initializer
+		bound float64
+	}{
+		// Uniform limits sqrt(3 * scale / n)
+		{"glorot_uniform", GlorotUniform{}, math.Sqrt(6.0 / (40 + 60))},
+		{"he_uniform", HeUniform{}, math.Sqrt(6.0 / 40)},
+		{"lecun_uniform", LeCunUniform{}, math.Sqrt(3.0 / 40)},
+	}
+	for _, tt := range tests {
+		m := NewMatrix(40, 60)
+		tt.init.Initialize(m, 40, 60)
+		worst := 0.0
+		for i := range m.Data {
+			for _, v := range m.Data[i] {
+				worst = math.Max(worst, math.Abs(v))
+			}
+		}
+		if worst > tt.bound || worst < 0.9*tt.bound {
+			t.Errorf("%s: largest magnitude %.4f, expected just below %.4f", tt.name, worst, tt.bound)
+		}
+	}
+}
+
+func TestOrthogonal(t *testing.T) {
+	for _, shape := range [][2]int{{4, 4}, {3, 6}, {6, 3}} {
+		m := NewMatrix(shape[0], shape[1])
+		Orthogonal{}.Initialize(m, shape[0], shape[1])
+
+		// The shorter side holds orthonormal vectors
+		transposed := m.Rows > m.Cols
+		count, length := m.Rows, m.Cols
+		if transposed {
+			count, length = m.Cols, m.Rows
+		}
+		at := func(v, k int) float64 {
+			if transposed {
+				return m.Data[k][v]
+			}
+			return m.Data[v][k]
+		}
+		for a := 0; a < count; a++ {
+			for b := 0; b < count; b++ {
+				dot := 0.0
+				for k := 0; k < length; k++ {
+					dot += at(a, k) * at(b, k)
+				}
+				want := 0.0
+				if a == b {
+					want = 1
+				}
+				if math.Abs(dot-want) > 1e-9 {
+					t.Errorf("%dx%d: vectors %d and %d have dot product %v", shape[0], shape[1], a, b, dot)
+				}
+			}
+		}
+	}
+}
+
+func TestInitOptions(t *testing.T) {
+	two := WithWeights(Constant{Value: 2})
+	three := WithBias(Constant{Value: 3})
+
+	dense := NewDense(3, 2, two, three)
+	assertMatrix(t, "dense weights", dense.Weights, [][]float64{{2, 2}, {2, 2}, {2, 2}}, 0)
+	assertMatrix(t, "dense bias", dense.Bias, [][]float64{{3, 3}}, 0)
+	// A nil initializer keeps the default
+	dense = NewDense(3, 2, WithBias(nil))
+	assertMatrix(t, "dense default bias", dense.Bias, [][]float64{{0, 0}}, 0)
+
+	dense32 := NewDenseOf[float32](2, 2, WithWeights(Constant{Value: 0.1}))
+	if dense32.Weights.Data[1][1] != float32(0.1) {
+		t.Errorf("float32 weight %v, expected 0.1", dense32.Weights.Data[1][1])
+	}
+
+	// The filters view shares storage with the initialized weights
+	conv := NewConvLayer(2, 1, 2, 1, 0, two, three)
+	if conv.Filters[1][0][1][1] != 2 || conv.Bias.Data[0][1] != 3 {
+		t.Errorf("conv filter %v and bias %v, expected 2 and 3", conv.Filters[1][0][1][1], conv.Bias.Data[0][1])
+	}
+	conv1D := NewConv1D(Conv1DConfig{InChannels: 1, OutChannels: 2, KernelSize: 2}, three)
+	assertMatrix(t, "conv 1d bias", conv1D.Bias, [][]float64{{3, 3}}, 0)
+
+	ln := NewLayerNorm(2, three)
+	assertMatrix(t, "layer norm gamma", ln.Gamma, [][]float64{{1, 1}}, 0)
+	assertMatrix(t, "layer norm beta", ln.Beta, [][]float64{{3, 3}}, 0)
+
+	bn := NewBatchNorm2D(2, two)
+	assertMatrix(t, "batch norm gamma", bn.Gamma, [][]float64{{2, 2}}, 0)
+
+	rn := NewRMSNorm(2, WithWeights(Constant{Value: 3}))
+	assertMatrix(t, "rms norm gamma", rn.Gamma, [][]float64{{3, 3}}, 0)
+
+	emb := NewEmbedding(2, 2, two)
+	assertMatrix(t, "embedding", emb.Weights, [][]float64{{2, 2}, {2, 2}}, 0)
+
+	mha := NewMultiHeadAttention(2, 1, false, three)
+	for _, b := range []*Matrix{mha.QueryBias, mha.KeyBias, mha.ValueBias, mha.OutputBias} {
+		assertMatrix(t, "attention bias", b, [][]float64{{3, 3}}, 0)
+	}
+
+	enc := NewTransformerEncoderLayer(2, 1, 4, 0, two)
+	if enc.Attention.QueryKernel.Data[0][1] != 2 || enc.FeedForward2.Layer.GetParams()[0].Data[3][1] != 2 {
+		t.Error("encoder kernels were not initialized by the option")
+	}
+	assertMatrix(t, "encoder norm gamma", enc.Norm1.Gamma, [][]float64{{1, 1}}, 0)
+
+	gru := NewGRU(2, 2, false, two, three)
+	assertMatrix(t, "gru bias", gru.Bias, [][]float64{{3, 3, 3, 3, 3, 3}}, 0)
+	if gru.Kernel.Data[1][5] != 2 {
+		t.Errorf("gru kernel %v, expected 2", gru.Kernel.Data[1][5])
+	}
+
+	// The default LSTM bias opens the forget gate; an option replaces it
+	assertMatrix(t, "lstm bias", NewLSTM(1, 1, false).Bias, [][]float64{{0, 1, 0, 0}}, 0)
+	assertMatrix(t, "lstm bias option", NewLSTM(1, 1, false, three).Bias, [][]float64{{3, 3, 3, 3}}, 0)
+}
@@ -2,57 +2,65 @@ package nn
 
 import (
 	"fmt"
-	"math"
-	"math/rand"
 )
 
-// Layer interface for neural network layers
-type Layer interface {
-	Forward(input *Matrix) (*Matrix, error)
-	Backward(gradOutput *Matrix) (*Matrix, error)
-	GetParams() []*Matrix
-	GetGrads() []*Matrix
+// LayerOf is the interface of neural network layers with element type T
+//
+// Gradient convention: Backward receives dL/dOutput, where L is the scalar
+// returned by Loss.Forward (any 1/N factor of the reduction is already in
+// the gradient produced by Loss.Backward), and returns dL/dInput. Parameter
+// gradients exposed through GetGrads are exact derivatives of L and are
+// never rescaled by the batch size.
+type LayerOf[T Float] interface {
+	Forward(input *MatrixOf[T]) (*MatrixOf[T], error)
+	Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error)
+	GetParams() []*MatrixOf[T]
+	GetGrads() []*MatrixOf[T]
 	GetParamNames() []string
 }
 
-// Dense (Fully Connected) Layer
-type Dense struct {
+// Layer interface for neural network layers. Every layer implements it
+// and is also available in float32 (see NewDenseOf).
+type Layer = LayerOf[float64]
+
+// DenseOf is a fully connected layer with element type T
+type DenseOf[T Float] struct {
 	InputSize  int
 	OutputSize int
-	Weights    *Matrix // Shape: (InputSize, OutputSize)
-	Bias       *Matrix // Shape: (1, OutputSize)
+	Weights    *MatrixOf[T] // Shape: (InputSize, OutputSize)
+	Bias       *MatrixOf[T] // Shape: (1, OutputSize)
 
 	// Cache for backward pass
-	lastInput   *Matrix
-	weightsGrad *Matrix
-	biasGrad    *Matrix
-}
-
-// NewDense creates a new dense layer with He initialization
-func NewDense(inputSize, outputSize int) *Dense {
-	// He initialization: scale by sqrt(2/inputSize)
-	weights := NewMatrix(inputSize, outputSize)
-	scale := math.Sqrt(2.0 / float64(inputSize))
-	for i := 0; i < inputSize; i++ {
-		for j := 0; j < outputSize; j++ {
-			weights.Data[i][j] = rand.NormFloat64() * scale
-		}
-	}
+	lastInput   *MatrixOf[T]
+	weightsGrad *MatrixOf[T]
+	biasGrad    *MatrixOf[T]
+}
 
-	bias := NewMatrix(1, outputSize)
+// Dense (Fully Connected) Layer
+type Dense = DenseOf[float64]
+
+// NewDense creates a new dense layer with HeNormal weights and a zero bias,
+// unless opts select other initializers
+func NewDense(inputSize, outputSize int, opts ...InitOption) *Dense {
+	return NewDenseOf[float64](inputSize, outputSize, opts...)
+}
 
-	return &Dense{
+// NewDenseOf creates a new dense layer with element type T, e.g.
+// NewDenseOf[float32]. Initial values are drawn in float64 and rounded.
+func NewDenseOf[T Float](inputSize, outputSize int, opts ...InitOption) *DenseOf[T] {
+	weightInit, biasInit := initializers(opts, HeNormal{}, Zeros{})
+	return &DenseOf[T]{
 		InputSize:   inputSize,
 		OutputSize:  outputSize,
-		Weights:     weights,
-		Bias:        bias,
-		weightsGrad: NewMatrix(inputSize, outputSize),
-		biasGrad:    NewMatrix(1, outputSize),
+		Weights:     initializedMatrixOf[T](weightInit, inputSize, outputSize, inputSize, outputSize),
+		Bias:        initializedMatrixOf[T](biasInit, 1, outputSize, inputSize, outputSize),
+		weightsGrad: NewMatrixOf[T](inputSize, outputSize),
+		biasGrad:    NewMatrixOf[T](1, outputSize),
 	}
 }
 
 // Forward performs forward pass: output = input @ weights + bias
-func (d *Dense) Forward(input *Matrix) (*Matrix, error) {
+func (d *DenseOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
 	if input.Cols != d.InputSize {
 		return nil, fmt.Errorf("input size mismatch: got %d, expected %d", input.Cols, d.InputSize)
 	}
@@ -76,39 +84,37 @@ func (d *Dense) Forward(input *Matrix) (*Matrix, error) {
 }
 
 // Backward computes gradients for backpropagation
-func (d *Dense) Backward(gradOutput *Matrix) (*Matrix, error) {
+func (d *DenseOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
 	if gradOutput.Cols != d.OutputSize {
 		return nil, fmt.Errorf("gradient size mismatch")
 	}
 
-	batchSize := float64(gradOutput.Rows)
-
 	// Compute weight gradient: dL/dW = input^T @ gradOutput
 	// weightsGrad shape: (InputSize, OutputSize)
 	for i := 0; i < d.InputSize; i++ {
 		for j := 0; j < d.OutputSize; j++ {
-			sum := 0.0
+			var sum T
 			for b := 0; b < d.lastInput.Rows; b++ {
 				sum += d.lastInput.Data[b][i] * gradOutput.Data[b][j]
 			}
-			d.weightsGrad.Data[i][j] = sum / batchSize
+			d.weightsGrad.Data[i][j] = sum
 		}
 	}
 
 	// Compute bias gradient: sum over batch dimension
 	for j := 0; j < d.OutputSize; j++ {
-		sum := 0.0
+		var sum T
 		for i := 0; i < gradOutput.Rows; i++ {
 			sum += gradOutput.Data[i][j]
 		}
-		d.biasGrad.Data[0][j] = sum / batchSize
+		d.biasGrad.Data[0][j] = sum
 	}
 
 	// Compute input gradient: gradOutput @ weights^T
-	gradInput := NewMatrix(gradOutput.Rows, d.InputSize)
+	gradInput := NewMatrixOf[T](gradOutput.Rows, d.InputSize)
 	for i := 0; i < gradOutput.Rows; i++ {
 		for j := 0; j < d.InputSize; j++ {
-			sum := 0.0
+			var sum T
 			for k := 0; k < d.OutputSize; k++ {
 				sum += gradOutput.Data[i][k] * d.Weights.Data[j][k]
 			}
@@ -120,39 +126,55 @@ func (d *Dense) Backward(gradOutput *Matrix) (*Matrix, error) {
 }
 
 // GetParams returns the parameters of the layer
-func (d *Dense) GetParams() []*Matrix {
-	return []*Matrix{d.Weights, d.Bias}
+func (d *DenseOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{d.Weights, d.Bias}
 }
 
 // GetGrads returns the gradients of the parameters
-func (d *Dense) GetGrads() []*Matrix {
-	return []*Matrix{d.weightsGrad, d.biasGrad}
+func (d *DenseOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{d.weightsGrad, d.biasGrad}
 }
 
 // GetParamNames returns names for the parameters
-func (d *Dense) GetParamNames() []string {
+func (d *DenseOf[T]) GetParamNames() []string {
 	return []string{"weights", "bias"}
 }
 
-// ReLULayer activation layer
-type ReLULayer struct {
-	lastInput *Matrix
+// ReLULayerOf is a ReLU activation layer with element type T
+type ReLULayerOf[T Float] struct {
+	lastInput *MatrixOf[T]
 }
 
+// ReLULayer activation layer
+type ReLULayer = ReLULayerOf[float64]
+
 // NewReLULayer creates a new ReLU activation layer
 func NewReLULayer() *ReLULayer {
 	return &ReLULayer{}
 }
 
+// NewReLULayerOf creates a new ReLU activation layer with element type T
+func NewReLULayerOf[T Float]() *ReLULayerOf[T] {
+	return &ReLULayerOf[T]{}
+}
+
 // Forward applies ReLU activation
-func (r *ReLULayer) Forward(input *Matrix) (*Matrix, error) {
+func (r *ReLULayerOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
 	r.lastInput = input
-	return ReLUMatrix(input), nil
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+	for i := 0; i < input.Rows; i++ {
+		for j := 0; j < input.Cols; j++ {
+			if input.Data[i][j] > 0 {
+				output.Data[i][j] = input.Data[i][j]
+			}
+		}
+	}
+	return output, nil
 }
 
 // Backward computes gradient for ReLU
-func (r *ReLULayer) Backward(gradOutput *Matrix) (*Matrix, error) {
-	gradInput := NewMatrix(gradOutput.Rows, gradOutput.Cols)
+func (r *ReLULayerOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
 	for i := 0; i < gradOutput.Rows; i++ {
 		for j := 0; j < gradOutput.Cols; j++ {
 			if r.lastInput.Data[i][j] > 0 {
@@ -164,54 +186,76 @@ func (r *ReLULayer) Backward(gradOutput *Matrix) (*Matrix, error) {
 }
 
 // GetParams returns empty slice (no learnable parameters)
-func (r *ReLULayer) GetParams() []*Matrix {
-	return []*Matrix{}
+func (r *ReLULayerOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
 }
 
 // GetGrads returns empty slice
-func (r *ReLULayer) GetGrads() []*Matrix {
-	return []*Matrix{}
+func (r *ReLULayerOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
 }
 
 // GetParamNames returns empty slice
-func (r *ReLULayer) GetParamNames() []string {
+func (r *ReLULayerOf[T]) GetParamNames() []string {
 	return []string{}
 }
 
-// SoftmaxLayer activation layer
-type SoftmaxLayer struct {
-	lastOutput *Matrix
+// SoftmaxLayerOf is a softmax activation layer with element type T
+type SoftmaxLayerOf[T Float] struct {
+	lastOutput *MatrixOf[T]
 }
 
+// SoftmaxLayer activation layer
+type SoftmaxLayer = SoftmaxLayerOf[float64]
+
 // NewSoftmaxLayer creates a new softmax layer
 func NewSoftmaxLayer() *SoftmaxLayer {
 	return &SoftmaxLayer{}
 }
 
+// NewSoftmaxLayerOf creates a new softmax layer with element type T
+func NewSoftmaxLayerOf[T Float]() *SoftmaxLayerOf[T] {
+	return &SoftmaxLayerOf[T]{}
+}
+
 // Forward applies softmax activation
-func (s *SoftmaxLayer) Forward(input *Matrix) (*Matrix, error) {
-	s.lastOutput = SoftmaxMatrix(input)
+func (s *SoftmaxLayerOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	s.lastOutput = softmaxRows(input)
 	return s.lastOutput, nil
 }
 
-// Backward computes gradient for softmax (simplified for use with cross-entropy)
-func (s *SoftmaxLayer) Backward(gradOutput *Matrix) (*Matrix, error) {
-	// When used with cross-entropy, the gradient is simply passed through
-	// The actual softmax gradient is handled in the loss function
-	return gradOutput, nil
+// Backward computes gradient for softmax
+// dL/dx_j = s_j * (g_j - Σ_k g_k*s_k), which reduces to (s - y)/N after
+// CategoricalCrossEntropy.Backward
+func (s *SoftmaxLayerOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gradOutput.Rows != s.lastOutput.Rows || gradOutput.Cols != s.lastOutput.Cols {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
+	for i := 0; i < gradOutput.Rows; i++ {
+		var dot T
+		for j := 0; j < gradOutput.Cols; j++ {
+			dot += gradOutput.Data[i][j] * s.lastOutput.Data[i][j]
+		}
+		for j := 0; j < gradOutput.Cols; j++ {
+			gradInput.Data[i][j] = s.lastOutput.Data[i][j] * (gradOutput.Data[i][j] - dot)
+		}
+	}
+	return gradInput, nil
 }
 
 // GetParams returns empty slice
-func (s *SoftmaxLayer) GetParams() []*Matrix {
-	return []*Matrix{}
+func (s *SoftmaxLayerOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
 }
 
 // GetGrads returns empty slice
-func (s *SoftmaxLayer) GetGrads() []*Matrix {
-	return []*Matrix{}
+func (s *SoftmaxLayerOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
 }
 
 // GetParamNames returns empty slice
-func (s *SoftmaxLayer) GetParamNames() []string {
+func (s *SoftmaxLayerOf[T]) GetParamNames() []string {
 	return []string{}
 }
@@ -0,0 +1,272 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// layerTolerance is the largest relative error accepted for layers and
+// models. It is looser than gradTolerance because the random projection of
+// GradCheck yields tiny gradients that lose digits in the central
+// differences; a missing or extra 1/N factor still shows as an error near 0.5.
+const layerTolerance = 1e-3
+
+// layerCase builds a layer and the width of its input rows
+type layerCase struct {
+	name    string
+	layer   func() Layer
+	layer32 func() LayerOf[float32] // The same layer in float32
+	cols    int
+	lo      float64 // Input range, [-1, 1) when both bounds are 0
+	hi      float64
+}
+
+var layerCases = []layerCase{
+	{name: "dense", layer: func() Layer { return NewDense(6, 4) }, cols: 6,
+		layer32: func() LayerOf[float32] { return NewDenseOf[float32](6, 4) }},
+	{name: "relu", layer: func() Layer { return NewReLULayer() }, cols: 6,
+		layer32: func() LayerOf[float32] { return NewReLULayerOf[float32]() }},
+	{name: "softmax", layer: func() Layer { return NewSoftmaxLayer() }, cols: 5,
+		layer32: func() LayerOf[float32] { return NewSoftmaxLayerOf[float32]() }},
+	{name: "time_distributed", layer: func() Layer { return NewTimeDistributed(NewDense(3, 2), 3) }, cols: 4 * 3,
+		layer32: func() LayerOf[float32] { return NewTimeDistributedOf[float32](NewDenseOf[float32](3, 2), 3) }},
+	{name: "embedding", layer: func() Layer { return NewEmbedding(7, 3) }, cols: 4, lo: 0, hi: 6,
+		layer32: func() LayerOf[float32] { return NewEmbeddingOf[float32](7, 3) }},
+	{name: "batch_norm_1d", layer: func() Layer { return NewBatchNorm1D(5) }, cols: 5,
+		layer32: func() LayerOf[float32] { return NewBatchNorm1DOf[float32](5) }},
+	{name: "batch_norm_2d", layer: func() Layer { return NewBatchNorm2D(2) }, cols: 2 * 3 * 3,
+		layer32: func() LayerOf[float32] { return NewBatchNorm2DOf[float32](2) }},
+	{name: "layer_norm", layer: func() Layer { return NewLayerNorm(6) }, cols: 6,
+		layer32: func() LayerOf[float32] { return NewLayerNormOf[float32](6) }},
+	{name: "group_norm", layer: func() Layer { return NewGroupNorm(2, 4) }, cols: 4 * 2 * 2,
+		layer32: func() LayerOf[float32] { return NewGroupNormOf[float32](2, 4) }},
+	{name: "rms_norm", layer: func() Layer { return NewRMSNorm(6) }, cols: 6,
+		layer32: func() LayerOf[float32] { return NewRMSNormOf[float32](6) }},
+	{name: "simple_rnn", layer: func() Layer { return NewSimpleRNN(3, 4, false) }, cols: 4 * 3,
+		layer32: func() LayerOf[float32] { return NewSimpleRNNOf[float32](3, 4, false) }},
+	{name: "simple_rnn_sequences", layer: func() Layer { return NewSimpleRNN(3, 4, true) }, cols: 4 * 3,
+		layer32: func() LayerOf[float32] { return NewSimpleRNNOf[float32](3, 4, true) }},
+	{name: "lstm", layer: func() Layer { return NewLSTM(3, 4, false) }, cols: 4 * 3,
+		layer32: func() LayerOf[float32] { return NewLSTMOf[float32](3, 4, false) }},
+	{name: "lstm_sequences", layer: func() Layer { return NewLSTM(3, 4, true) }, cols: 4 * 3,
+		layer32: func() LayerOf[float32] { return NewLSTMOf[float32](3, 4, true) }},
+	{name: "gru", layer: func() Layer { return NewGRU(3, 4, false) }, cols: 4 * 3,
+		layer32: func() LayerOf[float32] { return NewGRUOf[float32](3, 4, false) }},
+	{name: "gru_sequences", layer: func() Layer { return NewGRU(3, 4, true) }, cols: 4 * 3,
+		layer32: func() LayerOf[float32] { return NewGRUOf[float32](3, 4, true) }},
+	{name: "bidirectional", layer: func() Layer { return NewBidirectional(NewLSTM(3, 2, true), NewGRU(3, 2, true)) }, layer32: func() LayerOf[float32] {
+		return NewBidirectionalOf[float32](NewLSTMOf[float32](3, 2, true), NewGRUOf[float32](3, 2, true))
+	}, cols: 4 * 3},
+	{name: "multi_head_attention", layer: func() Layer { return NewMultiHeadAttention(4, 2, false) }, cols: 3 * 4,
+		layer32: func() LayerOf[float32] { return NewMultiHeadAttentionOf[float32](4, 2, false) }},
+	{name: "causal_attention", layer: func() Layer { return NewMultiHeadAttention(4, 2, true) }, cols: 3 * 4,
+		layer32: func() LayerOf[float32] { return NewMultiHeadAttentionOf[float32](4, 2, true) }},
+	{name: "sinusoidal_encoding", layer: func() Layer { return NewSinusoidalPositionalEncoding(5, 4) }, cols: 3 * 4,
+		layer32: func() LayerOf[float32] { return NewSinusoidalPositionalEncodingOf[float32](5, 4) }},
+	{name: "learned_encoding", layer: func() Layer { return NewLearnedPositionalEncoding(5, 4) }, cols: 3 * 4,
+		layer32: func() LayerOf[float32] { return NewLearnedPositionalEncodingOf[float32](5, 4) }},
+	{name: "transformer_encoder", layer: func() Layer { return NewTransformerEncoderLayer(4, 2, 8, 0.1) }, cols: 3 * 4,
+		layer32: func() LayerOf[float32] { return NewTransformerEncoderLayerOf[float32](4, 2, 8, 0.1) }},
+	{name: "conv_1d", layer: func() Layer {
+		return NewConv1D(Conv1DConfig{InChannels: 2, OutChannels: 3, KernelSize: 3, Padding: PaddingSame})
+	}, layer32: func() LayerOf[float32] {
+		return NewConv1DOf[float32](Conv1DConfig{InChannels: 2, OutChannels: 3, KernelSize: 3, Padding: PaddingSame})
+	}, cols: 5 * 2},
+	{name: "grouped_conv_1d", layer: func() Layer {
+		return NewConv1D(Conv1DConfig{InChannels: 4, OutChannels: 2, KernelSize: 2, Stride: 2, Dilation: 1, Groups: 2, Padding: PaddingValid})
+	}, layer32: func() LayerOf[float32] {
+		return NewConv1DOf[float32](Conv1DConfig{InChannels: 4, OutChannels: 2, KernelSize: 2, Stride: 2, Dilation: 1, Groups: 2, Padding: PaddingValid})
+	}, cols: 6 * 4},
+	{name: "max_pool_1d", layer: func() Layer { return NewMaxPool1D(2, 2, 2) }, cols: 6 * 2,
+		layer32: func() LayerOf[float32] { return NewMaxPool1DOf[float32](2, 2, 2) }},
+	{name: "avg_pool_1d", layer: func() Layer { return NewAvgPool1D(2, 3, 1) }, cols: 6 * 2,
+		layer32: func() LayerOf[float32] { return NewAvgPool1DOf[float32](2, 3, 1) }},
+	{name: "adaptive_avg_pool_1d", layer: func() Layer { return NewAdaptiveAvgPool1D(2, 4) }, cols: 6 * 2,
+		layer32: func() LayerOf[float32] { return NewAdaptiveAvgPool1DOf[float32](2, 4) }},
+	{name: "global_avg_pool_1d", layer: func() Layer { return NewGlobalAveragePool1D(2) }, cols: 6 * 2,
+		layer32: func() LayerOf[float32] { return NewGlobalAveragePool1DOf[float32](2) }},
+	{name: "global_max_pool_1d", layer: func() Layer { return NewGlobalMaxPool1D(2) }, cols: 6 * 2,
+		layer32: func() LayerOf[float32] { return NewGlobalMaxPool1DOf[float32](2) }},
+	{name: "conv_2d", layer: func() Layer { return NewSpatial(NewConvLayer(3, 2, 3, 1, 1), 2, 4, 4) }, layer32: func() LayerOf[float32] {
+		return NewSpatialOf[float32](NewConv2DOf[float32](Conv2DConfig{InChannels: 2, OutChannels: 3, KernelH: 3, KernelW: 3, PadH: 1, PadW: 1}), 2, 4, 4)
+	}, cols: 2 * 4 * 4},
+	{name: "strided_dilated_conv_2d", layer: func() Layer {
+		return NewSpatial(NewConv2D(Conv2DConfig{InChannels: 2, OutChannels: 2, KernelH: 2, KernelW: 3, StrideH: 2, DilationW: 2, Padding: PaddingSame}), 2, 5, 5)
+	}, layer32: func() LayerOf[float32] {
+		return NewSpatialOf[float32](NewConv2DOf[float32](Conv2DConfig{InChannels: 2, OutChannels: 2, KernelH: 2, KernelW: 3, StrideH: 2, DilationW: 2, Padding: PaddingSame}), 2, 5, 5)
+	}, cols: 2 * 5 * 5},
+	{name: "depthwise_conv_2d", layer: func() Layer {
+		return NewSpatial(NewDepthwiseConv2D(2, 2, Conv2DConfig{KernelH: 3, KernelW: 3, Padding: PaddingValid}), 2, 4, 4)
+	}, layer32: func() LayerOf[float32] {
+		return NewSpatialOf[float32](NewConv2DOf[float32](Conv2DConfig{InChannels: 2, OutChannels: 4, KernelH: 3, KernelW: 3, Groups: 2, Padding: PaddingValid}), 2, 4, 4)
+	}, cols: 2 * 4 * 4},
+	{name: "conv_2d_transpose", layer: func() Layer {
+		return NewSpatial(NewConv2DTranspose(Conv2DConfig{InChannels: 2, OutChannels: 3, KernelH: 3, KernelW: 3, StrideH: 2, StrideW: 2, Padding: PaddingValid}), 2, 3, 3)
+	}, layer32: func() LayerOf[float32] {
+		return NewSpatialOf[float32](NewConv2DTransposeOf[float32](Conv2DConfig{InChannels: 2, OutChannels: 3, KernelH: 3, KernelW: 3, StrideH: 2, StrideW: 2, Padding: PaddingValid}), 2, 3, 3)
+	}, cols: 2 * 3 * 3},
+	{name: "max_pool_2d", layer: func() Layer { return NewSpatial(NewCheckedMaxPool2D(2, 2), 2, 4, 4) }, cols: 2 * 4 * 4,
+		layer32: func() LayerOf[float32] { return NewSpatialOf[float32](NewCheckedMaxPool2DOf[float32](2, 2), 2, 4, 4) }},
+	{name: "avg_pool_2d", layer: func() Layer { return NewSpatial(NewAvgPool2D(2, 1), 2, 4, 4) }, cols: 2 * 4 * 4,
+		layer32: func() LayerOf[float32] { return NewSpatialOf[float32](NewAvgPool2DOf[float32](2, 1), 2, 4, 4) }},
+	{name: "adaptive_avg_pool_2d", layer: func() Layer { return NewSpatial(NewAdaptiveAvgPool2D(3, 2), 2, 5, 5) }, cols: 2 * 5 * 5,
+		layer32: func() LayerOf[float32] { return NewSpatialOf[float32](NewAdaptiveAvgPool2DOf[float32](3, 2), 2, 5, 5) }},
+	{name: "global_avg_pool_2d", layer: func() Layer { return NewSpatial(NewGlobalAveragePool2D(), 2, 3, 3) }, cols: 2 * 3 * 3,
+		layer32: func() LayerOf[float32] { return NewSpatialOf[float32](NewGlobalAveragePool2DOf[float32](), 2, 3, 3) }},
+	{name: "global_max_pool_2d", layer: func() Layer { return NewSpatial(NewGlobalMaxPool2D(), 2, 3, 3) }, cols: 2 * 3 * 3,
+		layer32: func() LayerOf[float32] { return NewSpatialOf[float32](NewGlobalMaxPool2DOf[float32](), 2, 3, 3) }},
+	{name: "dropout", layer: func() Layer { return NewDropout(0.5) }, cols: 6,
+		layer32: func() LayerOf[float32] { return NewDropoutOf[float32](0.5) }},
+	{name: "alpha_dropout", layer: func() Layer { return NewAlphaDropout(0.5) }, cols: 6,
+		layer32: func() LayerOf[float32] { return NewAlphaDropoutOf[float32](0.5) }},
+	{name: "spatial_dropout_2d", layer: func() Layer { return NewSpatialDropout2D(0.5, 2) }, cols: 2 * 3 * 3,
+		layer32: func() LayerOf[float32] { return NewSpatialDropout2DOf[float32](0.5, 2) }},
+}
+
+// randomizeParams overwrites the parameters of layer with values from rng
+// so the checks do not depend on the global source used by initializers
+func randomizeParams(rng *rand.Rand, layer Layer) {
+	for _, p := range layer.GetParams() {
+		for i := range p.Data {
+			for j := range p.Data[i] {
+				p.Data[i][j] = rng.Float64() - 0.5
+			}
+		}
+	}
+}
+
+// input returns a batch of inputs for a layer case
+func (lc layerCase) input(rng *rand.Rand, rows int) *Matrix {
+	if lc.lo == 0 && lc.hi == 0 {
+		return uniformMatrix(rng, rows, lc.cols, -1, 1)
+	}
+	input := uniformMatrix(rng, rows, lc.cols, lc.lo, lc.hi)
+	for i := range input.Data {
+		for j := range input.Data[i] {
+			input.Data[i][j] = float64(int(input.Data[i][j] + 0.5))
+		}
+	}
+	return input
+}
+
+func TestLayerGradients(t *testing.T) {
+	for _, lc := range layerCases {
+		t.Run(lc.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(3))
+			layer := lc.layer()
+			randomizeParams(rng, layer)
+			result, err := GradCheck(layer, lc.input(rng, 3), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Errors) == 0 {
+				t.Fatal("nothing was checked")
+			}
+			if worst := result.Max(); worst > layerTolerance {
+				t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+			}
+		})
+	}
+}
+
+// assertClose32 compares a float32 result with its float64 counterpart
+func assertClose32(t *testing.T, name string, got *Matrix32, want *Matrix) {
+	t.Helper()
+	if got.Rows != want.Rows || got.Cols != want.Cols {
+		t.Fatalf("%s has shape %dx%d, expected %dx%d", name, got.Rows, got.Cols, want.Rows, want.Cols)
+	}
+	for i := range want.Data {
+		for j, w := range want.Data[i] {
+			if g := float64(got.Data[i][j]); math.Abs(g-w) > 1e-4*(1+math.Abs(w)) {
+				t.Fatalf("%s[%d][%d] = %v, expected %v", name, i, j, g, w)
+			}
+		}
+	}
+}
+
+// TestFloat32Layers checks that every layer computes the same outputs and
+// gradients in float32 as in float64, up to rounding
+func TestFloat32Layers(t *testing.T) {
+	for _, lc := range layerCases {
+		t.Run(lc.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(3))
+			layer, layer32 := lc.layer(), lc.layer32()
+			randomizeParams(rng, layer)
+			params32 := layer32.GetParams()
+			for i, p := range layer.GetParams() {
+				for r := range p.Data {
+					for c, v := range p.Data[r] {
+						params32[i].Data[r][c] = float32(v)
+					}
+				}
+			}
+			if s, ok := layer.(Seeder); ok {
+				s.SetSeed(5)
+				layer32.(Seeder).SetSeed(5)
+			}
+
+			input := lc.input(rng, 3)
+			output, err := layer.Forward(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output32, err := layer32.Forward(ConvertMatrix[float32](input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertClose32(t, "output", output32, output)
+
+			gradOutput := uniformMatrix(rng, output.Rows, output.Cols, -1, 1)
+			gradInput, err := layer.Backward(gradOutput)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gradInput32, err := layer32.Backward(ConvertMatrix[float32](gradOutput))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertClose32(t, "input gradient", gradInput32, gradInput)
+			grads32 := layer32.GetGrads()
+			for i, grad := range layer.GetGrads() {
+				assertClose32(t, layer.GetParamNames()[i]+" gradient", grads32[i], grad)
+			}
+		})
+	}
+}
+
+// TestModelGradients checks every loss and reduction end to end through a
+// model, so a layer rescaling its gradient by the batch size is caught
+func TestModelGradients(t *testing.T) {
+	const rows, features, classes = 4, 5, 3
+	for _, lc := range lossCases {
+		for _, r := range reductions {
+			t.Run(fmt.Sprintf("%s/%s", lc.name, r.name), func(t *testing.T) {
+				rng := rand.New(rand.NewSource(4))
+				X := uniformMatrix(rng, rows, features, -1, 1)
+				_, y := lc.inputs(rng, rows, classes)
+
+				model := NewSequential()
+				model.Add(NewDense(features, 6))
+				model.Add(NewLayerNorm(6))
+				model.Add(NewDense(6, classes))
+				if lc.name != "mse" {
+					model.Add(NewSoftmaxLayer())
+				}
+				model.Compile(lc.loss(LossOptions{Reduction: r.reduction, SampleWeights: uniformMatrix(rng, rows, 1, 0.5, 2)}), NewSGD(0.1, 0))
+
+				for _, layer := range model.Layers {
+					randomizeParams(rng, layer)
+				}
+				result, err := GradCheckModel(model, X, y, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if worst := result.Max(); worst > layerTolerance {
+					t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+				}
+			})
+		}
+	}
+}
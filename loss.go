@@ -5,156 +5,306 @@ import (
 	"math"
 )
 
+// LossOf is the interface of loss functions on predictions of element
+// type T. Losses are always returned as float64.
+//
+// Backward returns the gradient of the scalar returned by Forward with
+// respect to the predictions, including the factor applied by the
+// reduction. Layers propagate it unchanged (see Layer).
+type LossOf[T Float] interface {
+	Forward(predictions, targets *MatrixOf[T]) (float64, error)
+	Backward(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error)
+	PerSample(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error)
+}
+
 // Loss interface for different loss functions
-type Loss interface {
-	Forward(predictions, targets *Matrix) (float64, error)
-	Backward(predictions, targets *Matrix) (*Matrix, error)
+type Loss = LossOf[float64]
+
+// Reduction controls how per-sample losses are combined by Forward
+type Reduction int
+
+const (
+	// ReductionMean averages the weighted per-sample losses over the batch
+	ReductionMean Reduction = iota
+	// ReductionSum adds up the weighted per-sample losses
+	ReductionSum
+	// ReductionNone keeps the per-sample losses (see PerSample); Forward
+	// returns their sum and Backward seeds every sample with a gradient of 1
+	ReductionNone
+)
+
+// LossOptions holds the reduction mode and sample weights shared by all losses
+type LossOptions struct {
+	Reduction     Reduction
+	SampleWeights *Matrix // Optional, shape (N, 1); nil weights every sample by 1
 }
 
-// BinaryCrossEntropy loss for binary classification
-type BinaryCrossEntropy struct {
+// checkLoss validates the shapes of predictions, targets and sample weights
+func checkLoss[T Float](o *LossOptions, predictions, targets *MatrixOf[T]) error {
+	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
+		return fmt.Errorf("shape mismatch: predictions %dx%d, targets %dx%d",
+			predictions.Rows, predictions.Cols, targets.Rows, targets.Cols)
+	}
+	if o.SampleWeights != nil && (o.SampleWeights.Rows != predictions.Rows || o.SampleWeights.Cols != 1) {
+		return fmt.Errorf("sample weights shape mismatch: got %dx%d, expected %dx1",
+			o.SampleWeights.Rows, o.SampleWeights.Cols, predictions.Rows)
+	}
+	return nil
+}
+
+// weight returns the weight of sample i
+func (o *LossOptions) weight(i int) float64 {
+	if o.SampleWeights == nil {
+		return 1
+	}
+	return o.SampleWeights.Data[i][0]
+}
+
+// scale returns the factor the reduction applies to each per-sample loss
+func (o *LossOptions) scale(numSamples int) float64 {
+	if o.Reduction == ReductionMean {
+		return 1 / float64(numSamples)
+	}
+	return 1
+}
+
+// reduction returns the reduction mode
+func (o *LossOptions) reduction() Reduction {
+	return o.Reduction
+}
+
+// reductionOf returns the reduction of loss; losses without LossOptions
+// are taken to average over the batch
+func reductionOf[T Float](loss LossOf[T]) Reduction {
+	if r, ok := loss.(interface{ reduction() Reduction }); ok {
+		return r.reduction()
+	}
+	return ReductionMean
+}
+
+// reduceLoss combines weighted per-sample losses into a scalar
+func reduceLoss[T Float](o *LossOptions, perSample *MatrixOf[T]) float64 {
+	total := 0.0
+	for i := 0; i < perSample.Rows; i++ {
+		total += float64(perSample.Data[i][0])
+	}
+	return total * o.scale(perSample.Rows)
+}
+
+// BinaryCrossEntropyOf is the binary cross-entropy loss on predictions of
+// element type T
+type BinaryCrossEntropyOf[T Float] struct {
+	LossOptions
 	Epsilon float64 // Small value to avoid log(0)
 }
 
+// BinaryCrossEntropy loss for binary classification
+type BinaryCrossEntropy = BinaryCrossEntropyOf[float64]
+
 // NewBinaryCrossEntropy creates a new binary cross-entropy loss
 func NewBinaryCrossEntropy() *BinaryCrossEntropy {
-	return &BinaryCrossEntropy{Epsilon: 1e-7}
+	return NewBinaryCrossEntropyOf[float64]()
 }
 
-// Forward computes the binary cross-entropy loss
-// L = -1/N * Σ(y*log(p) + (1-y)*log(1-p))
-func (bce *BinaryCrossEntropy) Forward(predictions, targets *Matrix) (float64, error) {
-	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
-		return 0, fmt.Errorf("shape mismatch: predictions %dx%d, targets %dx%d",
-			predictions.Rows, predictions.Cols, targets.Rows, targets.Cols)
+// NewBinaryCrossEntropyOf creates a new binary cross-entropy loss for
+// predictions of element type T
+func NewBinaryCrossEntropyOf[T Float]() *BinaryCrossEntropyOf[T] {
+	return &BinaryCrossEntropyOf[T]{Epsilon: 1e-7}
+}
+
+// PerSample computes the weighted binary cross-entropy of each sample
+// l_i = -w_i/C * Σ_j(y*log(p) + (1-y)*log(1-p))
+func (bce *BinaryCrossEntropyOf[T]) PerSample(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error) {
+	if err := checkLoss(&bce.LossOptions, predictions, targets); err != nil {
+		return nil, err
 	}
 
-	totalLoss := 0.0
-	n := float64(predictions.Rows * predictions.Cols)
+	losses := NewMatrixOf[T](predictions.Rows, 1)
+	cols := float64(predictions.Cols)
 
 	for i := 0; i < predictions.Rows; i++ {
+		sum := 0.0
 		for j := 0; j < predictions.Cols; j++ {
-			pred := math.Max(bce.Epsilon, math.Min(1-bce.Epsilon, predictions.Data[i][j]))
-			target := targets.Data[i][j]
-			totalLoss += -(target*math.Log(pred) + (1-target)*math.Log(1-pred))
+			pred := math.Max(bce.Epsilon, math.Min(1-bce.Epsilon, float64(predictions.Data[i][j])))
+			target := float64(targets.Data[i][j])
+			sum += -(target*math.Log(pred) + (1-target)*math.Log(1-pred))
 		}
+		losses.Data[i][0] = T(bce.weight(i) * sum / cols)
 	}
 
-	return totalLoss / n, nil
+	return losses, nil
+}
+
+// Forward computes the binary cross-entropy loss
+// L = -1/N * Σ(y*log(p) + (1-y)*log(1-p)) for the default mean reduction
+func (bce *BinaryCrossEntropyOf[T]) Forward(predictions, targets *MatrixOf[T]) (float64, error) {
+	losses, err := bce.PerSample(predictions, targets)
+	if err != nil {
+		return 0, err
+	}
+	return reduceLoss(&bce.LossOptions, losses), nil
 }
 
 // Backward computes the gradient of binary cross-entropy
-// dL/dp = -1/N * (y/p - (1-y)/(1-p))
-func (bce *BinaryCrossEntropy) Backward(predictions, targets *Matrix) (*Matrix, error) {
-	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
-		return nil, fmt.Errorf("shape mismatch")
+// dL/dp = -1/N * (y/p - (1-y)/(1-p)) for the default mean reduction
+func (bce *BinaryCrossEntropyOf[T]) Backward(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error) {
+	if err := checkLoss(&bce.LossOptions, predictions, targets); err != nil {
+		return nil, err
 	}
 
-	gradient := NewMatrix(predictions.Rows, predictions.Cols)
-	n := float64(predictions.Rows * predictions.Cols)
+	gradient := NewMatrixOf[T](predictions.Rows, predictions.Cols)
+	scale := bce.scale(predictions.Rows) / float64(predictions.Cols)
 
 	for i := 0; i < predictions.Rows; i++ {
+		factor := bce.weight(i) * scale
 		for j := 0; j < predictions.Cols; j++ {
-			pred := math.Max(bce.Epsilon, math.Min(1-bce.Epsilon, predictions.Data[i][j]))
-			target := targets.Data[i][j]
-			gradient.Data[i][j] = -(target/pred - (1-target)/(1-pred)) / n
+			pred := math.Max(bce.Epsilon, math.Min(1-bce.Epsilon, float64(predictions.Data[i][j])))
+			target := float64(targets.Data[i][j])
+			gradient.Data[i][j] = T(-(target/pred - (1-target)/(1-pred)) * factor)
 		}
 	}
 
 	return gradient, nil
 }
 
-// CategoricalCrossEntropy loss for multi-class classification
-type CategoricalCrossEntropy struct {
+// CategoricalCrossEntropyOf is the categorical cross-entropy loss on
+// predictions of element type T
+type CategoricalCrossEntropyOf[T Float] struct {
+	LossOptions
 	Epsilon float64
 }
 
+// CategoricalCrossEntropy loss for multi-class classification
+type CategoricalCrossEntropy = CategoricalCrossEntropyOf[float64]
+
 // NewCategoricalCrossEntropy creates a new categorical cross-entropy loss
 func NewCategoricalCrossEntropy() *CategoricalCrossEntropy {
-	return &CategoricalCrossEntropy{Epsilon: 1e-7}
+	return NewCategoricalCrossEntropyOf[float64]()
 }
 
-// Forward computes the categorical cross-entropy loss
-// L = -1/N * Σ(y*log(p))
-func (cce *CategoricalCrossEntropy) Forward(predictions, targets *Matrix) (float64, error) {
-	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
-		return 0, fmt.Errorf("shape mismatch")
+// NewCategoricalCrossEntropyOf creates a new categorical cross-entropy loss
+// for predictions of element type T
+func NewCategoricalCrossEntropyOf[T Float]() *CategoricalCrossEntropyOf[T] {
+	return &CategoricalCrossEntropyOf[T]{Epsilon: 1e-7}
+}
+
+// PerSample computes the weighted categorical cross-entropy of each sample
+// l_i = -w_i * Σ_j(y*log(p))
+func (cce *CategoricalCrossEntropyOf[T]) PerSample(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error) {
+	if err := checkLoss(&cce.LossOptions, predictions, targets); err != nil {
+		return nil, err
 	}
 
-	totalLoss := 0.0
-	n := float64(predictions.Rows)
+	losses := NewMatrixOf[T](predictions.Rows, 1)
 
 	for i := 0; i < predictions.Rows; i++ {
+		sum := 0.0
 		for j := 0; j < predictions.Cols; j++ {
-			pred := math.Max(cce.Epsilon, predictions.Data[i][j])
-			totalLoss += -targets.Data[i][j] * math.Log(pred)
+			pred := math.Max(cce.Epsilon, float64(predictions.Data[i][j]))
+			sum += -float64(targets.Data[i][j]) * math.Log(pred)
 		}
+		losses.Data[i][0] = T(cce.weight(i) * sum)
 	}
 
-	return totalLoss / n, nil
+	return losses, nil
+}
+
+// Forward computes the categorical cross-entropy loss
+// L = -1/N * Σ(y*log(p)) for the default mean reduction
+func (cce *CategoricalCrossEntropyOf[T]) Forward(predictions, targets *MatrixOf[T]) (float64, error) {
+	losses, err := cce.PerSample(predictions, targets)
+	if err != nil {
+		return 0, err
+	}
+	return reduceLoss(&cce.LossOptions, losses), nil
 }
 
 // Backward computes the gradient of categorical cross-entropy
-// dL/dp = -y/p
-func (cce *CategoricalCrossEntropy) Backward(predictions, targets *Matrix) (*Matrix, error) {
-	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
-		return nil, fmt.Errorf("shape mismatch")
+// dL/dp = -y/p / N for the default mean reduction
+func (cce *CategoricalCrossEntropyOf[T]) Backward(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error) {
+	if err := checkLoss(&cce.LossOptions, predictions, targets); err != nil {
+		return nil, err
 	}
 
-	gradient := NewMatrix(predictions.Rows, predictions.Cols)
-	n := float64(predictions.Rows)
+	gradient := NewMatrixOf[T](predictions.Rows, predictions.Cols)
+	scale := cce.scale(predictions.Rows)
 
 	for i := 0; i < predictions.Rows; i++ {
+		factor := cce.weight(i) * scale
 		for j := 0; j < predictions.Cols; j++ {
-			pred := math.Max(cce.Epsilon, predictions.Data[i][j])
-			gradient.Data[i][j] = -targets.Data[i][j] / pred / n
+			pred := math.Max(cce.Epsilon, float64(predictions.Data[i][j]))
+			gradient.Data[i][j] = T(-float64(targets.Data[i][j]) / pred * factor)
 		}
 	}
 
 	return gradient, nil
 }
 
+// MSEOf is the mean squared error loss on predictions of element type T
+type MSEOf[T Float] struct {
+	LossOptions
+}
+
 // MSE (Mean Squared Error) loss for regression
-type MSE struct{}
+type MSE = MSEOf[float64]
 
 // NewMSE creates a new MSE loss
 func NewMSE() *MSE {
-	return &MSE{}
+	return NewMSEOf[float64]()
 }
 
-// Forward computes mean squared error
-// L = 1/(2N) * Σ(y - p)²
-func (mse *MSE) Forward(predictions, targets *Matrix) (float64, error) {
-	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
-		return 0, fmt.Errorf("shape mismatch")
+// NewMSEOf creates a new MSE loss for predictions of element type T
+func NewMSEOf[T Float]() *MSEOf[T] {
+	return &MSEOf[T]{}
+}
+
+// PerSample computes the weighted squared error of each sample
+// l_i = w_i/(2C) * Σ_j(y - p)²
+func (mse *MSEOf[T]) PerSample(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error) {
+	if err := checkLoss(&mse.LossOptions, predictions, targets); err != nil {
+		return nil, err
 	}
 
-	totalLoss := 0.0
-	n := float64(predictions.Rows * predictions.Cols)
+	losses := NewMatrixOf[T](predictions.Rows, 1)
+	cols := float64(predictions.Cols)
 
 	for i := 0; i < predictions.Rows; i++ {
+		sum := 0.0
 		for j := 0; j < predictions.Cols; j++ {
-			diff := targets.Data[i][j] - predictions.Data[i][j]
-			totalLoss += diff * diff
+			diff := float64(targets.Data[i][j]) - float64(predictions.Data[i][j])
+			sum += diff * diff
 		}
+		losses.Data[i][0] = T(mse.weight(i) * sum / (2 * cols))
 	}
 
-	return totalLoss / (2 * n), nil
+	return losses, nil
+}
+
+// Forward computes mean squared error
+// L = 1/(2N) * Σ(y - p)² for the default mean reduction
+func (mse *MSEOf[T]) Forward(predictions, targets *MatrixOf[T]) (float64, error) {
+	losses, err := mse.PerSample(predictions, targets)
+	if err != nil {
+		return 0, err
+	}
+	return reduceLoss(&mse.LossOptions, losses), nil
 }
 
 // Backward computes the gradient of MSE
-// dL/dp = -(y - p) / N
-func (mse *MSE) Backward(predictions, targets *Matrix) (*Matrix, error) {
-	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
-		return nil, fmt.Errorf("shape mismatch")
+// dL/dp = -(y - p) / N for the default mean reduction
+func (mse *MSEOf[T]) Backward(predictions, targets *MatrixOf[T]) (*MatrixOf[T], error) {
+	if err := checkLoss(&mse.LossOptions, predictions, targets); err != nil {
+		return nil, err
 	}
 
-	gradient := NewMatrix(predictions.Rows, predictions.Cols)
-	n := float64(predictions.Rows * predictions.Cols)
+	gradient := NewMatrixOf[T](predictions.Rows, predictions.Cols)
+	scale := mse.scale(predictions.Rows) / float64(predictions.Cols)
 
 	for i := 0; i < predictions.Rows; i++ {
+		factor := mse.weight(i) * scale
 		for j := 0; j < predictions.Cols; j++ {
-			gradient.Data[i][j] = -(targets.Data[i][j] - predictions.Data[i][j]) / n
+			diff := float64(targets.Data[i][j]) - float64(predictions.Data[i][j])
+			gradient.Data[i][j] = T(-diff * factor)
 		}
 	}
 
@@ -0,0 +1,252 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// gradTolerance is the largest relative error accepted by the gradient checks
+const gradTolerance = 1e-6
+
+// uniformMatrix returns a rows x cols matrix of values drawn uniformly
+// from [lo, hi)
+func uniformMatrix(rng *rand.Rand, rows, cols int, lo, hi float64) *Matrix {
+	m := NewMatrix(rows, cols)
+	for i := range m.Data {
+		for j := range m.Data[i] {
+			m.Data[i][j] = lo + (hi-lo)*rng.Float64()
+		}
+	}
+	return m
+}
+
+// oneHotMatrix returns rows random one-hot rows of the given width
+func oneHotMatrix(rng *rand.Rand, rows, classes int) *Matrix {
+	m := NewMatrix(rows, classes)
+	for i := range m.Data {
+		m.Data[i][rng.Intn(classes)] = 1
+	}
+	return m
+}
+
+// probabilityRows returns random rows of probabilities summing to 1
+func probabilityRows(rng *rand.Rand, rows, cols int) *Matrix {
+	m := uniformMatrix(rng, rows, cols, 0.1, 1)
+	for i := range m.Data {
+		sum := 0.0
+		for _, v := range m.Data[i] {
+			sum += v
+		}
+		for j := range m.Data[i] {
+			m.Data[i][j] /= sum
+		}
+	}
+	return m
+}
+
+// assertMatrix fails when got differs from want by more than tol anywhere
+func assertMatrix(t *testing.T, name string, got *Matrix, want [][]float64, tol float64) {
+	t.Helper()
+	if got.Rows != len(want) || (got.Rows > 0 && got.Cols != len(want[0])) {
+		t.Fatalf("%s has shape %dx%d, expected %dx%d", name, got.Rows, got.Cols, len(want), len(want[0]))
+	}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(got.Data[i][j]-want[i][j]) > tol {
+				t.Errorf("%s[%d][%d] = %v, expected %v", name, i, j, got.Data[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+// lossCase builds a loss with the given options and matching inputs
+type lossCase struct {
+	name   string
+	loss   func(LossOptions) Loss
+	inputs func(rng *rand.Rand, rows, cols int) (predictions, targets *Matrix)
+}
+
+var lossCases = []lossCase{
+	{
+		name: "bce",
+		loss: func(o LossOptions) Loss {
+			bce := NewBinaryCrossEntropy()
+			bce.LossOptions = o
+			return bce
+		},
+		inputs: func(rng *rand.Rand, rows, cols int) (*Matrix, *Matrix) {
+			targets := uniformMatrix(rng, rows, cols, 0, 1)
+			for i := range targets.Data {
+				for j := range targets.Data[i] {
+					targets.Data[i][j] = math.Round(targets.Data[i][j])
+				}
+			}
+			return uniformMatrix(rng, rows, cols, 0.1, 0.9), targets
+		},
+	},
+	{
+		name: "cce",
+		loss: func(o LossOptions) Loss {
+			cce := NewCategoricalCrossEntropy()
+			cce.LossOptions = o
+			return cce
+		},
+		inputs: func(rng *rand.Rand, rows, cols int) (*Matrix, *Matrix) {
+			return probabilityRows(rng, rows, cols), oneHotMatrix(rng, rows, cols)
+		},
+	},
+	{
+		name: "mse",
+		loss: func(o LossOptions) Loss {
+			mse := NewMSE()
+			mse.LossOptions = o
+			return mse
+		},
+		inputs: func(rng *rand.Rand, rows, cols int) (*Matrix, *Matrix) {
+			return uniformMatrix(rng, rows, cols, -2, 2), uniformMatrix(rng, rows, cols, -2, 2)
+		},
+	},
+}
+
+var reductions = []struct {
+	name      string
+	reduction Reduction
+}{
+	{"mean", ReductionMean},
+	{"sum", ReductionSum},
+	{"none", ReductionNone},
+}
+
+func TestLossGradients(t *testing.T) {
+	const rows, cols = 5, 4
+	for _, lc := range lossCases {
+		for _, r := range reductions {
+			for _, weighted := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%s/%s/weighted=%v", lc.name, r.name, weighted), func(t *testing.T) {
+					rng := rand.New(rand.NewSource(1))
+					opts := LossOptions{Reduction: r.reduction}
+					if weighted {
+						opts.SampleWeights = uniformMatrix(rng, rows, 1, 0.5, 2)
+					}
+					predictions, targets := lc.inputs(rng, rows, cols)
+
+					worst, err := CheckLossGradient(lc.loss(opts), predictions, targets, 0)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if worst > gradTolerance {
+						t.Errorf("relative gradient error %.3e exceeds %.0e", worst, gradTolerance)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestLossReduction(t *testing.T) {
+	const rows, cols = 6, 3
+	for _, lc := range lossCases {
+		t.Run(lc.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(2))
+			predictions, targets := lc.inputs(rng, rows, cols)
+			weights := uniformMatrix(rng, rows, 1, 0.5, 2)
+
+			perSample, err := lc.loss(LossOptions{SampleWeights: weights}).PerSample(predictions, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perSample.Rows != rows || perSample.Cols != 1 {
+				t.Fatalf("per-sample losses have shape %dx%d, expected %dx1", perSample.Rows, perSample.Cols, rows)
+			}
+			sum := 0.0
+			for i := range perSample.Data {
+				sum += perSample.Data[i][0]
+			}
+
+			for _, r := range reductions {
+				got, err := lc.loss(LossOptions{Reduction: r.reduction, SampleWeights: weights}).Forward(predictions, targets)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := sum
+				if r.reduction == ReductionMean {
+					want /= rows
+				}
+				if math.Abs(got-want) > 1e-12 {
+					t.Errorf("%s reduction: got %v, expected %v", r.name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLossShapeMismatch(t *testing.T) {
+	for _, lc := range lossCases {
+		loss := lc.loss(LossOptions{SampleWeights: NewMatrix(3, 1)})
+		if _, err := loss.Forward(NewMatrix(2, 3), NewMatrix(2, 2)); err == nil {
+			t.Errorf("%s: expected an error for mismatched targets", lc.name)
+		}
+		if _, err := loss.Backward(NewMatrix(2, 3), NewMatrix(2, 3)); err == nil {
+			t.Errorf("%s: expected an error for mismatched sample weights", lc.name)
+		}
+	}
+}
+
+func TestLossValues(t *testing.T) {
+	weights := &Matrix{Rows: 2, Cols: 1, Data: [][]float64{{2}, {1}}}
+	mse := NewMSE()
+	mse.SampleWeights = weights
+	predictions := &Matrix{Rows: 2, Cols: 2, Data: [][]float64{{1, 2}, {0, 0}}}
+	targets := &Matrix{Rows: 2, Cols: 2, Data: [][]float64{{0, 0}, {1, 1}}}
+
+	// Every sample loss is its weight times Σ(y - p)² / (2 * Cols)
+	perSample, err := mse.PerSample(predictions, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "mse per sample", perSample, [][]float64{{2.5}, {0.5}}, 1e-12)
+
+	for _, tt := range []struct {
+		reduction Reduction
+		loss      float64
+		grad      [][]float64
+	}{
+		{ReductionMean, 1.5, [][]float64{{0.5, 1}, {-0.25, -0.25}}},
+		{ReductionSum, 3, [][]float64{{1, 2}, {-0.5, -0.5}}},
+		{ReductionNone, 3, [][]float64{{1, 2}, {-0.5, -0.5}}},
+	} {
+		mse.Reduction = tt.reduction
+		loss, err := mse.Forward(predictions, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(loss-tt.loss) > 1e-12 {
+			t.Errorf("reduction %d: loss %v, expected %v", tt.reduction, loss, tt.loss)
+		}
+		grad, err := mse.Backward(predictions, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertMatrix(t, fmt.Sprintf("reduction %d gradient", tt.reduction), grad, tt.grad, 1e-12)
+	}
+
+	cce := NewCategoricalCrossEntropy()
+	perSample, err = cce.PerSample(
+		&Matrix{Rows: 2, Cols: 2, Data: [][]float64{{0.5, 0.5}, {0.25, 0.75}}},
+		&Matrix{Rows: 2, Cols: 2, Data: [][]float64{{1, 0}, {0, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "cce per sample", perSample, [][]float64{{math.Ln2}, {-math.Log(0.75)}}, 1e-12)
+
+	bce := NewBinaryCrossEntropy()
+	perSample, err = bce.PerSample(
+		&Matrix{Rows: 1, Cols: 2, Data: [][]float64{{0.5, 0.25}}},
+		&Matrix{Rows: 1, Cols: 2, Data: [][]float64{{1, 0}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "bce per sample", perSample, [][]float64{{(math.Ln2 - math.Log(0.75)) / 2}}, 1e-12)
+}
@@ -2,46 +2,68 @@ package nn
 
 import (
 	"fmt"
-	"math/rand"
 )
 
-// Matrix represents a 2D matrix
-type Matrix struct {
+// Float is the element type of matrices: float64 (the default) or float32
+type Float interface {
+	~float32 | ~float64
+}
+
+// MatrixOf represents a 2D matrix of elements of type T
+type MatrixOf[T Float] struct {
 	Rows int
 	Cols int
-	Data [][]float64
+	Data [][]T
 }
 
+// Matrix represents a 2D matrix of float64 values
+type Matrix = MatrixOf[float64]
+
+// Matrix32 represents a 2D matrix of float32 values
+type Matrix32 = MatrixOf[float32]
+
 // NewMatrix creates a new matrix with given dimensions
 func NewMatrix(rows, cols int) *Matrix {
-	data := make([][]float64, rows)
+	return NewMatrixOf[float64](rows, cols)
+}
+
+// NewMatrixOf creates a new matrix of element type T with given dimensions
+func NewMatrixOf[T Float](rows, cols int) *MatrixOf[T] {
+	data := make([][]T, rows)
 	for i := range data {
-		data[i] = make([]float64, cols)
+		data[i] = make([]T, cols)
 	}
-	return &Matrix{Rows: rows, Cols: cols, Data: data}
+	return &MatrixOf[T]{Rows: rows, Cols: cols, Data: data}
 }
 
 // RandomMatrix creates a matrix filled with random values
 func RandomMatrix(rows, cols int) *Matrix {
 	m := NewMatrix(rows, cols)
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			m.Data[i][j] = rand.Float64()*2 - 1 // Random values between -1 and 1
+	Uniform{Min: -1, Max: 1}.Initialize(m, rows, cols) // Random values between -1 and 1
+	return m
+}
+
+// ConvertMatrix returns a copy of m with elements converted to type To
+func ConvertMatrix[To, From Float](m *MatrixOf[From]) *MatrixOf[To] {
+	result := NewMatrixOf[To](m.Rows, m.Cols)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			result.Data[i][j] = To(m.Data[i][j])
 		}
 	}
-	return m
+	return result
 }
 
 // Multiply performs matrix multiplication
-func (m *Matrix) Multiply(other *Matrix) (*Matrix, error) {
+func (m *MatrixOf[T]) Multiply(other *MatrixOf[T]) (*MatrixOf[T], error) {
 	if m.Cols != other.Rows {
 		return nil, fmt.Errorf("incompatible dimensions: (%d, %d) and (%d, %d)", m.Rows, m.Cols, other.Rows, other.Cols)
 	}
 
-	result := NewMatrix(m.Rows, other.Cols)
+	result := NewMatrixOf[T](m.Rows, other.Cols)
 	for i := 0; i < m.Rows; i++ {
 		for j := 0; j < other.Cols; j++ {
-			sum := 0.0
+			var sum T
 			for k := 0; k < m.Cols; k++ {
 				sum += m.Data[i][k] * other.Data[k][j]
 			}
@@ -52,12 +74,12 @@ func (m *Matrix) Multiply(other *Matrix) (*Matrix, error) {
 }
 
 // Add performs element-wise addition
-func (m *Matrix) Add(other *Matrix) (*Matrix, error) {
+func (m *MatrixOf[T]) Add(other *MatrixOf[T]) (*MatrixOf[T], error) {
 	if m.Rows != other.Rows || m.Cols != other.Cols {
 		return nil, fmt.Errorf("incompatible dimensions")
 	}
 
-	result := NewMatrix(m.Rows, m.Cols)
+	result := NewMatrixOf[T](m.Rows, m.Cols)
 	for i := 0; i < m.Rows; i++ {
 		for j := 0; j < m.Cols; j++ {
 			result.Data[i][j] = m.Data[i][j] + other.Data[i][j]
@@ -67,8 +89,8 @@ func (m *Matrix) Add(other *Matrix) (*Matrix, error) {
 }
 
 // Scale multiplies all elements by a scalar
-func (m *Matrix) Scale(scalar float64) *Matrix {
-	result := NewMatrix(m.Rows, m.Cols)
+func (m *MatrixOf[T]) Scale(scalar T) *MatrixOf[T] {
+	result := NewMatrixOf[T](m.Rows, m.Cols)
 	for i := 0; i < m.Rows; i++ {
 		for j := 0; j < m.Cols; j++ {
 			result.Data[i][j] = m.Data[i][j] * scalar
@@ -76,3 +98,91 @@ func (m *Matrix) Scale(scalar float64) *Matrix {
 	}
 	return result
 }
+
+// Transpose returns a new matrix with rows and columns swapped
+func (m *MatrixOf[T]) Transpose() *MatrixOf[T] {
+	result := NewMatrixOf[T](m.Cols, m.Rows)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			result.Data[j][i] = m.Data[i][j]
+		}
+	}
+	return result
+}
+
+// rowRange copies the rows [start, end) of m into a new matrix
+func rowRange[T Float](m *MatrixOf[T], start, end int) *MatrixOf[T] {
+	result := NewMatrixOf[T](end-start, m.Cols)
+	for i := start; i < end; i++ {
+		copy(result.Data[i-start], m.Data[i])
+	}
+	return result
+}
+
+// gemm accumulates op(a) @ op(b) into dst, where op transposes its argument
+// when the corresponding flag is set. Shapes are assumed to be compatible.
+func gemm[T Float](dst, a, b *MatrixOf[T], transA, transB bool) {
+	rows, inner := a.Rows, a.Cols
+	if transA {
+		rows, inner = a.Cols, a.Rows
+	}
+	cols := b.Cols
+	if transB {
+		cols = b.Rows
+	}
+
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			var sum T
+			for k := 0; k < inner; k++ {
+				var av, bv T
+				if transA {
+					av = a.Data[k][i]
+				} else {
+					av = a.Data[i][k]
+				}
+				if transB {
+					bv = b.Data[j][k]
+				} else {
+					bv = b.Data[k][j]
+				}
+				sum += av * bv
+			}
+			dst.Data[i][j] += sum
+		}
+	}
+}
+
+// columns copies the columns [start, end) of m into a new matrix
+func columns[T Float](m *MatrixOf[T], start, end int) *MatrixOf[T] {
+	result := NewMatrixOf[T](m.Rows, end-start)
+	for i := 0; i < m.Rows; i++ {
+		copy(result.Data[i], m.Data[i][start:end])
+	}
+	return result
+}
+
+// setColumns copies src into the columns of dst starting at start
+func setColumns[T Float](dst *MatrixOf[T], start int, src *MatrixOf[T]) {
+	for i := 0; i < src.Rows; i++ {
+		copy(dst.Data[i][start:start+src.Cols], src.Data[i])
+	}
+}
+
+// addColumns adds src into the columns of dst starting at start
+func addColumns[T Float](dst *MatrixOf[T], start int, src *MatrixOf[T]) {
+	for i := 0; i < src.Rows; i++ {
+		for j := 0; j < src.Cols; j++ {
+			dst.Data[i][start+j] += src.Data[i][j]
+		}
+	}
+}
+
+// zero sets every element of m to 0
+func (m *MatrixOf[T]) zero() {
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			m.Data[i][j] = 0
+		}
+	}
+}
@@ -0,0 +1,505 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Metric accumulates a quality measure over several batches. Update adds a
+// batch, Result returns the value over everything seen since Reset.
+type Metric interface {
+	Name() string
+	Reset()
+	Update(predictions, targets *Matrix) error
+	Result() float64
+}
+
+// checkShapes returns an error naming the metric when predictions and
+// targets differ in shape
+func checkShapes(name string, predictions, targets *Matrix) error {
+	if predictions.Rows != targets.Rows || predictions.Cols != targets.Cols {
+		return fmt.Errorf("%s: predictions and targets must have same dimensions", name)
+	}
+	return nil
+}
+
+// meanMetric averages a per-sample score over all samples
+type meanMetric struct {
+	name   string
+	score  func(prediction, target []float64) float64
+	finish func(mean float64) float64 // Optional, applied to the mean
+	total  float64
+	count  int
+}
+
+// Name returns the name of the metric
+func (m *meanMetric) Name() string {
+	return m.name
+}
+
+// Reset clears the accumulated samples
+func (m *meanMetric) Reset() {
+	m.total = 0
+	m.count = 0
+}
+
+// Update scores every sample of the batch
+func (m *meanMetric) Update(predictions, targets *Matrix) error {
+	if err := checkShapes(m.name, predictions, targets); err != nil {
+		return err
+	}
+	for i := 0; i < predictions.Rows; i++ {
+		m.total += m.score(predictions.Data[i], targets.Data[i])
+		m.count++
+	}
+	return nil
+}
+
+// Result returns the mean score, or 0 before any update
+func (m *meanMetric) Result() float64 {
+	if m.count == 0 {
+		return 0
+	}
+	if m.finish != nil {
+		return m.finish(m.total / float64(m.count))
+	}
+	return m.total / float64(m.count)
+}
+
+// NewAccuracy creates a classification accuracy metric. With one column
+// predictions are probabilities thresholded at 0.5; with several columns
+// the arg max of the predictions is compared with the one-hot target.
+func NewAccuracy() Metric {
+	return &meanMetric{name: "accuracy", score: func(prediction, target []float64) float64 {
+		if predictedClass(prediction) == predictedClass(target) {
+			return 1
+		}
+		return 0
+	}}
+}
+
+// NewTopKAccuracy creates a metric counting a sample as correct when its
+// one-hot target class is among the k largest predictions
+func NewTopKAccuracy(k int) Metric {
+	return &meanMetric{name: fmt.Sprintf("top_%d_accuracy", k), score: func(prediction, target []float64) float64 {
+		class := argmax(target)
+		higher := 0
+		for _, p := range prediction {
+			if p > prediction[class] {
+				higher++
+			}
+		}
+		if higher < k {
+			return 1
+		}
+		return 0
+	}}
+}
+
+// NewLogLoss creates a metric averaging the cross-entropy of probability
+// predictions: binary with one column, categorical with several
+func NewLogLoss() Metric {
+	const epsilon = 1e-15
+	clip := func(p float64) float64 {
+		return math.Max(epsilon, math.Min(1-epsilon, p))
+	}
+	return &meanMetric{name: "log_loss", score: func(prediction, target []float64) float64 {
+		if len(prediction) == 1 {
+			p := clip(prediction[0])
+			return -(target[0]*math.Log(p) + (1-target[0])*math.Log(1-p))
+		}
+		loss := 0.0
+		for j, p := range prediction {
+			loss -= target[j] * math.Log(clip(p))
+		}
+		return loss
+	}}
+}
+
+// NewMeanAbsoluteError creates a metric averaging |prediction - target|
+// over all outputs
+func NewMeanAbsoluteError() Metric {
+	return &meanMetric{name: "mae", score: func(prediction, target []float64) float64 {
+		sum := 0.0
+		for j := range prediction {
+			sum += math.Abs(prediction[j] - target[j])
+		}
+		return sum / float64(len(prediction))
+	}}
+}
+
+// NewRootMeanSquaredError creates a metric taking the square root of the
+// squared error averaged over all outputs
+func NewRootMeanSquaredError() Metric {
+	return &meanMetric{name: "rmse", finish: math.Sqrt, score: func(prediction, target []float64) float64 {
+		sum := 0.0
+		for j := range prediction {
+			d := prediction[j] - target[j]
+			sum += d * d
+		}
+		return sum / float64(len(prediction))
+	}}
+}
+
+// r2Metric accumulates the sums needed for the coefficient of
+// determination of every output
+type r2Metric struct {
+	count       int
+	sum, sumSq  []float64 // Of the targets
+	residualsSq []float64
+}
+
+// NewR2Score creates a metric computing the coefficient of determination
+// R² of every output, averaged over the outputs
+func NewR2Score() Metric {
+	return &r2Metric{}
+}
+
+// Name returns the name of the metric
+func (m *r2Metric) Name() string {
+	return "r2"
+}
+
+// Reset clears the accumulated samples
+func (m *r2Metric) Reset() {
+	*m = r2Metric{}
+}
+
+// Update adds the samples of the batch
+func (m *r2Metric) Update(predictions, targets *Matrix) error {
+	if err := checkShapes("r2", predictions, targets); err != nil {
+		return err
+	}
+	if m.sum == nil {
+		m.sum = make([]float64, targets.Cols)
+		m.sumSq = make([]float64, targets.Cols)
+		m.residualsSq = make([]float64, targets.Cols)
+	} else if len(m.sum) != targets.Cols {
+		return fmt.Errorf("r2: expected %d outputs, got %d", len(m.sum), targets.Cols)
+	}
+
+	for i, row := range targets.Data {
+		for j, t := range row {
+			d := predictions.Data[i][j] - t
+			m.sum[j] += t
+			m.sumSq[j] += t * t
+			m.residualsSq[j] += d * d
+		}
+	}
+	m.count += targets.Rows
+	return nil
+}
+
+// Result returns the mean R² of the outputs, or 0 before any update. An
+// output with constant targets scores 1 when predicted exactly and 0
+// otherwise.
+func (m *r2Metric) Result() float64 {
+	if m.count == 0 {
+		return 0
+	}
+	total := 0.0
+	for j := range m.sum {
+		variance := m.sumSq[j] - m.sum[j]*m.sum[j]/float64(m.count)
+		switch {
+		case variance > 0:
+			total += 1 - m.residualsSq[j]/variance
+		case m.residualsSq[j] == 0:
+			total++
+		}
+	}
+	return total / float64(len(m.sum))
+}
+
+// Average selects how precision, recall and F1 combine the classes
+type Average string
+
+const (
+	// AverageBinary scores only class 1, the positive class of one-column
+	// probability predictions
+	AverageBinary Average = "binary"
+	// AverageMacro takes the unweighted mean of the class scores
+	AverageMacro Average = "macro"
+	// AverageMicro scores the counts summed over all classes
+	AverageMicro Average = "micro"
+	// AverageWeighted weights every class score by its number of targets
+	AverageWeighted Average = "weighted"
+)
+
+// predictedClass returns the class of a row: the arg max of several
+// columns, or 1 for a single probability of at least 0.5 and 0 otherwise
+func predictedClass(row []float64) int {
+	if len(row) == 1 {
+		if row[0] >= 0.5 {
+			return 1
+		}
+		return 0
+	}
+	return argmax(row)
+}
+
+// ratio returns num/den, or 0 when den is 0
+func ratio(num, den int) float64 {
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// precisionScore is the precision of a class from its counts
+func precisionScore(tp, fp, fn int) float64 {
+	return ratio(tp, tp+fp)
+}
+
+// recallScore is the recall of a class from its counts
+func recallScore(tp, fp, fn int) float64 {
+	return ratio(tp, tp+fn)
+}
+
+// f1Score is the F1 score of a class from its counts
+func f1Score(tp, fp, fn int) float64 {
+	return ratio(2*tp, 2*tp+fp+fn)
+}
+
+// classificationMetric averages a score computed from the confusion counts
+// of every class
+type classificationMetric struct {
+	confusion *Confusion // nil before the first update
+	name      string
+	average   Average
+	score     func(tp, fp, fn int) float64
+}
+
+// newClassificationMetric names the metric after the score and, except
+// for AverageBinary, the averaging
+func newClassificationMetric(name string, average Average, score func(tp, fp, fn int) float64) Metric {
+	if average != AverageBinary {
+		name += "_" + string(average)
+	}
+	return &classificationMetric{name: name, average: average, score: score}
+}
+
+// NewPrecision creates a metric of the fraction of predictions of a class
+// that are right
+func NewPrecision(average Average) Metric {
+	return newClassificationMetric("precision", average, precisionScore)
+}
+
+// NewRecall creates a metric of the fraction of samples of a class that
+// are predicted as such
+func NewRecall(average Average) Metric {
+	return newClassificationMetric("recall", average, recallScore)
+}
+
+// NewF1Score creates a metric of the harmonic mean of precision and recall
+func NewF1Score(average Average) Metric {
+	return newClassificationMetric("f1", average, f1Score)
+}
+
+// Name returns the name of the metric
+func (m *classificationMetric) Name() string {
+	return m.name
+}
+
+// Reset clears the accumulated samples
+func (m *classificationMetric) Reset() {
+	m.confusion = nil
+}
+
+// Update counts the samples of the batch
+func (m *classificationMetric) Update(predictions, targets *Matrix) error {
+	if err := checkShapes(m.name, predictions, targets); err != nil {
+		return err
+	}
+	// One column holds probabilities of class 1
+	classes := max(predictions.Cols, 2)
+	if m.confusion == nil {
+		m.confusion = newConfusion(classes)
+	} else if len(m.confusion.Counts) != classes {
+		return fmt.Errorf("%s: expected %d classes, got %d columns", m.name, len(m.confusion.Counts), predictions.Cols)
+	}
+	for i := range predictions.Data {
+		m.confusion.add(predictedClass(targets.Data[i]), predictedClass(predictions.Data[i]))
+	}
+	return nil
+}
+
+// Result returns the averaged score, or 0 before any update. Macro
+// averaging skips classes that are neither targets nor predictions.
+func (m *classificationMetric) Result() float64 {
+	c := m.confusion
+	if c == nil {
+		return 0
+	}
+
+	switch m.average {
+	case AverageBinary:
+		return m.score(c.classCounts(1))
+	case AverageMicro:
+		var tp, fp, fn int
+		for k := range c.Counts {
+			ktp, kfp, kfn := c.classCounts(k)
+			tp, fp, fn = tp+ktp, fp+kfp, fn+kfn
+		}
+		return m.score(tp, fp, fn)
+	}
+
+	total, weights := 0.0, 0
+	for k := range c.Counts {
+		tp, fp, fn := c.classCounts(k)
+		weight := 1
+		if m.average == AverageWeighted {
+			weight = tp + fn
+		} else if tp+fp+fn == 0 {
+			continue
+		}
+		total += float64(weight) * m.score(tp, fp, fn)
+		weights += weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return total / float64(weights)
+}
+
+// rankingMetric keeps every prediction to compute an area under a curve,
+// for one probability column or one-vs-rest for each of several columns
+type rankingMetric struct {
+	name        string
+	area        func(scores []float64, positive []bool) float64
+	predictions [][]float64
+	targets     [][]float64
+}
+
+// NewROCAUC creates a metric of the area under the ROC curve. With several
+// columns it is the macro average of the one-vs-rest areas of the classes
+// having both positive and negative targets.
+func NewROCAUC() Metric {
+	return &rankingMetric{name: "roc_auc", area: rocArea}
+}
+
+// NewPRAUC creates a metric of the area under the precision-recall curve,
+// computed as the average precision, averaged over classes like NewROCAUC
+func NewPRAUC() Metric {
+	return &rankingMetric{name: "pr_auc", area: averagePrecision}
+}
+
+// Name returns the name of the metric
+func (m *rankingMetric) Name() string {
+	return m.name
+}
+
+// Reset clears the accumulated samples
+func (m *rankingMetric) Reset() {
+	m.predictions, m.targets = nil, nil
+}
+
+// Update keeps copies of the rows of the batch
+func (m *rankingMetric) Update(predictions, targets *Matrix) error {
+	if err := checkShapes(m.name, predictions, targets); err != nil {
+		return err
+	}
+	if len(m.predictions) > 0 && len(m.predictions[0]) != predictions.Cols {
+		return fmt.Errorf("%s: expected %d columns, got %d", m.name, len(m.predictions[0]), predictions.Cols)
+	}
+	for i := range predictions.Data {
+		m.predictions = append(m.predictions, append([]float64(nil), predictions.Data[i]...))
+		m.targets = append(m.targets, append([]float64(nil), targets.Data[i]...))
+	}
+	return nil
+}
+
+// Result returns the mean area over the scorable columns, or 0 when no
+// column has both positive and negative targets
+func (m *rankingMetric) Result() float64 {
+	if len(m.predictions) == 0 {
+		return 0
+	}
+
+	total, columns := 0.0, 0
+	scores := make([]float64, len(m.predictions))
+	positive := make([]bool, len(m.predictions))
+	for j := range m.predictions[0] {
+		positives := 0
+		for i := range m.predictions {
+			scores[i] = m.predictions[i][j]
+			positive[i] = m.targets[i][j] >= 0.5
+			if positive[i] {
+				positives++
+			}
+		}
+		if positives == 0 || positives == len(scores) {
+			continue
+		}
+		total += m.area(scores, positive)
+		columns++
+	}
+	if columns == 0 {
+		return 0
+	}
+	return total / float64(columns)
+}
+
+// thresholds sorts the samples by decreasing score and returns the
+// cumulative true and false positives at every distinct score
+func thresholds(scores []float64, positive []bool) (tps, fps []int) {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	tp, fp := 0, 0
+	for k, i := range order {
+		if positive[i] {
+			tp++
+		} else {
+			fp++
+		}
+		// Tied scores share one threshold
+		if k == len(order)-1 || scores[order[k+1]] != scores[i] {
+			tps = append(tps, tp)
+			fps = append(fps, fp)
+		}
+	}
+	return tps, fps
+}
+
+// rocArea integrates the true positive rate over the false positive rate
+// with the trapezoidal rule
+func rocArea(scores []float64, positive []bool) float64 {
+	tps, fps := thresholds(scores, positive)
+	p, n := float64(tps[len(tps)-1]), float64(fps[len(fps)-1])
+
+	area, prevTP, prevFP := 0.0, 0, 0
+	for k := range tps {
+		area += float64(fps[k]-prevFP) * float64(tps[k]+prevTP) / 2
+		prevTP, prevFP = tps[k], fps[k]
+	}
+	return area / (p * n)
+}
+
+// averagePrecision sums the precision at every threshold weighted by the
+// increase in recall
+func averagePrecision(scores []float64, positive []bool) float64 {
+	tps, fps := thresholds(scores, positive)
+	p := float64(tps[len(tps)-1])
+
+	ap, prevTP := 0.0, 0
+	for k := range tps {
+		ap += float64(tps[k]-prevTP) / p * ratio(tps[k], tps[k]+fps[k])
+		prevTP = tps[k]
+	}
+	return ap
+}
+
+// argmax returns the index of the largest value
+func argmax(values []float64) int {
+	best := 0
+	for j, v := range values {
+		if v > values[best] {
+			best = j
+		}
+	}
+	return best
+}
@@ -0,0 +1,172 @@
+package nn
+
+import (
+	"math"
+	"testing"
+)
+
+// metricTolerance matches the four decimals of the reference values
+const metricTolerance = 1e-4
+
+// columnMatrix returns a one-column matrix of values
+func columnMatrix(values ...float64) *Matrix {
+	m := NewMatrix(len(values), 1)
+	for i, v := range values {
+		m.Data[i][0] = v
+	}
+	return m
+}
+
+// oneHotRows returns one-hot rows of the classes
+func oneHotRows(t *testing.T, classes int, labels ...int) *Matrix {
+	t.Helper()
+	m, err := oneHotLabels(labels, classes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// batchedResult feeds the rows to the metric in batches of two, so results
+// must accumulate across updates
+func batchedResult(t *testing.T, metric Metric, predictions, targets *Matrix) float64 {
+	t.Helper()
+	metric.Reset()
+	for start := 0; start < predictions.Rows; start += 2 {
+		end := min(start+2, predictions.Rows)
+		if err := metric.Update(rowRange(predictions, start, end), rowRange(targets, start, end)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return metric.Result()
+}
+
+// checkMetric compares a metric result with an expected value
+func checkMetric(t *testing.T, metric Metric, predictions, targets *Matrix, want float64) {
+	t.Helper()
+	if got := batchedResult(t, metric, predictions, targets); math.Abs(got-want) > metricTolerance {
+		t.Errorf("%s = %.4f, expected %.4f", metric.Name(), got, want)
+	}
+}
+
+func TestRankingMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		scores  []float64
+		targets []float64
+		roc, ap float64
+	}{
+		{"distinct", []float64{0.1, 0.4, 0.35, 0.8}, []float64{0, 0, 1, 1}, 0.75, 0.8333},
+		// A tied positive and negative count as half a correctly ordered pair
+		{"tied pair", []float64{0.1, 0.5, 0.5, 0.8}, []float64{0, 0, 1, 1}, 0.875, 0.8333},
+		{"all tied", []float64{0.3, 0.3, 0.3, 0.3}, []float64{1, 0, 1, 0}, 0.5, 0.5},
+		{"tied positives", []float64{0.9, 0.9, 0.2, 0.6, 0.6}, []float64{1, 1, 0, 1, 0}, 0.9167, 0.9167},
+		{"reversed", []float64{0.9, 0.8, 0.2, 0.1}, []float64{0, 0, 1, 1}, 0, 0.4167},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, targets := columnMatrix(tt.scores...), columnMatrix(tt.targets...)
+			checkMetric(t, NewROCAUC(), scores, targets, tt.roc)
+			checkMetric(t, NewPRAUC(), scores, targets, tt.ap)
+		})
+	}
+}
+
+func TestRankingMetricsOneVsRest(t *testing.T) {
+	// Column 1 is the distinct case above and column 0 its complement;
+	// column 2 has no positive targets and is left out of the average
+	scores := []float64{0.1, 0.4, 0.35, 0.8}
+	predictions, targets := NewMatrix(4, 3), oneHotRows(t, 3, 0, 0, 1, 1)
+	for i, s := range scores {
+		predictions.Data[i] = []float64{1 - s, s, 0.2}
+	}
+	checkMetric(t, NewROCAUC(), predictions, targets, 0.75)
+	// Column 0 ranks 0.9, 0.65, 0.6, 0.2 with positives first and third,
+	// which also has an average precision of 0.8333
+	checkMetric(t, NewPRAUC(), predictions, targets, 0.8333)
+
+	if got := batchedResult(t, NewROCAUC(), columnMatrix(0.2, 0.7), columnMatrix(1, 1)); got != 0 {
+		t.Errorf("roc_auc = %v without negative targets, expected 0", got)
+	}
+}
+
+func TestClassificationAveraging(t *testing.T) {
+	// y_true = [0, 1, 2, 0, 1, 2], y_pred = [0, 2, 1, 0, 0, 1]
+	predictions := oneHotRows(t, 3, 0, 2, 1, 0, 0, 1)
+	targets := oneHotRows(t, 3, 0, 1, 2, 0, 1, 2)
+	for _, tt := range []struct {
+		average       Average
+		precision     float64
+		recall, f1    float64
+		precisionName string
+	}{
+		{AverageMacro, 0.2222, 0.3333, 0.2667, "precision_macro"},
+		{AverageMicro, 0.3333, 0.3333, 0.3333, "precision_micro"},
+		{AverageWeighted, 0.2222, 0.3333, 0.2667, "precision_weighted"},
+	} {
+		if name := NewPrecision(tt.average).Name(); name != tt.precisionName {
+			t.Errorf("metric named %q, expected %q", name, tt.precisionName)
+		}
+		checkMetric(t, NewPrecision(tt.average), predictions, targets, tt.precision)
+		checkMetric(t, NewRecall(tt.average), predictions, targets, tt.recall)
+		checkMetric(t, NewF1Score(tt.average), predictions, targets, tt.f1)
+	}
+
+	// Unequal supports separate the weighted from the macro average:
+	// y_true = [0, 0, 0, 1, 2], y_pred = [0, 0, 1, 1, 1]. A fourth class
+	// that is never seen is left out of the macro average.
+	predictions = oneHotRows(t, 4, 0, 0, 1, 1, 1)
+	targets = oneHotRows(t, 4, 0, 0, 0, 1, 2)
+	checkMetric(t, NewPrecision(AverageMacro), predictions, targets, 0.4444)
+	checkMetric(t, NewRecall(AverageMacro), predictions, targets, 0.5556)
+	checkMetric(t, NewF1Score(AverageMacro), predictions, targets, 0.4333)
+	checkMetric(t, NewPrecision(AverageWeighted), predictions, targets, 0.6667)
+	checkMetric(t, NewRecall(AverageWeighted), predictions, targets, 0.6)
+	checkMetric(t, NewF1Score(AverageWeighted), predictions, targets, 0.58)
+	checkMetric(t, NewAccuracy(), predictions, targets, 0.6)
+}
+
+func TestBinaryClassificationMetrics(t *testing.T) {
+	// Probabilities of at least 0.5 predict class 1: y_pred = [0, 1, 0, 1, 1, 1]
+	predictions := columnMatrix(0.2, 0.7, 0.4, 0.6, 0.9, 0.5)
+	targets := columnMatrix(0, 1, 1, 0, 1, 0)
+	if name := NewF1Score(AverageBinary).Name(); name != "f1" {
+		t.Errorf("binary metric named %q, expected f1", name)
+	}
+	checkMetric(t, NewPrecision(AverageBinary), predictions, targets, 0.5)
+	checkMetric(t, NewRecall(AverageBinary), predictions, targets, 0.6667)
+	checkMetric(t, NewF1Score(AverageBinary), predictions, targets, 0.5714)
+	checkMetric(t, NewAccuracy(), predictions, targets, 0.5)
+
+	// Predicting no positives scores 0 instead of dividing by zero
+	checkMetric(t, NewPrecision(AverageBinary), columnMatrix(0.1, 0.2), columnMatrix(1, 0), 0)
+
+	metric := NewPrecision(AverageBinary)
+	if err := metric.Update(predictions, targets); err != nil {
+		t.Fatal(err)
+	}
+	if err := metric.Update(oneHotRows(t, 3, 0), oneHotRows(t, 3, 1)); err == nil {
+		t.Error("expected an error for a change in the number of classes")
+	}
+}
+
+func TestRegressionMetrics(t *testing.T) {
+	predictions := columnMatrix(2.5, 0, 2, 8)
+	targets := columnMatrix(3, -0.5, 2, 7)
+	checkMetric(t, NewR2Score(), predictions, targets, 0.9486)
+	checkMetric(t, NewRootMeanSquaredError(), predictions, targets, 0.6124)
+	checkMetric(t, NewMeanAbsoluteError(), predictions, targets, 0.5)
+
+	// Several outputs are averaged uniformly
+	predictions = &Matrix{Rows: 3, Cols: 2, Data: [][]float64{{0, 2}, {-1, 2}, {8, -5}}}
+	targets = &Matrix{Rows: 3, Cols: 2, Data: [][]float64{{0.5, 1}, {-1, 1}, {7, -6}}}
+	checkMetric(t, NewR2Score(), predictions, targets, 0.9368)
+
+	// Constant targets score 1 only when predicted exactly
+	checkMetric(t, NewR2Score(), columnMatrix(2, 2), columnMatrix(2, 2), 1)
+	checkMetric(t, NewR2Score(), columnMatrix(2, 3), columnMatrix(2, 2), 0)
+
+	if err := NewR2Score().Update(columnMatrix(1, 2), columnMatrix(1)); err == nil {
+		t.Error("expected an error for mismatched shapes")
+	}
+}
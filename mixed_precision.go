@@ -0,0 +1,290 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// HalfPrecision is a 16-bit floating point format. Values are simulated in
+// float64 by rounding them to the nearest representable number, so mixed
+// precision training can run and be tested on any CPU.
+type HalfPrecision int
+
+const (
+	// Float16 is IEEE 754 half precision: 10 mantissa bits, largest
+	// finite value 65504
+	Float16 HalfPrecision = iota
+	// BFloat16 keeps the float32 exponent range with 7 mantissa bits
+	BFloat16
+)
+
+// String returns the name of the format
+func (p HalfPrecision) String() string {
+	if p == BFloat16 {
+		return "bfloat16"
+	}
+	return "float16"
+}
+
+// Round returns the value of the format nearest to v, rounding ties to
+// even. Values beyond the largest finite value become infinite.
+func (p HalfPrecision) Round(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) || v == 0 {
+		return v
+	}
+
+	if p == BFloat16 {
+		bits := math.Float32bits(float32(v))
+		bits += 0x7fff + (bits>>16)&1
+		return float64(math.Float32frombits(bits & 0xffff0000))
+	}
+
+	a := math.Abs(v)
+	if a >= 65520 { // Halfway between 65504 and 65536 rounds up
+		return math.Copysign(math.Inf(1), v)
+	}
+	_, exp := math.Frexp(a) // a = frac * 2^exp with frac in [0.5, 1)
+	if exp < -13 {
+		exp = -13 // Subnormals have a fixed spacing of 2^-24
+	}
+	step := math.Ldexp(1, exp-11)
+	return math.RoundToEven(v/step) * step
+}
+
+// roundMatrix rounds every element of m to the format in place
+func roundMatrix[T Float](m *MatrixOf[T], p HalfPrecision) {
+	for _, row := range m.Data {
+		for j, v := range row {
+			row[j] = T(p.Round(float64(v)))
+		}
+	}
+}
+
+// MixedPrecision configures mixed precision training of a Sequential
+// model. The layer parameters stay the full precision master copy; every
+// TrainOnBatch runs the forward and backward passes with parameters,
+// activations and gradients rounded to Precision. The loss gradient is
+// multiplied by Scale so that small gradients do not flush to zero, and
+// divided out again before the update. A step whose gradients overflow is
+// skipped and Scale is reduced by BackoffFactor; after GrowthInterval
+// finite steps in a row Scale is multiplied by GrowthFactor.
+type MixedPrecision struct {
+	Precision      HalfPrecision
+	Scale          float64
+	GrowthFactor   float64
+	BackoffFactor  float64
+	GrowthInterval int
+
+	// SkippedSteps counts the steps skipped because of overflow
+	SkippedSteps int
+
+	goodSteps int
+}
+
+// NewMixedPrecision creates a dynamic loss scaler starting at 2^16 that
+// doubles every 2000 finite steps and halves on overflow
+func NewMixedPrecision(precision HalfPrecision) *MixedPrecision {
+	return &MixedPrecision{
+		Precision:      precision,
+		Scale:          65536,
+		GrowthFactor:   2,
+		BackoffFactor:  0.5,
+		GrowthInterval: 2000,
+	}
+}
+
+// update adjusts the loss scale after a step
+func (mp *MixedPrecision) update(overflow bool) {
+	if overflow {
+		mp.Scale *= mp.BackoffFactor
+		mp.SkippedSteps++
+		mp.goodSteps = 0
+		return
+	}
+	mp.goodSteps++
+	if mp.goodSteps >= mp.GrowthInterval {
+		mp.Scale *= mp.GrowthFactor
+		mp.goodSteps = 0
+	}
+}
+
+// castParams rounds the parameters of layers to the format in place and
+// returns copies of their previous values
+func castParams[T Float](layers []LayerOf[T], p HalfPrecision) [][]*MatrixOf[T] {
+	master := make([][]*MatrixOf[T], len(layers))
+	for i, layer := range layers {
+		for _, param := range layer.GetParams() {
+			master[i] = append(master[i], ConvertMatrix[T](param))
+			roundMatrix(param, p)
+		}
+	}
+	return master
+}
+
+// restoreParams copies the master values saved by castParams back
+func restoreParams[T Float](layers []LayerOf[T], master [][]*MatrixOf[T]) {
+	for i, layer := range layers {
+		for k, param := range layer.GetParams() {
+			for r := range param.Data {
+				copy(param.Data[r], master[i][k].Data[r])
+			}
+		}
+	}
+}
+
+// gradientsOverflow reports whether a parameter gradient of layers is not
+// finite in the format. The sparse gradients of a SparseLayer are checked
+// row by row instead of building its dense gradient; a nil entry falls back
+// to the dense gradient of that parameter.
+func gradientsOverflow[T Float](layers []LayerOf[T], p HalfPrecision) bool {
+	for _, layer := range layers {
+		var sparseGrads []*SparseGrad
+		if sparseLayer, ok := any(layer).(SparseLayer); ok {
+			sparseGrads = sparseLayer.GetSparseGrads()
+		}
+
+		var grads []*MatrixOf[T]
+		for i := range layer.GetParams() {
+			if i < len(sparseGrads) && sparseGrads[i] != nil {
+				if matrixOverflows(sparseGrads[i].Values, p) {
+					return true
+				}
+				continue
+			}
+
+			if grads == nil {
+				grads = layer.GetGrads()
+			}
+			if matrixOverflows(grads[i], p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matrixOverflows reports whether a value of m is not finite in the format
+func matrixOverflows[T Float](m *MatrixOf[T], p HalfPrecision) bool {
+	for _, row := range m.Data {
+		for _, v := range row {
+			r := p.Round(float64(v))
+			if math.IsInf(r, 0) || math.IsNaN(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trainMixedPrecision is TrainOnBatch with loss scaling. It returns the
+// unscaled loss whether or not the step was skipped.
+func (s *SequentialOf[T]) trainMixedPrecision(X, y *MatrixOf[T]) (float64, error) {
+	mp := s.MixedPrecision
+
+	master := castParams(s.Layers, mp.Precision)
+	loss, err := s.scaledPass(X, y)
+	restoreParams(s.Layers, master)
+	if err != nil {
+		return 0, err
+	}
+
+	overflow := gradientsOverflow(s.Layers, mp.Precision)
+	if !overflow {
+		updateLayers[T](&unscaledOptimizer[T]{optimizer: s.Optimizer, precision: mp.Precision, scale: mp.Scale}, s.Layers)
+	}
+	mp.update(overflow)
+
+	return loss, nil
+}
+
+// scaledPass runs the forward and backward passes in reduced precision
+// with the loss gradient multiplied by the loss scale
+func (s *SequentialOf[T]) scaledPass(X, y *MatrixOf[T]) (float64, error) {
+	p := s.MixedPrecision.Precision
+
+	output := X
+	for i, layer := range s.Layers {
+		var err error
+		if output, err = layer.Forward(output); err != nil {
+			return 0, fmt.Errorf("error in layer %d: %v", i, err)
+		}
+		if output == X {
+			output = ConvertMatrix[T](X) // Never round the caller's data
+		}
+		roundMatrix(output, p)
+	}
+
+	loss, err := s.Loss.Forward(output, y)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.updateMetrics(output, y); err != nil {
+		return 0, err
+	}
+	grad, err := s.Loss.Backward(output, y)
+	if err != nil {
+		return 0, err
+	}
+	grad = grad.Scale(T(s.MixedPrecision.Scale))
+	roundMatrix(grad, p)
+
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		if grad, err = s.Layers[i].Backward(grad); err != nil {
+			return 0, fmt.Errorf("error in backward pass at layer %d: %v", i, err)
+		}
+		roundMatrix(grad, p)
+	}
+
+	return loss, nil
+}
+
+// unscaledOptimizer passes gradients rounded to the reduced precision and
+// divided by the loss scale to the wrapped optimizer
+type unscaledOptimizer[T Float] struct {
+	optimizer OptimizerOf[T]
+	precision HalfPrecision
+	scale     float64
+}
+
+// unscale returns the gradient value of the master copy
+func (u *unscaledOptimizer[T]) unscale(v float64) float64 {
+	return u.precision.Round(v) / u.scale
+}
+
+// Update unscales the gradients and updates the master parameters
+func (u *unscaledOptimizer[T]) Update(paramName string, params, gradients *MatrixOf[T]) *MatrixOf[T] {
+	unscaled := NewMatrixOf[T](gradients.Rows, gradients.Cols)
+	for i, row := range gradients.Data {
+		for j, v := range row {
+			unscaled.Data[i][j] = T(u.unscale(float64(v)))
+		}
+	}
+	return u.optimizer.Update(paramName, params, unscaled)
+}
+
+// UpdateSparse unscales a sparse gradient. Optimizers without sparse
+// support get it as a dense gradient.
+func (u *unscaledOptimizer[T]) UpdateSparse(paramName string, params *MatrixOf[T], grad *SparseGrad) {
+	unscaled := NewMatrix(grad.Values.Rows, grad.Values.Cols)
+	for i, row := range grad.Values.Data {
+		for j, v := range row {
+			unscaled.Data[i][j] = u.unscale(v)
+		}
+	}
+
+	if sparse, ok := u.optimizer.(SparseOptimizerOf[T]); ok {
+		sparse.UpdateSparse(paramName, params, &SparseGrad{Indices: grad.Indices, Values: unscaled})
+		return
+	}
+
+	dense := NewMatrixOf[T](params.Rows, params.Cols)
+	for pos, i := range grad.Indices {
+		for j, v := range unscaled.Data[pos] {
+			dense.Data[i][j] = T(v)
+		}
+	}
+	updated := u.optimizer.Update(paramName, params, dense)
+	for i := range params.Data {
+		copy(params.Data[i], updated.Data[i])
+	}
+}
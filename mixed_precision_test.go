@@ -0,0 +1,215 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// denseCountingEmbedding counts the dense gradients built for an Embedding
+type denseCountingEmbedding struct {
+	*Embedding
+	denseCalls int
+	sparse     []*SparseGrad
+}
+
+func (e *denseCountingEmbedding) GetGrads() []*Matrix {
+	e.denseCalls++
+	return e.Embedding.GetGrads()
+}
+
+func (e *denseCountingEmbedding) GetSparseGrads() []*SparseGrad {
+	return e.sparse
+}
+
+func TestGradientsOverflowSparse(t *testing.T) {
+	emb := &denseCountingEmbedding{Embedding: NewEmbedding(1000, 4)}
+	if _, err := emb.Forward(&Matrix{Rows: 1, Cols: 2, Data: [][]float64{{3, 7}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := emb.Backward(filledMatrix(1, 8, 1e5)); err != nil {
+		t.Fatal(err)
+	}
+	layers := []Layer{emb}
+
+	// 1e5 overflows float16 but not bfloat16, and only the looked-up rows
+	// are checked
+	emb.sparse = emb.Embedding.GetSparseGrads()
+	if !gradientsOverflow(layers, Float16) {
+		t.Error("float16 overflow not detected")
+	}
+	if gradientsOverflow(layers, BFloat16) {
+		t.Error("bfloat16 overflow reported")
+	}
+	if emb.denseCalls != 0 {
+		t.Errorf("built the dense %dx%d gradient %d times", emb.VocabSize, emb.Dim, emb.denseCalls)
+	}
+
+	// A nil sparse gradient falls back to the dense one
+	emb.sparse = []*SparseGrad{nil}
+	if !gradientsOverflow(layers, Float16) {
+		t.Error("float16 overflow not detected in the dense gradient")
+	}
+	if emb.denseCalls != 1 {
+		t.Errorf("built the dense gradient %d times, expected 1", emb.denseCalls)
+	}
+}
+
+func TestHalfPrecisionRound(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name string
+		p    HalfPrecision
+		v    float64
+		want float64
+	}{
+		{"float16 exact", Float16, 1.5, 1.5},
+		// Ties go to the even mantissa
+		{"float16 tie down", Float16, 1 + 0x1p-11, 1},
+		{"float16 tie up", Float16, 1 + 3*0x1p-11, 1 + 0x1p-9},
+		{"float16 negative tie", Float16, -1 - 0x1p-11, -1},
+		{"float16 nearest", Float16, 1 + 0x1p-10 + 0x1p-13, 1 + 0x1p-10},
+		{"float16 smallest normal", Float16, 0x1p-14, 0x1p-14},
+		// Subnormals are spaced 2^-24 apart
+		{"float16 subnormal", Float16, 5 * 0x1p-24, 5 * 0x1p-24},
+		{"float16 subnormal tie", Float16, 3 * 0x1p-25, 0x1p-23},
+		{"float16 underflow tie", Float16, 0x1p-25, 0},
+		{"float16 underflow", Float16, 0x1p-26, 0},
+		{"float16 max", Float16, 65504, 65504},
+		{"float16 below overflow", Float16, 65519, 65504},
+		{"float16 overflow", Float16, 65520, inf},
+		{"float16 negative overflow", Float16, -65520, -inf},
+		{"float16 inf", Float16, inf, inf},
+		{"bfloat16 exact", BFloat16, 1.5, 1.5},
+		{"bfloat16 tie down", BFloat16, 1 + 0x1p-8, 1},
+		{"bfloat16 tie up", BFloat16, 1 + 3*0x1p-8, 1 + 0x1p-6},
+		{"bfloat16 wide range", BFloat16, 65520, 65536},
+		{"bfloat16 subnormal", BFloat16, 0x1p-133, 0x1p-133},
+		{"bfloat16 overflow", BFloat16, math.MaxFloat32, inf},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Round(tt.v); got != tt.want {
+			t.Errorf("%s: rounded %v to %v, expected %v", tt.name, tt.v, got, tt.want)
+		}
+	}
+	if got := Float16.Round(math.NaN()); !math.IsNaN(got) {
+		t.Errorf("rounded NaN to %v", got)
+	}
+}
+
+func TestMixedPrecisionScaleUpdate(t *testing.T) {
+	mp := NewMixedPrecision(Float16)
+	mp.GrowthInterval = 3
+
+	steps := []struct {
+		overflow bool
+		scale    float64
+	}{
+		{false, 65536},
+		{false, 65536},
+		{false, 131072}, // GrowthInterval finite steps grow the scale
+		{false, 131072},
+		{true, 65536}, // An overflow backs off and restarts the count
+		{false, 65536},
+		{false, 65536},
+		{false, 131072},
+	}
+	for i, step := range steps {
+		mp.update(step.overflow)
+		if mp.Scale != step.scale {
+			t.Fatalf("step %d: scale %v, expected %v", i, mp.Scale, step.scale)
+		}
+	}
+	if mp.SkippedSteps != 1 {
+		t.Errorf("%d skipped steps, expected 1", mp.SkippedSteps)
+	}
+}
+
+// mixedPrecisionModel returns a small regression model and its data
+func mixedPrecisionModel(p HalfPrecision, opt Optimizer) (*Sequential, *Matrix, *Matrix) {
+	rng := rand.New(rand.NewSource(17))
+	model := NewSequential()
+	model.Add(NewDense(2, 8))
+	model.Add(NewReLULayer())
+	model.Add(NewDense(8, 1))
+	for _, layer := range model.Layers {
+		randomizeParams(rng, layer)
+	}
+	model.Compile(NewMSE(), opt)
+	model.MixedPrecision = NewMixedPrecision(p)
+
+	X := uniformMatrix(rng, 16, 2, -1, 1)
+	y := NewMatrix(16, 1)
+	for i, row := range X.Data {
+		y.Data[i][0] = row[0] - 2*row[1]
+	}
+	return model, X, y
+}
+
+func TestMixedPrecisionSkippedStep(t *testing.T) {
+	adam := NewAdamOptimizer(0.01)
+	model, X, y := mixedPrecisionModel(Float16, adam)
+	// The scaled loss gradient overflows float16
+	model.MixedPrecision.Scale = 1e10
+	var before [][]*Matrix
+	for _, layer := range model.Layers {
+		var params []*Matrix
+		for _, p := range layer.GetParams() {
+			params = append(params, copyMatrix(p))
+		}
+		before = append(before, params)
+	}
+
+	loss, err := model.TrainOnBatch(X, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsNaN(loss) || math.IsInf(loss, 0) || loss == 0 {
+		t.Errorf("skipped step returned loss %v, expected the unscaled loss", loss)
+	}
+	for i, layer := range model.Layers {
+		for k, p := range layer.GetParams() {
+			if !matricesEqual(p, before[i][k]) {
+				t.Errorf("layer %d parameter %d changed on a skipped step", i, k)
+			}
+		}
+	}
+	if adam.T != 0 || len(adam.M) != 0 || len(adam.V) != 0 {
+		t.Errorf("skipped step touched the optimizer: step %d, %d moments", adam.T, len(adam.M))
+	}
+	if mp := model.MixedPrecision; mp.SkippedSteps != 1 || mp.Scale != 5e9 {
+		t.Errorf("%d skipped steps and scale %v, expected 1 and 5e9", mp.SkippedSteps, mp.Scale)
+	}
+}
+
+func TestMixedPrecisionTraining(t *testing.T) {
+	for _, p := range []HalfPrecision{Float16, BFloat16} {
+		t.Run(p.String(), func(t *testing.T) {
+			model, X, y := mixedPrecisionModel(p, NewAdamOptimizer(0.01))
+			first, err := model.TrainOnBatch(X, y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := first
+			for step := 0; step < 300; step++ {
+				if last, err = model.TrainOnBatch(X, y); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last > first/4 {
+				t.Errorf("loss went from %v to %v", first, last)
+			}
+			// The master parameters keep full precision
+			w := model.Layers[0].GetParams()[0]
+			full := false
+			for _, row := range w.Data {
+				for _, v := range row {
+					full = full || p.Round(v) != v
+				}
+			}
+			if !full {
+				t.Error("the master weights were rounded to the reduced precision")
+			}
+		})
+	}
+}
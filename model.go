@@ -4,38 +4,146 @@ import (
 	"fmt"
 )
 
+// SequentialOf is a model stacking layers with element type T
+type SequentialOf[T Float] struct {
+	Layers    []LayerOf[T]
+	Loss      LossOf[T]
+	Optimizer OptimizerOf[T]
+	Metrics   []Metric
+	History   []*Report // Training report of every epoch run by Fit
+
+	// MixedPrecision enables loss scaled training in reduced precision
+	// when set
+	MixedPrecision *MixedPrecision
+
+	// Preprocessing is saved and loaded with the weights so inputs can be
+	// transformed the same way when serving; it is not applied by Predict
+	Preprocessing *Pipeline
+
+	training bool
+}
+
 // Sequential model that stacks layers
-type Sequential struct {
-	Layers    []Layer
-	Loss      Loss
-	Optimizer Optimizer
+type Sequential = SequentialOf[float64]
+
+// OptimizerOf is the interface of optimizers updating parameters of
+// element type T
+type OptimizerOf[T Float] interface {
+	Update(paramName string, params, gradients *MatrixOf[T]) *MatrixOf[T]
 }
 
 // Optimizer interface for different optimization algorithms
-type Optimizer interface {
-	Update(paramName string, params, gradients *Matrix) *Matrix
+type Optimizer = OptimizerOf[float64]
+
+// SparseOptimizerOf is implemented by optimizers that can update selected
+// rows of a parameter in place, used for the gradients of a SparseLayer
+type SparseOptimizerOf[T Float] interface {
+	UpdateSparse(paramName string, params *MatrixOf[T], grad *SparseGrad)
 }
 
+// SparseOptimizer is a SparseOptimizerOf for float64 parameters
+type SparseOptimizer = SparseOptimizerOf[float64]
+
 // NewSequential creates a new sequential model
 func NewSequential() *Sequential {
-	return &Sequential{
-		Layers: make([]Layer, 0),
+	return NewSequentialOf[float64]()
+}
+
+// NewSequentialOf creates a new sequential model with element type T
+func NewSequentialOf[T Float]() *SequentialOf[T] {
+	return &SequentialOf[T]{
+		Layers: make([]LayerOf[T], 0),
 	}
 }
 
 // Add adds a layer to the model
-func (s *Sequential) Add(layer Layer) {
+func (s *SequentialOf[T]) Add(layer LayerOf[T]) {
+	if t, ok := any(layer).(TrainingSetter); ok {
+		t.SetTraining(s.training)
+	}
 	s.Layers = append(s.Layers, layer)
 }
 
-// Compile sets the loss function and optimizer
-func (s *Sequential) Compile(loss Loss, optimizer Optimizer) {
+// Train switches the model and its layers to training mode
+func (s *SequentialOf[T]) Train() {
+	s.setTraining(true)
+}
+
+// Eval switches the model and its layers to inference mode
+func (s *SequentialOf[T]) Eval() {
+	s.setTraining(false)
+}
+
+// IsTraining reports whether the model is in training mode
+func (s *SequentialOf[T]) IsTraining() bool {
+	return s.training
+}
+
+// setTraining propagates the mode to every layer that supports it
+func (s *SequentialOf[T]) setTraining(training bool) {
+	s.training = training
+	setLayersTraining(s.Layers, training)
+}
+
+// setLayersTraining sets the mode of every layer that supports it
+func setLayersTraining[T Float](layers []LayerOf[T], training bool) {
+	for _, layer := range layers {
+		if t, ok := any(layer).(TrainingSetter); ok {
+			t.SetTraining(training)
+		}
+	}
+}
+
+// Compile sets the loss function, the optimizer and the metrics reported
+// by Fit and EvaluateReport
+func (s *SequentialOf[T]) Compile(loss LossOf[T], optimizer OptimizerOf[T], metrics ...Metric) {
 	s.Loss = loss
 	s.Optimizer = optimizer
+	s.Metrics = metrics
+}
+
+// resetMetrics resets every metric
+func (s *SequentialOf[T]) resetMetrics() {
+	for _, metric := range s.Metrics {
+		metric.Reset()
+	}
+}
+
+// updateMetrics adds a batch to every metric
+func (s *SequentialOf[T]) updateMetrics(predictions, y *MatrixOf[T]) error {
+	if len(s.Metrics) == 0 {
+		return nil
+	}
+	p, t := asFloat64(predictions), asFloat64(y)
+	for _, metric := range s.Metrics {
+		if err := metric.Update(p, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// report builds a Report from the loss and the current metric results
+func (s *SequentialOf[T]) report(loss float64) *Report {
+	r := &Report{Loss: loss, HeadLosses: make(map[string]float64), Metrics: make(map[string]float64)}
+	for _, metric := range s.Metrics {
+		r.Metrics[metric.Name()] = metric.Result()
+		r.keys = append(r.keys, metric.Name())
+	}
+	return r
+}
+
+// asFloat64 returns m as a float64 matrix, converting it only when T is
+// not float64
+func asFloat64[T Float](m *MatrixOf[T]) *Matrix {
+	if same, ok := any(m).(*Matrix); ok {
+		return same
+	}
+	return ConvertMatrix[float64](m)
 }
 
 // Forward performs forward pass through all layers
-func (s *Sequential) Forward(input *Matrix) (*Matrix, error) {
+func (s *SequentialOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
 	output := input
 	var err error
 
@@ -50,7 +158,7 @@ func (s *Sequential) Forward(input *Matrix) (*Matrix, error) {
 }
 
 // Backward performs backward pass through all layers
-func (s *Sequential) Backward(gradOutput *Matrix) error {
+func (s *SequentialOf[T]) Backward(gradOutput *MatrixOf[T]) error {
 	grad := gradOutput
 
 	// Backpropagate through layers in reverse order
@@ -66,16 +174,37 @@ func (s *Sequential) Backward(gradOutput *Matrix) error {
 }
 
 // UpdateWeights updates all parameters using the optimizer
-func (s *Sequential) UpdateWeights() {
-	layerIdx := 0
-	for _, layer := range s.Layers {
+func (s *SequentialOf[T]) UpdateWeights() {
+	updateLayers(s.Optimizer, s.Layers)
+}
+
+// updateLayers applies the optimizer to the parameters of layers, naming
+// them layer_<index>_<param>. Sparse gradients are applied row by row when
+// the optimizer supports it.
+func updateLayers[T Float](optimizer OptimizerOf[T], layers []LayerOf[T]) {
+	sparseOpt, canSparse := optimizer.(SparseOptimizerOf[T])
+
+	for layerIdx, layer := range layers {
 		params := layer.GetParams()
-		grads := layer.GetGrads()
 		paramNames := layer.GetParamNames()
 
+		var sparseGrads []*SparseGrad
+		if sparseLayer, ok := any(layer).(SparseLayer); ok && canSparse {
+			sparseGrads = sparseLayer.GetSparseGrads()
+		}
+
+		var grads []*MatrixOf[T]
 		for i := range params {
 			paramName := fmt.Sprintf("layer_%d_%s", layerIdx, paramNames[i])
-			updated := s.Optimizer.Update(paramName, params[i], grads[i])
+			if i < len(sparseGrads) && sparseGrads[i] != nil {
+				sparseOpt.UpdateSparse(paramName, params[i], sparseGrads[i])
+				continue
+			}
+
+			if grads == nil {
+				grads = layer.GetGrads()
+			}
+			updated := optimizer.Update(paramName, params[i], grads[i])
 
 			// Update the parameter in place
 			for r := 0; r < params[i].Rows; r++ {
@@ -84,12 +213,17 @@ func (s *Sequential) UpdateWeights() {
 				}
 			}
 		}
-		layerIdx++
 	}
 }
 
-// TrainOnBatch trains the model on a single batch
-func (s *Sequential) TrainOnBatch(X, y *Matrix) (float64, error) {
+// TrainOnBatch trains the model on a single batch in training mode
+func (s *SequentialOf[T]) TrainOnBatch(X, y *MatrixOf[T]) (float64, error) {
+	s.Train()
+
+	if s.MixedPrecision != nil {
+		return s.trainMixedPrecision(X, y)
+	}
+
 	// Forward pass
 	predictions, err := s.Forward(X)
 	if err != nil {
@@ -101,6 +235,9 @@ func (s *Sequential) TrainOnBatch(X, y *Matrix) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if err := s.updateMetrics(predictions, y); err != nil {
+		return 0, err
+	}
 
 	// Backward pass through loss
 	gradLoss, err := s.Loss.Backward(predictions, y)
@@ -120,11 +257,16 @@ func (s *Sequential) TrainOnBatch(X, y *Matrix) (float64, error) {
 	return loss, nil
 }
 
-// Fit trains the model for multiple epochs
-func (s *Sequential) Fit(X, y *Matrix, epochs int, batchSize int, verbose bool) error {
+// Fit trains the model for multiple epochs and leaves it in inference mode.
+// The training report of every epoch, with the loss averaged over batches,
+// is appended to History.
+func (s *SequentialOf[T]) Fit(X, y *MatrixOf[T], epochs int, batchSize int, verbose bool) error {
+	defer s.Eval()
+
 	numSamples := X.Rows
 
 	for epoch := 0; epoch < epochs; epoch++ {
+		s.resetMetrics()
 		totalLoss := 0.0
 		numBatches := 0
 
@@ -135,21 +277,8 @@ func (s *Sequential) Fit(X, y *Matrix, epochs int, batchSize int, verbose bool)
 				end = numSamples
 			}
 
-			// Create batch
-			batchX := NewMatrix(end-i, X.Cols)
-			batchY := NewMatrix(end-i, y.Cols)
-
-			for j := i; j < end; j++ {
-				for k := 0; k < X.Cols; k++ {
-					batchX.Data[j-i][k] = X.Data[j][k]
-				}
-				for k := 0; k < y.Cols; k++ {
-					batchY.Data[j-i][k] = y.Data[j][k]
-				}
-			}
-
 			// Train on batch
-			loss, err := s.TrainOnBatch(batchX, batchY)
+			loss, err := s.TrainOnBatch(rowRange(X, i, end), rowRange(y, i, end))
 			if err != nil {
 				return err
 			}
@@ -158,32 +287,46 @@ func (s *Sequential) Fit(X, y *Matrix, epochs int, batchSize int, verbose bool)
 			numBatches++
 		}
 
-		avgLoss := totalLoss / float64(numBatches)
+		report := s.report(totalLoss / float64(numBatches))
+		s.History = append(s.History, report)
 
 		if verbose {
-			fmt.Printf("Epoch %d/%d - Loss: %.6f\n", epoch+1, epochs, avgLoss)
+			fmt.Printf("Epoch %d/%d - %s\n", epoch+1, epochs, report)
 		}
 	}
 
 	return nil
 }
 
-// Predict makes predictions on input data
-func (s *Sequential) Predict(X *Matrix) (*Matrix, error) {
+// Predict makes predictions on input data in inference mode
+func (s *SequentialOf[T]) Predict(X *MatrixOf[T]) (*MatrixOf[T], error) {
+	s.Eval()
 	return s.Forward(X)
 }
 
 // Evaluate computes loss on test data
-func (s *Sequential) Evaluate(X, y *Matrix) (float64, error) {
-	predictions, err := s.Predict(X)
+func (s *SequentialOf[T]) Evaluate(X, y *MatrixOf[T]) (float64, error) {
+	report, err := s.EvaluateReport(X, y)
 	if err != nil {
 		return 0, err
 	}
+	return report.Loss, nil
+}
 
+// EvaluateReport computes the loss and the metrics on test data
+func (s *SequentialOf[T]) EvaluateReport(X, y *MatrixOf[T]) (*Report, error) {
+	predictions, err := s.Predict(X)
+	if err != nil {
+		return nil, err
+	}
 	loss, err := s.Loss.Forward(predictions, y)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return loss, nil
+	s.resetMetrics()
+	if err := s.updateMetrics(predictions, y); err != nil {
+		return nil, err
+	}
+	return s.report(loss), nil
 }
@@ -0,0 +1,609 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// normBackward computes the input gradient of x̂ = (x - mean) * invStd over
+// one normalization group: dx = invStd/n * (n*dx̂ - Σdx̂ - x̂*Σ(dx̂*x̂)). The
+// sums are accumulated in float64.
+func normBackward[T Float](xhat, dxhat []T, invStd float64, dx []T) {
+	n := float64(len(xhat))
+	sumD, sumDX := 0.0, 0.0
+	for k := range xhat {
+		sumD += float64(dxhat[k])
+		sumDX += float64(dxhat[k] * xhat[k])
+	}
+	for k := range xhat {
+		dx[k] = T(invStd / n * (n*float64(dxhat[k]) - sumD - float64(xhat[k])*sumDX))
+	}
+}
+
+// meanVar returns the mean and biased variance of values, computed in
+// float64
+func meanVar[T Float](values []T) (float64, float64) {
+	mean := 0.0
+	for _, v := range values {
+		mean += float64(v)
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		d := float64(v) - mean
+		variance += d * d
+	}
+	variance /= float64(len(values))
+
+	return mean, variance
+}
+
+// onesMatrix creates a (1, size) matrix filled with ones
+func onesMatrix[T Float](size int) *MatrixOf[T] {
+	m := NewMatrixOf[T](1, size)
+	for j := 0; j < size; j++ {
+		m.Data[0][j] = 1
+	}
+	return m
+}
+
+// batchNorm normalizes every channel over the batch and spatial positions.
+// Rows hold samples flattened channel-major, so channel c of a row is the
+// contiguous block [c*S, (c+1)*S) with S = Cols/Channels. Statistics are
+// computed in float64.
+type batchNorm[T Float] struct {
+	Channels    int
+	Momentum    float64 // Weight of the current batch in the running statistics
+	Epsilon     float64
+	Gamma       *MatrixOf[T] // Shape: (1, Channels)
+	Beta        *MatrixOf[T] // Shape: (1, Channels)
+	RunningMean *MatrixOf[T] // Shape: (1, Channels)
+	RunningVar  *MatrixOf[T] // Shape: (1, Channels)
+
+	training bool
+
+	// Cache for backward pass
+	xhat      *MatrixOf[T]
+	invStd    []float64
+	batchStat bool // Whether the forward pass used batch statistics
+	gammaGrad *MatrixOf[T]
+	betaGrad  *MatrixOf[T]
+}
+
+// normParams returns gamma and beta of a normalization layer over size
+// features: Ones and Zeros unless opts select other initializers
+func normParams[T Float](size int, opts []InitOption) (*MatrixOf[T], *MatrixOf[T]) {
+	gammaInit, betaInit := initializers(opts, Ones{}, Zeros{})
+	return initializedMatrixOf[T](gammaInit, 1, size, size, size), initializedMatrixOf[T](betaInit, 1, size, size, size)
+}
+
+// newBatchNorm creates the shared batch normalization state
+func newBatchNorm[T Float](channels int, opts []InitOption) batchNorm[T] {
+	gamma, beta := normParams[T](channels, opts)
+	return batchNorm[T]{
+		Channels:    channels,
+		Momentum:    0.1,
+		Epsilon:     1e-5,
+		Gamma:       gamma,
+		Beta:        beta,
+		RunningMean: NewMatrixOf[T](1, channels),
+		RunningVar:  onesMatrix[T](channels),
+		gammaGrad:   NewMatrixOf[T](1, channels),
+		betaGrad:    NewMatrixOf[T](1, channels),
+	}
+}
+
+// SetTraining switches between batch statistics (training) and running
+// statistics (inference)
+func (bn *batchNorm[T]) SetTraining(training bool) {
+	bn.training = training
+}
+
+// Forward normalizes each channel and applies gamma and beta
+func (bn *batchNorm[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if bn.Channels <= 0 || input.Cols%bn.Channels != 0 {
+		return nil, fmt.Errorf("input size %d is not divisible into %d channels", input.Cols, bn.Channels)
+	}
+	spatial := input.Cols / bn.Channels
+
+	bn.xhat = NewMatrixOf[T](input.Rows, input.Cols)
+	bn.invStd = make([]float64, bn.Channels)
+	bn.batchStat = bn.training
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+
+	values := make([]T, 0, input.Rows*spatial)
+	for c := 0; c < bn.Channels; c++ {
+		var mean, invStd float64
+		if bn.training {
+			values = values[:0]
+			for i := 0; i < input.Rows; i++ {
+				values = append(values, input.Data[i][c*spatial:(c+1)*spatial]...)
+			}
+			var variance float64
+			mean, variance = meanVar(values)
+			invStd = 1 / math.Sqrt(variance+bn.Epsilon)
+
+			// Running variance uses the unbiased estimate
+			count := float64(len(values))
+			if count > 1 {
+				variance *= count / (count - 1)
+			}
+			bn.RunningMean.Data[0][c] = T((1-bn.Momentum)*float64(bn.RunningMean.Data[0][c]) + bn.Momentum*mean)
+			bn.RunningVar.Data[0][c] = T((1-bn.Momentum)*float64(bn.RunningVar.Data[0][c]) + bn.Momentum*variance)
+		} else {
+			mean = float64(bn.RunningMean.Data[0][c])
+			invStd = 1 / math.Sqrt(float64(bn.RunningVar.Data[0][c])+bn.Epsilon)
+		}
+		bn.invStd[c] = invStd
+
+		for i := 0; i < input.Rows; i++ {
+			for k := c * spatial; k < (c+1)*spatial; k++ {
+				bn.xhat.Data[i][k] = T((float64(input.Data[i][k]) - mean) * invStd)
+				output.Data[i][k] = bn.Gamma.Data[0][c]*bn.xhat.Data[i][k] + bn.Beta.Data[0][c]
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// Backward computes gradients for gamma, beta and the input
+func (bn *batchNorm[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gradOutput.Rows != bn.xhat.Rows || gradOutput.Cols != bn.xhat.Cols {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+	spatial := gradOutput.Cols / bn.Channels
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
+
+	n := gradOutput.Rows * spatial
+	xhat := make([]T, 0, n)
+	dxhat := make([]T, 0, n)
+	dx := make([]T, n)
+	for c := 0; c < bn.Channels; c++ {
+		gamma := bn.Gamma.Data[0][c]
+		xhat, dxhat = xhat[:0], dxhat[:0]
+		var gammaGrad, betaGrad T
+		for i := 0; i < gradOutput.Rows; i++ {
+			for k := c * spatial; k < (c+1)*spatial; k++ {
+				g := gradOutput.Data[i][k]
+				gammaGrad += g * bn.xhat.Data[i][k]
+				betaGrad += g
+				xhat = append(xhat, bn.xhat.Data[i][k])
+				dxhat = append(dxhat, g*gamma)
+			}
+		}
+		bn.gammaGrad.Data[0][c] = gammaGrad
+		bn.betaGrad.Data[0][c] = betaGrad
+
+		if bn.batchStat {
+			normBackward(xhat, dxhat, bn.invStd[c], dx)
+		} else {
+			// Running statistics are constants with respect to the input
+			for k := range dxhat {
+				dx[k] = dxhat[k] * T(bn.invStd[c])
+			}
+		}
+
+		idx := 0
+		for i := 0; i < gradOutput.Rows; i++ {
+			for k := c * spatial; k < (c+1)*spatial; k++ {
+				gradInput.Data[i][k] = dx[idx]
+				idx++
+			}
+		}
+	}
+
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (bn *batchNorm[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{bn.Gamma, bn.Beta}
+}
+
+// GetGrads returns the gradients of the parameters
+func (bn *batchNorm[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{bn.gammaGrad, bn.betaGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (bn *batchNorm[T]) GetParamNames() []string {
+	return []string{"gamma", "beta"}
+}
+
+// GetState returns the running statistics
+func (bn *batchNorm[T]) GetState() []*MatrixOf[T] {
+	return []*MatrixOf[T]{bn.RunningMean, bn.RunningVar}
+}
+
+// GetStateNames returns names for the running statistics
+func (bn *batchNorm[T]) GetStateNames() []string {
+	return []string{"running_mean", "running_var"}
+}
+
+// BatchNorm1DOf normalizes each feature of a (batch, features) input over
+// the batch. Running mean and variance are used in inference mode.
+type BatchNorm1DOf[T Float] struct {
+	batchNorm[T]
+}
+
+// BatchNorm1D is a BatchNorm1DOf with float64 values
+type BatchNorm1D = BatchNorm1DOf[float64]
+
+// NewBatchNorm1D creates a new batch normalization layer for dense inputs.
+// Gamma starts at 1 and beta at 0 unless opts select other initializers.
+func NewBatchNorm1D(features int, opts ...InitOption) *BatchNorm1D {
+	return NewBatchNorm1DOf[float64](features, opts...)
+}
+
+// NewBatchNorm1DOf creates a new batch normalization layer for dense inputs
+// with element type T
+func NewBatchNorm1DOf[T Float](features int, opts ...InitOption) *BatchNorm1DOf[T] {
+	return &BatchNorm1DOf[T]{newBatchNorm[T](features, opts)}
+}
+
+// BatchNorm2DOf normalizes each channel of rows flattened as
+// (Channels, H, W) over the batch and all spatial positions
+type BatchNorm2DOf[T Float] struct {
+	batchNorm[T]
+}
+
+// BatchNorm2D is a BatchNorm2DOf with float64 values
+type BatchNorm2D = BatchNorm2DOf[float64]
+
+// NewBatchNorm2D creates a new batch normalization layer for feature maps.
+// Gamma starts at 1 and beta at 0 unless opts select other initializers.
+func NewBatchNorm2D(channels int, opts ...InitOption) *BatchNorm2D {
+	return NewBatchNorm2DOf[float64](channels, opts...)
+}
+
+// NewBatchNorm2DOf creates a new batch normalization layer for feature maps
+// with element type T
+func NewBatchNorm2DOf[T Float](channels int, opts ...InitOption) *BatchNorm2DOf[T] {
+	return &BatchNorm2DOf[T]{newBatchNorm[T](channels, opts)}
+}
+
+// LayerNormOf normalizes every consecutive group of Size features of a row
+// independently, so a row holding a (time, Size) sequence is normalized
+// per step
+type LayerNormOf[T Float] struct {
+	Size    int
+	Epsilon float64
+	Gamma   *MatrixOf[T] // Shape: (1, Size)
+	Beta    *MatrixOf[T] // Shape: (1, Size)
+
+	// Cache for backward pass
+	xhat      *MatrixOf[T]
+	invStd    *Matrix // Shape: (batch, Cols/Size)
+	gammaGrad *MatrixOf[T]
+	betaGrad  *MatrixOf[T]
+}
+
+// LayerNorm is a LayerNormOf with float64 values
+type LayerNorm = LayerNormOf[float64]
+
+// NewLayerNorm creates a new layer normalization layer. Gamma starts at 1
+// and beta at 0 unless opts select other initializers.
+func NewLayerNorm(size int, opts ...InitOption) *LayerNorm {
+	return NewLayerNormOf[float64](size, opts...)
+}
+
+// NewLayerNormOf creates a new layer normalization layer with element
+// type T
+func NewLayerNormOf[T Float](size int, opts ...InitOption) *LayerNormOf[T] {
+	gamma, beta := normParams[T](size, opts)
+	return &LayerNormOf[T]{
+		Size:      size,
+		Epsilon:   1e-5,
+		Gamma:     gamma,
+		Beta:      beta,
+		gammaGrad: NewMatrixOf[T](1, size),
+		betaGrad:  NewMatrixOf[T](1, size),
+	}
+}
+
+// Forward normalizes each group and applies gamma and beta
+func (ln *LayerNormOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if ln.Size <= 0 || input.Cols%ln.Size != 0 {
+		return nil, fmt.Errorf("input size %d is not a multiple of %d", input.Cols, ln.Size)
+	}
+	groups := input.Cols / ln.Size
+
+	ln.xhat = NewMatrixOf[T](input.Rows, input.Cols)
+	ln.invStd = NewMatrix(input.Rows, groups)
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+
+	for i := 0; i < input.Rows; i++ {
+		for g := 0; g < groups; g++ {
+			start := g * ln.Size
+			mean, variance := meanVar(input.Data[i][start : start+ln.Size])
+			invStd := 1 / math.Sqrt(variance+ln.Epsilon)
+			ln.invStd.Data[i][g] = invStd
+			for j := 0; j < ln.Size; j++ {
+				xhat := T((float64(input.Data[i][start+j]) - mean) * invStd)
+				ln.xhat.Data[i][start+j] = xhat
+				output.Data[i][start+j] = ln.Gamma.Data[0][j]*xhat + ln.Beta.Data[0][j]
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// Backward computes gradients for gamma, beta and the input
+func (ln *LayerNormOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gradOutput.Rows != ln.xhat.Rows || gradOutput.Cols != ln.xhat.Cols {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+	groups := gradOutput.Cols / ln.Size
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
+
+	for j := 0; j < ln.Size; j++ {
+		ln.gammaGrad.Data[0][j] = 0
+		ln.betaGrad.Data[0][j] = 0
+	}
+
+	dxhat := make([]T, ln.Size)
+	for i := 0; i < gradOutput.Rows; i++ {
+		for g := 0; g < groups; g++ {
+			start := g * ln.Size
+			for j := 0; j < ln.Size; j++ {
+				grad := gradOutput.Data[i][start+j]
+				ln.gammaGrad.Data[0][j] += grad * ln.xhat.Data[i][start+j]
+				ln.betaGrad.Data[0][j] += grad
+				dxhat[j] = grad * ln.Gamma.Data[0][j]
+			}
+			normBackward(ln.xhat.Data[i][start:start+ln.Size], dxhat, ln.invStd.Data[i][g], gradInput.Data[i][start:start+ln.Size])
+		}
+	}
+
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (ln *LayerNormOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{ln.Gamma, ln.Beta}
+}
+
+// GetGrads returns the gradients of the parameters
+func (ln *LayerNormOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{ln.gammaGrad, ln.betaGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (ln *LayerNormOf[T]) GetParamNames() []string {
+	return []string{"gamma", "beta"}
+}
+
+// GroupNormOf splits the channels of rows flattened as (Channels, H, W) into
+// NumGroups groups and normalizes each group of each sample independently
+type GroupNormOf[T Float] struct {
+	NumGroups int
+	Channels  int
+	Epsilon   float64
+	Gamma     *MatrixOf[T] // Shape: (1, Channels)
+	Beta      *MatrixOf[T] // Shape: (1, Channels)
+
+	// Cache for backward pass
+	xhat      *MatrixOf[T]
+	invStd    *Matrix // Shape: (batch, NumGroups)
+	gammaGrad *MatrixOf[T]
+	betaGrad  *MatrixOf[T]
+}
+
+// GroupNorm is a GroupNormOf with float64 values
+type GroupNorm = GroupNormOf[float64]
+
+// NewGroupNorm creates a new group normalization layer. Gamma starts at 1
+// and beta at 0 unless opts select other initializers.
+func NewGroupNorm(numGroups, channels int, opts ...InitOption) *GroupNorm {
+	return NewGroupNormOf[float64](numGroups, channels, opts...)
+}
+
+// NewGroupNormOf creates a new group normalization layer with element
+// type T
+func NewGroupNormOf[T Float](numGroups, channels int, opts ...InitOption) *GroupNormOf[T] {
+	gamma, beta := normParams[T](channels, opts)
+	return &GroupNormOf[T]{
+		NumGroups: numGroups,
+		Channels:  channels,
+		Epsilon:   1e-5,
+		Gamma:     gamma,
+		Beta:      beta,
+		gammaGrad: NewMatrixOf[T](1, channels),
+		betaGrad:  NewMatrixOf[T](1, channels),
+	}
+}
+
+// Forward normalizes each channel group and applies gamma and beta
+func (gn *GroupNormOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gn.NumGroups <= 0 || gn.Channels%gn.NumGroups != 0 {
+		return nil, fmt.Errorf("%d channels cannot be split into %d groups", gn.Channels, gn.NumGroups)
+	}
+	if input.Cols%gn.Channels != 0 {
+		return nil, fmt.Errorf("input size %d is not divisible into %d channels", input.Cols, gn.Channels)
+	}
+	spatial := input.Cols / gn.Channels
+	groupSize := input.Cols / gn.NumGroups
+
+	gn.xhat = NewMatrixOf[T](input.Rows, input.Cols)
+	gn.invStd = NewMatrix(input.Rows, gn.NumGroups)
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+
+	for i := 0; i < input.Rows; i++ {
+		for g := 0; g < gn.NumGroups; g++ {
+			start := g * groupSize
+			mean, variance := meanVar(input.Data[i][start : start+groupSize])
+			invStd := 1 / math.Sqrt(variance+gn.Epsilon)
+			gn.invStd.Data[i][g] = invStd
+			for k := start; k < start+groupSize; k++ {
+				c := k / spatial
+				gn.xhat.Data[i][k] = T((float64(input.Data[i][k]) - mean) * invStd)
+				output.Data[i][k] = gn.Gamma.Data[0][c]*gn.xhat.Data[i][k] + gn.Beta.Data[0][c]
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// Backward computes gradients for gamma, beta and the input
+func (gn *GroupNormOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gradOutput.Rows != gn.xhat.Rows || gradOutput.Cols != gn.xhat.Cols {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+	spatial := gradOutput.Cols / gn.Channels
+	groupSize := gradOutput.Cols / gn.NumGroups
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
+
+	for c := 0; c < gn.Channels; c++ {
+		gn.gammaGrad.Data[0][c] = 0
+		gn.betaGrad.Data[0][c] = 0
+	}
+
+	dxhat := make([]T, groupSize)
+	for i := 0; i < gradOutput.Rows; i++ {
+		for g := 0; g < gn.NumGroups; g++ {
+			start := g * groupSize
+			for k := start; k < start+groupSize; k++ {
+				c := k / spatial
+				grad := gradOutput.Data[i][k]
+				gn.gammaGrad.Data[0][c] += grad * gn.xhat.Data[i][k]
+				gn.betaGrad.Data[0][c] += grad
+				dxhat[k-start] = grad * gn.Gamma.Data[0][c]
+			}
+			normBackward(gn.xhat.Data[i][start:start+groupSize], dxhat, gn.invStd.Data[i][g], gradInput.Data[i][start:start+groupSize])
+		}
+	}
+
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (gn *GroupNormOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{gn.Gamma, gn.Beta}
+}
+
+// GetGrads returns the gradients of the parameters
+func (gn *GroupNormOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{gn.gammaGrad, gn.betaGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (gn *GroupNormOf[T]) GetParamNames() []string {
+	return []string{"gamma", "beta"}
+}
+
+// RMSNormOf scales every consecutive group of Size features by the inverse
+// of its root mean square: y = x / sqrt(mean(x²) + ε) * gamma
+type RMSNormOf[T Float] struct {
+	Size    int
+	Epsilon float64
+	Gamma   *MatrixOf[T] // Shape: (1, Size)
+
+	// Cache for backward pass
+	lastInput *MatrixOf[T]
+	invRMS    *Matrix // Shape: (batch, Cols/Size)
+	gammaGrad *MatrixOf[T]
+}
+
+// RMSNorm is an RMSNormOf with float64 values
+type RMSNorm = RMSNormOf[float64]
+
+// NewRMSNorm creates a new RMS normalization layer. Gamma starts at 1
+// unless WithWeights selects another initializer; RMSNorm has no bias.
+func NewRMSNorm(size int, opts ...InitOption) *RMSNorm {
+	return NewRMSNormOf[float64](size, opts...)
+}
+
+// NewRMSNormOf creates a new RMS normalization layer with element type T
+func NewRMSNormOf[T Float](size int, opts ...InitOption) *RMSNormOf[T] {
+	gamma, _ := normParams[T](size, opts)
+	return &RMSNormOf[T]{
+		Size:      size,
+		Epsilon:   1e-5,
+		Gamma:     gamma,
+		gammaGrad: NewMatrixOf[T](1, size),
+	}
+}
+
+// Forward rescales each group by its inverse RMS and applies gamma
+func (rn *RMSNormOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if rn.Size <= 0 || input.Cols%rn.Size != 0 {
+		return nil, fmt.Errorf("input size %d is not a multiple of %d", input.Cols, rn.Size)
+	}
+	groups := input.Cols / rn.Size
+
+	rn.lastInput = input
+	rn.invRMS = NewMatrix(input.Rows, groups)
+	output := NewMatrixOf[T](input.Rows, input.Cols)
+
+	for i := 0; i < input.Rows; i++ {
+		for g := 0; g < groups; g++ {
+			start := g * rn.Size
+			sumSq := 0.0
+			for j := 0; j < rn.Size; j++ {
+				x := float64(input.Data[i][start+j])
+				sumSq += x * x
+			}
+			invRMS := 1 / math.Sqrt(sumSq/float64(rn.Size)+rn.Epsilon)
+			rn.invRMS.Data[i][g] = invRMS
+			for j := 0; j < rn.Size; j++ {
+				output.Data[i][start+j] = input.Data[i][start+j] * T(invRMS) * rn.Gamma.Data[0][j]
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// Backward computes gradients for gamma and the input
+// dx = r*gamma*g - x * r³/Size * Σ(gamma*g*x), with r the inverse RMS
+func (rn *RMSNormOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	if gradOutput.Rows != rn.lastInput.Rows || gradOutput.Cols != rn.lastInput.Cols {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+	groups := gradOutput.Cols / rn.Size
+	gradInput := NewMatrixOf[T](gradOutput.Rows, gradOutput.Cols)
+
+	for j := 0; j < rn.Size; j++ {
+		rn.gammaGrad.Data[0][j] = 0
+	}
+
+	for i := 0; i < gradOutput.Rows; i++ {
+		for g := 0; g < groups; g++ {
+			start := g * rn.Size
+			r := rn.invRMS.Data[i][g]
+			dot := 0.0
+			for j := 0; j < rn.Size; j++ {
+				x := float64(rn.lastInput.Data[i][start+j])
+				grad := float64(gradOutput.Data[i][start+j])
+				rn.gammaGrad.Data[0][j] += T(grad * x * r)
+				dot += float64(rn.Gamma.Data[0][j]) * grad * x
+			}
+			for j := 0; j < rn.Size; j++ {
+				x := float64(rn.lastInput.Data[i][start+j])
+				grad := float64(gradOutput.Data[i][start+j])
+				gradInput.Data[i][start+j] = T(r*float64(rn.Gamma.Data[0][j])*grad - x*r*r*r*dot/float64(rn.Size))
+			}
+		}
+	}
+
+	return gradInput, nil
+}
+
+// GetParams returns the parameters of the layer
+func (rn *RMSNormOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{rn.Gamma}
+}
+
+// GetGrads returns the gradients of the parameters
+func (rn *RMSNormOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{rn.gammaGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (rn *RMSNormOf[T]) GetParamNames() []string {
+	return []string{"gamma"}
+}
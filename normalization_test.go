@@ -0,0 +1,185 @@
+package nn
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBatchNorm1D(t *testing.T) {
+	bn := NewBatchNorm1D(2)
+	bn.SetTraining(true)
+	input := &Matrix{Rows: 2, Cols: 2, Data: [][]float64{{1, 10}, {3, 10}}}
+
+	out, err := bn.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Feature 0: mean 2, variance 1; feature 1 is constant
+	s := 1 / math.Sqrt(1+1e-5)
+	assertMatrix(t, "output", out, [][]float64{{-s, 0}, {s, 0}}, 1e-12)
+	// Running statistics move 10% towards the batch, with the unbiased
+	// variance 2 for feature 0
+	assertMatrix(t, "running_mean", bn.RunningMean, [][]float64{{0.2, 1}}, 1e-12)
+	assertMatrix(t, "running_var", bn.RunningVar, [][]float64{{1.1, 0.9}}, 1e-12)
+
+	bn.SetTraining(false)
+	if out, err = bn.Forward(input); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{
+		{(1 - 0.2) / math.Sqrt(1.1+1e-5), 9 / math.Sqrt(0.9+1e-5)},
+		{(3 - 0.2) / math.Sqrt(1.1+1e-5), 9 / math.Sqrt(0.9+1e-5)},
+	}
+	assertMatrix(t, "inference output", out, want, 1e-12)
+
+	if _, err := bn.Forward(NewMatrix(2, 3)); err == nil {
+		t.Error("expected an error for 3 features")
+	}
+}
+
+func TestBatchNorm2DChannels(t *testing.T) {
+	bn := NewBatchNorm2D(2)
+	bn.SetTraining(true)
+	// One sample, two 1x2 channels: statistics are per channel over space
+	input := &Matrix{Rows: 1, Cols: 4, Data: [][]float64{{0, 2, 5, 7}}}
+
+	out, err := bn.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := 1 / math.Sqrt(1+1e-5)
+	assertMatrix(t, "output", out, [][]float64{{-s, s, -s, s}}, 1e-12)
+	assertMatrix(t, "running_mean", bn.RunningMean, [][]float64{{0.1, 0.6}}, 1e-12)
+}
+
+func TestLayerNorm(t *testing.T) {
+	ln := NewLayerNorm(3)
+	ln.Gamma.Data[0] = []float64{1, 2, 1}
+	ln.Beta.Data[0] = []float64{0, 0, 1}
+
+	out, err := ln.Forward(&Matrix{Rows: 1, Cols: 3, Data: [][]float64{{1, 2, 3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mean 2, variance 2/3
+	s := 1 / math.Sqrt(2.0/3+1e-5)
+	assertMatrix(t, "output", out, [][]float64{{-s, 0, s + 1}}, 1e-12)
+}
+
+func TestGroupNorm(t *testing.T) {
+	gn := NewGroupNorm(2, 4)
+	out, err := gn.Forward(&Matrix{Rows: 1, Cols: 4, Data: [][]float64{{1, 3, 10, 14}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Groups {1, 3} and {10, 14} have variances 1 and 4
+	a, b := 1/math.Sqrt(1+1e-5), 2/math.Sqrt(4+1e-5)
+	assertMatrix(t, "output", out, [][]float64{{-a, a, -b, b}}, 1e-12)
+
+	if _, err := NewGroupNorm(3, 4).Forward(NewMatrix(1, 4)); err == nil {
+		t.Error("expected an error for 4 channels in 3 groups")
+	}
+}
+
+func TestRMSNorm(t *testing.T) {
+	rn := NewRMSNorm(2)
+	out, err := rn.Forward(&Matrix{Rows: 1, Cols: 2, Data: [][]float64{{3, 4}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rms := math.Sqrt(12.5 + 1e-5)
+	assertMatrix(t, "output", out, [][]float64{{3 / rms, 4 / rms}}, 1e-12)
+}
+
+func TestBatchNormGradientsInTraining(t *testing.T) {
+	layers := map[string]interface {
+		Layer
+		TrainingSetter
+	}{
+		"batch_norm_1d": NewBatchNorm1D(3),
+		"batch_norm_2d": NewBatchNorm2D(2),
+	}
+	for name, layer := range layers {
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(10))
+			randomizeParams(rng, layer)
+			layer.SetTraining(true)
+			result, err := GradCheck(layer, uniformMatrix(rng, 4, 6, -1, 1), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if worst := result.Max(); worst > layerTolerance {
+				t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+			}
+		})
+	}
+}
+
+// normModel builds a model holding every normalization layer
+func normModel() *Sequential {
+	model := NewSequential()
+	model.Add(NewDense(4, 8))
+	model.Add(NewBatchNorm1D(8))
+	model.Add(NewLayerNorm(8))
+	model.Add(NewBatchNorm2D(2))
+	model.Add(NewGroupNorm(2, 4))
+	model.Add(NewRMSNorm(8))
+	return model
+}
+
+func TestNormalizationSaveLoad(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	X := uniformMatrix(rng, 5, 4, -1, 1)
+
+	model := normModel()
+	for _, layer := range model.Layers {
+		randomizeParams(rng, layer)
+	}
+	// Move the running statistics away from their initial values
+	model.Train()
+	for i := 0; i < 3; i++ {
+		if _, err := model.Forward(X); err != nil {
+			t.Fatal(err)
+		}
+	}
+	model.Eval()
+	want, err := model.Forward(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := model.SaveWeights(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := normModel()
+	if err := loaded.LoadWeights(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, layer := range model.Layers {
+		for k, p := range layer.GetParams() {
+			if !matricesEqual(p, loaded.Layers[i].GetParams()[k]) {
+				t.Errorf("layer %d parameter %s differs", i, layer.GetParamNames()[k])
+			}
+		}
+		if stateful, ok := layer.(StatefulLayer); ok {
+			for k, m := range stateful.GetState() {
+				if !matricesEqual(m, loaded.Layers[i].(StatefulLayer).GetState()[k]) {
+					t.Errorf("layer %d state %s differs", i, stateful.GetStateNames()[k])
+				}
+			}
+		}
+	}
+
+	loaded.Eval()
+	got, err := loaded.Forward(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matricesEqual(got, want) {
+		t.Error("loaded model predicts differently")
+	}
+}
@@ -2,8 +2,10 @@ package nn
 
 import "math"
 
-// AdamOptimizer implements the Adam optimization algorithm
-type AdamOptimizer struct {
+// AdamOptimizerOf implements the Adam optimization algorithm for parameters
+// of element type T. Moment estimates are stored in T and the update is
+// computed in float64.
+type AdamOptimizerOf[T Float] struct {
 	LearningRate float64
 	Beta1        float64 // Exponential decay rate for first moment estimates
 	Beta2        float64 // Exponential decay rate for second moment estimates
@@ -11,112 +13,149 @@ type AdamOptimizer struct {
 	T            int     // Time step
 
 	// First moment vector (mean of gradients)
-	M map[string]*Matrix
+	M map[string]*MatrixOf[T]
 
 	// Second moment vector (uncentered variance of gradients)
-	V map[string]*Matrix
+	V map[string]*MatrixOf[T]
 }
 
+// AdamOptimizer implements the Adam optimization algorithm
+type AdamOptimizer = AdamOptimizerOf[float64]
+
 // NewAdamOptimizer creates a new Adam optimizer with default parameters
 func NewAdamOptimizer(learningRate float64) *AdamOptimizer {
-	return &AdamOptimizer{
+	return NewAdamOptimizerOf[float64](learningRate)
+}
+
+// NewAdamOptimizerOf creates a new Adam optimizer for parameters of element
+// type T
+func NewAdamOptimizerOf[T Float](learningRate float64) *AdamOptimizerOf[T] {
+	return &AdamOptimizerOf[T]{
 		LearningRate: learningRate,
 		Beta1:        0.9,
 		Beta2:        0.999,
 		Epsilon:      1e-8,
 		T:            0,
-		M:            make(map[string]*Matrix),
-		V:            make(map[string]*Matrix),
+		M:            make(map[string]*MatrixOf[T]),
+		V:            make(map[string]*MatrixOf[T]),
 	}
 }
 
-// Update updates parameters using Adam algorithm
-func (adam *AdamOptimizer) Update(paramName string, params, gradients *Matrix) *Matrix {
-	adam.T++
-
-	// Initialize moment vectors if not exists
+// moments returns the moment estimates of a parameter, creating them on
+// first use. They are dense even for sparse updates, so Adam keeps two
+// extra copies of every parameter, including whole embedding tables.
+func (adam *AdamOptimizerOf[T]) moments(paramName string, rows, cols int) (*MatrixOf[T], *MatrixOf[T]) {
 	if adam.M[paramName] == nil {
-		adam.M[paramName] = NewMatrix(params.Rows, params.Cols)
-		adam.V[paramName] = NewMatrix(params.Rows, params.Cols)
+		adam.M[paramName] = NewMatrixOf[T](rows, cols)
+		adam.V[paramName] = NewMatrixOf[T](rows, cols)
 	}
+	return adam.M[paramName], adam.V[paramName]
+}
 
-	m := adam.M[paramName]
-	v := adam.V[paramName]
+// step updates the moments of one element and returns its new value
+func (adam *AdamOptimizerOf[T]) step(param, grad float64, m, v *T, correction1, correction2 float64) T {
+	*m = T(adam.Beta1*float64(*m) + (1-adam.Beta1)*grad)
+	*v = T(adam.Beta2*float64(*v) + (1-adam.Beta2)*grad*grad)
+	mHat := float64(*m) / correction1
+	vHat := float64(*v) / correction2
+	return T(param - adam.LearningRate*mHat/(math.Sqrt(vHat)+adam.Epsilon))
+}
 
-	// Update biased first moment estimate
-	for i := 0; i < params.Rows; i++ {
-		for j := 0; j < params.Cols; j++ {
-			m.Data[i][j] = adam.Beta1*m.Data[i][j] + (1-adam.Beta1)*gradients.Data[i][j]
-		}
-	}
+// Update updates parameters using Adam algorithm
+func (adam *AdamOptimizerOf[T]) Update(paramName string, params, gradients *MatrixOf[T]) *MatrixOf[T] {
+	adam.T++
 
-	// Update biased second raw moment estimate
-	for i := 0; i < params.Rows; i++ {
-		for j := 0; j < params.Cols; j++ {
-			v.Data[i][j] = adam.Beta2*v.Data[i][j] + (1-adam.Beta2)*gradients.Data[i][j]*gradients.Data[i][j]
-		}
-	}
+	m, v := adam.moments(paramName, params.Rows, params.Cols)
+	correction1 := 1 - math.Pow(adam.Beta1, float64(adam.T))
+	correction2 := 1 - math.Pow(adam.Beta2, float64(adam.T))
 
-	// Compute bias-corrected first moment estimate
-	mHat := NewMatrix(params.Rows, params.Cols)
+	updated := NewMatrixOf[T](params.Rows, params.Cols)
 	for i := 0; i < params.Rows; i++ {
 		for j := 0; j < params.Cols; j++ {
-			mHat.Data[i][j] = m.Data[i][j] / (1 - math.Pow(adam.Beta1, float64(adam.T)))
+			updated.Data[i][j] = adam.step(float64(params.Data[i][j]), float64(gradients.Data[i][j]),
+				&m.Data[i][j], &v.Data[i][j], correction1, correction2)
 		}
 	}
 
-	// Compute bias-corrected second raw moment estimate
-	vHat := NewMatrix(params.Rows, params.Cols)
-	for i := 0; i < params.Rows; i++ {
-		for j := 0; j < params.Cols; j++ {
-			vHat.Data[i][j] = v.Data[i][j] / (1 - math.Pow(adam.Beta2, float64(adam.T)))
-		}
-	}
+	return updated
+}
 
-	// Update parameters
-	updated := NewMatrix(params.Rows, params.Cols)
-	for i := 0; i < params.Rows; i++ {
+// UpdateSparse applies the Adam update to the rows in grad only. Moment
+// estimates of the other rows are left untouched (lazy Adam), so the cost
+// scales with the number of looked-up rows rather than the table size.
+func (adam *AdamOptimizerOf[T]) UpdateSparse(paramName string, params *MatrixOf[T], grad *SparseGrad) {
+	adam.T++
+
+	m, v := adam.moments(paramName, params.Rows, params.Cols)
+	correction1 := 1 - math.Pow(adam.Beta1, float64(adam.T))
+	correction2 := 1 - math.Pow(adam.Beta2, float64(adam.T))
+
+	for pos, i := range grad.Indices {
 		for j := 0; j < params.Cols; j++ {
-			updated.Data[i][j] = params.Data[i][j] - adam.LearningRate*mHat.Data[i][j]/(math.Sqrt(vHat.Data[i][j])+adam.Epsilon)
+			params.Data[i][j] = adam.step(float64(params.Data[i][j]), grad.Values.Data[pos][j],
+				&m.Data[i][j], &v.Data[i][j], correction1, correction2)
 		}
 	}
-
-	return updated
 }
 
-// SGD implements simple stochastic gradient descent
-type SGD struct {
+// SGDOf implements stochastic gradient descent for parameters of element
+// type T
+type SGDOf[T Float] struct {
 	LearningRate float64
 	Momentum     float64
-	Velocity     map[string]*Matrix
+	Velocity     map[string]*MatrixOf[T]
 }
 
+// SGD implements simple stochastic gradient descent
+type SGD = SGDOf[float64]
+
 // NewSGD creates a new SGD optimizer
 func NewSGD(learningRate, momentum float64) *SGD {
-	return &SGD{
+	return NewSGDOf[float64](learningRate, momentum)
+}
+
+// NewSGDOf creates a new SGD optimizer for parameters of element type T
+func NewSGDOf[T Float](learningRate, momentum float64) *SGDOf[T] {
+	return &SGDOf[T]{
 		LearningRate: learningRate,
 		Momentum:     momentum,
-		Velocity:     make(map[string]*Matrix),
+		Velocity:     make(map[string]*MatrixOf[T]),
 	}
 }
 
-// Update updates parameters using SGD with momentum
-func (sgd *SGD) Update(paramName string, params, gradients *Matrix) *Matrix {
-	// Initialize velocity if not exists
+// velocity returns the velocity of a parameter, creating it on first use
+func (sgd *SGDOf[T]) velocity(paramName string, rows, cols int) *MatrixOf[T] {
 	if sgd.Velocity[paramName] == nil {
-		sgd.Velocity[paramName] = NewMatrix(params.Rows, params.Cols)
+		sgd.Velocity[paramName] = NewMatrixOf[T](rows, cols)
 	}
+	return sgd.Velocity[paramName]
+}
 
-	velocity := sgd.Velocity[paramName]
+// Update updates parameters using SGD with momentum
+func (sgd *SGDOf[T]) Update(paramName string, params, gradients *MatrixOf[T]) *MatrixOf[T] {
+	velocity := sgd.velocity(paramName, params.Rows, params.Cols)
 
 	// Update velocity and parameters
-	updated := NewMatrix(params.Rows, params.Cols)
+	updated := NewMatrixOf[T](params.Rows, params.Cols)
 	for i := 0; i < params.Rows; i++ {
 		for j := 0; j < params.Cols; j++ {
-			velocity.Data[i][j] = sgd.Momentum*velocity.Data[i][j] - sgd.LearningRate*gradients.Data[i][j]
+			velocity.Data[i][j] = T(sgd.Momentum*float64(velocity.Data[i][j]) - sgd.LearningRate*float64(gradients.Data[i][j]))
 			updated.Data[i][j] = params.Data[i][j] + velocity.Data[i][j]
 		}
 	}
 
 	return updated
 }
+
+// UpdateSparse applies SGD with momentum to the rows in grad only; the
+// velocity of the other rows is not decayed
+func (sgd *SGDOf[T]) UpdateSparse(paramName string, params *MatrixOf[T], grad *SparseGrad) {
+	velocity := sgd.velocity(paramName, params.Rows, params.Cols)
+
+	for pos, i := range grad.Indices {
+		for j := 0; j < params.Cols; j++ {
+			velocity.Data[i][j] = T(sgd.Momentum*float64(velocity.Data[i][j]) - sgd.LearningRate*grad.Values.Data[pos][j])
+			params.Data[i][j] += velocity.Data[i][j]
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package nn
+
+import (
+	"math"
+	"testing"
+)
+
+// filledMatrix returns a rows x cols matrix holding value everywhere
+func filledMatrix(rows, cols int, value float64) *Matrix {
+	m := NewMatrix(rows, cols)
+	Constant{Value: value}.Initialize(m, rows, cols)
+	return m
+}
+
+func TestAdamUpdate(t *testing.T) {
+	adam := NewAdamOptimizer(0.1)
+	params := &Matrix{Rows: 1, Cols: 2, Data: [][]float64{{1, 1}}}
+	grads := &Matrix{Rows: 1, Cols: 2, Data: [][]float64{{0.5, -2}}}
+
+	// The first bias-corrected step moves every element by the learning
+	// rate against the sign of its gradient
+	params = adam.Update("w", params, grads)
+	assertMatrix(t, "step 1", params, [][]float64{{0.9, 1.1}}, 1e-7)
+
+	// Second step with the same gradient: mHat = g and vHat = g²
+	params = adam.Update("w", params, grads)
+	assertMatrix(t, "step 2", params, [][]float64{{0.8, 1.2}}, 1e-7)
+	if adam.T != 2 {
+		t.Errorf("time step %d, expected 2", adam.T)
+	}
+}
+
+func TestSGDMomentum(t *testing.T) {
+	sgd := NewSGD(0.1, 0.9)
+	params := filledMatrix(1, 1, 1)
+	grads := filledMatrix(1, 1, 1)
+
+	params = sgd.Update("w", params, grads)
+	assertMatrix(t, "step 1", params, [][]float64{{0.9}}, 1e-12)
+	// v = 0.9 * -0.1 - 0.1
+	params = sgd.Update("w", params, grads)
+	assertMatrix(t, "step 2", params, [][]float64{{0.71}}, 1e-12)
+}
+
+func TestSparseAdamIsLazy(t *testing.T) {
+	sparse, dense := NewAdamOptimizer(0.1), NewAdamOptimizer(0.1)
+	sparseParams, denseParams := filledMatrix(4, 2, 1), filledMatrix(4, 2, 1)
+
+	// Step 1 touches rows 0 and 2: identical to a dense update with zero
+	// gradients elsewhere
+	grad := &SparseGrad{Indices: []int{0, 2}, Values: &Matrix{Rows: 2, Cols: 2, Data: [][]float64{{1, -1}, {0.5, 0.5}}}}
+	denseGrad := &Matrix{Rows: 4, Cols: 2, Data: [][]float64{{1, -1}, {0, 0}, {0.5, 0.5}, {0, 0}}}
+	sparse.UpdateSparse("table", sparseParams, grad)
+	denseParams = dense.Update("table", denseParams, denseGrad)
+	assertMatrix(t, "step 1", sparseParams, denseParams.Data, 1e-12)
+	assertMatrix(t, "step 1", sparseParams, [][]float64{{0.9, 1.1}, {1, 1}, {0.9, 0.9}, {1, 1}}, 1e-7)
+
+	// Step 2 touches row 1 only: row 0 keeps its value and its moments,
+	// where dense Adam would keep moving it with the decayed momentum
+	grad = &SparseGrad{Indices: []int{1}, Values: &Matrix{Rows: 1, Cols: 2, Data: [][]float64{{2, 2}}}}
+	sparse.UpdateSparse("table", sparseParams, grad)
+	for i, want := range map[int][]float64{0: {0.9, 1.1}, 2: {0.9, 0.9}, 3: {1, 1}} {
+		assertMatrix(t, "untouched row", &Matrix{Rows: 1, Cols: 2, Data: sparseParams.Data[i : i+1]}, [][]float64{want}, 1e-7)
+	}
+	if m := sparse.M["table"].Data[0][0]; math.Abs(m-0.1) > 1e-12 {
+		t.Errorf("first moment of an untouched row decayed to %v, expected 0.1", m)
+	}
+	if sparseParams.Data[1][0] >= 1 {
+		t.Errorf("touched row did not move: %v", sparseParams.Data[1][0])
+	}
+	if sparse.T != 2 {
+		t.Errorf("time step %d, expected 2", sparse.T)
+	}
+}
+
+func TestSparseSGDIsLazy(t *testing.T) {
+	sgd := NewSGD(0.1, 0.9)
+	params := filledMatrix(3, 1, 1)
+
+	sgd.UpdateSparse("table", params, &SparseGrad{Indices: []int{0, 1}, Values: filledMatrix(2, 1, 1)})
+	sgd.UpdateSparse("table", params, &SparseGrad{Indices: []int{1}, Values: filledMatrix(1, 1, 1)})
+
+	// Row 0 moved once and keeps its velocity, row 1 moved twice with
+	// momentum, row 2 never moved
+	assertMatrix(t, "params", params, [][]float64{{0.9}, {0.71}, {1}}, 1e-12)
+	assertMatrix(t, "velocity", sgd.Velocity["table"], [][]float64{{-0.1}, {-0.19}, {0}}, 1e-12)
+}
+
+func TestEmbeddingTrainsLookedUpRows(t *testing.T) {
+	for name, opt := range map[string]Optimizer{"adam": NewAdamOptimizer(0.1), "sgd": NewSGD(0.1, 0.9)} {
+		t.Run(name, func(t *testing.T) {
+			emb := NewEmbedding(5, 2)
+			model := NewSequential()
+			model.Add(emb)
+			model.Compile(NewMSE(), opt)
+			before := copyMatrix(emb.Weights)
+
+			X := &Matrix{Rows: 2, Cols: 1, Data: [][]float64{{1}, {3}}}
+			if _, err := model.TrainOnBatch(X, filledMatrix(2, 2, 1)); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 5; i++ {
+				changed := emb.Weights.Data[i][0] != before.Data[i][0]
+				if changed != (i == 1 || i == 3) {
+					t.Errorf("row %d changed: %v", i, changed)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddingDenseGradReused(t *testing.T) {
+	emb := NewEmbedding(5, 2)
+	backward := func(ids ...float64) *Matrix {
+		input := &Matrix{Rows: 1, Cols: len(ids), Data: [][]float64{ids}}
+		if _, err := emb.Forward(input); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := emb.Backward(filledMatrix(1, len(ids)*2, 1)); err != nil {
+			t.Fatal(err)
+		}
+		return emb.GetGrads()[0]
+	}
+
+	first := backward(1, 3)
+	second := backward(2)
+	if first != second {
+		t.Error("GetGrads allocated a new dense gradient")
+	}
+	// Rows of the previous batch are cleared
+	for i, row := range second.Data {
+		want := 0.0
+		if i == 2 {
+			want = 1
+		}
+		if row[0] != want || row[1] != want {
+			t.Errorf("row %d is %v, expected %v", i, row, want)
+		}
+	}
+}
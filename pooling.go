@@ -0,0 +1,738 @@
+package nn
+
+import (
+	"fmt"
+)
+
+// window is the [start, end) input range pooled into one output position
+type window struct {
+	start, end int
+}
+
+// fixedWindows returns windows of the given size moved by stride
+func fixedWindows(in, size, stride int) ([]window, error) {
+	if size < 1 || stride < 1 {
+		return nil, fmt.Errorf("pool size and stride must be positive")
+	}
+	if in < size {
+		return nil, fmt.Errorf("input size %d is smaller than pool size %d", in, size)
+	}
+	windows := make([]window, (in-size)/stride+1)
+	for i := range windows {
+		windows[i] = window{i * stride, i*stride + size}
+	}
+	return windows, nil
+}
+
+// adaptiveWindows splits in positions into out windows of nearly equal
+// size, possibly overlapping when out does not divide in
+func adaptiveWindows(in, out int) ([]window, error) {
+	if out < 1 || in < out {
+		return nil, fmt.Errorf("cannot pool %d positions into %d", in, out)
+	}
+	windows := make([]window, out)
+	for i := range windows {
+		windows[i] = window{i * in / out, ((i+1)*in + out - 1) / out}
+	}
+	return windows, nil
+}
+
+// pooler pools (C, H, W) samples over row and column windows by average or
+// maximum. It is embedded by every pooling layer; pooling layers have no
+// parameters.
+type pooler[T Float] struct {
+	average       bool
+	rows, cols    []window
+	inC, inH, inW int
+	argmax        [][][][2]int // Per sample, input position of every maximum
+}
+
+// forward pools every sample and remembers what backward needs
+func (p *pooler[T]) forward(inputs []*Tensor3DOf[T], rows, cols []window) []*Tensor3DOf[T] {
+	p.rows, p.cols = rows, cols
+	p.argmax = make([][][][2]int, len(inputs))
+	outputs := make([]*Tensor3DOf[T], len(inputs))
+
+	if len(inputs) > 0 {
+		p.inC, p.inH, p.inW = inputs[0].Channels, inputs[0].Height, inputs[0].Width
+	}
+
+	parallelFor(len(inputs), workerCount(len(inputs)), func(_, n int) {
+		input := inputs[n]
+		output := NewTensor3DOf[T](input.Channels, len(rows), len(cols))
+		if !p.average {
+			p.argmax[n] = make([][][2]int, input.Channels)
+		}
+
+		for c := 0; c < input.Channels; c++ {
+			if !p.average {
+				p.argmax[n][c] = make([][2]int, len(rows)*len(cols))
+			}
+			for oh, r := range rows {
+				for ow, w := range cols {
+					if p.average {
+						sum := 0.0
+						for h := r.start; h < r.end; h++ {
+							for x := w.start; x < w.end; x++ {
+								sum += float64(input.Data[c][h][x])
+							}
+						}
+						output.Data[c][oh][ow] = T(sum / float64((r.end-r.start)*(w.end-w.start)))
+						continue
+					}
+
+					best := [2]int{r.start, w.start}
+					for h := r.start; h < r.end; h++ {
+						for x := w.start; x < w.end; x++ {
+							if input.Data[c][h][x] > input.Data[c][best[0]][best[1]] {
+								best = [2]int{h, x}
+							}
+						}
+					}
+					p.argmax[n][c][oh*len(cols)+ow] = best
+					output.Data[c][oh][ow] = input.Data[c][best[0]][best[1]]
+				}
+			}
+		}
+		outputs[n] = output
+	})
+
+	return outputs
+}
+
+// backward routes every output gradient to the maximum of its window or
+// spreads it evenly over the window
+func (p *pooler[T]) backward(gradOutputs []*Tensor3DOf[T]) ([]*Tensor3DOf[T], error) {
+	if p.rows == nil || len(gradOutputs) != len(p.argmax) {
+		return nil, fmt.Errorf("backward called before forward")
+	}
+
+	for _, gradOutput := range gradOutputs {
+		if gradOutput.Channels != p.inC || gradOutput.Height != len(p.rows) || gradOutput.Width != len(p.cols) {
+			return nil, fmt.Errorf("gradient size mismatch: got (%d, %d, %d), expected (%d, %d, %d)",
+				gradOutput.Channels, gradOutput.Height, gradOutput.Width, p.inC, len(p.rows), len(p.cols))
+		}
+	}
+
+	gradInputs := make([]*Tensor3DOf[T], len(gradOutputs))
+	parallelFor(len(gradOutputs), workerCount(len(gradOutputs)), func(_, n int) {
+		gradOutput := gradOutputs[n]
+		gradInput := NewTensor3DOf[T](gradOutput.Channels, p.inH, p.inW)
+
+		for c := 0; c < gradOutput.Channels; c++ {
+			for oh, r := range p.rows {
+				for ow, w := range p.cols {
+					grad := gradOutput.Data[c][oh][ow]
+					if !p.average {
+						best := p.argmax[n][c][oh*len(p.cols)+ow]
+						gradInput.Data[c][best[0]][best[1]] += grad
+						continue
+					}
+
+					share := grad / T((r.end-r.start)*(w.end-w.start))
+					for h := r.start; h < r.end; h++ {
+						for x := w.start; x < w.end; x++ {
+							gradInput.Data[c][h][x] += share
+						}
+					}
+				}
+			}
+		}
+		gradInputs[n] = gradInput
+	})
+
+	return gradInputs, nil
+}
+
+// forwardOne pools a single sample
+func (p *pooler[T]) forwardOne(input *Tensor3DOf[T], rows, cols []window) *Tensor3DOf[T] {
+	return p.forward([]*Tensor3DOf[T]{input}, rows, cols)[0]
+}
+
+// backwardOne computes the input gradient of a single sample
+func (p *pooler[T]) backwardOne(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	grads, err := p.backward([]*Tensor3DOf[T]{gradOutput})
+	if err != nil {
+		return nil, err
+	}
+	return grads[0], nil
+}
+
+// forwardBatch pools every sample of a batch
+func (p *pooler[T]) forwardBatch(input *Tensor4DOf[T], rows, cols []window) *Tensor4DOf[T] {
+	outputs := p.forward(input.Samples(), rows, cols)
+	return &Tensor4DOf[T]{Batch: input.Batch, Channels: input.Channels, Height: len(rows), Width: len(cols),
+		Data: tensorData(outputs)}
+}
+
+// backwardBatch computes the input gradient of every sample of a batch
+func (p *pooler[T]) backwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	grads, err := p.backward(gradOutput.Samples())
+	if err != nil {
+		return nil, err
+	}
+	return &Tensor4DOf[T]{Batch: gradOutput.Batch, Channels: gradOutput.Channels, Height: p.inH, Width: p.inW,
+		Data: tensorData(grads)}, nil
+}
+
+// tensorData collects the data of tensors into a 4D slice
+func tensorData[T Float](tensors []*Tensor3DOf[T]) [][][][]T {
+	data := make([][][][]T, len(tensors))
+	for n, t := range tensors {
+		data[n] = t.Data
+	}
+	return data
+}
+
+// forwardSequences pools (batch, time*channels) rows along time
+func (p *pooler[T]) forwardSequences(input *MatrixOf[T], channels int, windows func(length int) ([]window, error)) (*MatrixOf[T], error) {
+	if input.Cols%channels != 0 {
+		return nil, fmt.Errorf("input size %d is not a multiple of %d channels", input.Cols, channels)
+	}
+	cols, err := windows(input.Cols / channels)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]*Tensor3DOf[T], input.Rows)
+	for i := range inputs {
+		inputs[i] = sequenceToTensor(input.Data[i], channels)
+	}
+	outputs := p.forward(inputs, []window{{0, 1}}, cols)
+
+	output := NewMatrixOf[T](input.Rows, len(cols)*channels)
+	for i, out := range outputs {
+		tensorToSequence(out, output.Data[i])
+	}
+	return output, nil
+}
+
+// backwardSequences computes the gradient of forwardSequences
+func (p *pooler[T]) backwardSequences(gradOutput *MatrixOf[T], channels int) (*MatrixOf[T], error) {
+	if gradOutput.Cols != len(p.cols)*channels {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	grads := make([]*Tensor3DOf[T], gradOutput.Rows)
+	for i := range grads {
+		grads[i] = sequenceToTensor(gradOutput.Data[i], channels)
+	}
+	gradInputs, err := p.backward(grads)
+	if err != nil {
+		return nil, err
+	}
+
+	gradInput := NewMatrixOf[T](gradOutput.Rows, p.inW*channels)
+	for i, g := range gradInputs {
+		tensorToSequence(g, gradInput.Data[i])
+	}
+	return gradInput, nil
+}
+
+// GetParams returns no parameters
+func (p *pooler[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetGrads returns no gradients
+func (p *pooler[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{}
+}
+
+// GetParamNames returns no names
+func (p *pooler[T]) GetParamNames() []string {
+	return []string{}
+}
+
+// fixedWindows2D returns the windows of a square pool along the rows and
+// the columns of an input
+func fixedWindows2D(height, width, size, stride int) ([]window, []window, error) {
+	rows, err := fixedWindows(height, size, stride)
+	if err != nil {
+		return nil, nil, err
+	}
+	cols, err := fixedWindows(width, size, stride)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, cols, nil
+}
+
+// poolShape returns the output shape of pooling windows, or the error
+// finding them
+func poolShape(channels int, rows, cols []window, err error) (int, int, int, error) {
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return channels, len(rows), len(cols), nil
+}
+
+// MaxPool2DOf performs 2D max pooling. Forward keeps its original signature
+// and panics when the pool does not fit the input; ForwardChecked returns
+// an error instead, and CheckedMaxPool2D wraps the layer for NewSpatial.
+type MaxPool2DOf[T Float] struct {
+	pooler[T]
+	PoolSize int
+	Stride   int
+}
+
+// MaxPool2D is a MaxPool2DOf with float64 values
+type MaxPool2D = MaxPool2DOf[float64]
+
+// NewMaxPool2D creates a new max pooling layer
+func NewMaxPool2D(poolSize, stride int) *MaxPool2D {
+	return NewMaxPool2DOf[float64](poolSize, stride)
+}
+
+// NewMaxPool2DOf creates a new max pooling layer with element type T
+func NewMaxPool2DOf[T Float](poolSize, stride int) *MaxPool2DOf[T] {
+	return &MaxPool2DOf[T]{PoolSize: poolSize, Stride: stride}
+}
+
+// Forward performs max pooling. It panics if the pool size or the stride
+// is below 1 or if the input is smaller than the pool.
+func (pool *MaxPool2DOf[T]) Forward(input *Tensor3DOf[T]) *Tensor3DOf[T] {
+	output, err := pool.ForwardChecked(input)
+	if err != nil {
+		panic(err)
+	}
+	return output
+}
+
+// ForwardChecked performs max pooling, returning an error where Forward
+// panics
+func (pool *MaxPool2DOf[T]) ForwardChecked(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	rows, cols, err := fixedWindows2D(input.Height, input.Width, pool.PoolSize, pool.Stride)
+	if err != nil {
+		return nil, err
+	}
+	return pool.forwardOne(input, rows, cols), nil
+}
+
+// Backward routes the gradient to the maximum of every window
+func (pool *MaxPool2DOf[T]) Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.backwardOne(gradOutput)
+}
+
+// ForwardBatch performs max pooling on every sample of the batch
+func (pool *MaxPool2DOf[T]) ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	rows, cols, err := fixedWindows2D(input.Height, input.Width, pool.PoolSize, pool.Stride)
+	if err != nil {
+		return nil, err
+	}
+	return pool.forwardBatch(input, rows, cols), nil
+}
+
+// BackwardBatch routes the gradient of every sample to its maxima
+func (pool *MaxPool2DOf[T]) BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	return pool.backwardBatch(gradOutput)
+}
+
+// OutputShape returns the (C, H, W) output shape for an input shape
+func (pool *MaxPool2DOf[T]) OutputShape(channels, height, width int) (int, int, int, error) {
+	rows, cols, err := fixedWindows2D(height, width, pool.PoolSize, pool.Stride)
+	return poolShape(channels, rows, cols, err)
+}
+
+// CheckedMaxPool2DOf is a MaxPool2DOf whose Forward returns an error instead
+// of panicking, so it implements SpatialLayerOf and can be wrapped by
+// NewSpatialOf
+type CheckedMaxPool2DOf[T Float] struct {
+	*MaxPool2DOf[T]
+}
+
+// CheckedMaxPool2D is a CheckedMaxPool2DOf with float64 values
+type CheckedMaxPool2D = CheckedMaxPool2DOf[float64]
+
+// NewCheckedMaxPool2D creates a new max pooling layer for NewSpatial
+func NewCheckedMaxPool2D(poolSize, stride int) *CheckedMaxPool2D {
+	return NewCheckedMaxPool2DOf[float64](poolSize, stride)
+}
+
+// NewCheckedMaxPool2DOf creates a new max pooling layer for NewSpatialOf
+// with element type T
+func NewCheckedMaxPool2DOf[T Float](poolSize, stride int) *CheckedMaxPool2DOf[T] {
+	return &CheckedMaxPool2DOf[T]{NewMaxPool2DOf[T](poolSize, stride)}
+}
+
+// Forward performs max pooling, returning an error if the pool does not fit
+// the input
+func (pool *CheckedMaxPool2DOf[T]) Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.ForwardChecked(input)
+}
+
+// AvgPool2DOf performs 2D average pooling
+type AvgPool2DOf[T Float] struct {
+	pooler[T]
+	PoolSize int
+	Stride   int
+}
+
+// AvgPool2D is an AvgPool2DOf with float64 values
+type AvgPool2D = AvgPool2DOf[float64]
+
+// NewAvgPool2D creates a new average pooling layer
+func NewAvgPool2D(poolSize, stride int) *AvgPool2D {
+	return NewAvgPool2DOf[float64](poolSize, stride)
+}
+
+// NewAvgPool2DOf creates a new average pooling layer with element type T
+func NewAvgPool2DOf[T Float](poolSize, stride int) *AvgPool2DOf[T] {
+	return &AvgPool2DOf[T]{pooler: pooler[T]{average: true}, PoolSize: poolSize, Stride: stride}
+}
+
+// Forward averages every window
+func (pool *AvgPool2DOf[T]) Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	rows, cols, err := fixedWindows2D(input.Height, input.Width, pool.PoolSize, pool.Stride)
+	if err != nil {
+		return nil, err
+	}
+	return pool.forwardOne(input, rows, cols), nil
+}
+
+// Backward spreads the gradient evenly over every window
+func (pool *AvgPool2DOf[T]) Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.backwardOne(gradOutput)
+}
+
+// ForwardBatch averages every window of every sample of the batch
+func (pool *AvgPool2DOf[T]) ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	rows, cols, err := fixedWindows2D(input.Height, input.Width, pool.PoolSize, pool.Stride)
+	if err != nil {
+		return nil, err
+	}
+	return pool.forwardBatch(input, rows, cols), nil
+}
+
+// BackwardBatch spreads the gradient of every sample over its windows
+func (pool *AvgPool2DOf[T]) BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	return pool.backwardBatch(gradOutput)
+}
+
+// OutputShape returns the (C, H, W) output shape for an input shape
+func (pool *AvgPool2DOf[T]) OutputShape(channels, height, width int) (int, int, int, error) {
+	rows, cols, err := fixedWindows2D(height, width, pool.PoolSize, pool.Stride)
+	return poolShape(channels, rows, cols, err)
+}
+
+// AdaptiveAvgPool2DOf averages (C, H, W) samples down to a fixed
+// (C, OutHeight, OutWidth) whatever the input size
+type AdaptiveAvgPool2DOf[T Float] struct {
+	pooler[T]
+	OutHeight int
+	OutWidth  int
+}
+
+// AdaptiveAvgPool2D is an AdaptiveAvgPool2DOf with float64 values
+type AdaptiveAvgPool2D = AdaptiveAvgPool2DOf[float64]
+
+// NewAdaptiveAvgPool2D creates a new adaptive average pooling layer
+func NewAdaptiveAvgPool2D(outHeight, outWidth int) *AdaptiveAvgPool2D {
+	return NewAdaptiveAvgPool2DOf[float64](outHeight, outWidth)
+}
+
+// NewAdaptiveAvgPool2DOf creates a new adaptive average pooling layer with element type T
+func NewAdaptiveAvgPool2DOf[T Float](outHeight, outWidth int) *AdaptiveAvgPool2DOf[T] {
+	return &AdaptiveAvgPool2DOf[T]{pooler: pooler[T]{average: true}, OutHeight: outHeight, OutWidth: outWidth}
+}
+
+// windows returns the OutHeight row and OutWidth column windows of an input
+func (pool *AdaptiveAvgPool2DOf[T]) windows(height, width int) ([]window, []window, error) {
+	rows, err := adaptiveWindows(height, pool.OutHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+	cols, err := adaptiveWindows(width, pool.OutWidth)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, cols, nil
+}
+
+// Forward averages the input over OutHeight x OutWidth windows
+func (pool *AdaptiveAvgPool2DOf[T]) Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	rows, cols, err := pool.windows(input.Height, input.Width)
+	if err != nil {
+		return nil, err
+	}
+	return pool.forwardOne(input, rows, cols), nil
+}
+
+// Backward spreads the gradient evenly over every window
+func (pool *AdaptiveAvgPool2DOf[T]) Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.backwardOne(gradOutput)
+}
+
+// ForwardBatch averages every sample of the batch over OutHeight x OutWidth
+// windows
+func (pool *AdaptiveAvgPool2DOf[T]) ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	rows, cols, err := pool.windows(input.Height, input.Width)
+	if err != nil {
+		return nil, err
+	}
+	return pool.forwardBatch(input, rows, cols), nil
+}
+
+// BackwardBatch spreads the gradient of every sample over its windows
+func (pool *AdaptiveAvgPool2DOf[T]) BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	return pool.backwardBatch(gradOutput)
+}
+
+// OutputShape returns the (C, H, W) output shape for an input shape
+func (pool *AdaptiveAvgPool2DOf[T]) OutputShape(channels, height, width int) (int, int, int, error) {
+	rows, cols, err := pool.windows(height, width)
+	return poolShape(channels, rows, cols, err)
+}
+
+// GlobalAveragePool2DOf averages every channel to a single value, giving a
+// (C, 1, 1) output
+type GlobalAveragePool2DOf[T Float] struct {
+	pooler[T]
+}
+
+// GlobalAveragePool2D is a GlobalAveragePool2DOf with float64 values
+type GlobalAveragePool2D = GlobalAveragePool2DOf[float64]
+
+// NewGlobalAveragePool2D creates a new global average pooling layer
+func NewGlobalAveragePool2D() *GlobalAveragePool2D {
+	return NewGlobalAveragePool2DOf[float64]()
+}
+
+// NewGlobalAveragePool2DOf creates a new global average pooling layer with element type T
+func NewGlobalAveragePool2DOf[T Float]() *GlobalAveragePool2DOf[T] {
+	return &GlobalAveragePool2DOf[T]{pooler: pooler[T]{average: true}}
+}
+
+// Forward averages every channel
+func (pool *GlobalAveragePool2DOf[T]) Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.forwardOne(input, []window{{0, input.Height}}, []window{{0, input.Width}}), nil
+}
+
+// Backward spreads the gradient evenly over every channel
+func (pool *GlobalAveragePool2DOf[T]) Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.backwardOne(gradOutput)
+}
+
+// ForwardBatch averages every channel of every sample of the batch
+func (pool *GlobalAveragePool2DOf[T]) ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	return pool.forwardBatch(input, []window{{0, input.Height}}, []window{{0, input.Width}}), nil
+}
+
+// BackwardBatch spreads the gradient evenly over every channel
+func (pool *GlobalAveragePool2DOf[T]) BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	return pool.backwardBatch(gradOutput)
+}
+
+// OutputShape returns the (C, 1, 1) output shape for an input shape
+func (pool *GlobalAveragePool2DOf[T]) OutputShape(channels, height, width int) (int, int, int, error) {
+	return poolShape(channels, []window{{0, height}}, []window{{0, width}}, nil)
+}
+
+// GlobalMaxPool2DOf keeps the maximum of every channel, giving a (C, 1, 1)
+// output
+type GlobalMaxPool2DOf[T Float] struct {
+	pooler[T]
+}
+
+// GlobalMaxPool2D is a GlobalMaxPool2DOf with float64 values
+type GlobalMaxPool2D = GlobalMaxPool2DOf[float64]
+
+// NewGlobalMaxPool2D creates a new global max pooling layer
+func NewGlobalMaxPool2D() *GlobalMaxPool2D {
+	return NewGlobalMaxPool2DOf[float64]()
+}
+
+// NewGlobalMaxPool2DOf creates a new global max pooling layer with element type T
+func NewGlobalMaxPool2DOf[T Float]() *GlobalMaxPool2DOf[T] {
+	return &GlobalMaxPool2DOf[T]{}
+}
+
+// Forward takes the maximum of every channel
+func (pool *GlobalMaxPool2DOf[T]) Forward(input *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.forwardOne(input, []window{{0, input.Height}}, []window{{0, input.Width}}), nil
+}
+
+// Backward routes the gradient to the maximum of every channel
+func (pool *GlobalMaxPool2DOf[T]) Backward(gradOutput *Tensor3DOf[T]) (*Tensor3DOf[T], error) {
+	return pool.backwardOne(gradOutput)
+}
+
+// ForwardBatch takes the maximum of every channel of every sample of the batch
+func (pool *GlobalMaxPool2DOf[T]) ForwardBatch(input *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	return pool.forwardBatch(input, []window{{0, input.Height}}, []window{{0, input.Width}}), nil
+}
+
+// BackwardBatch routes the gradient to the maximum of every channel
+func (pool *GlobalMaxPool2DOf[T]) BackwardBatch(gradOutput *Tensor4DOf[T]) (*Tensor4DOf[T], error) {
+	return pool.backwardBatch(gradOutput)
+}
+
+// OutputShape returns the (C, 1, 1) output shape for an input shape
+func (pool *GlobalMaxPool2DOf[T]) OutputShape(channels, height, width int) (int, int, int, error) {
+	return poolShape(channels, []window{{0, height}}, []window{{0, width}}, nil)
+}
+
+// MaxPool1DOf performs max pooling along time on (batch, time*Channels) rows
+// laid out step by step
+type MaxPool1DOf[T Float] struct {
+	pooler[T]
+	Channels int
+	PoolSize int
+	Stride   int
+}
+
+// MaxPool1D is a MaxPool1DOf with float64 values
+type MaxPool1D = MaxPool1DOf[float64]
+
+// NewMaxPool1D creates a new 1D max pooling layer
+func NewMaxPool1D(channels, poolSize, stride int) *MaxPool1D {
+	return NewMaxPool1DOf[float64](channels, poolSize, stride)
+}
+
+// NewMaxPool1DOf creates a new 1D max pooling layer with element type T
+func NewMaxPool1DOf[T Float](channels, poolSize, stride int) *MaxPool1DOf[T] {
+	return &MaxPool1DOf[T]{Channels: channels, PoolSize: poolSize, Stride: stride}
+}
+
+// Forward performs max pooling on every sequence
+func (pool *MaxPool1DOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.forwardSequences(input, pool.Channels, func(length int) ([]window, error) {
+		return fixedWindows(length, pool.PoolSize, pool.Stride)
+	})
+}
+
+// Backward routes the gradient to the maximum of every window
+func (pool *MaxPool1DOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.backwardSequences(gradOutput, pool.Channels)
+}
+
+// AvgPool1DOf performs average pooling along time on (batch, time*Channels)
+// rows laid out step by step
+type AvgPool1DOf[T Float] struct {
+	pooler[T]
+	Channels int
+	PoolSize int
+	Stride   int
+}
+
+// AvgPool1D is an AvgPool1DOf with float64 values
+type AvgPool1D = AvgPool1DOf[float64]
+
+// NewAvgPool1D creates a new 1D average pooling layer
+func NewAvgPool1D(channels, poolSize, stride int) *AvgPool1D {
+	return NewAvgPool1DOf[float64](channels, poolSize, stride)
+}
+
+// NewAvgPool1DOf creates a new 1D average pooling layer with element type T
+func NewAvgPool1DOf[T Float](channels, poolSize, stride int) *AvgPool1DOf[T] {
+	return &AvgPool1DOf[T]{pooler: pooler[T]{average: true}, Channels: channels, PoolSize: poolSize, Stride: stride}
+}
+
+// Forward averages every window of every sequence
+func (pool *AvgPool1DOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.forwardSequences(input, pool.Channels, func(length int) ([]window, error) {
+		return fixedWindows(length, pool.PoolSize, pool.Stride)
+	})
+}
+
+// Backward spreads the gradient evenly over every window
+func (pool *AvgPool1DOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.backwardSequences(gradOutput, pool.Channels)
+}
+
+// AdaptiveAvgPool1DOf averages sequences of any length down to OutLength
+// steps
+type AdaptiveAvgPool1DOf[T Float] struct {
+	pooler[T]
+	Channels  int
+	OutLength int
+}
+
+// AdaptiveAvgPool1D is an AdaptiveAvgPool1DOf with float64 values
+type AdaptiveAvgPool1D = AdaptiveAvgPool1DOf[float64]
+
+// NewAdaptiveAvgPool1D creates a new 1D adaptive average pooling layer
+func NewAdaptiveAvgPool1D(channels, outLength int) *AdaptiveAvgPool1D {
+	return NewAdaptiveAvgPool1DOf[float64](channels, outLength)
+}
+
+// NewAdaptiveAvgPool1DOf creates a new 1D adaptive average pooling layer with element type T
+func NewAdaptiveAvgPool1DOf[T Float](channels, outLength int) *AdaptiveAvgPool1DOf[T] {
+	return &AdaptiveAvgPool1DOf[T]{pooler: pooler[T]{average: true}, Channels: channels, OutLength: outLength}
+}
+
+// Forward averages every sequence over OutLength windows
+func (pool *AdaptiveAvgPool1DOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.forwardSequences(input, pool.Channels, func(length int) ([]window, error) {
+		return adaptiveWindows(length, pool.OutLength)
+	})
+}
+
+// Backward spreads the gradient evenly over every window
+func (pool *AdaptiveAvgPool1DOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.backwardSequences(gradOutput, pool.Channels)
+}
+
+// GlobalAveragePool1DOf averages every channel over time, giving
+// (batch, Channels) rows
+type GlobalAveragePool1DOf[T Float] struct {
+	pooler[T]
+	Channels int
+}
+
+// GlobalAveragePool1D is a GlobalAveragePool1DOf with float64 values
+type GlobalAveragePool1D = GlobalAveragePool1DOf[float64]
+
+// NewGlobalAveragePool1D creates a new 1D global average pooling layer
+func NewGlobalAveragePool1D(channels int) *GlobalAveragePool1D {
+	return NewGlobalAveragePool1DOf[float64](channels)
+}
+
+// NewGlobalAveragePool1DOf creates a new 1D global average pooling layer with element type T
+func NewGlobalAveragePool1DOf[T Float](channels int) *GlobalAveragePool1DOf[T] {
+	return &GlobalAveragePool1DOf[T]{pooler: pooler[T]{average: true}, Channels: channels}
+}
+
+// Forward averages every channel of every sequence
+func (pool *GlobalAveragePool1DOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.forwardSequences(input, pool.Channels, func(length int) ([]window, error) {
+		return adaptiveWindows(length, 1)
+	})
+}
+
+// Backward spreads the gradient evenly over time
+func (pool *GlobalAveragePool1DOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.backwardSequences(gradOutput, pool.Channels)
+}
+
+// GlobalMaxPool1DOf keeps the maximum of every channel over time, giving
+// (batch, Channels) rows
+type GlobalMaxPool1DOf[T Float] struct {
+	pooler[T]
+	Channels int
+}
+
+// GlobalMaxPool1D is a GlobalMaxPool1DOf with float64 values
+type GlobalMaxPool1D = GlobalMaxPool1DOf[float64]
+
+// NewGlobalMaxPool1D creates a new 1D global max pooling layer
+func NewGlobalMaxPool1D(channels int) *GlobalMaxPool1D {
+	return NewGlobalMaxPool1DOf[float64](channels)
+}
+
+// NewGlobalMaxPool1DOf creates a new 1D global max pooling layer with element type T
+func NewGlobalMaxPool1DOf[T Float](channels int) *GlobalMaxPool1DOf[T] {
+	return &GlobalMaxPool1DOf[T]{Channels: channels}
+}
+
+// Forward takes the maximum of every channel of every sequence
+func (pool *GlobalMaxPool1DOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.forwardSequences(input, pool.Channels, func(length int) ([]window, error) {
+		return adaptiveWindows(length, 1)
+	})
+}
+
+// Backward routes the gradient to the maximum of every channel
+func (pool *GlobalMaxPool1DOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	return pool.backwardSequences(gradOutput, pool.Channels)
+}
@@ -0,0 +1,172 @@
+package nn
+
+import (
+	"testing"
+)
+
+// poolingInput is a single-channel 4x4 sample
+func poolingInput() *Tensor3D {
+	return &Tensor3D{Channels: 1, Height: 4, Width: 4, Data: [][][]float64{{
+		{1, 5, 2, 0},
+		{3, 4, 8, 6},
+		{0, 9, 1, 1},
+		{7, 2, 3, 4},
+	}}}
+}
+
+// assertPlane fails when the first channel of got differs from want
+func assertPlane(t *testing.T, name string, got *Tensor3D, want [][]float64) {
+	t.Helper()
+	assertMatrix(t, name, &Matrix{Rows: got.Height, Cols: got.Width, Data: got.Data[0]}, want, 1e-12)
+}
+
+func TestPoolingValues(t *testing.T) {
+	tests := []struct {
+		name string
+		pool interface {
+			Forward(*Tensor3D) (*Tensor3D, error)
+		}
+		want [][]float64
+	}{
+		{"max", NewCheckedMaxPool2D(2, 2), [][]float64{{5, 8}, {9, 4}}},
+		{"avg", NewAvgPool2D(2, 2), [][]float64{{3.25, 4}, {4.5, 2.25}}},
+		{"strided_max", NewCheckedMaxPool2D(3, 1), [][]float64{{9, 9}, {9, 9}}},
+		// Windows [0, 2), [1, 3) and [2, 4) overlap
+		{"adaptive_avg", NewAdaptiveAvgPool2D(3, 3), [][]float64{{3.25, 4.75, 4}, {4, 5.5, 4}, {4.5, 3.75, 2.25}}},
+		{"global_avg", NewGlobalAveragePool2D(), [][]float64{{3.5}}},
+		{"global_max", NewGlobalMaxPool2D(), [][]float64{{9}}},
+	}
+	for _, tt := range tests {
+		out, err := tt.pool.Forward(poolingInput())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		assertPlane(t, tt.name, out, tt.want)
+	}
+
+	if _, err := NewMaxPool2D(5, 1).ForwardChecked(poolingInput()); err == nil {
+		t.Error("expected an error for a pool larger than the input")
+	}
+}
+
+func TestMaxPool2DForward(t *testing.T) {
+	// Forward keeps its original signature and panics on a bad pool
+	assertPlane(t, "max", NewMaxPool2D(2, 2).Forward(poolingInput()), [][]float64{{5, 8}, {9, 4}})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a pool larger than the input")
+		}
+	}()
+	NewMaxPool2D(5, 1).Forward(poolingInput())
+}
+
+func TestPoolingOutputShape(t *testing.T) {
+	tests := []struct {
+		name string
+		pool OutputShaper
+		want [3]int
+	}{
+		{"max", NewMaxPool2D(2, 2), [3]int{3, 2, 2}},
+		{"avg", NewAvgPool2D(3, 1), [3]int{3, 2, 2}},
+		{"adaptive_avg", NewAdaptiveAvgPool2D(3, 1), [3]int{3, 3, 1}},
+		{"global_avg", NewGlobalAveragePool2D(), [3]int{3, 1, 1}},
+		{"global_max", NewGlobalMaxPool2D(), [3]int{3, 1, 1}},
+	}
+	for _, tt := range tests {
+		c, h, w, err := tt.pool.OutputShape(3, 4, 4)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := [3]int{c, h, w}; got != tt.want {
+			t.Errorf("%s: output shape %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+	if _, _, _, err := NewMaxPool2D(5, 1).OutputShape(3, 4, 4); err == nil {
+		t.Error("expected an error for a pool larger than the input")
+	}
+}
+
+func TestPoolingBackward(t *testing.T) {
+	grad := &Tensor3D{Channels: 1, Height: 2, Width: 2, Data: [][][]float64{{{1, 2}, {3, 4}}}}
+
+	maxPool := NewMaxPool2D(2, 2)
+	maxPool.Forward(poolingInput())
+	gradInput, err := maxPool.Backward(grad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every gradient goes to the maximum of its window
+	assertPlane(t, "max", gradInput, [][]float64{{0, 1, 0, 0}, {0, 0, 2, 0}, {0, 3, 0, 0}, {0, 0, 0, 4}})
+
+	avgPool := NewAvgPool2D(2, 2)
+	if _, err := avgPool.Forward(poolingInput()); err != nil {
+		t.Fatal(err)
+	}
+	if gradInput, err = avgPool.Backward(grad); err != nil {
+		t.Fatal(err)
+	}
+	assertPlane(t, "avg", gradInput, [][]float64{
+		{0.25, 0.25, 0.5, 0.5}, {0.25, 0.25, 0.5, 0.5}, {0.75, 0.75, 1, 1}, {0.75, 0.75, 1, 1}})
+}
+
+func TestPoolingBackwardShape(t *testing.T) {
+	pools := map[string]interface {
+		Forward(*Tensor3D) (*Tensor3D, error)
+		Backward(*Tensor3D) (*Tensor3D, error)
+	}{
+		"max":        NewCheckedMaxPool2D(2, 2),
+		"avg":        NewAvgPool2D(2, 2),
+		"global_max": NewGlobalMaxPool2D(),
+	}
+	for name, pool := range pools {
+		if _, err := pool.Backward(NewTensor3D(1, 2, 2)); err == nil {
+			t.Errorf("%s: expected an error before forward", name)
+		}
+		out, err := pool.Forward(poolingInput())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// More channels than the input would index past the maxima
+		if _, err := pool.Backward(NewTensor3D(2, out.Height, out.Width)); err == nil {
+			t.Errorf("%s: expected an error for 2 gradient channels on 1", name)
+		}
+		if _, err := pool.Backward(NewTensor3D(1, out.Height+1, out.Width)); err == nil {
+			t.Errorf("%s: expected an error for a gradient of the wrong height", name)
+		}
+	}
+
+	batchPool := NewMaxPool2D(2, 2)
+	if _, err := batchPool.ForwardBatch(NewTensor4D(3, 2, 4, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := batchPool.BackwardBatch(NewTensor4D(3, 3, 2, 2)); err == nil {
+		t.Error("expected an error for 3 gradient channels on 2 in a batch")
+	}
+}
+
+func TestPooling1DValues(t *testing.T) {
+	// Two channels over four steps, laid out step by step
+	input := &Matrix{Rows: 1, Cols: 8, Data: [][]float64{{1, 4, 3, 2, 0, 5, 2, 2}}}
+	tests := []struct {
+		name string
+		pool Layer
+		want []float64
+	}{
+		{"max", NewMaxPool1D(2, 2, 2), []float64{3, 4, 2, 5}},
+		{"avg", NewAvgPool1D(2, 2, 2), []float64{2, 3, 1, 3.5}},
+		{"global_avg", NewGlobalAveragePool1D(2), []float64{1.5, 3.25}},
+		{"global_max", NewGlobalMaxPool1D(2), []float64{3, 5}},
+	}
+	for _, tt := range tests {
+		out, err := tt.pool.Forward(input)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		assertMatrix(t, tt.name, out, [][]float64{tt.want}, 1e-12)
+	}
+
+	if _, err := NewMaxPool1D(3, 2, 2).Forward(input); err == nil {
+		t.Error("expected an error for 8 values in 3 channels")
+	}
+}
@@ -0,0 +1,602 @@
+package nn
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Transformer is a preprocessing step. Fit learns its statistics from
+// data, Transform applies them and InverseTransform undoes Transform.
+// Transform and InverseTransform return new matrices.
+type Transformer interface {
+	Fit(X *Matrix) error
+	Transform(X *Matrix) (*Matrix, error)
+	InverseTransform(X *Matrix) (*Matrix, error)
+}
+
+// FitTransform fits t on X and returns the transformed X
+func FitTransform(t Transformer, X *Matrix) (*Matrix, error) {
+	if err := t.Fit(X); err != nil {
+		return nil, err
+	}
+	return t.Transform(X)
+}
+
+// columnValues returns the non-NaN values of column j
+func columnValues(X *Matrix, j int) []float64 {
+	values := make([]float64, 0, X.Rows)
+	for i := 0; i < X.Rows; i++ {
+		if v := X.Data[i][j]; !math.IsNaN(v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// fitColumns computes one statistic per column from its non-NaN values
+func fitColumns(X *Matrix, stat func(values []float64) float64) []float64 {
+	stats := make([]float64, X.Cols)
+	for j := range stats {
+		stats[j] = stat(columnValues(X, j))
+	}
+	return stats
+}
+
+// checkFitted returns an error when X does not have the number of columns
+// the transformer was fitted on
+func checkFitted(name string, X *Matrix, cols int) error {
+	if cols == 0 {
+		return fmt.Errorf("%s is not fitted", name)
+	}
+	if X.Cols != cols {
+		return fmt.Errorf("%s: got %d columns, fitted on %d", name, X.Cols, cols)
+	}
+	return nil
+}
+
+// mapElements returns a copy of X with fn applied to every element
+func mapElements(X *Matrix, fn func(v float64, j int) float64) *Matrix {
+	result := NewMatrix(X.Rows, X.Cols)
+	for i := 0; i < X.Rows; i++ {
+		for j := 0; j < X.Cols; j++ {
+			result.Data[i][j] = fn(X.Data[i][j], j)
+		}
+	}
+	return result
+}
+
+// mean returns the mean of values, or 0 for none
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// quantile returns the q-quantile of values with linear interpolation, or
+// 0 for none
+func quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (pos-float64(lo))*(sorted[hi]-sorted[lo])
+}
+
+// nonZero replaces a zero scale by 1 so constant columns are left as is
+func nonZero(scale float64) float64 {
+	if scale == 0 {
+		return 1
+	}
+	return scale
+}
+
+// StandardScaler scales every column to zero mean and unit variance
+type StandardScaler struct {
+	Mean []float64
+	Std  []float64
+}
+
+// NewStandardScaler creates an unfitted StandardScaler
+func NewStandardScaler() *StandardScaler {
+	return &StandardScaler{}
+}
+
+// Fit computes the mean and the standard deviation of every column,
+// ignoring NaN values
+func (s *StandardScaler) Fit(X *Matrix) error {
+	s.Mean = fitColumns(X, mean)
+	s.Std = make([]float64, X.Cols)
+	for j := range s.Std {
+		values := columnValues(X, j)
+		sum := 0.0
+		for _, v := range values {
+			sum += (v - s.Mean[j]) * (v - s.Mean[j])
+		}
+		if len(values) > 0 {
+			s.Std[j] = math.Sqrt(sum / float64(len(values)))
+		}
+	}
+	return nil
+}
+
+// Transform returns (X - Mean) / Std
+func (s *StandardScaler) Transform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("StandardScaler", X, len(s.Mean)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, j int) float64 { return (v - s.Mean[j]) / nonZero(s.Std[j]) }), nil
+}
+
+// InverseTransform returns X * Std + Mean
+func (s *StandardScaler) InverseTransform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("StandardScaler", X, len(s.Mean)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, j int) float64 { return v*nonZero(s.Std[j]) + s.Mean[j] }), nil
+}
+
+// MinMaxScaler maps every column linearly from its fitted range to
+// [Min, Max]
+type MinMaxScaler struct {
+	Min     float64
+	Max     float64
+	DataMin []float64
+	DataMax []float64
+}
+
+// NewMinMaxScaler creates an unfitted scaler to the range [min, max]
+func NewMinMaxScaler(min, max float64) *MinMaxScaler {
+	return &MinMaxScaler{Min: min, Max: max}
+}
+
+// Fit finds the range of every column, ignoring NaN values
+func (s *MinMaxScaler) Fit(X *Matrix) error {
+	if s.Min >= s.Max {
+		return fmt.Errorf("MinMaxScaler: invalid target range [%g, %g]", s.Min, s.Max)
+	}
+	s.DataMin = fitColumns(X, func(values []float64) float64 { return quantile(values, 0) })
+	s.DataMax = fitColumns(X, func(values []float64) float64 { return quantile(values, 1) })
+	return nil
+}
+
+// Transform maps [DataMin, DataMax] to [Min, Max]
+func (s *MinMaxScaler) Transform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("MinMaxScaler", X, len(s.DataMin)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, j int) float64 {
+		return s.Min + (v-s.DataMin[j])/nonZero(s.DataMax[j]-s.DataMin[j])*(s.Max-s.Min)
+	}), nil
+}
+
+// InverseTransform maps [Min, Max] back to [DataMin, DataMax]
+func (s *MinMaxScaler) InverseTransform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("MinMaxScaler", X, len(s.DataMin)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, j int) float64 {
+		return s.DataMin[j] + (v-s.Min)/(s.Max-s.Min)*nonZero(s.DataMax[j]-s.DataMin[j])
+	}), nil
+}
+
+// RobustScaler centers every column on its median and scales it by its
+// interquartile range, so outliers have little influence
+type RobustScaler struct {
+	Median []float64
+	IQR    []float64
+}
+
+// NewRobustScaler creates an unfitted RobustScaler
+func NewRobustScaler() *RobustScaler {
+	return &RobustScaler{}
+}
+
+// Fit computes the median and the interquartile range of every column,
+// ignoring NaN values
+func (s *RobustScaler) Fit(X *Matrix) error {
+	s.Median = fitColumns(X, func(values []float64) float64 { return quantile(values, 0.5) })
+	s.IQR = fitColumns(X, func(values []float64) float64 { return quantile(values, 0.75) - quantile(values, 0.25) })
+	return nil
+}
+
+// Transform returns (X - Median) / IQR
+func (s *RobustScaler) Transform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("RobustScaler", X, len(s.Median)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, j int) float64 { return (v - s.Median[j]) / nonZero(s.IQR[j]) }), nil
+}
+
+// InverseTransform returns X * IQR + Median
+func (s *RobustScaler) InverseTransform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("RobustScaler", X, len(s.Median)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, j int) float64 { return v*nonZero(s.IQR[j]) + s.Median[j] }), nil
+}
+
+// ImputeStrategy selects the value an Imputer fills NaN values with
+type ImputeStrategy string
+
+const (
+	// ImputeMean fills with the column mean
+	ImputeMean ImputeStrategy = "mean"
+	// ImputeMedian fills with the column median
+	ImputeMedian ImputeStrategy = "median"
+	// ImputeMostFrequent fills with the most frequent value of the column
+	ImputeMostFrequent ImputeStrategy = "most_frequent"
+	// ImputeConstant fills with FillValue
+	ImputeConstant ImputeStrategy = "constant"
+)
+
+// Imputer replaces NaN values, e.g. from CSV files read with MissingNaN,
+// by a per-column statistic. Columns without any value use FillValue.
+type Imputer struct {
+	Strategy  ImputeStrategy
+	FillValue float64
+	Values    []float64 // Fitted fill value of every column
+}
+
+// NewImputer creates an unfitted imputer
+func NewImputer(strategy ImputeStrategy, fillValue float64) *Imputer {
+	return &Imputer{Strategy: strategy, FillValue: fillValue}
+}
+
+// Fit computes the fill value of every column
+func (imp *Imputer) Fit(X *Matrix) error {
+	var stat func(values []float64) float64
+	switch imp.Strategy {
+	case ImputeMean:
+		stat = mean
+	case ImputeMedian:
+		stat = func(values []float64) float64 { return quantile(values, 0.5) }
+	case ImputeMostFrequent:
+		stat = mostFrequent
+	case ImputeConstant:
+		stat = func([]float64) float64 { return imp.FillValue }
+	default:
+		return fmt.Errorf("unknown impute strategy %q", imp.Strategy)
+	}
+
+	imp.Values = make([]float64, X.Cols)
+	for j := range imp.Values {
+		values := columnValues(X, j)
+		imp.Values[j] = imp.FillValue
+		if len(values) > 0 {
+			imp.Values[j] = stat(values)
+		}
+	}
+	return nil
+}
+
+// mostFrequent returns the most frequent value, the smallest on ties
+func mostFrequent(values []float64) float64 {
+	counts := make(map[float64]int)
+	for _, v := range values {
+		counts[v]++
+	}
+	best, bestCount := math.Inf(1), 0
+	for v, count := range counts {
+		if count > bestCount || (count == bestCount && v < best) {
+			best, bestCount = v, count
+		}
+	}
+	return best
+}
+
+// Transform returns X with NaN values replaced by the fill value of their
+// column
+func (imp *Imputer) Transform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("Imputer", X, len(imp.Values)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, j int) float64 {
+		if math.IsNaN(v) {
+			return imp.Values[j]
+		}
+		return v
+	}), nil
+}
+
+// InverseTransform returns a copy of X: which values were imputed is not
+// recorded, so imputation cannot be undone
+func (imp *Imputer) InverseTransform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("Imputer", X, len(imp.Values)); err != nil {
+		return nil, err
+	}
+	return mapElements(X, func(v float64, _ int) float64 { return v }), nil
+}
+
+// sortedDistinct returns the distinct values in increasing order
+func sortedDistinct(values []float64) []float64 {
+	seen := make(map[float64]bool)
+	var distinct []float64
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			distinct = append(distinct, v)
+		}
+	}
+	sort.Float64s(distinct)
+	return distinct
+}
+
+// categoryIndex returns the position of v in the sorted categories
+func categoryIndex(categories []float64, v float64) (int, bool) {
+	k := sort.SearchFloat64s(categories, v)
+	return k, k < len(categories) && categories[k] == v
+}
+
+// LabelEncoder maps the values of a single label column, such as class ids
+// 3, 7 and 10, to consecutive indices 0, 1, 2
+type LabelEncoder struct {
+	Classes []float64
+}
+
+// NewLabelEncoder creates an unfitted LabelEncoder
+func NewLabelEncoder() *LabelEncoder {
+	return &LabelEncoder{}
+}
+
+// Fit collects the sorted classes of a one-column matrix
+func (e *LabelEncoder) Fit(X *Matrix) error {
+	if X.Cols != 1 {
+		return fmt.Errorf("LabelEncoder: expected 1 column, got %d", X.Cols)
+	}
+	classes := sortedDistinct(columnValues(X, 0))
+	if len(classes) == 0 {
+		return fmt.Errorf("LabelEncoder: no classes, every value is NaN")
+	}
+	e.Classes = classes
+	return nil
+}
+
+// check returns an error unless e is fitted and X has one column
+func (e *LabelEncoder) check(X *Matrix) error {
+	if len(e.Classes) == 0 {
+		return fmt.Errorf("LabelEncoder is not fitted")
+	}
+	return checkFitted("LabelEncoder", X, 1)
+}
+
+// Transform replaces every class by its index
+func (e *LabelEncoder) Transform(X *Matrix) (*Matrix, error) {
+	if err := e.check(X); err != nil {
+		return nil, err
+	}
+	result := NewMatrix(X.Rows, 1)
+	for i := 0; i < X.Rows; i++ {
+		k, ok := categoryIndex(e.Classes, X.Data[i][0])
+		if !ok {
+			return nil, fmt.Errorf("LabelEncoder: unknown class %g in row %d", X.Data[i][0], i)
+		}
+		result.Data[i][0] = float64(k)
+	}
+	return result, nil
+}
+
+// InverseTransform replaces every index by its class
+func (e *LabelEncoder) InverseTransform(X *Matrix) (*Matrix, error) {
+	if err := e.check(X); err != nil {
+		return nil, err
+	}
+	result := NewMatrix(X.Rows, 1)
+	for i := 0; i < X.Rows; i++ {
+		k := int(math.Round(X.Data[i][0]))
+		if k < 0 || k >= len(e.Classes) {
+			return nil, fmt.Errorf("LabelEncoder: index %g in row %d out of range [0, %d)", X.Data[i][0], i, len(e.Classes))
+		}
+		result.Data[i][0] = e.Classes[k]
+	}
+	return result, nil
+}
+
+// OneHotEncoder replaces every categorical column by one column per
+// category. Categories are sorted, so class ids 0..K-1 give the usual
+// one-hot layout.
+type OneHotEncoder struct {
+	Categories [][]float64 // Sorted categories of every input column
+}
+
+// NewOneHotEncoder creates an unfitted OneHotEncoder
+func NewOneHotEncoder() *OneHotEncoder {
+	return &OneHotEncoder{}
+}
+
+// Fit collects the categories of every column
+func (e *OneHotEncoder) Fit(X *Matrix) error {
+	categories := make([][]float64, X.Cols)
+	for j := range categories {
+		if categories[j] = sortedDistinct(columnValues(X, j)); len(categories[j]) == 0 {
+			return fmt.Errorf("OneHotEncoder: column %d has no categories, every value is NaN", j)
+		}
+	}
+	e.Categories = categories
+	return nil
+}
+
+// outputCols returns the number of encoded columns
+func (e *OneHotEncoder) outputCols() int {
+	cols := 0
+	for _, categories := range e.Categories {
+		cols += len(categories)
+	}
+	return cols
+}
+
+// Transform encodes every column as a block of one-hot columns
+func (e *OneHotEncoder) Transform(X *Matrix) (*Matrix, error) {
+	if err := checkFitted("OneHotEncoder", X, len(e.Categories)); err != nil {
+		return nil, err
+	}
+	result := NewMatrix(X.Rows, e.outputCols())
+	for i := 0; i < X.Rows; i++ {
+		offset := 0
+		for j, categories := range e.Categories {
+			k, ok := categoryIndex(categories, X.Data[i][j])
+			if !ok {
+				return nil, fmt.Errorf("OneHotEncoder: unknown category %g in row %d, column %d", X.Data[i][j], i, j)
+			}
+			result.Data[i][offset+k] = 1
+			offset += len(categories)
+		}
+	}
+	return result, nil
+}
+
+// InverseTransform returns the category with the largest value in every
+// block, so predicted probabilities can be decoded as well
+func (e *OneHotEncoder) InverseTransform(X *Matrix) (*Matrix, error) {
+	if len(e.Categories) == 0 {
+		return nil, fmt.Errorf("OneHotEncoder is not fitted")
+	}
+	if X.Cols != e.outputCols() {
+		return nil, fmt.Errorf("OneHotEncoder: got %d columns, expected %d", X.Cols, e.outputCols())
+	}
+	result := NewMatrix(X.Rows, len(e.Categories))
+	for i := 0; i < X.Rows; i++ {
+		offset := 0
+		for j, categories := range e.Categories {
+			result.Data[i][j] = categories[argmax(X.Data[i][offset:offset+len(categories)])]
+			offset += len(categories)
+		}
+	}
+	return result, nil
+}
+
+// Pipeline chains transformers, each fitted on the output of the previous
+// one. It is saved with the weights of the model holding it.
+type Pipeline struct {
+	Steps []Transformer
+}
+
+// NewPipeline creates a pipeline of steps applied in order
+func NewPipeline(steps ...Transformer) *Pipeline {
+	return &Pipeline{Steps: steps}
+}
+
+// Fit fits every step on the transformed output of the previous steps
+func (p *Pipeline) Fit(X *Matrix) error {
+	_, err := p.FitTransform(X)
+	return err
+}
+
+// FitTransform fits every step and returns the output of the last one
+func (p *Pipeline) FitTransform(X *Matrix) (*Matrix, error) {
+	for i, step := range p.Steps {
+		var err error
+		if X, err = FitTransform(step, X); err != nil {
+			return nil, fmt.Errorf("pipeline step %d: %v", i, err)
+		}
+	}
+	return X, nil
+}
+
+// Transform applies every step in order
+func (p *Pipeline) Transform(X *Matrix) (*Matrix, error) {
+	for i, step := range p.Steps {
+		var err error
+		if X, err = step.Transform(X); err != nil {
+			return nil, fmt.Errorf("pipeline step %d: %v", i, err)
+		}
+	}
+	return X, nil
+}
+
+// InverseTransform undoes the steps in reverse order
+func (p *Pipeline) InverseTransform(X *Matrix) (*Matrix, error) {
+	for i := len(p.Steps) - 1; i >= 0; i-- {
+		var err error
+		if X, err = p.Steps[i].InverseTransform(X); err != nil {
+			return nil, fmt.Errorf("pipeline step %d: %v", i, err)
+		}
+	}
+	return X, nil
+}
+
+// transformerTypes creates an empty transformer for every serialized type
+// name
+var transformerTypes = map[string]func() Transformer{
+	"standard_scaler": func() Transformer { return &StandardScaler{} },
+	"min_max_scaler":  func() Transformer { return &MinMaxScaler{} },
+	"robust_scaler":   func() Transformer { return &RobustScaler{} },
+	"imputer":         func() Transformer { return &Imputer{} },
+	"label_encoder":   func() Transformer { return &LabelEncoder{} },
+	"one_hot_encoder": func() Transformer { return &OneHotEncoder{} },
+	"pipeline":        func() Transformer { return &Pipeline{} },
+}
+
+// transformerType returns the serialized type name of t
+func transformerType(t Transformer) (string, error) {
+	switch t.(type) {
+	case *StandardScaler:
+		return "standard_scaler", nil
+	case *MinMaxScaler:
+		return "min_max_scaler", nil
+	case *RobustScaler:
+		return "robust_scaler", nil
+	case *Imputer:
+		return "imputer", nil
+	case *LabelEncoder:
+		return "label_encoder", nil
+	case *OneHotEncoder:
+		return "one_hot_encoder", nil
+	case *Pipeline:
+		return "pipeline", nil
+	}
+	return "", fmt.Errorf("cannot serialize transformer %T", t)
+}
+
+// serializedStep is the JSON form of a pipeline step
+type serializedStep struct {
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+// MarshalJSON writes every step with its type name
+func (p *Pipeline) MarshalJSON() ([]byte, error) {
+	steps := make([]serializedStep, len(p.Steps))
+	for i, step := range p.Steps {
+		name, err := transformerType(step)
+		if err != nil {
+			return nil, err
+		}
+		config, err := json.Marshal(step)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = serializedStep{Type: name, Config: config}
+	}
+	return json.Marshal(steps)
+}
+
+// UnmarshalJSON rebuilds the steps written by MarshalJSON
+func (p *Pipeline) UnmarshalJSON(data []byte) error {
+	var steps []serializedStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return err
+	}
+	p.Steps = make([]Transformer, len(steps))
+	for i, step := range steps {
+		create, ok := transformerTypes[step.Type]
+		if !ok {
+			return fmt.Errorf("unknown transformer type %q", step.Type)
+		}
+		p.Steps[i] = create()
+		if err := json.Unmarshal(step.Config, p.Steps[i]); err != nil {
+			return fmt.Errorf("decoding %s: %v", step.Type, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package nn
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// numericData has a missing value in each column
+func numericData() *Matrix {
+	nan := math.NaN()
+	return &Matrix{Rows: 5, Cols: 2, Data: [][]float64{{1, 10}, {2, nan}, {nan, 30}, {4, 40}, {100, 50}}}
+}
+
+// numericPipeline chains every numeric transformer, with a nested pipeline
+func numericPipeline() *Pipeline {
+	return NewPipeline(
+		NewImputer(ImputeMedian, 0),
+		NewPipeline(NewStandardScaler(), NewMinMaxScaler(-1, 1)),
+		NewRobustScaler(),
+	)
+}
+
+// categoricalData holds class ids 3, 7 and 10
+func categoricalData() *Matrix {
+	return &Matrix{Rows: 4, Cols: 1, Data: [][]float64{{7}, {3}, {10}, {7}}}
+}
+
+func TestPreprocessingValues(t *testing.T) {
+	imputed, err := FitTransform(NewImputer(ImputeMedian, 0), numericData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "imputed", imputed, [][]float64{{1, 10}, {2, 35}, {3, 30}, {4, 40}, {100, 50}}, 1e-12)
+
+	scaled, err := FitTransform(NewStandardScaler(), &Matrix{Rows: 2, Cols: 2, Data: [][]float64{{1, 5}, {3, 5}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A constant column keeps a scale of 1
+	assertMatrix(t, "standard", scaled, [][]float64{{-1, 0}, {1, 0}}, 1e-12)
+
+	encoded, err := FitTransform(NewPipeline(NewLabelEncoder(), NewOneHotEncoder()), categoricalData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "one-hot", encoded, [][]float64{{0, 1, 0}, {1, 0, 0}, {0, 0, 1}, {0, 1, 0}}, 0)
+}
+
+// assertSamePipeline fails unless loaded transforms and inverts X like p
+func assertSamePipeline(t *testing.T, p, loaded *Pipeline, X *Matrix) {
+	t.Helper()
+	want, err := p.Transform(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Transform(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "transform", got, want.Data, 0)
+
+	wantInverse, err := p.InverseTransform(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotInverse, err := loaded.InverseTransform(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMatrix(t, "inverse", gotInverse, wantInverse.Data, 0)
+}
+
+func TestPipelineJSONRoundTrip(t *testing.T) {
+	pipelines := map[string]struct {
+		pipeline *Pipeline
+		data     *Matrix
+	}{
+		"numeric":     {numericPipeline(), numericData()},
+		"categorical": {NewPipeline(NewLabelEncoder(), NewOneHotEncoder()), categoricalData()},
+	}
+	for name, tt := range pipelines {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.pipeline.Fit(tt.data); err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(tt.pipeline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var loaded Pipeline
+			if err := json.Unmarshal(data, &loaded); err != nil {
+				t.Fatal(err)
+			}
+			if len(loaded.Steps) != len(tt.pipeline.Steps) {
+				t.Fatalf("loaded %d steps, expected %d", len(loaded.Steps), len(tt.pipeline.Steps))
+			}
+			assertSamePipeline(t, tt.pipeline, &loaded, tt.data)
+		})
+	}
+}
+
+func TestPreprocessingSavedWithWeights(t *testing.T) {
+	X := numericData()
+	model := NewSequential()
+	model.Add(NewDense(2, 1))
+	model.Preprocessing = numericPipeline()
+	if err := model.Preprocessing.Fit(X); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := model.SaveWeights(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewSequential()
+	loaded.Add(NewDense(2, 1))
+	if err := loaded.LoadWeights(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Preprocessing == nil {
+		t.Fatal("preprocessing was not loaded")
+	}
+	assertSamePipeline(t, model.Preprocessing, loaded.Preprocessing, X)
+
+	// Graph models save their pipeline the same way
+	in := Input(1)
+	graph, err := NewModel([]*Node{in}, []*Node{Apply(NewDense(1, 1), in)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.Preprocessing = NewPipeline(NewLabelEncoder())
+	if err := graph.Preprocessing.Fit(categoricalData()); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := graph.SaveWeights(&buf); err != nil {
+		t.Fatal(err)
+	}
+	graph.Preprocessing = nil
+	if err := graph.LoadWeights(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if graph.Preprocessing == nil {
+		t.Fatal("graph preprocessing was not loaded")
+	}
+	assertSamePipeline(t, NewPipeline(&LabelEncoder{Classes: []float64{3, 7, 10}}), graph.Preprocessing, categoricalData())
+}
+
+// customTransformer is a Transformer unknown to the serializer
+type customTransformer struct{ LabelEncoder }
+
+func TestPipelineJSONErrors(t *testing.T) {
+	if _, err := json.Marshal(NewPipeline(&customTransformer{})); err == nil || !strings.Contains(err.Error(), "cannot serialize") {
+		t.Errorf("expected an error for an unknown transformer, got %v", err)
+	}
+	var p Pipeline
+	if err := json.Unmarshal([]byte(`[{"type": "pca", "config": {}}]`), &p); err == nil || !strings.Contains(err.Error(), "unknown transformer type") {
+		t.Errorf("expected an error for an unknown type, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(`[{"type": "standard_scaler", "config": {"Mean": "x"}}]`), &p); err == nil {
+		t.Error("expected an error for a malformed config")
+	}
+}
+
+func TestEncoderFitWithoutCategories(t *testing.T) {
+	nan := math.NaN()
+	X := &Matrix{Rows: 2, Cols: 2, Data: [][]float64{{1, nan}, {2, nan}}}
+	if err := NewOneHotEncoder().Fit(X); err == nil || !strings.Contains(err.Error(), "column 1") {
+		t.Errorf("expected an error for an all-NaN column, got %v", err)
+	}
+	if err := NewLabelEncoder().Fit(&Matrix{Rows: 1, Cols: 1, Data: [][]float64{{nan}}}); err == nil {
+		t.Error("expected an error for a label column without classes")
+	}
+}
@@ -0,0 +1,434 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// QuantParams maps real values to int8 as real = Scale * (q - ZeroPoint)
+type QuantParams struct {
+	Scale     float64
+	ZeroPoint int32
+}
+
+// NewQuantParams returns asymmetric int8 parameters covering [min, max].
+// The range is widened to contain 0 so that zero padding is exact.
+func NewQuantParams(min, max float64) QuantParams {
+	min = math.Min(min, 0)
+	max = math.Max(max, 0)
+	scale := (max - min) / 255
+	if scale == 0 {
+		scale = 1
+	}
+	zeroPoint := math.Round(-128 - min/scale)
+	return QuantParams{Scale: scale, ZeroPoint: int32(math.Max(-128, math.Min(127, zeroPoint)))}
+}
+
+// Quantize converts a real value to int8, saturating at the range limits
+func (p QuantParams) Quantize(v float64) int8 {
+	q := math.Round(v/p.Scale) + float64(p.ZeroPoint)
+	return int8(math.Max(-128, math.Min(127, q)))
+}
+
+// Dequantize converts an int8 value back to a real value
+func (p QuantParams) Dequantize(q int8) float64 {
+	return p.Scale * float64(int32(q)-p.ZeroPoint)
+}
+
+// shifted quantizes v and subtracts the zero point, giving the integer
+// operand of the matrix products
+func (p QuantParams) shifted(v float64) int32 {
+	return int32(p.Quantize(v)) - p.ZeroPoint
+}
+
+// quantizeChannels quantizes every row of channels symmetrically to
+// [-127, 127] with its own scale
+func quantizeChannels(channels [][]float64) ([][]int8, []float64) {
+	weights := make([][]int8, len(channels))
+	scales := make([]float64, len(channels))
+	for f, row := range channels {
+		maxAbs := 0.0
+		for _, v := range row {
+			maxAbs = math.Max(maxAbs, math.Abs(v))
+		}
+		scales[f] = maxAbs / 127
+		if scales[f] == 0 {
+			scales[f] = 1
+		}
+		weights[f] = make([]int8, len(row))
+		for k, v := range row {
+			weights[f][k] = int8(math.Max(-127, math.Min(127, math.Round(v/scales[f]))))
+		}
+	}
+	return weights, scales
+}
+
+// quantizeBias converts the bias of every output channel to int32 in units
+// of the accumulator, inputScale * weightScale
+func quantizeBias(bias []float64, inputScale float64, weightScales []float64) []int32 {
+	quantized := make([]int32, len(bias))
+	for f, b := range bias {
+		quantized[f] = int32(math.Round(b / (inputScale * weightScales[f])))
+	}
+	return quantized
+}
+
+// QuantizedDense is an inference-only Dense layer with per-output-channel
+// int8 weights. Inputs are quantized with Input, multiplied in integers and
+// the int32 results are scaled back to float64.
+type QuantizedDense struct {
+	InputSize    int
+	OutputSize   int
+	Weights      [][]int8  // Shape: (OutputSize, InputSize), one row per output channel
+	WeightScales []float64 // Per output channel, zero point 0
+	Bias         []int32   // In units of Input.Scale * WeightScales
+	Input        QuantParams
+}
+
+// QuantizeDense converts a trained Dense layer to int8 for inputs in the
+// range described by input
+func QuantizeDense(d *Dense, input QuantParams) *QuantizedDense {
+	weights, scales := quantizeChannels(d.Weights.Transpose().Data)
+	return &QuantizedDense{
+		InputSize:    d.InputSize,
+		OutputSize:   d.OutputSize,
+		Weights:      weights,
+		WeightScales: scales,
+		Bias:         quantizeBias(d.Bias.Data[0], input.Scale, scales),
+		Input:        input,
+	}
+}
+
+// Forward computes input @ weights + bias with an int8 x int8 -> int32
+// matrix product
+func (q *QuantizedDense) Forward(input *Matrix) (*Matrix, error) {
+	if input.Cols != q.InputSize {
+		return nil, fmt.Errorf("input size mismatch: got %d, expected %d", input.Cols, q.InputSize)
+	}
+
+	output := NewMatrix(input.Rows, q.OutputSize)
+	x := make([]int32, q.InputSize)
+	for i := 0; i < input.Rows; i++ {
+		for k, v := range input.Data[i] {
+			x[k] = q.Input.shifted(v)
+		}
+		for j := 0; j < q.OutputSize; j++ {
+			acc := q.Bias[j]
+			for k, w := range q.Weights[j] {
+				acc += x[k] * int32(w)
+			}
+			output.Data[i][j] = float64(acc) * q.Input.Scale * q.WeightScales[j]
+		}
+	}
+	return output, nil
+}
+
+// Backward is not supported: quantized layers are for inference only
+func (q *QuantizedDense) Backward(gradOutput *Matrix) (*Matrix, error) {
+	return nil, fmt.Errorf("QuantizedDense is inference only")
+}
+
+// GetParams returns no parameters, the layer is not trainable
+func (q *QuantizedDense) GetParams() []*Matrix {
+	return []*Matrix{}
+}
+
+// GetGrads returns no gradients
+func (q *QuantizedDense) GetGrads() []*Matrix {
+	return []*Matrix{}
+}
+
+// GetParamNames returns no names
+func (q *QuantizedDense) GetParamNames() []string {
+	return []string{}
+}
+
+// QuantizedConv2D is an inference-only ConvLayer with per-output-channel
+// int8 filters
+type QuantizedConv2D struct {
+	Conv2DConfig
+	Weights      [][]int8  // Shape: (OutChannels, InChannels/Groups*KernelH*KernelW)
+	WeightScales []float64 // Per output channel, zero point 0
+	Bias         []int32   // In units of Input.Scale * WeightScales
+	Input        QuantParams
+}
+
+// QuantizeConv2D converts a trained ConvLayer to int8 for inputs in the
+// range described by input
+func QuantizeConv2D(conv *ConvLayer, input QuantParams) *QuantizedConv2D {
+	weights, scales := quantizeChannels(conv.Weights.Data)
+	return &QuantizedConv2D{
+		Conv2DConfig: conv.Conv2DConfig,
+		Weights:      weights,
+		WeightScales: scales,
+		Bias:         quantizeBias(conv.Bias.Data[0], input.Scale, scales),
+		Input:        input,
+	}
+}
+
+// Forward performs the integer convolution of one sample
+func (q *QuantizedConv2D) Forward(input *Tensor3D) (*Tensor3D, error) {
+	output, err := q.ForwardBatch(input.asBatch())
+	if err != nil {
+		return nil, err
+	}
+	return output.Sample(0), nil
+}
+
+// ForwardBatch performs the integer convolution of every sample, one
+// goroutine per group of samples
+func (q *QuantizedConv2D) ForwardBatch(input *Tensor4D) (*Tensor4D, error) {
+	if input.Channels != q.InChannels {
+		return nil, fmt.Errorf("input channels mismatch: got %d, expected %d", input.Channels, q.InChannels)
+	}
+
+	g, err := newConvGeometry(q.Conv2DConfig, input.Height, input.Width)
+	if err != nil {
+		return nil, err
+	}
+
+	output := NewTensor4D(input.Batch, q.OutChannels, g.outH, g.outW)
+	parallelFor(input.Batch, workerCount(input.Batch), func(_, n int) {
+		x := make([][][]int32, g.inC)
+		for c, plane := range input.Data[n] {
+			x[c] = make([][]int32, g.inH)
+			for i, row := range plane {
+				x[c][i] = make([]int32, g.inW)
+				for j, v := range row {
+					x[c][i][j] = q.Input.shifted(v)
+				}
+			}
+		}
+
+		acc := g.forwardInt8(q.Weights, x)
+		for f := 0; f < q.OutChannels; f++ {
+			scale := q.Input.Scale * q.WeightScales[f]
+			for oh := 0; oh < g.outH; oh++ {
+				for ow := 0; ow < g.outW; ow++ {
+					output.Data[n][f][oh][ow] = float64(acc[f][oh][ow]+q.Bias[f]) * scale
+				}
+			}
+		}
+	})
+
+	return output, nil
+}
+
+// forwardInt8 is forward on zero-point shifted inputs and int8 weights,
+// accumulating in int32. Padding contributes 0 like a real zero would.
+func (g *convGeometry) forwardInt8(weights [][]int8, input [][][]int32) [][][]int32 {
+	inPerGroup, outPerGroup := g.inC/g.groups, g.outC/g.groups
+
+	output := make([][][]int32, g.outC)
+	for f := 0; f < g.outC; f++ {
+		w := weights[f]
+		base := (f / outPerGroup) * inPerGroup
+		output[f] = make([][]int32, g.outH)
+		for oh := 0; oh < g.outH; oh++ {
+			output[f][oh] = make([]int32, g.outW)
+			for ow := 0; ow < g.outW; ow++ {
+				var sum int32
+				for c := 0; c < inPerGroup; c++ {
+					plane := input[base+c]
+					for i := 0; i < g.kh; i++ {
+						ih := oh*g.strideH + i*g.dilH - g.padTop
+						if ih < 0 || ih >= g.inH {
+							continue
+						}
+						k := (c*g.kh + i) * g.kw
+						for j := 0; j < g.kw; j++ {
+							iw := ow*g.strideW + j*g.dilW - g.padLeft
+							if iw >= 0 && iw < g.inW {
+								sum += plane[ih][iw] * int32(w[k+j])
+							}
+						}
+					}
+				}
+				output[f][oh][ow] = sum
+			}
+		}
+	}
+	return output
+}
+
+// Backward is not supported: quantized layers are for inference only
+func (q *QuantizedConv2D) Backward(gradOutput *Tensor3D) (*Tensor3D, error) {
+	return nil, fmt.Errorf("QuantizedConv2D is inference only")
+}
+
+// BackwardBatch is not supported: quantized layers are for inference only
+func (q *QuantizedConv2D) BackwardBatch(gradOutput *Tensor4D) (*Tensor4D, error) {
+	return nil, fmt.Errorf("QuantizedConv2D is inference only")
+}
+
+// GetParams returns no parameters, the layer is not trainable
+func (q *QuantizedConv2D) GetParams() []*Matrix {
+	return []*Matrix{}
+}
+
+// GetGrads returns no gradients
+func (q *QuantizedConv2D) GetGrads() []*Matrix {
+	return []*Matrix{}
+}
+
+// GetParamNames returns no names
+func (q *QuantizedConv2D) GetParamNames() []string {
+	return []string{}
+}
+
+// quantizable reports whether Quantize replaces layer
+func quantizable(layer Layer) bool {
+	switch l := layer.(type) {
+	case *Dense:
+		return true
+	case *Spatial:
+		_, ok := l.Layer.(*ConvLayer)
+		return ok
+	}
+	return false
+}
+
+// Quantize returns an int8 copy of a trained model for inference. Dense
+// layers and ConvLayers wrapped in Spatial are replaced by quantized
+// versions whose input ranges are calibrated on the rows of calibration,
+// which should be representative of the inputs seen in production.
+// Calibration runs model in inference mode and restores its mode afterwards.
+//
+// Other layers and the loss are not copied: the returned model holds the
+// same instances as model, so training model, loading weights into it or
+// switching its mode also changes them in the quantized model.
+func Quantize(model *Sequential, calibration *Matrix) (*Sequential, error) {
+	if calibration.Rows == 0 {
+		return nil, fmt.Errorf("calibration data is empty")
+	}
+	defer model.setTraining(model.training)
+	model.Eval()
+
+	quantized := NewSequential()
+	quantized.Loss = model.Loss
+	activations := calibration
+
+	for i, layer := range model.Layers {
+		replacement := layer
+		if quantizable(layer) {
+			min, max := math.Inf(1), math.Inf(-1)
+			for _, row := range activations.Data {
+				for _, v := range row {
+					min = math.Min(min, v)
+					max = math.Max(max, v)
+				}
+			}
+			input := NewQuantParams(min, max)
+
+			switch l := layer.(type) {
+			case *Dense:
+				replacement = QuantizeDense(l, input)
+			case *Spatial:
+				replacement = NewSpatial(QuantizeConv2D(l.Layer.(*ConvLayer), input), l.Channels, l.Height, l.Width)
+			}
+		}
+		quantized.Add(replacement)
+
+		var err error
+		activations, err = layer.Forward(activations)
+		if err != nil {
+			return nil, fmt.Errorf("calibrating layer %d: %v", i, err)
+		}
+	}
+
+	return quantized, nil
+}
+
+// QuantizationReport compares a quantized model with the float model it
+// was built from on the same data
+type QuantizationReport struct {
+	FloatLoss     float64
+	QuantizedLoss float64
+	MaxOutputDiff float64 // Largest absolute difference between the predictions
+
+	// FloatMetrics and QuantizedMetrics map metric names to results
+	FloatMetrics     map[string]float64
+	QuantizedMetrics map[string]float64
+}
+
+// LossDelta returns the quantized loss minus the float loss
+func (r *QuantizationReport) LossDelta() float64 {
+	return r.QuantizedLoss - r.FloatLoss
+}
+
+// MetricDelta returns the quantized result of a metric minus the float one
+func (r *QuantizationReport) MetricDelta(name string) float64 {
+	return r.QuantizedMetrics[name] - r.FloatMetrics[name]
+}
+
+// String formats the report as "loss: float -> quantized (delta) - ..."
+func (r *QuantizationReport) String() string {
+	parts := []string{fmt.Sprintf("loss: %.6f -> %.6f (%+.6f)", r.FloatLoss, r.QuantizedLoss, r.LossDelta())}
+	names := make([]string, 0, len(r.FloatMetrics))
+	for name := range r.FloatMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %.6f -> %.6f (%+.6f)",
+			name, r.FloatMetrics[name], r.QuantizedMetrics[name], r.MetricDelta(name)))
+	}
+	parts = append(parts, fmt.Sprintf("max output diff: %.6f", r.MaxOutputDiff))
+	return strings.Join(parts, " - ")
+}
+
+// CompareQuantized evaluates the float model and its quantized version on
+// X and y with the float model's loss and the given metrics
+func CompareQuantized(model, quantized *Sequential, X, y *Matrix, metrics ...Metric) (*QuantizationReport, error) {
+	if model.Loss == nil {
+		return nil, fmt.Errorf("model must be compiled")
+	}
+
+	floatPred, err := model.Predict(X)
+	if err != nil {
+		return nil, err
+	}
+	quantPred, err := quantized.Predict(X)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QuantizationReport{
+		FloatMetrics:     make(map[string]float64),
+		QuantizedMetrics: make(map[string]float64),
+	}
+	if report.FloatLoss, err = model.Loss.Forward(floatPred, y); err != nil {
+		return nil, err
+	}
+	if report.QuantizedLoss, err = model.Loss.Forward(quantPred, y); err != nil {
+		return nil, err
+	}
+
+	for _, metric := range metrics {
+		if report.FloatMetrics[metric.Name()], err = metricResult(metric, floatPred, y); err != nil {
+			return nil, err
+		}
+		if report.QuantizedMetrics[metric.Name()], err = metricResult(metric, quantPred, y); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range floatPred.Data {
+		for j := range floatPred.Data[i] {
+			report.MaxOutputDiff = math.Max(report.MaxOutputDiff, math.Abs(floatPred.Data[i][j]-quantPred.Data[i][j]))
+		}
+	}
+
+	return report, nil
+}
+
+// metricResult resets metric and returns its result on one batch
+func metricResult(metric Metric, predictions, targets *Matrix) (float64, error) {
+	metric.Reset()
+	if err := metric.Update(predictions, targets); err != nil {
+		return 0, err
+	}
+	return metric.Result(), nil
+}
@@ -0,0 +1,244 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQuantizeKeepsCallerMode(t *testing.T) {
+	rng := rand.New(rand.NewSource(23))
+	model := NewSequential()
+	model.Add(NewDense(4, 6))
+	model.Add(NewBatchNorm1D(6))
+	model.Add(NewDense(6, 3))
+	model.Compile(NewMSE(), NewSGD(0.1, 0))
+	for _, layer := range model.Layers {
+		randomizeParams(rng, layer)
+	}
+	bn := model.Layers[1].(*BatchNorm1D)
+	X := uniformMatrix(rng, 8, 4, -1, 1)
+
+	model.Train()
+	before := snapshotState(model.Layers)
+	quantized, err := Quantize(model, X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !model.IsTraining() {
+		t.Error("Quantize left the model in inference mode")
+	}
+	// Calibration runs in inference mode and leaves the running statistics
+	for k, m := range bn.GetState() {
+		if !matricesEqual(m, before[1][k]) {
+			t.Errorf("calibration changed %s", bn.GetStateNames()[k])
+		}
+	}
+
+	if _, ok := quantized.Layers[0].(*QuantizedDense); !ok {
+		t.Errorf("layer 0 is %T, expected *QuantizedDense", quantized.Layers[0])
+	}
+	if quantized.Layers[1] != model.Layers[1] {
+		t.Error("non-quantized layers are not shared")
+	}
+
+	want, err := model.Predict(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := quantized.Predict(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want.Data {
+		for j := range want.Data[i] {
+			if diff := math.Abs(got.Data[i][j] - want.Data[i][j]); diff > 0.05 {
+				t.Errorf("prediction [%d][%d] differs by %v", i, j, diff)
+			}
+		}
+	}
+}
+
+func TestNewQuantParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		min, max  float64
+		scale     float64
+		zeroPoint int32
+	}{
+		{"zero inside", -1, 3, 4.0 / 255, -64},
+		// The range is widened to contain 0
+		{"positive", 2, 5, 5.0 / 255, -128},
+		{"negative", -4, -1, 4.0 / 255, 127},
+		{"empty", 0, 0, 1, -128},
+	}
+	for _, tt := range tests {
+		p := NewQuantParams(tt.min, tt.max)
+		if math.Abs(p.Scale-tt.scale) > 1e-15 || p.ZeroPoint != tt.zeroPoint {
+			t.Errorf("%s: got scale %v and zero point %d, expected %v and %d", tt.name, p.Scale, p.ZeroPoint, tt.scale, tt.zeroPoint)
+		}
+		if got := p.Dequantize(p.Quantize(0)); got != 0 {
+			t.Errorf("%s: 0 round trips to %v", tt.name, got)
+		}
+	}
+
+	p := NewQuantParams(-1, 3)
+	for _, v := range []float64{-1, -0.3, 0.7, 2.9, 3} {
+		if got := p.Dequantize(p.Quantize(v)); math.Abs(got-v) > p.Scale/2+1e-12 {
+			t.Errorf("%v round trips to %v", v, got)
+		}
+	}
+	// Values outside the range saturate
+	if q := p.Quantize(100); q != 127 {
+		t.Errorf("quantized 100 to %d, expected 127", q)
+	}
+	if q := p.Quantize(-100); q != -128 {
+		t.Errorf("quantized -100 to %d, expected -128", q)
+	}
+}
+
+func TestQuantizePerChannelScales(t *testing.T) {
+	d := NewDense(2, 3)
+	d.Weights = &Matrix{Rows: 2, Cols: 3, Data: [][]float64{{4, 0.5, 0}, {-1, 0.1, 0}}}
+	d.Bias = &Matrix{Rows: 1, Cols: 3, Data: [][]float64{{0.2, -0.1, 0.3}}}
+	input := QuantParams{Scale: 0.01, ZeroPoint: 0}
+	q := QuantizeDense(d, input)
+
+	// Every output channel gets the scale of its largest weight; an all-zero
+	// channel falls back to 1
+	wantScales := []float64{4.0 / 127, 0.5 / 127, 1}
+	wantWeights := [][]int8{{127, -32}, {127, 25}, {0, 0}}
+	for f := range wantScales {
+		if math.Abs(q.WeightScales[f]-wantScales[f]) > 1e-15 {
+			t.Errorf("channel %d scale %v, expected %v", f, q.WeightScales[f], wantScales[f])
+		}
+		for k, w := range wantWeights[f] {
+			if q.Weights[f][k] != w {
+				t.Errorf("weight [%d][%d] is %d, expected %d", f, k, q.Weights[f][k], w)
+			}
+		}
+		// The bias is stored in accumulator units
+		if got := float64(q.Bias[f]) * input.Scale * q.WeightScales[f]; math.Abs(got-d.Bias.Data[0][f]) > input.Scale*q.WeightScales[f] {
+			t.Errorf("channel %d bias %v, expected %v", f, got, d.Bias.Data[0][f])
+		}
+	}
+}
+
+func TestQuantizedConv2DExact(t *testing.T) {
+	// With integer inputs and weights whose largest magnitude is 127 every
+	// scale is 1, so the integer convolution must match the float one
+	// exactly, including the zero padding and the groups
+	rng := rand.New(rand.NewSource(5))
+	conv := NewConv2D(Conv2DConfig{InChannels: 4, OutChannels: 4, KernelH: 3, KernelW: 3, StrideH: 2, StrideW: 2, PadH: 1, PadW: 1, Groups: 2})
+	for f, row := range conv.Weights.Data {
+		for k := range row {
+			row[k] = float64(rng.Intn(255) - 127)
+		}
+		row[f] = 127
+		conv.Bias.Data[0][f] = float64(rng.Intn(21) - 10)
+	}
+	input := NewTensor4D(2, 4, 5, 5)
+	for n := range input.Data {
+		for c := range input.Data[n] {
+			for i := range input.Data[n][c] {
+				for j := range input.Data[n][c][i] {
+					input.Data[n][c][i][j] = float64(rng.Intn(11) - 5)
+				}
+			}
+		}
+	}
+
+	want, err := conv.ForwardBatch(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A non-zero zero point must not leak into the padding
+	q := QuantizeConv2D(conv, QuantParams{Scale: 1, ZeroPoint: 10})
+	got, err := q.ForwardBatch(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Height != 3 || got.Width != 3 {
+		t.Fatalf("output is %dx%d, expected 3x3", got.Height, got.Width)
+	}
+	for n := range want.Data {
+		for f := range want.Data[n] {
+			for i := range want.Data[n][f] {
+				for j, v := range want.Data[n][f][i] {
+					if got.Data[n][f][i][j] != v {
+						t.Fatalf("output [%d][%d][%d][%d] is %v, expected %v", n, f, i, j, got.Data[n][f][i][j], v)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := q.Forward(NewTensor3D(3, 5, 5)); err == nil {
+		t.Error("expected an error for 3 input channels on 4")
+	}
+	if _, err := q.Backward(NewTensor3D(4, 3, 3)); err == nil {
+		t.Error("expected an error from the backward pass")
+	}
+}
+
+func TestCompareQuantized(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	model := NewSequential()
+	model.Add(NewSpatial(NewConvLayer(2, 1, 3, 1, 1), 1, 4, 4))
+	model.Add(NewReLULayer())
+	model.Add(NewDense(2*4*4, 2))
+	for _, layer := range model.Layers {
+		randomizeParams(rng, layer)
+	}
+	X, y := uniformMatrix(rng, 6, 16, 0, 1), uniformMatrix(rng, 6, 2, -1, 1)
+
+	quantized, err := Quantize(model, X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spatial, ok := quantized.Layers[0].(*Spatial); !ok {
+		t.Errorf("layer 0 is %T, expected *Spatial", quantized.Layers[0])
+	} else if _, ok := spatial.Layer.(*QuantizedConv2D); !ok {
+		t.Errorf("spatial layer is %T, expected *QuantizedConv2D", spatial.Layer)
+	}
+
+	if _, err := CompareQuantized(model, quantized, X, y); err == nil {
+		t.Error("expected an error for an uncompiled model")
+	}
+	model.Compile(NewMSE(), NewSGD(0.1, 0))
+	report, err := CompareQuantized(model, quantized, X, y, NewMeanAbsoluteError())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	floatPred, _ := model.Predict(X)
+	quantPred, _ := quantized.Predict(X)
+	floatLoss, _ := NewMSE().Forward(floatPred, y)
+	quantLoss, _ := NewMSE().Forward(quantPred, y)
+	maxDiff := 0.0
+	for i := range floatPred.Data {
+		for j := range floatPred.Data[i] {
+			maxDiff = math.Max(maxDiff, math.Abs(floatPred.Data[i][j]-quantPred.Data[i][j]))
+		}
+	}
+	if report.FloatLoss != floatLoss || report.QuantizedLoss != quantLoss || report.MaxOutputDiff != maxDiff {
+		t.Errorf("report %+v does not match losses %v, %v and max diff %v", report, floatLoss, quantLoss, maxDiff)
+	}
+	if maxDiff == 0 || maxDiff > 0.05 {
+		t.Errorf("max output diff %v, expected a small non-zero error", maxDiff)
+	}
+	if got := report.LossDelta(); got != quantLoss-floatLoss {
+		t.Errorf("loss delta %v, expected %v", got, quantLoss-floatLoss)
+	}
+	if got := report.MetricDelta("mae"); got != report.QuantizedMetrics["mae"]-report.FloatMetrics["mae"] {
+		t.Errorf("mae delta %v", got)
+	}
+
+	want := fmt.Sprintf("loss: %.6f -> %.6f (%+.6f) - mae: %.6f -> %.6f (%+.6f) - max output diff: %.6f",
+		floatLoss, quantLoss, quantLoss-floatLoss,
+		report.FloatMetrics["mae"], report.QuantizedMetrics["mae"], report.MetricDelta("mae"), maxDiff)
+	if got := report.String(); got != want {
+		t.Errorf("report:\n%s\nexpected:\n%s", got, want)
+	}
+}
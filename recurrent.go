@@ -0,0 +1,797 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+)
+
+// RecurrentLayerOf is implemented by SimpleRNN, LSTM and GRU with element
+// type T
+type RecurrentLayerOf[T Float] interface {
+	LayerOf[T]
+	SetMask(mask *MatrixOf[T])
+	SetInitialState(states ...*MatrixOf[T])
+	InitialStateGrads() []*MatrixOf[T]
+	recurrentBase() *recurrent[T]
+}
+
+// RecurrentLayer is a RecurrentLayerOf with float64 values
+type RecurrentLayer = RecurrentLayerOf[float64]
+
+// recurrentCell computes one time step of a recurrent layer over a batch
+type recurrentCell[T Float] interface {
+	// numStates returns the number of state matrices (h, or h and c)
+	numStates() int
+	// stepForward computes the next states from x (batch, InputSize) and the
+	// previous states; states[0] is the hidden state emitted as output
+	stepForward(x *MatrixOf[T], states []*MatrixOf[T]) ([]*MatrixOf[T], interface{})
+	// stepBackward accumulates the parameter gradients of one step and
+	// returns the gradients of x and of the previous states
+	stepBackward(cache interface{}, gradStates []*MatrixOf[T]) (*MatrixOf[T], []*MatrixOf[T])
+	// zeroGrads resets the accumulated parameter gradients
+	zeroGrads()
+}
+
+// recurrent implements backpropagation through time for SimpleRNN, LSTM and
+// GRU. Inputs have shape (batch, time*InputSize) with the features of a row
+// laid out step by step.
+type recurrent[T Float] struct {
+	InputSize       int
+	HiddenSize      int
+	ReturnSequences bool // Output (batch, time*HiddenSize) instead of the last state
+	GoBackwards     bool // Process the sequence from the last step to the first
+
+	cell              recurrentCell[T]
+	mask              *MatrixOf[T]
+	initialState      []*MatrixOf[T]
+	initialStateGrads []*MatrixOf[T]
+
+	// Cache for backward pass
+	batch  int
+	order  []int // Time index processed at every step
+	caches []interface{}
+	valid  [][]bool // valid[k][i] reports whether sample i is unmasked at step k
+}
+
+// newRecurrent creates the shared recurrent state driving cell
+func newRecurrent[T Float](inputSize, hiddenSize int, returnSequences bool, cell recurrentCell[T]) recurrent[T] {
+	return recurrent[T]{
+		InputSize:       inputSize,
+		HiddenSize:      hiddenSize,
+		ReturnSequences: returnSequences,
+		cell:            cell,
+	}
+}
+
+// recurrentWeights returns the input kernel, the recurrent kernel and the
+// bias of a cell with gates*hiddenSize columns. The input kernel and the
+// bias use GlorotUniform and defBias unless opts select other initializers;
+// the recurrent kernel is always Orthogonal.
+func recurrentWeights[T Float](inputSize, hiddenSize, gates int, defBias Initializer, opts []InitOption) (kernel, recurrentKernel, bias *MatrixOf[T]) {
+	kernelInit, biasInit := initializers(opts, GlorotUniform{}, defBias)
+	cols := gates * hiddenSize
+	kernel = initializedMatrixOf[T](kernelInit, inputSize, cols, inputSize, cols)
+	recurrentKernel = initializedMatrixOf[T](Orthogonal{}, hiddenSize, cols, hiddenSize, cols)
+	bias = initializedMatrixOf[T](biasInit, 1, cols, inputSize, cols)
+	return kernel, recurrentKernel, bias
+}
+
+// recurrentBase gives Bidirectional access to the shared configuration
+func (r *recurrent[T]) recurrentBase() *recurrent[T] {
+	return r
+}
+
+// SetMask sets a (batch, time) mask for the following passes: steps with a
+// 0 keep the previous state and output zeros. A nil mask disables masking.
+func (r *recurrent[T]) SetMask(mask *MatrixOf[T]) {
+	r.mask = mask
+}
+
+// SetInitialState sets the (batch, HiddenSize) states used before the first
+// step: h for SimpleRNN and GRU, h and c for LSTM. Calling it without
+// arguments restores zero initial states.
+func (r *recurrent[T]) SetInitialState(states ...*MatrixOf[T]) {
+	r.initialState = states
+}
+
+// InitialStateGrads returns the gradients of the initial states computed by
+// the last Backward call
+func (r *recurrent[T]) InitialStateGrads() []*MatrixOf[T] {
+	return r.initialStateGrads
+}
+
+// startStates returns the initial states for a batch
+func (r *recurrent[T]) startStates(batch int) ([]*MatrixOf[T], error) {
+	n := r.cell.numStates()
+	if len(r.initialState) == 0 {
+		states := make([]*MatrixOf[T], n)
+		for s := range states {
+			states[s] = NewMatrixOf[T](batch, r.HiddenSize)
+		}
+		return states, nil
+	}
+
+	if len(r.initialState) != n {
+		return nil, fmt.Errorf("expected %d initial states, got %d", n, len(r.initialState))
+	}
+	for _, state := range r.initialState {
+		if state.Rows != batch || state.Cols != r.HiddenSize {
+			return nil, fmt.Errorf("initial state shape mismatch: got %dx%d, expected %dx%d",
+				state.Rows, state.Cols, batch, r.HiddenSize)
+		}
+	}
+	return r.initialState, nil
+}
+
+// Forward runs the cell over every time step
+func (r *recurrent[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	if r.InputSize <= 0 || input.Cols%r.InputSize != 0 || input.Cols == 0 {
+		return nil, fmt.Errorf("input size %d is not a positive multiple of %d", input.Cols, r.InputSize)
+	}
+	steps := input.Cols / r.InputSize
+	if r.mask != nil && (r.mask.Rows != input.Rows || r.mask.Cols != steps) {
+		return nil, fmt.Errorf("mask shape mismatch: got %dx%d, expected %dx%d",
+			r.mask.Rows, r.mask.Cols, input.Rows, steps)
+	}
+
+	states, err := r.startStates(input.Rows)
+	if err != nil {
+		return nil, err
+	}
+
+	r.batch = input.Rows
+	r.order = make([]int, steps)
+	r.caches = make([]interface{}, steps)
+	r.valid = make([][]bool, steps)
+
+	var output *MatrixOf[T]
+	if r.ReturnSequences {
+		output = NewMatrixOf[T](input.Rows, steps*r.HiddenSize)
+	}
+
+	for k := 0; k < steps; k++ {
+		t := k
+		if r.GoBackwards {
+			t = steps - 1 - k
+		}
+		r.order[k] = t
+
+		x := columns(input, t*r.InputSize, (t+1)*r.InputSize)
+		next, cache := r.cell.stepForward(x, states)
+		r.caches[k] = cache
+
+		r.valid[k] = make([]bool, input.Rows)
+		for i := 0; i < input.Rows; i++ {
+			r.valid[k][i] = r.mask == nil || r.mask.Data[i][t] != 0
+			if !r.valid[k][i] {
+				// Padded steps carry the previous state over unchanged
+				for s := range next {
+					copy(next[s].Data[i], states[s].Data[i])
+				}
+			} else if r.ReturnSequences {
+				copy(output.Data[i][t*r.HiddenSize:(t+1)*r.HiddenSize], next[0].Data[i])
+			}
+		}
+
+		states = next
+	}
+
+	if !r.ReturnSequences {
+		output = copyMatrix(states[0])
+	}
+	return output, nil
+}
+
+// Backward performs backpropagation through time
+func (r *recurrent[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	steps := len(r.order)
+	expectedCols := r.HiddenSize
+	if r.ReturnSequences {
+		expectedCols = steps * r.HiddenSize
+	}
+	if gradOutput.Rows != r.batch || gradOutput.Cols != expectedCols {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	r.cell.zeroGrads()
+
+	gradStates := make([]*MatrixOf[T], r.cell.numStates())
+	for s := range gradStates {
+		gradStates[s] = NewMatrixOf[T](r.batch, r.HiddenSize)
+	}
+	if !r.ReturnSequences {
+		gradStates[0] = copyMatrix(gradOutput)
+	}
+
+	gradInput := NewMatrixOf[T](r.batch, steps*r.InputSize)
+	for k := steps - 1; k >= 0; k-- {
+		t := r.order[k]
+		valid := r.valid[k]
+
+		if r.ReturnSequences {
+			for i := 0; i < r.batch; i++ {
+				if !valid[i] {
+					continue
+				}
+				for j := 0; j < r.HiddenSize; j++ {
+					gradStates[0].Data[i][j] += gradOutput.Data[i][t*r.HiddenSize+j]
+				}
+			}
+		}
+
+		// Masked samples bypass the cell: their state gradient flows
+		// straight to the previous step
+		cellGrads := make([]*MatrixOf[T], len(gradStates))
+		for s := range gradStates {
+			cellGrads[s] = copyMatrix(gradStates[s])
+			for i := 0; i < r.batch; i++ {
+				if !valid[i] {
+					for j := range cellGrads[s].Data[i] {
+						cellGrads[s].Data[i][j] = 0
+					}
+				}
+			}
+		}
+
+		gradX, gradPrev := r.cell.stepBackward(r.caches[k], cellGrads)
+		for s := range gradPrev {
+			for i := 0; i < r.batch; i++ {
+				if !valid[i] {
+					copy(gradPrev[s].Data[i], gradStates[s].Data[i])
+				}
+			}
+		}
+
+		setColumns(gradInput, t*r.InputSize, gradX)
+		gradStates = gradPrev
+	}
+
+	r.initialStateGrads = gradStates
+	return gradInput, nil
+}
+
+// linearStep computes x @ kernel + h @ recurrentKernel + bias
+func linearStep[T Float](x, h, kernel, recurrentKernel, bias *MatrixOf[T]) *MatrixOf[T] {
+	z := NewMatrixOf[T](x.Rows, kernel.Cols)
+	gemm(z, x, kernel, false, false)
+	gemm(z, h, recurrentKernel, false, false)
+	for i := 0; i < z.Rows; i++ {
+		for j := 0; j < z.Cols; j++ {
+			z.Data[i][j] += bias.Data[0][j]
+		}
+	}
+	return z
+}
+
+// linearStepBackward accumulates the gradients of linearStep given dz and
+// returns the gradients of x and h
+func linearStepBackward[T Float](x, h, dz, kernel, recurrentKernel, kernelGrad, recurrentGrad, biasGrad *MatrixOf[T]) (*MatrixOf[T], *MatrixOf[T]) {
+	gemm(kernelGrad, x, dz, true, false)
+	gemm(recurrentGrad, h, dz, true, false)
+	for i := 0; i < dz.Rows; i++ {
+		for j := 0; j < dz.Cols; j++ {
+			biasGrad.Data[0][j] += dz.Data[i][j]
+		}
+	}
+
+	gradX := NewMatrixOf[T](x.Rows, x.Cols)
+	gemm(gradX, dz, kernel, false, true)
+	gradH := NewMatrixOf[T](h.Rows, h.Cols)
+	gemm(gradH, dz, recurrentKernel, false, true)
+	return gradX, gradH
+}
+
+// SimpleRNNOf is a fully connected recurrent layer with element type T:
+// h_t = tanh(x_t @ Kernel + h_{t-1} @ RecurrentKernel + Bias)
+type SimpleRNNOf[T Float] struct {
+	recurrent[T]
+	Kernel          *MatrixOf[T] // Shape: (InputSize, HiddenSize)
+	RecurrentKernel *MatrixOf[T] // Shape: (HiddenSize, HiddenSize)
+	Bias            *MatrixOf[T] // Shape: (1, HiddenSize)
+
+	kernelGrad    *MatrixOf[T]
+	recurrentGrad *MatrixOf[T]
+	biasGrad      *MatrixOf[T]
+}
+
+// SimpleRNN is a SimpleRNNOf with float64 values
+type SimpleRNN = SimpleRNNOf[float64]
+
+// rnnCache holds the values of one SimpleRNN step
+type rnnCache[T Float] struct {
+	x, h, next *MatrixOf[T]
+}
+
+// NewSimpleRNN creates a new SimpleRNN layer with a GlorotUniform input
+// kernel, an Orthogonal recurrent kernel and a zero bias. opts select other
+// initializers for the input kernel and the bias.
+func NewSimpleRNN(inputSize, hiddenSize int, returnSequences bool, opts ...InitOption) *SimpleRNN {
+	return NewSimpleRNNOf[float64](inputSize, hiddenSize, returnSequences, opts...)
+}
+
+// NewSimpleRNNOf creates a new SimpleRNN layer with element type T
+func NewSimpleRNNOf[T Float](inputSize, hiddenSize int, returnSequences bool, opts ...InitOption) *SimpleRNNOf[T] {
+	kernel, recurrentKernel, bias := recurrentWeights[T](inputSize, hiddenSize, 1, Zeros{}, opts)
+	l := &SimpleRNNOf[T]{
+		Kernel:          kernel,
+		RecurrentKernel: recurrentKernel,
+		Bias:            bias,
+		kernelGrad:      NewMatrixOf[T](inputSize, hiddenSize),
+		recurrentGrad:   NewMatrixOf[T](hiddenSize, hiddenSize),
+		biasGrad:        NewMatrixOf[T](1, hiddenSize),
+	}
+	l.recurrent = newRecurrent[T](inputSize, hiddenSize, returnSequences, l)
+	return l
+}
+
+func (l *SimpleRNNOf[T]) numStates() int {
+	return 1
+}
+
+func (l *SimpleRNNOf[T]) stepForward(x *MatrixOf[T], states []*MatrixOf[T]) ([]*MatrixOf[T], interface{}) {
+	next := linearStep(x, states[0], l.Kernel, l.RecurrentKernel, l.Bias)
+	for i := 0; i < next.Rows; i++ {
+		for j := 0; j < next.Cols; j++ {
+			next.Data[i][j] = T(math.Tanh(float64(next.Data[i][j])))
+		}
+	}
+	return []*MatrixOf[T]{next}, &rnnCache[T]{x: x, h: states[0], next: copyMatrix(next)}
+}
+
+func (l *SimpleRNNOf[T]) stepBackward(cache interface{}, gradStates []*MatrixOf[T]) (*MatrixOf[T], []*MatrixOf[T]) {
+	c := cache.(*rnnCache[T])
+	dz := NewMatrixOf[T](c.next.Rows, c.next.Cols)
+	for i := 0; i < dz.Rows; i++ {
+		for j := 0; j < dz.Cols; j++ {
+			dz.Data[i][j] = gradStates[0].Data[i][j] * (1 - c.next.Data[i][j]*c.next.Data[i][j])
+		}
+	}
+	gradX, gradH := linearStepBackward(c.x, c.h, dz, l.Kernel, l.RecurrentKernel, l.kernelGrad, l.recurrentGrad, l.biasGrad)
+	return gradX, []*MatrixOf[T]{gradH}
+}
+
+func (l *SimpleRNNOf[T]) zeroGrads() {
+	l.kernelGrad.zero()
+	l.recurrentGrad.zero()
+	l.biasGrad.zero()
+}
+
+// GetParams returns the parameters of the layer
+func (l *SimpleRNNOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{l.Kernel, l.RecurrentKernel, l.Bias}
+}
+
+// GetGrads returns the gradients of the parameters
+func (l *SimpleRNNOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{l.kernelGrad, l.recurrentGrad, l.biasGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (l *SimpleRNNOf[T]) GetParamNames() []string {
+	return []string{"kernel", "recurrent_kernel", "bias"}
+}
+
+// LSTMOf is a long short-term memory layer with element type T. The gate
+// columns of the kernels and the bias are ordered input, forget, cell,
+// output.
+type LSTMOf[T Float] struct {
+	recurrent[T]
+	Kernel          *MatrixOf[T] // Shape: (InputSize, 4*HiddenSize)
+	RecurrentKernel *MatrixOf[T] // Shape: (HiddenSize, 4*HiddenSize)
+	Bias            *MatrixOf[T] // Shape: (1, 4*HiddenSize)
+
+	kernelGrad    *MatrixOf[T]
+	recurrentGrad *MatrixOf[T]
+	biasGrad      *MatrixOf[T]
+}
+
+// LSTM is an LSTMOf with float64 values
+type LSTM = LSTMOf[float64]
+
+// lstmCache holds the values of one LSTM step
+type lstmCache[T Float] struct {
+	x, h, c          *MatrixOf[T]
+	i, f, g, o       *MatrixOf[T]
+	nextC, tanhNextC *MatrixOf[T]
+}
+
+// NewLSTM creates a new LSTM layer with a GlorotUniform input kernel, an
+// Orthogonal recurrent kernel and a zero bias except for the forget gate,
+// which starts at 1. opts select other initializers for the input kernel
+// and the bias.
+func NewLSTM(inputSize, hiddenSize int, returnSequences bool, opts ...InitOption) *LSTM {
+	return NewLSTMOf[float64](inputSize, hiddenSize, returnSequences, opts...)
+}
+
+// NewLSTMOf creates a new LSTM layer with element type T
+func NewLSTMOf[T Float](inputSize, hiddenSize int, returnSequences bool, opts ...InitOption) *LSTMOf[T] {
+	gates := 4 * hiddenSize
+	kernel, recurrentKernel, bias := recurrentWeights[T](inputSize, hiddenSize, 4, forgetGateBias{hiddenSize}, opts)
+	l := &LSTMOf[T]{
+		Kernel:          kernel,
+		RecurrentKernel: recurrentKernel,
+		Bias:            bias,
+		kernelGrad:      NewMatrixOf[T](inputSize, gates),
+		recurrentGrad:   NewMatrixOf[T](hiddenSize, gates),
+		biasGrad:        NewMatrixOf[T](1, gates),
+	}
+	l.recurrent = newRecurrent[T](inputSize, hiddenSize, returnSequences, l)
+	return l
+}
+
+// forgetGateBias is the default LSTM bias: zero except for the forget gate,
+// which starts at 1
+type forgetGateBias struct {
+	hiddenSize int
+}
+
+// Initialize fills the bias
+func (f forgetGateBias) Initialize(m *Matrix, fanIn, fanOut int) {
+	Zeros{}.Initialize(m, fanIn, fanOut)
+	for j := f.hiddenSize; j < 2*f.hiddenSize; j++ {
+		m.Data[0][j] = 1
+	}
+}
+
+func (l *LSTMOf[T]) numStates() int {
+	return 2
+}
+
+func (l *LSTMOf[T]) stepForward(x *MatrixOf[T], states []*MatrixOf[T]) ([]*MatrixOf[T], interface{}) {
+	h, c := states[0], states[1]
+	hs := l.HiddenSize
+	z := linearStep(x, h, l.Kernel, l.RecurrentKernel, l.Bias)
+
+	cache := &lstmCache[T]{
+		x: x, h: h, c: c,
+		i: NewMatrixOf[T](x.Rows, hs), f: NewMatrixOf[T](x.Rows, hs),
+		g: NewMatrixOf[T](x.Rows, hs), o: NewMatrixOf[T](x.Rows, hs),
+		nextC: NewMatrixOf[T](x.Rows, hs), tanhNextC: NewMatrixOf[T](x.Rows, hs),
+	}
+	nextH := NewMatrixOf[T](x.Rows, hs)
+	for b := 0; b < x.Rows; b++ {
+		for j := 0; j < hs; j++ {
+			i := T(Sigmoid(float64(z.Data[b][j])))
+			f := T(Sigmoid(float64(z.Data[b][hs+j])))
+			g := T(math.Tanh(float64(z.Data[b][2*hs+j])))
+			o := T(Sigmoid(float64(z.Data[b][3*hs+j])))
+			nc := f*c.Data[b][j] + i*g
+
+			cache.i.Data[b][j], cache.f.Data[b][j] = i, f
+			cache.g.Data[b][j], cache.o.Data[b][j] = g, o
+			cache.nextC.Data[b][j] = nc
+			cache.tanhNextC.Data[b][j] = T(math.Tanh(float64(nc)))
+			nextH.Data[b][j] = o * cache.tanhNextC.Data[b][j]
+		}
+	}
+	return []*MatrixOf[T]{nextH, copyMatrix(cache.nextC)}, cache
+}
+
+func (l *LSTMOf[T]) stepBackward(cache interface{}, gradStates []*MatrixOf[T]) (*MatrixOf[T], []*MatrixOf[T]) {
+	c := cache.(*lstmCache[T])
+	hs := l.HiddenSize
+	gradH, gradC := gradStates[0], gradStates[1]
+
+	dz := NewMatrixOf[T](c.x.Rows, 4*hs)
+	gradPrevC := NewMatrixOf[T](c.x.Rows, hs)
+	for b := 0; b < c.x.Rows; b++ {
+		for j := 0; j < hs; j++ {
+			i, f, g, o := c.i.Data[b][j], c.f.Data[b][j], c.g.Data[b][j], c.o.Data[b][j]
+			tc := c.tanhNextC.Data[b][j]
+
+			dc := gradC.Data[b][j] + gradH.Data[b][j]*o*(1-tc*tc)
+			do := gradH.Data[b][j] * tc
+
+			dz.Data[b][j] = dc * g * i * (1 - i)
+			dz.Data[b][hs+j] = dc * c.c.Data[b][j] * f * (1 - f)
+			dz.Data[b][2*hs+j] = dc * i * (1 - g*g)
+			dz.Data[b][3*hs+j] = do * o * (1 - o)
+			gradPrevC.Data[b][j] = dc * f
+		}
+	}
+
+	gradX, gradPrevH := linearStepBackward(c.x, c.h, dz, l.Kernel, l.RecurrentKernel, l.kernelGrad, l.recurrentGrad, l.biasGrad)
+	return gradX, []*MatrixOf[T]{gradPrevH, gradPrevC}
+}
+
+func (l *LSTMOf[T]) zeroGrads() {
+	l.kernelGrad.zero()
+	l.recurrentGrad.zero()
+	l.biasGrad.zero()
+}
+
+// GetParams returns the parameters of the layer
+func (l *LSTMOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{l.Kernel, l.RecurrentKernel, l.Bias}
+}
+
+// GetGrads returns the gradients of the parameters
+func (l *LSTMOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{l.kernelGrad, l.recurrentGrad, l.biasGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (l *LSTMOf[T]) GetParamNames() []string {
+	return []string{"kernel", "recurrent_kernel", "bias"}
+}
+
+// GRUOf is a gated recurrent unit layer with element type T. The gate
+// columns of the kernels and the bias are ordered update (z), reset (r),
+// candidate (n):
+// n = tanh(x@Wn + (r*h)@Un + bn), h' = z*h + (1-z)*n
+type GRUOf[T Float] struct {
+	recurrent[T]
+	Kernel          *MatrixOf[T] // Shape: (InputSize, 3*HiddenSize)
+	RecurrentKernel *MatrixOf[T] // Shape: (HiddenSize, 3*HiddenSize)
+	Bias            *MatrixOf[T] // Shape: (1, 3*HiddenSize)
+
+	kernelGrad    *MatrixOf[T]
+	recurrentGrad *MatrixOf[T]
+	biasGrad      *MatrixOf[T]
+}
+
+// GRU is a GRUOf with float64 values
+type GRU = GRUOf[float64]
+
+// gruCache holds the values of one GRU step
+type gruCache[T Float] struct {
+	x, h, rh *MatrixOf[T]
+	z, r, n  *MatrixOf[T]
+}
+
+// NewGRU creates a new GRU layer with a GlorotUniform input kernel, an
+// Orthogonal recurrent kernel and a zero bias. opts select other
+// initializers for the input kernel and the bias.
+func NewGRU(inputSize, hiddenSize int, returnSequences bool, opts ...InitOption) *GRU {
+	return NewGRUOf[float64](inputSize, hiddenSize, returnSequences, opts...)
+}
+
+// NewGRUOf creates a new GRU layer with element type T
+func NewGRUOf[T Float](inputSize, hiddenSize int, returnSequences bool, opts ...InitOption) *GRUOf[T] {
+	gates := 3 * hiddenSize
+	kernel, recurrentKernel, bias := recurrentWeights[T](inputSize, hiddenSize, 3, Zeros{}, opts)
+	l := &GRUOf[T]{
+		Kernel:          kernel,
+		RecurrentKernel: recurrentKernel,
+		Bias:            bias,
+		kernelGrad:      NewMatrixOf[T](inputSize, gates),
+		recurrentGrad:   NewMatrixOf[T](hiddenSize, gates),
+		biasGrad:        NewMatrixOf[T](1, gates),
+	}
+	l.recurrent = newRecurrent[T](inputSize, hiddenSize, returnSequences, l)
+	return l
+}
+
+func (l *GRUOf[T]) numStates() int {
+	return 1
+}
+
+func (l *GRUOf[T]) stepForward(x *MatrixOf[T], states []*MatrixOf[T]) ([]*MatrixOf[T], interface{}) {
+	h := states[0]
+	hs := l.HiddenSize
+
+	// Input contribution of all gates and recurrent contribution of z and r
+	xz := NewMatrixOf[T](x.Rows, 3*hs)
+	gemm(xz, x, l.Kernel, false, false)
+	hzr := NewMatrixOf[T](x.Rows, 2*hs)
+	gemm(hzr, h, columns(l.RecurrentKernel, 0, 2*hs), false, false)
+
+	cache := &gruCache[T]{
+		x: x, h: h, rh: NewMatrixOf[T](x.Rows, hs),
+		z: NewMatrixOf[T](x.Rows, hs), r: NewMatrixOf[T](x.Rows, hs), n: NewMatrixOf[T](x.Rows, hs),
+	}
+	for b := 0; b < x.Rows; b++ {
+		for j := 0; j < hs; j++ {
+			cache.z.Data[b][j] = T(Sigmoid(float64(xz.Data[b][j] + hzr.Data[b][j] + l.Bias.Data[0][j])))
+			cache.r.Data[b][j] = T(Sigmoid(float64(xz.Data[b][hs+j] + hzr.Data[b][hs+j] + l.Bias.Data[0][hs+j])))
+			cache.rh.Data[b][j] = cache.r.Data[b][j] * h.Data[b][j]
+		}
+	}
+
+	hn := NewMatrixOf[T](x.Rows, hs)
+	gemm(hn, cache.rh, columns(l.RecurrentKernel, 2*hs, 3*hs), false, false)
+
+	next := NewMatrixOf[T](x.Rows, hs)
+	for b := 0; b < x.Rows; b++ {
+		for j := 0; j < hs; j++ {
+			n := T(math.Tanh(float64(xz.Data[b][2*hs+j] + hn.Data[b][j] + l.Bias.Data[0][2*hs+j])))
+			z := cache.z.Data[b][j]
+			cache.n.Data[b][j] = n
+			next.Data[b][j] = z*h.Data[b][j] + (1-z)*n
+		}
+	}
+	return []*MatrixOf[T]{next}, cache
+}
+
+func (l *GRUOf[T]) stepBackward(cache interface{}, gradStates []*MatrixOf[T]) (*MatrixOf[T], []*MatrixOf[T]) {
+	c := cache.(*gruCache[T])
+	hs := l.HiddenSize
+	gradNext := gradStates[0]
+
+	dz := NewMatrixOf[T](c.x.Rows, 3*hs)
+	gradH := NewMatrixOf[T](c.x.Rows, hs)
+	dn := NewMatrixOf[T](c.x.Rows, hs)
+	for b := 0; b < c.x.Rows; b++ {
+		for j := 0; j < hs; j++ {
+			g := gradNext.Data[b][j]
+			z, n := c.z.Data[b][j], c.n.Data[b][j]
+			dz.Data[b][j] = g * (c.h.Data[b][j] - n) * z * (1 - z)
+			dn.Data[b][j] = g * (1 - z) * (1 - n*n)
+			dz.Data[b][2*hs+j] = dn.Data[b][j]
+			gradH.Data[b][j] = g * z
+		}
+	}
+
+	// Candidate gate: gradients of Un and of r*h
+	unGrad := NewMatrixOf[T](hs, hs)
+	gemm(unGrad, c.rh, dn, true, false)
+	addColumns(l.recurrentGrad, 2*hs, unGrad)
+	gradRH := NewMatrixOf[T](c.x.Rows, hs)
+	gemm(gradRH, dn, columns(l.RecurrentKernel, 2*hs, 3*hs), false, true)
+
+	for b := 0; b < c.x.Rows; b++ {
+		for j := 0; j < hs; j++ {
+			r := c.r.Data[b][j]
+			dz.Data[b][hs+j] = gradRH.Data[b][j] * c.h.Data[b][j] * r * (1 - r)
+			gradH.Data[b][j] += gradRH.Data[b][j] * r
+		}
+	}
+
+	// Update and reset gates depend on h through Uz and Ur
+	dzr := columns(dz, 0, 2*hs)
+	uzrGrad := NewMatrixOf[T](hs, 2*hs)
+	gemm(uzrGrad, c.h, dzr, true, false)
+	addColumns(l.recurrentGrad, 0, uzrGrad)
+	gemm(gradH, dzr, columns(l.RecurrentKernel, 0, 2*hs), false, true)
+
+	// Input kernel and bias see all three gates
+	gemm(l.kernelGrad, c.x, dz, true, false)
+	for b := 0; b < dz.Rows; b++ {
+		for j := 0; j < dz.Cols; j++ {
+			l.biasGrad.Data[0][j] += dz.Data[b][j]
+		}
+	}
+	gradX := NewMatrixOf[T](c.x.Rows, c.x.Cols)
+	gemm(gradX, dz, l.Kernel, false, true)
+
+	return gradX, []*MatrixOf[T]{gradH}
+}
+
+func (l *GRUOf[T]) zeroGrads() {
+	l.kernelGrad.zero()
+	l.recurrentGrad.zero()
+	l.biasGrad.zero()
+}
+
+// GetParams returns the parameters of the layer
+func (l *GRUOf[T]) GetParams() []*MatrixOf[T] {
+	return []*MatrixOf[T]{l.Kernel, l.RecurrentKernel, l.Bias}
+}
+
+// GetGrads returns the gradients of the parameters
+func (l *GRUOf[T]) GetGrads() []*MatrixOf[T] {
+	return []*MatrixOf[T]{l.kernelGrad, l.recurrentGrad, l.biasGrad}
+}
+
+// GetParamNames returns names for the parameters
+func (l *GRUOf[T]) GetParamNames() []string {
+	return []string{"kernel", "recurrent_kernel", "bias"}
+}
+
+// BidirectionalOf runs one recurrent layer forward and another backward in
+// time over the same input and concatenates their outputs. With
+// ReturnSequences the outputs are concatenated per step, giving
+// (batch, time*(Hf+Hb)); otherwise the final states give (batch, Hf+Hb).
+type BidirectionalOf[T Float] struct {
+	ForwardLayer  RecurrentLayerOf[T]
+	BackwardLayer RecurrentLayerOf[T]
+
+	steps int
+}
+
+// Bidirectional wraps two float64 recurrent layers
+type Bidirectional = BidirectionalOf[float64]
+
+// NewBidirectional wraps two recurrent layers with the same input size and
+// ReturnSequences setting; the second one is switched to GoBackwards.
+// Initializers are chosen when creating the wrapped layers, e.g.
+// NewBidirectional(NewLSTM(8, 16, true, WithWeights(HeNormal{})), ...).
+func NewBidirectional(forward, backward RecurrentLayer) *Bidirectional {
+	return NewBidirectionalOf[float64](forward, backward)
+}
+
+// NewBidirectionalOf wraps two recurrent layers with element type T
+func NewBidirectionalOf[T Float](forward, backward RecurrentLayerOf[T]) *BidirectionalOf[T] {
+	forward.recurrentBase().GoBackwards = false
+	backward.recurrentBase().GoBackwards = true
+	return &BidirectionalOf[T]{ForwardLayer: forward, BackwardLayer: backward}
+}
+
+// SetMask sets the padding mask of both directions
+func (bi *BidirectionalOf[T]) SetMask(mask *MatrixOf[T]) {
+	bi.ForwardLayer.SetMask(mask)
+	bi.BackwardLayer.SetMask(mask)
+}
+
+// Forward runs both directions and concatenates the outputs
+func (bi *BidirectionalOf[T]) Forward(input *MatrixOf[T]) (*MatrixOf[T], error) {
+	fwd, bwd := bi.ForwardLayer.recurrentBase(), bi.BackwardLayer.recurrentBase()
+	if fwd.InputSize != bwd.InputSize || fwd.ReturnSequences != bwd.ReturnSequences {
+		return nil, fmt.Errorf("bidirectional layers must share input size and ReturnSequences")
+	}
+
+	forwardOut, err := bi.ForwardLayer.Forward(input)
+	if err != nil {
+		return nil, err
+	}
+	backwardOut, err := bi.BackwardLayer.Forward(input)
+	if err != nil {
+		return nil, err
+	}
+
+	bi.steps = 1
+	if fwd.ReturnSequences {
+		bi.steps = input.Cols / fwd.InputSize
+	}
+
+	hf, hb := fwd.HiddenSize, bwd.HiddenSize
+	output := NewMatrixOf[T](input.Rows, bi.steps*(hf+hb))
+	for i := 0; i < input.Rows; i++ {
+		for t := 0; t < bi.steps; t++ {
+			copy(output.Data[i][t*(hf+hb):], forwardOut.Data[i][t*hf:(t+1)*hf])
+			copy(output.Data[i][t*(hf+hb)+hf:], backwardOut.Data[i][t*hb:(t+1)*hb])
+		}
+	}
+	return output, nil
+}
+
+// Backward splits the gradient between both directions and sums their
+// input gradients
+func (bi *BidirectionalOf[T]) Backward(gradOutput *MatrixOf[T]) (*MatrixOf[T], error) {
+	hf := bi.ForwardLayer.recurrentBase().HiddenSize
+	hb := bi.BackwardLayer.recurrentBase().HiddenSize
+	if gradOutput.Cols != bi.steps*(hf+hb) {
+		return nil, fmt.Errorf("gradient size mismatch")
+	}
+
+	gradForward := NewMatrixOf[T](gradOutput.Rows, bi.steps*hf)
+	gradBackward := NewMatrixOf[T](gradOutput.Rows, bi.steps*hb)
+	for i := 0; i < gradOutput.Rows; i++ {
+		for t := 0; t < bi.steps; t++ {
+			copy(gradForward.Data[i][t*hf:(t+1)*hf], gradOutput.Data[i][t*(hf+hb):])
+			copy(gradBackward.Data[i][t*hb:(t+1)*hb], gradOutput.Data[i][t*(hf+hb)+hf:])
+		}
+	}
+
+	gradInput, err := bi.ForwardLayer.Backward(gradForward)
+	if err != nil {
+		return nil, err
+	}
+	gradInputBackward, err := bi.BackwardLayer.Backward(gradBackward)
+	if err != nil {
+		return nil, err
+	}
+	return gradInput.Add(gradInputBackward)
+}
+
+// GetParams returns the parameters of both directions
+func (bi *BidirectionalOf[T]) GetParams() []*MatrixOf[T] {
+	return append(bi.ForwardLayer.GetParams(), bi.BackwardLayer.GetParams()...)
+}
+
+// GetGrads returns the gradients of both directions
+func (bi *BidirectionalOf[T]) GetGrads() []*MatrixOf[T] {
+	return append(bi.ForwardLayer.GetGrads(), bi.BackwardLayer.GetGrads()...)
+}
+
+// GetParamNames returns the parameter names prefixed with the direction
+func (bi *BidirectionalOf[T]) GetParamNames() []string {
+	var names []string
+	for _, name := range bi.ForwardLayer.GetParamNames() {
+		names = append(names, "forward_"+name)
+	}
+	for _, name := range bi.BackwardLayer.GetParamNames() {
+		names = append(names, "backward_"+name)
+	}
+	return names
+}
@@ -0,0 +1,182 @@
+package nn
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestLSTMForgetBias(t *testing.T) {
+	const hidden = 3
+	forgetGate := func(l *LSTM) []float64 { return l.Bias.Data[0][hidden : 2*hidden] }
+	otherGates := func(l *LSTM) []float64 {
+		return append(append([]float64{}, l.Bias.Data[0][:hidden]...), l.Bias.Data[0][2*hidden:]...)
+	}
+
+	tests := []struct {
+		name   string
+		opts   []InitOption
+		forget float64
+	}{
+		{"defaults", nil, 1},
+		{"kernel only", []InitOption{WithWeights(GlorotNormal{})}, 1},
+		{"nil bias", []InitOption{WithBias(nil)}, 1}, // A nil bias keeps the default
+		{"explicit bias", []InitOption{WithBias(Zeros{})}, 0},
+	}
+	for _, tt := range tests {
+		l := NewLSTM(2, hidden, false, tt.opts...)
+		for _, b := range forgetGate(l) {
+			if b != tt.forget {
+				t.Errorf("%s: forget gate bias %v, expected %v", tt.name, b, tt.forget)
+			}
+		}
+		for _, b := range otherGates(l) {
+			if b != 0 {
+				t.Errorf("%s: gate bias %v, expected 0", tt.name, b)
+			}
+		}
+	}
+}
+
+// recurrentCases builds every recurrent layer
+var recurrentCases = map[string]func(inputSize, hidden int, sequences bool) RecurrentLayer{
+	"simple_rnn": func(in, h int, seq bool) RecurrentLayer { return NewSimpleRNN(in, h, seq) },
+	"lstm":       func(in, h int, seq bool) RecurrentLayer { return NewLSTM(in, h, seq) },
+	"gru":        func(in, h int, seq bool) RecurrentLayer { return NewGRU(in, h, seq) },
+}
+
+// paddingMask is a (3, 4) mask with a trailing pad, a fully valid row and
+// a gap in the middle
+func paddingMask() *Matrix {
+	return &Matrix{Rows: 3, Cols: 4, Data: [][]float64{{1, 1, 1, 0}, {1, 1, 1, 1}, {1, 0, 1, 1}}}
+}
+
+func TestRecurrentGradients(t *testing.T) {
+	const steps, inputSize, hidden = 4, 2, 3
+	for name, create := range recurrentCases {
+		for _, sequences := range []bool{false, true} {
+			for _, masked := range []bool{false, true} {
+				for _, backwards := range []bool{false, true} {
+					t.Run(fmt.Sprintf("%s/sequences=%v/masked=%v/backwards=%v", name, sequences, masked, backwards), func(t *testing.T) {
+						rng := rand.New(rand.NewSource(12))
+						layer := create(inputSize, hidden, sequences)
+						layer.recurrentBase().GoBackwards = backwards
+						randomizeParams(rng, layer)
+						if masked {
+							layer.SetMask(paddingMask())
+						}
+
+						result, err := GradCheck(layer, uniformMatrix(rng, 3, steps*inputSize, -1, 1), 0)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if worst := result.Max(); worst > layerTolerance {
+							t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestBidirectionalGradients(t *testing.T) {
+	for _, sequences := range []bool{false, true} {
+		for _, masked := range []bool{false, true} {
+			t.Run(fmt.Sprintf("sequences=%v/masked=%v", sequences, masked), func(t *testing.T) {
+				rng := rand.New(rand.NewSource(13))
+				layer := NewBidirectional(NewLSTM(2, 3, sequences), NewGRU(2, 2, sequences))
+				randomizeParams(rng, layer)
+				if masked {
+					layer.SetMask(paddingMask())
+				}
+
+				result, err := GradCheck(layer, uniformMatrix(rng, 3, 4*2, -1, 1), 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if worst := result.Max(); worst > layerTolerance {
+					t.Errorf("relative gradient error %.3e exceeds %.0e:\n%s", worst, layerTolerance, result)
+				}
+			})
+		}
+	}
+}
+
+func TestRecurrentMaskSkipsPadding(t *testing.T) {
+	for name, create := range recurrentCases {
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(14))
+			layer := create(2, 3, true)
+			randomizeParams(rng, layer)
+			input := uniformMatrix(rng, 1, 4*2, -1, 1)
+
+			// Masking the last step gives the outputs of the first three
+			// steps followed by zeros
+			layer.SetMask(&Matrix{Rows: 1, Cols: 4, Data: [][]float64{{1, 1, 1, 0}}})
+			masked, err := layer.Forward(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			layer.SetMask(nil)
+			truncated, err := layer.Forward(&Matrix{Rows: 1, Cols: 3 * 2, Data: [][]float64{input.Data[0][:3*2]}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]float64{}, truncated.Data[0]...), 0, 0, 0)
+			assertMatrix(t, "masked output", masked, [][]float64{want}, 1e-12)
+		})
+	}
+}
+
+func TestRecurrentInitialStateGradients(t *testing.T) {
+	for name, create := range recurrentCases {
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(15))
+			layer := create(2, 3, true)
+			randomizeParams(rng, layer)
+			input := uniformMatrix(rng, 2, 3*2, -1, 1)
+
+			states := []*Matrix{uniformMatrix(rng, 2, 3, -1, 1)}
+			if name == "lstm" {
+				states = append(states, uniformMatrix(rng, 2, 3, -1, 1))
+			}
+			layer.SetInitialState(states...)
+
+			projection := uniformMatrix(rng, 2, 3*3, -1, 1)
+			objective := func() (float64, error) {
+				out, err := layer.Forward(input)
+				if err != nil {
+					return 0, err
+				}
+				total := 0.0
+				for i := range out.Data {
+					for j, v := range out.Data[i] {
+						total += v * projection.Data[i][j]
+					}
+				}
+				return total, nil
+			}
+			if _, err := objective(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := layer.Backward(projection); err != nil {
+				t.Fatal(err)
+			}
+
+			grads := layer.InitialStateGrads()
+			if len(grads) != len(states) {
+				t.Fatalf("got %d initial state gradients, expected %d", len(grads), len(states))
+			}
+			for k, state := range states {
+				worst, err := checkMatrix(state, copyMatrix(grads[k]), objective, DefaultGradCheckEpsilon)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if worst > layerTolerance {
+					t.Errorf("state %d: relative gradient error %.3e exceeds %.0e", k, worst, layerTolerance)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package nn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// StatefulLayerOf is implemented by layers holding non-trainable state
+// that must be saved with the model, such as running statistics
+type StatefulLayerOf[T Float] interface {
+	GetState() []*MatrixOf[T]
+	GetStateNames() []string
+}
+
+// StatefulLayer is a StatefulLayerOf with float64 state
+type StatefulLayer = StatefulLayerOf[float64]
+
+// Precision is the floating point type of the values in a weights file
+type Precision string
+
+const (
+	// PrecisionFloat64 stores values as float64 (the default)
+	PrecisionFloat64 Precision = "float64"
+	// PrecisionFloat32 stores values as float32
+	PrecisionFloat32 Precision = "float32"
+)
+
+// precisionOf returns the precision matching element type T
+func precisionOf[T Float]() Precision {
+	var zero T
+	if _, ok := any(zero).(float32); ok {
+		return PrecisionFloat32
+	}
+	return PrecisionFloat64
+}
+
+// weightsFile is the JSON document written by SaveWeights. Files without
+// a precision hold float64 values.
+type weightsFile[T Float] struct {
+	Precision Precision               `json:"precision,omitempty"`
+	Params    map[string]*MatrixOf[T] `json:"params"`
+	State     map[string]*MatrixOf[T] `json:"state,omitempty"`
+
+	Preprocessing *Pipeline `json:"preprocessing,omitempty"`
+}
+
+// collectWeights gathers the parameters and state of layers keyed by the
+// names UpdateWeights passes to the optimizer
+func collectWeights[T Float](layers []LayerOf[T]) *weightsFile[T] {
+	file := &weightsFile[T]{
+		Precision: precisionOf[T](),
+		Params:    make(map[string]*MatrixOf[T]),
+		State:     make(map[string]*MatrixOf[T]),
+	}
+	for layerIdx, layer := range layers {
+		params := layer.GetParams()
+		for i, name := range layer.GetParamNames() {
+			file.Params[fmt.Sprintf("layer_%d_%s", layerIdx, name)] = params[i]
+		}
+		if stateful, ok := any(layer).(StatefulLayerOf[T]); ok {
+			state := stateful.GetState()
+			for i, name := range stateful.GetStateNames() {
+				file.State[fmt.Sprintf("layer_%d_%s", layerIdx, name)] = state[i]
+			}
+		}
+	}
+	return file
+}
+
+// SaveWeights writes all parameters and layer state as JSON in the model's
+// precision. The model architecture is not saved and must be rebuilt
+// before LoadWeights.
+func (s *SequentialOf[T]) SaveWeights(w io.Writer) error {
+	file := collectWeights(s.Layers)
+	file.Preprocessing = s.Preprocessing
+	return json.NewEncoder(w).Encode(file)
+}
+
+// LoadWeights reads weights written by SaveWeights into the model's layers.
+// Every tensor must be present with the same shape; values saved in
+// another precision are converted. Saved preprocessing replaces the
+// model's.
+func (s *SequentialOf[T]) LoadWeights(r io.Reader) error {
+	preprocessing, err := loadWeights(r, s.Layers)
+	if err != nil {
+		return err
+	}
+	if preprocessing != nil {
+		s.Preprocessing = preprocessing
+	}
+	return nil
+}
+
+// SaveWeights writes all parameters and layer state of the graph as JSON,
+// with layers numbered in topological order
+func (m *Model) SaveWeights(w io.Writer) error {
+	file := collectWeights(m.Layers)
+	file.Preprocessing = m.Preprocessing
+	return json.NewEncoder(w).Encode(file)
+}
+
+// LoadWeights reads weights written by SaveWeights into a model built from
+// the same graph
+func (m *Model) LoadWeights(r io.Reader) error {
+	preprocessing, err := loadWeights(r, m.Layers)
+	if err != nil {
+		return err
+	}
+	if preprocessing != nil {
+		m.Preprocessing = preprocessing
+	}
+	return nil
+}
+
+// ConvertWeights rewrites a weights file written by SaveWeights in the
+// given precision, so a model trained in one precision can be loaded
+// into a model of the other without rebuilding it
+func ConvertWeights(r io.Reader, w io.Writer, precision Precision) error {
+	file, err := decodeWeights(r)
+	if err != nil {
+		return err
+	}
+
+	switch precision {
+	case PrecisionFloat64:
+		return json.NewEncoder(w).Encode(file)
+	case PrecisionFloat32:
+		converted := &weightsFile[float32]{
+			Precision:     PrecisionFloat32,
+			Params:        make(map[string]*Matrix32, len(file.Params)),
+			State:         make(map[string]*Matrix32, len(file.State)),
+			Preprocessing: file.Preprocessing,
+		}
+		for name, m := range file.Params {
+			converted.Params[name] = ConvertMatrix[float32](m)
+		}
+		for name, m := range file.State {
+			converted.State[name] = ConvertMatrix[float32](m)
+		}
+		return json.NewEncoder(w).Encode(converted)
+	default:
+		return fmt.Errorf("unknown precision %q", precision)
+	}
+}
+
+// decodeWeights reads a weights file of either precision into float64
+// tensors, marked as float64
+func decodeWeights(r io.Reader) (*weightsFile[float64], error) {
+	var file weightsFile[float64]
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("decoding weights: %v", err)
+	}
+
+	switch file.Precision {
+	case "", PrecisionFloat64:
+	case PrecisionFloat32:
+		// JSON holds the shortest decimal of each float32; round it back so
+		// the widened value is exactly the one that was saved
+		for _, tensors := range []map[string]*Matrix{file.Params, file.State} {
+			for _, m := range tensors {
+				for _, row := range m.Data {
+					for j, v := range row {
+						row[j] = float64(float32(v))
+					}
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown precision %q", file.Precision)
+	}
+	file.Precision = PrecisionFloat64
+	return &file, nil
+}
+
+// loadWeights decodes a weights file into layers and returns its
+// preprocessing, if any
+func loadWeights[T Float](r io.Reader, layers []LayerOf[T]) (*Pipeline, error) {
+	file, err := decodeWeights(r)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := collectWeights(layers)
+	if err := copyWeights(expected.Params, file.Params); err != nil {
+		return nil, err
+	}
+	if err := copyWeights(expected.State, file.State); err != nil {
+		return nil, err
+	}
+	return file.Preprocessing, nil
+}
+
+// copyWeights copies every tensor of src into the tensor of dst with the
+// same name, converting values to T
+func copyWeights[T Float](dst map[string]*MatrixOf[T], src map[string]*Matrix) error {
+	if len(src) != len(dst) {
+		return fmt.Errorf("weights file has %d tensors, model expects %d", len(src), len(dst))
+	}
+	for name, target := range dst {
+		loaded, ok := src[name]
+		if !ok {
+			return fmt.Errorf("missing tensor %q", name)
+		}
+		if loaded.Rows != target.Rows || loaded.Cols != target.Cols || len(loaded.Data) != loaded.Rows {
+			return fmt.Errorf("tensor %q shape mismatch: got %dx%d, expected %dx%d",
+				name, loaded.Rows, loaded.Cols, target.Rows, target.Cols)
+		}
+		for i := 0; i < target.Rows; i++ {
+			if len(loaded.Data[i]) != target.Cols {
+				return fmt.Errorf("tensor %q has a malformed row %d", name, i)
+			}
+			for j, v := range loaded.Data[i] {
+				target.Data[i][j] = T(v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// classIndices returns the class of every row of y: the arg max of one-hot
+// rows, or the rounded value of a single label column
+func classIndices(y *Matrix) []int {
+	classes := make([]int, y.Rows)
+	for i, row := range y.Data {
+		if y.Cols == 1 {
+			classes[i] = int(math.Round(row[0]))
+		} else {
+			classes[i] = argmax(row)
+		}
+	}
+	return classes
+}
+
+// SelectRows returns a matrix holding copies of the rows of m at indices
+func SelectRows(m *Matrix, indices []int) *Matrix {
+	result := NewMatrix(len(indices), m.Cols)
+	for k, i := range indices {
+		copy(result.Data[k], m.Data[i])
+	}
+	return result
+}
+
+// permutation returns a random permutation of [0, n), or the identity when
+// shuffle is false
+func permutation(n int, shuffle bool, rng *rand.Rand) []int {
+	switch {
+	case !shuffle:
+		indices := make([]int, n)
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	case rng != nil:
+		return rng.Perm(n)
+	default:
+		return rand.Perm(n)
+	}
+}
+
+// SplitConfig configures TrainTestSplit
+type SplitConfig struct {
+	TestSize float64 // Fraction of the samples in the test set, in (0, 1)
+	Stratify bool    // Keep the class proportions of y in both sets
+	Seed     int64   // Seed of the shuffling; 0 uses the global math/rand source
+}
+
+// Split holds the two parts of a dataset
+type Split struct {
+	XTrain, YTrain *Matrix
+	XTest, YTest   *Matrix
+}
+
+// TrainTestSplit shuffles the rows of X and y and splits them into a
+// training and a test set. With Stratify, y holds one-hot rows or a single
+// integer label column and every class is split in the same proportion.
+// Split the training set again to get a validation set.
+func TrainTestSplit(X, y *Matrix, cfg SplitConfig) (*Split, error) {
+	if X.Rows != y.Rows {
+		return nil, fmt.Errorf("X has %d rows but y has %d", X.Rows, y.Rows)
+	}
+	if cfg.TestSize <= 0 || cfg.TestSize >= 1 {
+		return nil, fmt.Errorf("test size must be in (0, 1), got %g", cfg.TestSize)
+	}
+
+	order := permutation(X.Rows, true, newRand(cfg.Seed))
+	inTest := make([]bool, X.Rows)
+	if cfg.Stratify {
+		classes := classIndices(y)
+		byClass := make(map[int][]int)
+		for _, i := range order {
+			byClass[classes[i]] = append(byClass[classes[i]], i)
+		}
+		for _, members := range byClass {
+			for _, i := range members[:int(math.Round(cfg.TestSize*float64(len(members))))] {
+				inTest[i] = true
+			}
+		}
+	} else {
+		for _, i := range order[:int(math.Round(cfg.TestSize*float64(X.Rows)))] {
+			inTest[i] = true
+		}
+	}
+
+	var train, test []int
+	for _, i := range order {
+		if inTest[i] {
+			test = append(test, i)
+		} else {
+			train = append(train, i)
+		}
+	}
+	if len(train) == 0 || len(test) == 0 {
+		return nil, fmt.Errorf("split of %d samples leaves %d for training and %d for testing", X.Rows, len(train), len(test))
+	}
+
+	return &Split{
+		XTrain: SelectRows(X, train), YTrain: SelectRows(y, train),
+		XTest: SelectRows(X, test), YTest: SelectRows(y, test),
+	}, nil
+}
+
+// Fold is one round of cross-validation: the row indices to train on and
+// the held-out rows to evaluate on
+type Fold struct {
+	Train []int
+	Test  []int
+}
+
+// folds builds k folds from the test fold of every sample
+func folds(k int, foldOf []int) []Fold {
+	result := make([]Fold, k)
+	for i, f := range foldOf {
+		for j := range result {
+			if j == f {
+				result[j].Test = append(result[j].Test, i)
+			} else {
+				result[j].Train = append(result[j].Train, i)
+			}
+		}
+	}
+	return result
+}
+
+// KFold splits n samples into k folds of nearly equal size, optionally in
+// a shuffled order. Every sample is held out exactly once.
+func KFold(n, k int, shuffle bool, seed int64) ([]Fold, error) {
+	if k < 2 || k > n {
+		return nil, fmt.Errorf("k must be in [2, %d], got %d", n, k)
+	}
+
+	foldOf := make([]int, n)
+	for pos, i := range permutation(n, shuffle, newRand(seed)) {
+		foldOf[i] = pos * k / n
+	}
+	return folds(k, foldOf), nil
+}
+
+// StratifiedKFold is KFold keeping the class proportions of y, one-hot
+// rows or a single integer label column, in every fold
+func StratifiedKFold(y *Matrix, k int, shuffle bool, seed int64) ([]Fold, error) {
+	if k < 2 || k > y.Rows {
+		return nil, fmt.Errorf("k must be in [2, %d], got %d", y.Rows, k)
+	}
+
+	classes := classIndices(y)
+	order := permutation(y.Rows, shuffle, newRand(seed))
+	sort.SliceStable(order, func(a, b int) bool { return classes[order[a]] < classes[order[b]] })
+
+	// Deal the samples, grouped by class, to the folds in turn so both the
+	// classes and the fold sizes stay balanced
+	foldOf := make([]int, y.Rows)
+	for pos, i := range order {
+		foldOf[i] = pos % k
+	}
+	return folds(k, foldOf), nil
+}
+
+// CrossValidateConfig configures CrossValidate
+type CrossValidateConfig struct {
+	Loss      Loss
+	Optimizer func() Optimizer // Creates the optimizer of every fold
+	Metrics   func() []Metric  // Creates the metrics of every fold; may be nil
+	Epochs    int
+	BatchSize int
+	Workers   int // Folds trained at the same time; 0 or 1 trains them in turn
+}
+
+// CrossValidation holds the scores of every fold and their aggregates,
+// keyed "loss" and by metric name
+type CrossValidation struct {
+	Folds []map[string]float64
+	Mean  map[string]float64
+	Std   map[string]float64
+
+	keys []string // Scores in display order
+}
+
+// String formats the aggregates as "loss: mean ± std - accuracy: ..."
+func (cv *CrossValidation) String() string {
+	parts := make([]string, len(cv.keys))
+	for i, key := range cv.keys {
+		parts[i] = fmt.Sprintf("%s: %.4f ± %.4f", key, cv.Mean[key], cv.Std[key])
+	}
+	return strings.Join(parts, " - ")
+}
+
+// CrossValidate builds a model with factory for every fold, compiles it
+// with cfg, trains it on the training rows and evaluates the loss and the
+// metrics on the held-out rows. Models are built in fold order before any
+// training starts; with Workers > 1 folds are trained concurrently.
+func CrossValidate(factory func() *Sequential, X, y *Matrix, folds []Fold, cfg CrossValidateConfig) (*CrossValidation, error) {
+	if len(folds) == 0 {
+		return nil, fmt.Errorf("no folds")
+	}
+	if cfg.Loss == nil || cfg.Optimizer == nil {
+		return nil, fmt.Errorf("cross-validation needs a loss and an optimizer")
+	}
+
+	models := make([]*Sequential, len(folds))
+	for f := range folds {
+		var metrics []Metric
+		if cfg.Metrics != nil {
+			metrics = cfg.Metrics()
+		}
+		models[f] = factory()
+		models[f].Compile(cfg.Loss, cfg.Optimizer(), metrics...)
+	}
+
+	scores := make([]map[string]float64, len(folds))
+	errs := make([]error, len(folds))
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	parallelFor(len(folds), min(workers, len(folds)), func(_, f int) {
+		scores[f], errs[f] = evaluateFold(models[f], X, y, folds[f], cfg)
+	})
+	for f, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("fold %d: %v", f, err)
+		}
+	}
+
+	cv := &CrossValidation{Folds: scores, Mean: make(map[string]float64), Std: make(map[string]float64)}
+	cv.keys = append(cv.keys, "loss")
+	for _, metric := range models[0].Metrics {
+		cv.keys = append(cv.keys, metric.Name())
+	}
+	for _, key := range cv.keys {
+		for _, score := range scores {
+			cv.Mean[key] += score[key] / float64(len(scores))
+		}
+		for _, score := range scores {
+			d := score[key] - cv.Mean[key]
+			cv.Std[key] += d * d / float64(len(scores))
+		}
+		cv.Std[key] = math.Sqrt(cv.Std[key])
+	}
+	return cv, nil
+}
+
+// evaluateFold trains a compiled model on one fold and scores it
+func evaluateFold(model *Sequential, X, y *Matrix, fold Fold, cfg CrossValidateConfig) (map[string]float64, error) {
+	if err := model.Fit(SelectRows(X, fold.Train), SelectRows(y, fold.Train), cfg.Epochs, cfg.BatchSize, false); err != nil {
+		return nil, err
+	}
+	report, err := model.EvaluateReport(SelectRows(X, fold.Test), SelectRows(y, fold.Test))
+	if err != nil {
+		return nil, err
+	}
+
+	score := map[string]float64{"loss": report.Loss}
+	for name, value := range report.Metrics {
+		score[name] = value
+	}
+	return score, nil
+}
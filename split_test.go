@@ -0,0 +1,250 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// labelledRows returns X holding the row index and y holding the class of
+// every row: the first counts[0] rows are class 0, and so on
+func labelledRows(counts ...int) (*Matrix, *Matrix) {
+	var xs, ys [][]float64
+	for class, n := range counts {
+		for k := 0; k < n; k++ {
+			xs = append(xs, []float64{float64(len(xs))})
+			ys = append(ys, []float64{float64(class)})
+		}
+	}
+	return &Matrix{Rows: len(xs), Cols: 1, Data: xs}, &Matrix{Rows: len(ys), Cols: 1, Data: ys}
+}
+
+// countClasses counts the rows of every class in a label column
+func countClasses(y *Matrix) map[int]int {
+	counts := make(map[int]int)
+	for _, c := range classIndices(y) {
+		counts[c]++
+	}
+	return counts
+}
+
+func TestTrainTestSplit(t *testing.T) {
+	X, y := labelledRows(20, 10)
+	split, err := TrainTestSplit(X, y, SplitConfig{TestSize: 0.3, Seed: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if split.XTrain.Rows != 21 || split.XTest.Rows != 9 {
+		t.Errorf("split into %d and %d rows, expected 21 and 9", split.XTrain.Rows, split.XTest.Rows)
+	}
+
+	// Every row lands in exactly one set, with its own label
+	seen := make(map[float64]bool)
+	for _, part := range [][2]*Matrix{{split.XTrain, split.YTrain}, {split.XTest, split.YTest}} {
+		for i, row := range part[0].Data {
+			index := row[0]
+			if seen[index] {
+				t.Fatalf("row %v is in both sets", index)
+			}
+			seen[index] = true
+			if part[1].Data[i][0] != y.Data[int(index)][0] {
+				t.Errorf("row %v has label %v, expected %v", index, part[1].Data[i][0], y.Data[int(index)][0])
+			}
+		}
+	}
+	if len(seen) != 30 {
+		t.Errorf("%d distinct rows, expected 30", len(seen))
+	}
+
+	again, err := TrainTestSplit(X, y, SplitConfig{TestSize: 0.3, Seed: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matricesEqual(split.XTest, again.XTest) {
+		t.Error("the same seed gave different splits")
+	}
+
+	for _, size := range []float64{0, 1, 0.01} {
+		if _, err := TrainTestSplit(X, y, SplitConfig{TestSize: size}); err == nil {
+			t.Errorf("expected an error for test size %v", size)
+		}
+	}
+	if _, err := TrainTestSplit(X, NewMatrix(3, 1), SplitConfig{TestSize: 0.5}); err == nil {
+		t.Error("expected an error for mismatched rows")
+	}
+}
+
+func TestStratifiedTrainTestSplit(t *testing.T) {
+	X, y := labelledRows(20, 10, 5)
+	for seed := int64(1); seed <= 5; seed++ {
+		split, err := TrainTestSplit(X, y, SplitConfig{TestSize: 0.4, Stratify: true, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(countClasses(split.YTest)); got != "map[0:8 1:4 2:2]" {
+			t.Errorf("seed %d: test classes %s, expected map[0:8 1:4 2:2]", seed, got)
+		}
+		if got := fmt.Sprint(countClasses(split.YTrain)); got != "map[0:12 1:6 2:3]" {
+			t.Errorf("seed %d: training classes %s, expected map[0:12 1:6 2:3]", seed, got)
+		}
+	}
+
+	// One-hot rows are stratified by their arg max
+	oneHot := NewMatrix(y.Rows, 3)
+	for i, c := range classIndices(y) {
+		oneHot.Data[i][c] = 1
+	}
+	split, err := TrainTestSplit(X, oneHot, SplitConfig{TestSize: 0.4, Stratify: true, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(countClasses(split.YTest)); got != "map[0:8 1:4 2:2]" {
+		t.Errorf("one-hot test classes %s", got)
+	}
+}
+
+// checkFolds verifies that every one of n samples is held out exactly once
+// and that every fold trains on the other samples
+func checkFolds(t *testing.T, folds []Fold, n int) {
+	t.Helper()
+	heldOut := make([]int, n)
+	for f, fold := range folds {
+		if len(fold.Train)+len(fold.Test) != n {
+			t.Errorf("fold %d has %d training and %d test rows for %d samples", f, len(fold.Train), len(fold.Test), n)
+		}
+		inTest := make(map[int]bool)
+		for _, i := range fold.Test {
+			heldOut[i]++
+			inTest[i] = true
+		}
+		for _, i := range fold.Train {
+			if inTest[i] {
+				t.Errorf("fold %d trains on held-out row %d", f, i)
+			}
+		}
+	}
+	for i, count := range heldOut {
+		if count != 1 {
+			t.Errorf("row %d is held out %d times", i, count)
+		}
+	}
+}
+
+func TestKFold(t *testing.T) {
+	folds, err := KFold(10, 3, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFolds(t, folds, 10)
+	// Without shuffling the folds are contiguous
+	if got := fmt.Sprint(folds[0].Test, folds[1].Test, folds[2].Test); got != "[0 1 2 3] [4 5 6] [7 8 9]" {
+		t.Errorf("test folds %s", got)
+	}
+
+	shuffled, err := KFold(10, 3, true, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFolds(t, shuffled, 10)
+	again, err := KFold(10, 3, true, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(shuffled) != fmt.Sprint(again) {
+		t.Error("the same seed gave different folds")
+	}
+	sizes := []int{len(shuffled[0].Test), len(shuffled[1].Test), len(shuffled[2].Test)}
+	sort.Ints(sizes)
+	if fmt.Sprint(sizes) != "[3 3 4]" {
+		t.Errorf("fold sizes %v, expected 3, 3 and 4", sizes)
+	}
+
+	for _, k := range []int{1, 11} {
+		if _, err := KFold(10, k, false, 0); err == nil {
+			t.Errorf("expected an error for k = %d", k)
+		}
+	}
+}
+
+func TestStratifiedKFold(t *testing.T) {
+	_, y := labelledRows(9, 6, 3)
+	for _, shuffle := range []bool{false, true} {
+		folds, err := StratifiedKFold(y, 3, shuffle, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFolds(t, folds, 18)
+		for f, fold := range folds {
+			if got := fmt.Sprint(countClasses(SelectRows(y, fold.Test))); got != "map[0:3 1:2 2:1]" {
+				t.Errorf("shuffle %v: fold %d holds out classes %s, expected map[0:3 1:2 2:1]", shuffle, f, got)
+			}
+		}
+	}
+
+	// Uneven classes keep fold sizes and per-class counts within one
+	_, y = labelledRows(7, 4)
+	folds, err := StratifiedKFold(y, 3, true, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFolds(t, folds, 11)
+	for f, fold := range folds {
+		counts := countClasses(SelectRows(y, fold.Test))
+		if counts[0] < 2 || counts[0] > 3 || counts[1] < 1 || counts[1] > 2 || len(fold.Test) < 3 || len(fold.Test) > 4 {
+			t.Errorf("fold %d holds out %v", f, counts)
+		}
+	}
+
+	if _, err := StratifiedKFold(y, 12, false, 0); err == nil {
+		t.Error("expected an error for 12 folds of 11 samples")
+	}
+}
+
+func TestCrossValidate(t *testing.T) {
+	X, y := labelledRows(6, 6)
+	folds, err := StratifiedKFold(y, 3, true, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := func() *Sequential {
+		model := NewSequential()
+		model.Add(NewDense(1, 1))
+		return model
+	}
+	cfg := CrossValidateConfig{
+		Loss:      NewMSE(),
+		Optimizer: func() Optimizer { return NewSGD(0.001, 0) },
+		Metrics:   func() []Metric { return []Metric{NewMeanAbsoluteError()} },
+		Epochs:    2,
+		BatchSize: 4,
+		Workers:   3,
+	}
+	cv, err := CrossValidate(factory, X, y, folds, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cv.Folds) != 3 {
+		t.Fatalf("%d fold scores, expected 3", len(cv.Folds))
+	}
+	for _, key := range []string{"loss", NewMeanAbsoluteError().Name()} {
+		mean, sq := 0.0, 0.0
+		for _, score := range cv.Folds {
+			mean += score[key] / 3
+		}
+		for _, score := range cv.Folds {
+			sq += (score[key] - mean) * (score[key] - mean) / 3
+		}
+		if math.Abs(cv.Mean[key]-mean) > 1e-12 || math.Abs(cv.Std[key]-math.Sqrt(sq)) > 1e-12 {
+			t.Errorf("%s: mean %v and std %v, expected %v and %v", key, cv.Mean[key], cv.Std[key], mean, math.Sqrt(sq))
+		}
+	}
+	if s := cv.String(); !strings.HasPrefix(s, "loss: ") || !strings.Contains(s, " ± ") {
+		t.Errorf("unexpected summary %q", s)
+	}
+
+	if _, err := CrossValidate(factory, X, y, nil, cfg); err == nil {
+		t.Error("expected an error without folds")
+	}
+}